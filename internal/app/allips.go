@@ -1,63 +1,173 @@
 package app
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/idesyatov/ssl-watch/internal/cert"
 	"github.com/idesyatov/ssl-watch/internal/flags"
 )
 
-// runAllIPs resolves every address of the domain (optionally filtered to one
-// family by -4/-6), checks the certificate on each (same SNI), prints the
-// per-address result and reports the exit code: 1 if nothing was reachable or an
-// address failed for a real reason (addresses unreachable from this host are
-// skipped, not errors), otherwise 2 if the certificates differ or any expires
-// within -threshold, otherwise 0.
-func runAllIPs(fetcher cert.CertificateFetcher, t target, cfg flags.Config, opts cert.PrintOptions, fetchOpts cert.FetchOptions) int {
-	domain := t.host
-	ips, err := lookupIP(domain)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: failed to resolve %s: %v\n", domain, err)
-		return exitError
+// domainIPs is one target's outcome in an -all-ips run: the per-address results,
+// in address order, or err when no address could be resolved. warnings holds the
+// failures of the -resolver entries that did not answer while others did.
+type domainIPs struct {
+	target   target
+	results  []cert.IPResult
+	warnings []error
+	err      error
+}
+
+// checkAllIPs resolves every target's addresses (optionally filtered to one
+// family by -4/-6) and checks the certificate on each (same SNI). Resolutions
+// and then the (domain, address) fetches each run through the -concurrency pool;
+// results come back in target order.
+func checkAllIPs(fetcher cert.CertificateFetcher, targets []target, cfg flags.Config, fetchOpts cert.FetchOptions) []domainIPs {
+	out := make([]domainIPs, len(targets))
+	forEach(len(targets), cfg.Concurrency, func(i int) {
+		out[i].target = targets[i]
+		out[i].results, out[i].warnings, out[i].err = resolveAddrs(targets[i].host, cfg)
+	})
+
+	type pair struct{ domain, addr int }
+	var pairs []pair
+	for i := range out {
+		for j := range out[i].results {
+			pairs = append(pairs, pair{i, j})
+		}
 	}
+	forEach(len(pairs), cfg.Concurrency, func(k int) {
+		t, r := out[pairs[k].domain].target, &out[pairs[k].domain].results[pairs[k].addr]
+		r.Info, r.Err = fetcher.Fetch(t.host, t.port, r.IP, t.fetchOptions(fetchOpts))
+		r.Skipped = r.Err != nil && isUnreachable(r.Err)
+	})
+	return out
+}
 
-	seen := make(map[string]bool)
-	var addrs []string
-	for _, ip := range ips {
+// resolveAddrs resolves a host to its distinct addresses, sorted, keeping only
+// the family -4/-6 asks for, as results still to be fetched. With -resolver
+// every resolver is asked and each address records which of them returned it;
+// the failure of some resolvers comes back as warnings, of all as an error.
+func resolveAddrs(host string, cfg flags.Config) ([]cert.IPResult, []error, error) {
+	resolvers := splitList(cfg.Resolver)
+	via := make(map[string][]string)
+	var warnings []error
+	if len(resolvers) == 0 {
+		ips, err := lookupIP(host)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to resolve %s: %v", host, err)
+		}
+		for _, ip := range ips {
+			via[ip.String()] = nil
+		}
+	}
+	for _, r := range resolvers {
+		ips, err := lookupVia(host, r, time.Duration(cfg.Timeout)*time.Second)
+		if err != nil {
+			warnings = append(warnings, fmt.Errorf("resolver %s: failed to resolve %s: %v", r, host, err))
+			continue
+		}
+		for _, ip := range ips {
+			via[ip.String()] = appendUnique(via[ip.String()], r)
+		}
+	}
+	if len(resolvers) > 0 && len(warnings) == len(resolvers) {
+		return nil, nil, fmt.Errorf("failed to resolve %s through any -resolver: %v", host, warnings[0])
+	}
+
+	var results []cert.IPResult
+	for s, rs := range via {
+		ip := net.ParseIP(s)
 		if cfg.IPv4Only && ip.To4() == nil {
 			continue
 		}
 		if cfg.IPv6Only && ip.To4() != nil {
 			continue
 		}
-		s := ip.String()
-		if !seen[s] {
-			seen[s] = true
-			addrs = append(addrs, s)
+		results = append(results, cert.IPResult{IP: s, Resolvers: rs})
+	}
+	if len(results) == 0 {
+		return nil, warnings, fmt.Errorf("no matching addresses resolved for %s", host)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].IP < results[j].IP })
+	return results, warnings, nil
+}
+
+// appendUnique appends s to list unless it is already there.
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
 		}
 	}
-	if len(addrs) == 0 {
-		fmt.Fprintf(os.Stderr, "Error: no matching addresses resolved for %s\n", domain)
-		return exitError
+	return append(list, s)
+}
+
+// runAllIPs checks the certificate on every address of every target, prints the
+// per-address results domain by domain (a JSON array of the per-domain objects
+// when there are several) and reports the aggregated exit code. Per domain: 1 if
+// nothing was reachable, it could not be resolved, or an address failed for a
+// real reason (addresses unreachable from this host are skipped, not errors),
+// otherwise 3 on a pin mismatch, otherwise 5 if any expires within
+// -critical-threshold, otherwise 2 if the certificates differ or any expires
+// within -threshold, otherwise 0. The run exits with the most severe.
+func runAllIPs(fetcher cert.CertificateFetcher, targets []target, cfg flags.Config, opts cert.PrintOptions, fetchOpts cert.FetchOptions) int {
+	batchJSON := opts.JSON && len(targets) > 1
+	code := exitOK
+	printedText := false
+	var entries []any
+	for _, d := range checkAllIPs(fetcher, targets, cfg, fetchOpts) {
+		label := d.target.label()
+		printResolverWarnings(d)
+		if d.err != nil {
+			code = worseExit(code, exitError)
+			if batchJSON {
+				entries = append(entries, cert.ErrorPayload(label, d.err.Error()))
+			} else {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", d.err)
+			}
+			continue
+		}
+		var res cert.AllIPsResult
+		switch {
+		case batchJSON:
+			entries = append(entries, cert.AllIPsPayload(label, d.results, opts))
+			res = cert.SummarizeIPs(d.results, opts.Pins)
+		default:
+			if printedText {
+				fmt.Println()
+			}
+			res = cert.PrintAllIPs(label, d.results, opts)
+			printedText = true
+		}
+		code = worseExit(code, allIPsExit(res, d.results, opts.Threshold, opts.CriticalThreshold))
 	}
-	sort.Strings(addrs)
-
-	results := make([]cert.IPResult, 0, len(addrs))
-	for _, ip := range addrs {
-		info, err := fetcher.Fetch(domain, t.port, ip, fetchOpts)
-		results = append(results, cert.IPResult{
-			IP:      ip,
-			Info:    info,
-			Err:     err,
-			Skipped: err != nil && isUnreachable(err),
-		})
+
+	if batchJSON {
+		b, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to encode JSON: %v\n", err)
+			return exitError
+		}
+		fmt.Println(string(b))
 	}
+	return code
+}
 
-	res := cert.PrintAllIPs(t.label(), results, opts)
+// allIPsExit is the exit code for one domain's per-address verdict, with the
+// expiry of every reachable address judged against the thresholds.
+func allIPsExit(res cert.AllIPsResult, results []cert.IPResult, threshold, critical cert.Threshold) int {
+	expiry := exitOK
+	for _, r := range results {
+		if r.Info != nil {
+			expiry = worseExit(expiry, expiryExit(r.Info, threshold, critical))
+		}
+	}
 	switch {
 	case res.Reachable == 0:
 		return exitError
@@ -66,17 +176,74 @@ func runAllIPs(fetcher cert.CertificateFetcher, t target, cfg flags.Config, opts
 	case res.PinMismatch:
 		return exitMismatch
 	case !res.AllMatch:
-		return exitSoft
-	case cfg.Threshold > 0 && res.MinDays < cfg.Threshold:
-		return exitSoft
+		return worseExit(exitSoft, expiry)
+	}
+	return expiry
+}
+
+// collectIPSamples is collectSamples for -all-ips: one sample per address that
+// was checked (addresses unreachable from this host are left out), tagged with
+// its ip, and an error sample for a domain that could not be resolved or had no
+// reachable address. The exit code is aggregated with allIPsExit, so a domain
+// whose addresses serve different certificates counts as a soft failure.
+func collectIPSamples(fetcher cert.CertificateFetcher, targets []target, cfg flags.Config, opts cert.PrintOptions, fetchOpts cert.FetchOptions) ([]cert.PromSample, int) {
+	var samples []cert.PromSample
+	code := exitOK
+	for _, d := range checkAllIPs(fetcher, targets, cfg, fetchOpts) {
+		label := d.target.label()
+		printResolverWarnings(d)
+		if d.err != nil {
+			code = worseExit(code, exitError)
+			samples = append(samples, cert.PromSample{Domain: label, Err: d.err})
+			continue
+		}
+		res := cert.SummarizeIPs(d.results, opts.Pins)
+		if res.Reachable == 0 && !res.HadError {
+			code = worseExit(code, exitError)
+			samples = append(samples, cert.PromSample{Domain: label, Err: fmt.Errorf("no address of %s is reachable from this host", d.target.host)})
+			continue
+		}
+		for _, r := range d.results {
+			switch {
+			case r.Skipped:
+			case r.Err != nil:
+				samples = append(samples, cert.PromSample{Domain: label, IP: r.IP, Err: r.Err})
+			default:
+				samples = append(samples, cert.PromSample{Domain: label, IP: r.IP, Info: r.Info})
+			}
+		}
+		code = worseExit(code, allIPsExit(res, d.results, opts.Threshold, opts.CriticalThreshold))
 	}
-	return exitOK
+	return samples, code
+}
+
+// printResolverWarnings reports on stderr the -resolver entries that failed for
+// a domain the others resolved.
+func printResolverWarnings(d domainIPs) {
+	for _, w := range d.warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", w)
+	}
+}
+
+// worseExit returns the more severe of two exit codes, for aggregating several
+// results into one: an error outranks a revocation, then a mismatch, then a
+// critical expiry, then a soft failure.
+func worseExit(a, b int) int {
+	severity := map[int]int{exitOK: 0, exitSoft: 1, exitCritical: 2, exitMismatch: 3, exitRevoked: 4, exitError: 5}
+	if severity[b] > severity[a] {
+		return b
+	}
+	return a
 }
 
 // lookupIP resolves a host to its IP addresses. It is a package variable so tests
 // can substitute a deterministic resolver in place of real DNS.
 var lookupIP = net.LookupIP
 
+// lookupVia resolves a host through one -resolver; a package variable like
+// lookupIP.
+var lookupVia = cert.LookupAddrs
+
 // isUnreachable reports whether a connection error means the address family is
 // not routable from this host (e.g. no IPv6 route) — a benign skip rather than a
 // real failure. Matched by message text to stay portable (the syscall error
@@ -5,6 +5,7 @@ import (
 	"net"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/idesyatov/ssl-watch/internal/cert"
 	"github.com/idesyatov/ssl-watch/internal/flags"
@@ -25,7 +26,7 @@ func TestRunAllIPs(t *testing.T) {
 	}
 	var code int
 	out := captureStdout(t, func() {
-		code = runAllIPs(fetcher, tgt, flags.Config{Concurrency: 1}, cert.PrintOptions{}, cert.FetchOptions{})
+		code = runAllIPs(fetcher, []target{tgt}, flags.Config{Concurrency: 1}, cert.PrintOptions{}, cert.FetchOptions{})
 	})
 	if code != exitOK {
 		t.Errorf("identical certs on all addresses should yield %d, got %d", exitOK, code)
@@ -36,17 +37,142 @@ func TestRunAllIPs(t *testing.T) {
 
 	// Resolution failure → error exit.
 	lookupIP = func(string) ([]net.IP, error) { return nil, errors.New("no such host") }
-	if code := runAllIPs(fetcher, tgt, flags.Config{Concurrency: 1}, cert.PrintOptions{}, cert.FetchOptions{}); code != exitError {
+	if code := runAllIPs(fetcher, []target{tgt}, flags.Config{Concurrency: 1}, cert.PrintOptions{}, cert.FetchOptions{}); code != exitError {
 		t.Errorf("resolution failure should yield %d, got %d", exitError, code)
 	}
 
 	// No address of the requested family → error exit.
 	lookupIP = func(string) ([]net.IP, error) { return []net.IP{net.ParseIP("203.0.113.10")}, nil }
-	if code := runAllIPs(fetcher, tgt, flags.Config{Concurrency: 1, IPv6Only: true}, cert.PrintOptions{}, cert.FetchOptions{}); code != exitError {
+	if code := runAllIPs(fetcher, []target{tgt}, flags.Config{Concurrency: 1, IPv6Only: true}, cert.PrintOptions{}, cert.FetchOptions{}); code != exitError {
 		t.Errorf("no matching family should yield %d, got %d", exitError, code)
 	}
 }
 
+// ipFetcher returns canned certificate info per address, to stand in for load
+// balancer nodes serving different certificates.
+type ipFetcher map[string]*cert.CertInfo
+
+func (f ipFetcher) Fetch(domain, port, ipaddr string, opts cert.FetchOptions) (*cert.CertInfo, error) {
+	if info, ok := f[ipaddr]; ok {
+		return info, nil
+	}
+	return nil, errors.New("connection refused")
+}
+
+// TestRunAllIPs_Domains covers -all-ips over several domains: every address is
+// checked, one stale node sets the exit code, an unresolvable domain is an error,
+// and the report formats carry the address.
+func TestRunAllIPs_Domains(t *testing.T) {
+	orig := lookupIP
+	defer func() { lookupIP = orig }()
+	lookupIP = func(host string) ([]net.IP, error) {
+		switch host {
+		case "www.example":
+			return []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2")}, nil
+		case "api.example":
+			return []net.IP{net.ParseIP("192.0.2.3")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	current := leafInfo("www.example", 90)
+	current.Cert.Raw = []byte("current")
+	stale := leafInfo("www.example", 10)
+	stale.Cert.Raw = []byte("stale")
+	fetcher := ipFetcher{"192.0.2.1": current, "192.0.2.2": stale, "192.0.2.3": current}
+	targets := []target{{host: "www.example", port: "443"}, {host: "api.example", port: "443"}}
+	cfg := flags.Config{Concurrency: 4, AllIPs: true}
+
+	var code int
+	out := captureStdout(t, func() { code = runAllIPs(fetcher, targets, cfg, cert.PrintOptions{}, cert.FetchOptions{}) })
+	if code != exitSoft {
+		t.Errorf("a stale node should yield %d, got %d", exitSoft, code)
+	}
+	if !strings.Contains(out, "www.example — checking 2 address(es)") || !strings.Contains(out, "certificates differ") || !strings.Contains(out, "api.example — checking 1 address(es)") {
+		t.Errorf("unexpected all-ips output:\n%s", out)
+	}
+
+	out = captureStdout(t, func() {
+		code = runAllIPs(fetcher, append(targets, target{host: "gone.example", port: "443"}), cfg, cert.PrintOptions{JSON: true}, cert.FetchOptions{})
+	})
+	if code != exitError {
+		t.Errorf("an unresolvable domain should yield %d, got %d", exitError, code)
+	}
+	if !strings.HasPrefix(out, "[") || !strings.Contains(out, `"certificates_match": false`) || !strings.Contains(out, "failed to resolve gone.example") {
+		t.Errorf("unexpected all-ips JSON:\n%s", out)
+	}
+
+	cfg.Output = "prometheus"
+	out = captureStdout(t, func() { code = runPrometheus(fetcher, targets, cfg, cert.PrintOptions{}, cert.FetchOptions{}) })
+	if code != exitSoft {
+		t.Errorf("prometheus: a stale node should yield %d, got %d", exitSoft, code)
+	}
+	for _, want := range []string{`ssl_cert_expiry_days{domain="www.example",ip="192.0.2.2"} 10`, `ssl_cert_addresses_match{domain="www.example"} 0`, `ssl_cert_addresses_match{domain="api.example"} 1`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("prometheus output missing %q:\n%s", want, out)
+		}
+	}
+
+	// A pin no address matches is a mismatch in the report formats too.
+	pinned := cert.PrintOptions{Pins: []string{strings.Repeat("ab", 32)}}
+	for _, output := range []string{"prometheus", "csv"} {
+		cfg.Output = output
+		captureStdout(t, func() {
+			if output == "csv" {
+				code = runCSV(fetcher, targets[1:], cfg, pinned, cert.FetchOptions{})
+			} else {
+				code = runPrometheus(fetcher, targets[1:], cfg, pinned, cert.FetchOptions{})
+			}
+		})
+		if code != exitMismatch {
+			t.Errorf("%s: a pin mismatch should yield %d, got %d", output, exitMismatch, code)
+		}
+	}
+}
+
+// TestRunAllIPs_Resolvers compares what two -resolver entries return: each
+// address records its resolvers, and a failing resolver is only a warning.
+func TestRunAllIPs_Resolvers(t *testing.T) {
+	orig := lookupVia
+	defer func() { lookupVia = orig }()
+	lookupVia = func(host, resolver string, _ time.Duration) ([]net.IP, error) {
+		switch resolver {
+		case "10.0.0.53":
+			return []net.IP{net.ParseIP("10.1.0.1"), net.ParseIP("192.0.2.1")}, nil
+		case "tls://1.1.1.1":
+			return []net.IP{net.ParseIP("192.0.2.1")}, nil
+		}
+		return nil, errors.New("i/o timeout")
+	}
+	info := leafInfo("www.example", 90)
+	fetcher := ipFetcher{"10.1.0.1": info, "192.0.2.1": info}
+	tgt := []target{{host: "www.example", port: "443"}}
+	cfg := flags.Config{Concurrency: 2, Timeout: 1, AllIPs: true, Resolver: "10.0.0.53,tls://1.1.1.1,10.9.9.9"}
+
+	var code int
+	out := captureStdout(t, func() { code = runAllIPs(fetcher, tgt, cfg, cert.PrintOptions{}, cert.FetchOptions{}) })
+	if code != exitOK {
+		t.Errorf("expected %d, got %d", exitOK, code)
+	}
+	for _, want := range []string{"via 10.0.0.53: 10.1.0.1, 192.0.2.1", "via tls://1.1.1.1: 192.0.2.1", "Resolvers disagree"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	out = captureStdout(t, func() { runAllIPs(fetcher, tgt, cfg, cert.PrintOptions{JSON: true}, cert.FetchOptions{}) })
+	if !strings.Contains(out, `"resolvers": [
+        "10.0.0.53",
+        "tls://1.1.1.1"
+      ]`) {
+		t.Errorf("expected the address to list both resolvers:\n%s", out)
+	}
+
+	cfg.Resolver = "10.9.9.9"
+	if code := runAllIPs(fetcher, tgt, cfg, cert.PrintOptions{}, cert.FetchOptions{}); code != exitError {
+		t.Errorf("no resolver answering should yield %d, got %d", exitError, code)
+	}
+}
+
 // TestIsUnreachable verifies that no-route connection errors are classified as
 // skippable, while real failures are not.
 func TestIsUnreachable(t *testing.T) {
@@ -5,13 +5,17 @@
 // File map (setup → fetch → one file per output mode):
 //   - app.go: entry point — wiring (Run), dispatch (run), color and version
 //   - targets.go: parse and resolve targets (-domain, -domain-file, ports, dedup)
+//   - config.go: -config file — targets with per-target settings
 //   - validate.go: reject unsupported flag combinations
 //   - gather.go: fetch every target concurrently, results kept in input order
 //   - single.go: single-target output and its exit code
 //   - batch.go: multi-target aggregated output
-//   - allips.go: -all-ips mode (resolve + per-address) and reachability helpers
+//   - allips.go: -all-ips mode (resolve + per-address, per domain) and reachability helpers
+//   - state.go: -state — previous-run records, change detection and its exit codes
+//   - known.go: -known-certs — trust on first use, and the known-certs command
 //   - export.go: PEM export (-pem / -export)
 //   - report.go: Prometheus / CSV / Nagios output dispatch
+//   - serve.go: long-running exporter (serve) — scheduled checks, cached /metrics
 package app
 
 import (
@@ -47,7 +51,9 @@ const (
 	exitOK       = 0 // success
 	exitError    = 1 // operational error: could not check, or invalid arguments
 	exitSoft     = 2 // soft problem: expiring within -threshold, a -strict warning, or differing certs
-	exitMismatch = 3 // explicit expectation failed: -pin or -expect-issuer
+	exitMismatch = 3 // explicit expectation failed: -pin, -expect-issuer or -expect-cname
+	exitRevoked  = 4 // the certificate is revoked (-ocsp, staple, -crl)
+	exitCritical = 5 // expiring within -critical-threshold
 )
 
 // Run wires the real dependencies and executes the program, returning the process
@@ -64,7 +70,7 @@ func Run() int {
 // run is the program body, separated from main so it can be exercised in tests:
 // it takes its dependencies as parameters and returns the process exit code
 // instead of calling os.Exit, so the dispatch and error handling are unit-testable.
-func run(parser flags.FlagParser, fetcher cert.CertificateFetcher, loader cert.CertificateLoader, printer cert.CertificatePrinter) int {
+func run(parser flags.FlagParser, fetcher cert.CertificateFetcher, loader cert.CertificateLoader, printer cert.CertificatePrinter) (code int) {
 	cfg := parser.Parse()
 
 	// Check if the version flag is set
@@ -74,8 +80,8 @@ func run(parser flags.FlagParser, fetcher cert.CertificateFetcher, loader cert.C
 		return exitOK
 	}
 
-	// Resolve the list of targets from -domain (comma-separated) and -domain-file.
-	// Each token may carry its own port (host:port or a URL); bare hosts use the
+	// Resolve the list of targets from -domain (comma-separated), -domain-file and
+	// -config. Each token may carry its own port (host:port or a URL); bare hosts use the
 	// effective default port (the STARTTLS protocol's port when applicable).
 	targets, err := resolveTargets(cfg, effectiveDefaultPort(cfg))
 	if err != nil {
@@ -93,36 +99,90 @@ func run(parser flags.FlagParser, fetcher cert.CertificateFetcher, loader cert.C
 
 	// -pin produces the normalized hex used for the match (validate already
 	// rejected -pin with multiple domains; here we surface a malformed value).
-	var pinHex string
+	var pins []string
 	if cfg.Pin != "" {
-		pinHex, err = cert.NormalizePin(cfg.Pin)
+		pinHex, err := cert.NormalizePin(cfg.Pin)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: invalid -pin: %v\n\n", err)
 			parser.Usage()
 			return exitError
 		}
+		pins = []string{pinHex}
 	}
 
+	// The thresholds and -at parsed in validate; the errors cannot recur. Every
+	// expiry, validity and chain check below evaluates at -at when it is set:
+	// fetches record it from fetchOpts, a loaded certificate is given it here.
+	threshold, critical, _ := parseThresholds(cfg)
+	at, _ := parseAt(cfg.At)
 	opts := cert.PrintOptions{
-		Short:        cfg.Short,
-		JSON:         cfg.Output == "json",
-		Threshold:    cfg.Threshold,
-		Color:        useColor(cfg),
-		Chain:        cfg.Chain,
-		Fingerprint:  cfg.Fingerprint,
-		Pin:          pinHex,
-		ExpectIssuer: cfg.ExpectIssuer,
+		Short:             cfg.Short,
+		JSON:              cfg.Output == "json",
+		Threshold:         threshold,
+		CriticalThreshold: critical,
+		Color:             useColor(cfg),
+		Chain:             cfg.Chain,
+		Fingerprint:       cfg.Fingerprint,
+		Pins:              pins,
+		ExpectIssuer:      cfg.ExpectIssuer,
 	}
 	timeout := time.Duration(cfg.Timeout) * time.Second
 
 	// Connection/verification options shared by every fetch path. -cafile replaces
 	// the system roots; -servername overrides the SNI and verified name.
 	fetchOpts := cert.FetchOptions{
-		Insecure:   cfg.Insecure,
-		Timeout:    timeout,
-		StartTLS:   cfg.StartTLS,
-		ServerName: cfg.ServerName,
-		Proxy:      cfg.Proxy,
+		Insecure:     cfg.Insecure,
+		Timeout:      timeout,
+		StartTLS:     cfg.StartTLS,
+		ServerName:   cfg.ServerName,
+		Proxy:        cert.Proxy{URL: cfg.Proxy, Insecure: cfg.ProxyInsecure},
+		ProxyFromEnv: cfg.ProxyFromEnv,
+		ProxyHeader:  cert.ProxyHeader{Version: cfg.ProxyProtocol, Source: cfg.ProxyProtocolSrc, Dest: cfg.ProxyProtocolDst},
+		OCSP:         cfg.OCSP,
+		CRL:          cfg.CRL,
+		AIAFetch:     cfg.AIAFetch,
+		ScanVersions: cfg.ScanVersions,
+		ScanCiphers:  cfg.ScanCiphers,
+		KeyTypes:     cfg.KeyTypes,
+		DANE:         cfg.DANE,
+		CAA:          cfg.CAA,
+		DNSInfo:      cfg.DNSInfo,
+		ExpectCNAME:  cfg.ExpectCNAME,
+		DNSResolver:  dnsResolver(cfg),
+		Resolvers:    splitList(cfg.Resolver),
+		At:           at,
+	}
+	// -crl caches downloads under -crl-cache, else the user cache directory;
+	// -crlfile CRLs are loaded once and shared by every target.
+	if cfg.CRL {
+		fetchOpts.CRLCache = cfg.CRLCache
+		if fetchOpts.CRLCache == "" {
+			fetchOpts.CRLCache = cert.DefaultCRLCacheDir()
+		}
+	}
+	if cfg.CRLFile != "" {
+		crls, loadErr := cert.LoadCRLFiles(splitList(cfg.CRLFile))
+		if loadErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", loadErr)
+			return exitError
+		}
+		fetchOpts.CRLs = crls
+	}
+	if cfg.ProxyCAFile != "" {
+		roots, loadErr := cert.LoadCAFile(cfg.ProxyCAFile)
+		if loadErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", loadErr)
+			return exitError
+		}
+		fetchOpts.Proxy.Roots = roots
+	}
+	if cfg.CAAMap != "" {
+		caaMap, loadErr := cert.LoadCAAMap(cfg.CAAMap)
+		if loadErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", loadErr)
+			return exitError
+		}
+		fetchOpts.CAAMap = caaMap
 	}
 	if cfg.CAFile != "" {
 		roots, loadErr := cert.LoadCAFile(cfg.CAFile)
@@ -140,15 +200,49 @@ func run(parser flags.FlagParser, fetcher cert.CertificateFetcher, loader cert.C
 		}
 		fetchOpts.ClientCert = clientCert
 	}
+	// -config targets layer their own settings over the options above.
+	if err := resolveCheckSettings(targets, fetchOpts, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	// known-certs: list, accept or revoke entries of the -known-certs file.
+	if cfg.Command == flags.CommandKnownCerts {
+		return runKnownCerts(fetcher, targets, cfg, fetchOpts)
+	}
+
+	// -known-certs: every target the file knows must match its recorded pins;
+	// the public key of any other is recorded on first contact.
+	if cfg.KnownCerts != "" {
+		store, err := openKnown(cfg.KnownCerts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+		store.enforce(targets, opts)
+		fetcher = knownFetcher{CertificateFetcher: fetcher, store: store}
+		defer func() {
+			if err := store.save(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				code = exitError
+			}
+		}()
+	}
+
+	// serve: keep running, re-check every target on a schedule and serve the
+	// latest Prometheus exposition over HTTP.
+	if cfg.Command == flags.CommandServe {
+		return runServe(fetcher, targets, cfg, opts, fetchOpts)
+	}
 
 	// Prometheus exposition: fetch every target and emit one metric set each.
 	if cfg.Output == "prometheus" {
-		return runPrometheus(fetcher, targets, cfg, fetchOpts, pinHex)
+		return runPrometheus(fetcher, targets, cfg, opts, fetchOpts)
 	}
 
 	// CSV: fetch every target and emit one row each.
 	if cfg.Output == "csv" {
-		return runCSV(fetcher, targets, cfg, fetchOpts)
+		return runCSV(fetcher, targets, cfg, opts, fetchOpts)
 	}
 
 	// Nagios/Icinga plugin: a status line per run, with Nagios exit codes.
@@ -156,9 +250,26 @@ func run(parser flags.FlagParser, fetcher cert.CertificateFetcher, loader cert.C
 		return runNagios(fetcher, targets, cfg, opts, fetchOpts)
 	}
 
-	// -all-ips: resolve the domain and check the certificate on every address.
+	// -all-ips: resolve every domain and check the certificate on each address.
 	if cfg.AllIPs {
-		return runAllIPs(fetcher, targets[0], cfg, opts, fetchOpts)
+		return runAllIPs(fetcher, targets, cfg, opts, fetchOpts)
+	}
+
+	// -state: compare every certificate fetched below with the previous run's
+	// record, and save this run's records once it is done.
+	if cfg.State != "" {
+		tracker, err := openState(cfg.State)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+		fetcher = stateFetcher{CertificateFetcher: fetcher, tracker: tracker}
+		defer func() {
+			if err := tracker.save(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				code = exitError
+			}
+		}()
 	}
 
 	// Single target — a certificate file or exactly one domain — keeps the
@@ -169,12 +280,15 @@ func run(parser flags.FlagParser, fetcher cert.CertificateFetcher, loader cert.C
 			fmt.Fprintf(os.Stderr, "Error retrieving certificate: %v\n", err)
 			return exitError
 		}
+		if !at.IsZero() {
+			info.At = at
+		}
 		if cfg.Pem || cfg.Export != "" {
 			return runExport(info, cfg)
 		}
 		return printSingle(printer, info, cfg, opts)
 	}
-	if len(targets) == 1 {
+	if len(targets) == 1 && cfg.ConfigFile == "" {
 		t := targets[0]
 		info, err := fetcher.Fetch(t.host, t.port, cfg.IPAddr, fetchOpts)
 		if err != nil {
@@ -184,13 +298,26 @@ func run(parser flags.FlagParser, fetcher cert.CertificateFetcher, loader cert.C
 		if cfg.Pem || cfg.Export != "" {
 			return runExport(info, cfg)
 		}
-		return printSingle(printer, info, cfg, opts)
+		return printSingle(printer, info, cfg, t.printOptions(opts))
 	}
 
-	// Multiple targets — mass check with aggregated output and exit code.
+	// Multiple targets (or a -config file) — mass check with aggregated output and exit code.
 	return runBatch(fetcher, printer, targets, cfg, opts, fetchOpts)
 }
 
+// dnsResolver is the resolver for the DANE, CAA and CNAME lookups: -dns-resolver
+// when set, else the first -resolver, so a split-horizon setup names its
+// resolver once; empty leaves them to the system's.
+func dnsResolver(cfg flags.Config) string {
+	if cfg.DNSResolver != "" {
+		return cfg.DNSResolver
+	}
+	if rs := splitList(cfg.Resolver); len(rs) > 0 {
+		return rs[0]
+	}
+	return ""
+}
+
 // useColor reports whether the human-readable output should be colorized:
 // only for plain text output to an interactive terminal, and never when the
 // NO_COLOR environment variable is set.
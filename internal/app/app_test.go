@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/idesyatov/ssl-watch/internal/cert"
 	"github.com/idesyatov/ssl-watch/internal/flags"
@@ -49,6 +50,21 @@ func TestUseColor(t *testing.T) {
 	}
 }
 
+func TestDNSResolver(t *testing.T) {
+	for _, tt := range []struct {
+		cfg  flags.Config
+		want string
+	}{
+		{flags.Config{}, ""},
+		{flags.Config{Resolver: "tls://10.0.0.53, 10.0.0.54"}, "tls://10.0.0.53"},
+		{flags.Config{Resolver: "10.0.0.53", DNSResolver: "127.0.0.1"}, "127.0.0.1"},
+	} {
+		if got := dnsResolver(tt.cfg); got != tt.want {
+			t.Errorf("dnsResolver(%+v) = %q, want %q", tt.cfg, got, tt.want)
+		}
+	}
+}
+
 // TestRun exercises the run() dispatcher across its main branches: version, the
 // early error paths, and each output/target path with injected dependencies.
 func TestRun(t *testing.T) {
@@ -127,6 +143,20 @@ func TestRun(t *testing.T) {
 		}
 	})
 
+	t.Run("at", func(t *testing.T) {
+		at := testNow.AddDate(0, 0, 80).Format(time.DateOnly)
+		code, out := runArgs(t, []string{"-domain", "a.example", "-threshold", "30", "-at", at}, fetcher, loader)
+		if code != exitSoft || !strings.Contains(out, "(as of "+at) {
+			t.Errorf("at: code=%d out=%q", code, out)
+		}
+		// -at applies to a certificate loaded from a file too.
+		fileLoader := &fakeLoader{info: realCertInfo(t, "file.example", 90)}
+		code, out = runArgs(t, []string{"-certfile", "file.pem", "-threshold", "30", "-at", at}, fetcher, fileLoader)
+		if code != exitSoft || !strings.Contains(out, "Days remaining: 10 (as of "+at) {
+			t.Errorf("at, certfile: code=%d out=%q", code, out)
+		}
+	})
+
 	t.Run("batch dispatch", func(t *testing.T) {
 		code, out := runArgs(t, []string{"-domain", "a.example,b.example"}, fetcher, loader)
 		if code != exitOK || !strings.Contains(out, "==> a.example") {
@@ -14,14 +14,21 @@ import (
 // JSON mode it emits an array (one object per target, with an "error" entry for
 // failures); in text mode it prints one block per target, with failures on
 // stderr. It returns the process exit code: 1 if any target failed to be
-// retrieved, otherwise 2 if any certificate in a chain expires within the
-// threshold, otherwise 0.
+// retrieved, otherwise 4 if any certificate is revoked, otherwise 3 if a pin or the expected issuer did not match, otherwise
+// 5 if any certificate in a chain expires within its critical threshold,
+// otherwise 2 if any expires within the threshold, otherwise 0 — or, with
+// -state, the code of a change since the last run when that is more severe. A
+// target from -config is printed and judged with its own expectations.
 func runBatch(fetcher cert.CertificateFetcher, printer cert.CertificatePrinter, targets []target, cfg flags.Config, opts cert.PrintOptions, fetchOpts cert.FetchOptions) int {
 	hadError := false
 	expiring := false
-	issuerFail := false
+	critical := false
+	mismatch := false
+	revoked := false
 	strictFail := false
 	printedText := false
+	changed := exitOK
+	codes, _ := parseStateExit(cfg.StateExit) // validated
 	var entries []any
 
 	for _, r := range fetchAll(fetcher, targets, cfg.IPAddr, fetchOpts, cfg.Concurrency) {
@@ -36,6 +43,7 @@ func runBatch(fetcher cert.CertificateFetcher, printer cert.CertificatePrinter,
 			continue
 		}
 		info := r.info
+		topts := r.target.printOptions(opts)
 
 		if opts.JSON {
 			entries = append(entries, cert.Payload(info, label, opts.Chain, opts.Fingerprint))
@@ -43,25 +51,32 @@ func runBatch(fetcher cert.CertificateFetcher, printer cert.CertificatePrinter,
 			// Multi-domain short mode: prefix each days count with its target so
 			// the numbers stay attributable and greppable (target<TAB>days).
 			fmt.Printf("%s\t", label)
-			printer.Print(info, opts)
+			printer.Print(info, topts)
 		} else {
 			if printedText {
 				fmt.Println()
 			}
 			fmt.Printf("==> %s\n", label)
-			printer.Print(info, opts)
+			printer.Print(info, topts)
 			printedText = true
 		}
 
-		if cfg.Threshold > 0 && info.MinDaysUntilExpiry() < cfg.Threshold {
+		switch expiryExit(info, topts.Threshold, topts.CriticalThreshold) {
+		case exitCritical:
+			critical = true
+		case exitSoft:
 			expiring = true
 		}
-		if cfg.ExpectIssuer != "" && !cert.IssuerMatches(info.Cert, cfg.ExpectIssuer) {
-			issuerFail = true
+		if expectationFailed(info, topts) {
+			mismatch = true
+		}
+		if info.RevokedBy() != nil {
+			revoked = true
 		}
 		if cfg.Strict && cert.HasWarnings(info) {
 			strictFail = true
 		}
+		changed = worseExit(changed, stateExit(info.State, codes))
 	}
 
 	if opts.JSON {
@@ -73,13 +88,18 @@ func runBatch(fetcher cert.CertificateFetcher, printer cert.CertificatePrinter,
 		fmt.Println(string(b))
 	}
 
+	code := exitOK
 	switch {
 	case hadError:
-		return exitError
-	case issuerFail:
-		return exitMismatch
+		code = exitError
+	case revoked:
+		code = exitRevoked
+	case mismatch:
+		code = exitMismatch
+	case critical:
+		code = exitCritical
 	case expiring || strictFail:
-		return exitSoft
+		code = exitSoft
 	}
-	return exitOK
+	return worseExit(code, changed)
 }
@@ -59,8 +59,8 @@ func TestRunBatch_Text(t *testing.T) {
 		},
 	}
 	targets := hostTargets("a.example", "b.example")
-	cfg := flags.Config{Output: "text", Threshold: 30, Concurrency: 1}
-	opts := cert.PrintOptions{Threshold: 30}
+	cfg := flags.Config{Output: "text", Threshold: "30", Concurrency: 1}
+	opts := cert.PrintOptions{Threshold: cert.ThresholdDays(30)}
 
 	var code int
 	out := captureStdout(t, func() {
@@ -77,6 +77,32 @@ func TestRunBatch_Text(t *testing.T) {
 	}
 }
 
+// TestRunBatch_CriticalThreshold verifies a certificate within
+// -critical-threshold outranks one merely within -threshold.
+func TestRunBatch_CriticalThreshold(t *testing.T) {
+	fetcher := &fakeFetcher{
+		infos: map[string]*cert.CertInfo{
+			"a.example": leafInfo("a.example", 20),
+			"b.example": leafInfo("b.example", 5),
+		},
+	}
+	cfg := flags.Config{Output: "text", Threshold: "30", CriticalThreshold: "7", Concurrency: 1}
+	opts := cert.PrintOptions{Threshold: cert.ThresholdDays(30), CriticalThreshold: cert.ThresholdDays(7)}
+	run := func(targets []target) int {
+		var code int
+		captureStdout(t, func() {
+			code = runBatch(fetcher, &cert.CertificatePrinterImpl{}, targets, cfg, opts, cert.FetchOptions{Timeout: time.Second})
+		})
+		return code
+	}
+	if code := run(hostTargets("a.example")); code != exitSoft {
+		t.Errorf("within threshold: expected %d, got %d", exitSoft, code)
+	}
+	if code := run(hostTargets("a.example", "b.example")); code != exitCritical {
+		t.Errorf("within critical threshold: expected %d, got %d", exitCritical, code)
+	}
+}
+
 // TestRunBatch_Short verifies that multi-domain short mode prefixes each days
 // count with its domain (domain<TAB>days), so the numbers stay attributable.
 func TestRunBatch_Short(t *testing.T) {
@@ -115,7 +141,7 @@ func TestRunBatch_ConcurrencyOrder(t *testing.T) {
 		},
 	}
 	targets := []target{
-		{"a.example", "443"}, {"b.example", "8443"}, {"c.example", "443"},
+		{host: "a.example", port: "443"}, {host: "b.example", port: "8443"}, {host: "c.example", port: "443"},
 	}
 	cfg := flags.Config{Output: "text", Concurrency: 3}
 	opts := cert.PrintOptions{}
@@ -0,0 +1,356 @@
+package app
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/idesyatov/ssl-watch/internal/cert"
+	"github.com/idesyatov/ssl-watch/internal/flags"
+)
+
+// checkSettings are the settings a -config file can give a target, either at the
+// top level (shared defaults for every target) or on the target itself. An unset
+// field inherits: target → file defaults → command-line flags. Pointer fields
+// distinguish "unset" from an explicit zero (e.g. "threshold": 0 disables).
+type checkSettings struct {
+	Port              string          `json:"port,omitempty"`               // port for a domain that does not carry one
+	IPAddr            string          `json:"ipaddr,omitempty"`             // connect to this address instead of resolving
+	ServerName        string          `json:"servername,omitempty"`         // SNI and verified name
+	StartTLS          string          `json:"starttls,omitempty"`           // STARTTLS protocol
+	Pins              []string        `json:"pins,omitempty"`               // sha256:<hex> pins; any one must match
+	ExpectIssuer      string          `json:"expect_issuer,omitempty"`      // issuer DN substring
+	Threshold         *cert.Threshold `json:"threshold,omitempty"`          // expiry threshold: days, or a string as for -threshold
+	CriticalThreshold *cert.Threshold `json:"critical_threshold,omitempty"` // critical expiry threshold, in the same forms
+	CAFile            string          `json:"cafile,omitempty"`             // PEM bundle replacing the system roots
+	ClientCert        string          `json:"client_cert,omitempty"`        // client certificate for mutual TLS
+	ClientKey         string          `json:"client_key,omitempty"`         // key for client_cert
+	Proxy             string          `json:"proxy,omitempty"`              // proxy URL
+	ProxyFromEnv      *bool           `json:"proxy_from_env,omitempty"`     // pick the proxy from HTTPS_PROXY/NO_PROXY
+	ProxyProtocol     string          `json:"proxy_protocol,omitempty"`     // PROXY protocol header: v1 or v2
+	Timeout           *int            `json:"timeout,omitempty"`            // connection timeout in seconds
+	Insecure          *bool           `json:"insecure,omitempty"`           // skip chain verification
+	OCSP              *bool           `json:"ocsp,omitempty"`               // check revocation via OCSP
+	CRL               *bool           `json:"crl,omitempty"`                // check revocation via the chain's CRLs
+	AIAFetch          *bool           `json:"aia_fetch,omitempty"`          // repair an incomplete chain via AIA caIssuers
+	ScanVersions      *bool           `json:"scan_versions,omitempty"`      // probe the accepted TLS versions
+	ScanCiphers       *bool           `json:"scan_ciphers,omitempty"`       // probe and grade the accepted cipher suites
+	KeyTypes          *bool           `json:"key_types,omitempty"`          // fetch the RSA and the ECDSA certificate separately
+	DANE              *bool           `json:"dane,omitempty"`               // match the chain against the service's TLSA records
+	CAA               *bool           `json:"caa,omitempty"`                // check the domain's CAA records authorize the issuer
+	DNSInfo           *bool           `json:"dns_info,omitempty"`           // report the CNAME chain, final addresses and TTLs
+	ExpectCNAME       string          `json:"expect_cname,omitempty"`       // suffix the CNAME chain must end under
+}
+
+// checkFile is the layout of a -config file: shared defaults at the top level
+// and the list of targets, each a domain plus its own overrides.
+type checkFile struct {
+	checkSettings
+	Targets []checkTarget `json:"targets"`
+}
+
+// checkTarget is one entry of a -config file's "targets" list. Domain may carry
+// its own port (host:port) or be a URL, like a -domain token.
+type checkTarget struct {
+	Domain string `json:"domain"`
+	checkSettings
+}
+
+// over returns s with every unset field taken from base.
+func (s checkSettings) over(base checkSettings) checkSettings {
+	out := base
+	if s.Port != "" {
+		out.Port = s.Port
+	}
+	if s.IPAddr != "" {
+		out.IPAddr = s.IPAddr
+	}
+	if s.ServerName != "" {
+		out.ServerName = s.ServerName
+	}
+	if s.StartTLS != "" {
+		out.StartTLS = s.StartTLS
+	}
+	if len(s.Pins) > 0 {
+		out.Pins = s.Pins
+	}
+	if s.ExpectIssuer != "" {
+		out.ExpectIssuer = s.ExpectIssuer
+	}
+	if s.Threshold != nil {
+		out.Threshold = s.Threshold
+	}
+	if s.CriticalThreshold != nil {
+		out.CriticalThreshold = s.CriticalThreshold
+	}
+	if s.CAFile != "" {
+		out.CAFile = s.CAFile
+	}
+	if s.ClientCert != "" {
+		out.ClientCert, out.ClientKey = s.ClientCert, s.ClientKey
+	}
+	if s.Proxy != "" {
+		out.Proxy = s.Proxy
+	}
+	if s.ProxyFromEnv != nil {
+		out.ProxyFromEnv = s.ProxyFromEnv
+	}
+	if s.ProxyProtocol != "" {
+		out.ProxyProtocol = s.ProxyProtocol
+	}
+	if s.Timeout != nil {
+		out.Timeout = s.Timeout
+	}
+	if s.Insecure != nil {
+		out.Insecure = s.Insecure
+	}
+	if s.OCSP != nil {
+		out.OCSP = s.OCSP
+	}
+	if s.CRL != nil {
+		out.CRL = s.CRL
+	}
+	if s.AIAFetch != nil {
+		out.AIAFetch = s.AIAFetch
+	}
+	if s.ScanVersions != nil {
+		out.ScanVersions = s.ScanVersions
+	}
+	if s.ScanCiphers != nil {
+		out.ScanCiphers = s.ScanCiphers
+	}
+	if s.KeyTypes != nil {
+		out.KeyTypes = s.KeyTypes
+	}
+	if s.DANE != nil {
+		out.DANE = s.DANE
+	}
+	if s.CAA != nil {
+		out.CAA = s.CAA
+	}
+	if s.DNSInfo != nil {
+		out.DNSInfo = s.DNSInfo
+	}
+	if s.ExpectCNAME != "" {
+		out.ExpectCNAME = s.ExpectCNAME
+	}
+	return out
+}
+
+// check reports the first invalid value in a target's merged settings. Like
+// validate it is pure; loading the referenced files is left to
+// resolveCheckSettings.
+func (s checkSettings) check() error {
+	if s.Port != "" {
+		if err := validatePort(s.Port); err != nil {
+			return err
+		}
+	}
+	if s.StartTLS != "" {
+		if _, ok := starttlsPorts[s.StartTLS]; !ok {
+			return fmt.Errorf("invalid starttls %q (expected one of %s)", s.StartTLS, starttlsNames())
+		}
+	}
+	if s.ProxyProtocol != "" && s.ProxyProtocol != "v1" && s.ProxyProtocol != "v2" {
+		return fmt.Errorf("invalid proxy_protocol %q (expected v1 or v2)", s.ProxyProtocol)
+	}
+	if (s.ClientCert != "") != (s.ClientKey != "") {
+		return errors.New("client_cert and client_key must be used together")
+	}
+	if s.Timeout != nil && *s.Timeout <= 0 {
+		return fmt.Errorf("invalid timeout %d (expected a positive number of seconds)", *s.Timeout)
+	}
+	if s.CAFile != "" && s.Insecure != nil && *s.Insecure {
+		return errors.New("cafile cannot be combined with insecure")
+	}
+	if s.ExpectCNAME != "" {
+		if err := validateCNAMESuffix(s.ExpectCNAME); err != nil {
+			return fmt.Errorf("invalid expect_cname: %v", err)
+		}
+	}
+	for _, p := range s.Pins {
+		if _, err := cert.NormalizePin(p); err != nil {
+			return fmt.Errorf("invalid pin %q: %v", p, err)
+		}
+	}
+	return nil
+}
+
+// readCheckFile parses a -config file. The format is JSON; unknown keys are
+// rejected so a typo does not silently fall back to a default.
+func readCheckFile(path string) (*checkFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %v", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var f checkFile
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	if len(f.Targets) == 0 {
+		return nil, fmt.Errorf("config file %s lists no targets", path)
+	}
+	return &f, nil
+}
+
+// configTargets reads the -config file and returns its targets in file order,
+// each carrying its merged settings (target over the file's defaults). A
+// domain without its own port uses the merged "port", else its STARTTLS
+// protocol's port, else -port.
+func configTargets(cfg flags.Config) ([]target, error) {
+	f, err := readCheckFile(cfg.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]target, 0, len(f.Targets))
+	for i, ct := range f.Targets {
+		if ct.Domain == "" {
+			return nil, fmt.Errorf("config file %s: target %d has no domain", cfg.ConfigFile, i+1)
+		}
+		spec := ct.checkSettings.over(f.checkSettings)
+		if err := spec.check(); err != nil {
+			return nil, fmt.Errorf("config file %s: target %s: %v", cfg.ConfigFile, ct.Domain, err)
+		}
+		defaultPort := spec.Port
+		if defaultPort == "" {
+			portCfg := cfg
+			if spec.StartTLS != "" {
+				portCfg.StartTLS = spec.StartTLS
+			}
+			defaultPort = effectiveDefaultPort(portCfg)
+		}
+		t, err := parseTarget(ct.Domain, defaultPort)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %v", cfg.ConfigFile, err)
+		}
+		t.spec = &spec
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// resolveCheckSettings applies each -config target's settings over the shared
+// command-line options: it loads per-target CA bundles and client certificates
+// (each file once), normalizes pins and stores the merged options on the target.
+// Targets without settings are left as they are.
+func resolveCheckSettings(targets []target, fetchOpts cert.FetchOptions, opts cert.PrintOptions) error {
+	roots := make(map[string]*x509.CertPool)
+	clients := make(map[string]*tls.Certificate)
+	for i := range targets {
+		t := &targets[i]
+		s := t.spec
+		if s == nil {
+			continue
+		}
+		fo, po := fetchOpts, opts
+		if s.ServerName != "" {
+			fo.ServerName = s.ServerName
+		}
+		if s.StartTLS != "" {
+			fo.StartTLS = s.StartTLS
+		}
+		if s.Proxy != "" {
+			fo.Proxy.URL = s.Proxy
+		}
+		if s.ProxyFromEnv != nil {
+			fo.ProxyFromEnv = *s.ProxyFromEnv
+		}
+		if s.ProxyProtocol != "" {
+			fo.ProxyHeader.Version = s.ProxyProtocol
+		}
+		if s.Timeout != nil {
+			fo.Timeout = time.Duration(*s.Timeout) * time.Second
+		}
+		if s.Insecure != nil {
+			fo.Insecure = *s.Insecure
+		}
+		if s.OCSP != nil {
+			fo.OCSP = *s.OCSP
+		}
+		if s.AIAFetch != nil {
+			fo.AIAFetch = *s.AIAFetch
+		}
+		if s.ScanVersions != nil {
+			fo.ScanVersions = *s.ScanVersions
+		}
+		if s.ScanCiphers != nil {
+			fo.ScanCiphers = *s.ScanCiphers
+		}
+		if s.KeyTypes != nil {
+			fo.KeyTypes = *s.KeyTypes
+		}
+		if s.DANE != nil {
+			fo.DANE = *s.DANE
+		}
+		if s.CAA != nil {
+			fo.CAA = *s.CAA
+		}
+		if s.DNSInfo != nil {
+			fo.DNSInfo = *s.DNSInfo
+		}
+		if s.ExpectCNAME != "" {
+			fo.ExpectCNAME = s.ExpectCNAME
+		}
+		if s.CRL != nil {
+			fo.CRL = *s.CRL
+			if fo.CRL && fo.CRLCache == "" {
+				fo.CRLCache = cert.DefaultCRLCacheDir()
+			}
+		}
+		if s.CAFile != "" {
+			pool, ok := roots[s.CAFile]
+			if !ok {
+				var err error
+				if pool, err = cert.LoadCAFile(s.CAFile); err != nil {
+					return err
+				}
+				roots[s.CAFile] = pool
+			}
+			fo.Roots = pool
+		}
+		if s.ClientCert != "" {
+			key := s.ClientCert + "\x00" + s.ClientKey
+			pair, ok := clients[key]
+			if !ok {
+				var err error
+				if pair, err = cert.LoadClientCert(s.ClientCert, s.ClientKey); err != nil {
+					return err
+				}
+				clients[key] = pair
+			}
+			fo.ClientCert = pair
+		}
+		if len(s.Pins) > 0 {
+			po.Pins = nil
+			for _, p := range s.Pins {
+				hex, err := cert.NormalizePin(p)
+				if err != nil {
+					return fmt.Errorf("invalid pin %q for %s: %v", p, t.label(), err)
+				}
+				po.Pins = append(po.Pins, hex)
+			}
+		}
+		if s.ExpectIssuer != "" {
+			po.ExpectIssuer = s.ExpectIssuer
+		}
+		if s.Threshold != nil {
+			po.Threshold = *s.Threshold
+		}
+		if s.CriticalThreshold != nil {
+			po.CriticalThreshold = *s.CriticalThreshold
+		}
+		if err := validateThresholds(po.Threshold, po.CriticalThreshold); err != nil {
+			return fmt.Errorf("invalid thresholds for %s: %v", t.label(), err)
+		}
+		t.ipaddr = s.IPAddr
+		t.fetch, t.print = &fo, &po
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/idesyatov/ssl-watch/internal/cert"
+	"github.com/idesyatov/ssl-watch/internal/flags"
+)
+
+// writeConfig writes a -config file into a temp dir and returns its path.
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "targets.json")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+// TestConfigTargets verifies targets keep file order, a target's own settings
+// win over the file defaults, and the port comes from the domain, then "port",
+// then the STARTTLS protocol, then -port.
+func TestConfigTargets(t *testing.T) {
+	path := writeConfig(t, `{
+  "threshold": 30,
+  "timeout": 5,
+  "targets": [
+    {"domain": "a.example"},
+    {"domain": "b.example:8443", "threshold": "36h"},
+    {"domain": "mail.example", "starttls": "smtp", "expect_cname": "cdn.example.net"},
+    {"domain": "c.example", "port": "9443", "servername": "sni.example", "ipaddr": "192.0.2.9"}
+  ]
+}`)
+	cfg := flags.Config{ConfigFile: path, Port: "443"}
+	targets, err := resolveTargets(cfg, effectiveDefaultPort(cfg))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantPorts := []string{"443", "8443", "587", "9443"}
+	if len(targets) != len(wantPorts) {
+		t.Fatalf("expected %d targets, got %d", len(wantPorts), len(targets))
+	}
+	for i, p := range wantPorts {
+		if targets[i].port != p {
+			t.Errorf("target %d (%s): port %q, want %q", i, targets[i].host, targets[i].port, p)
+		}
+	}
+
+	shared := cert.FetchOptions{Timeout: 10 * time.Second}
+	if err := resolveCheckSettings(targets, shared, cert.PrintOptions{Threshold: cert.ThresholdDays(60)}); err != nil {
+		t.Fatalf("resolveCheckSettings: %v", err)
+	}
+	if got := targets[0].printOptions(cert.PrintOptions{}).Threshold; got != cert.ThresholdDays(30) {
+		t.Errorf("file default threshold: got %s, want 30", got)
+	}
+	if got := targets[1].printOptions(cert.PrintOptions{}).Threshold; got.String() != "36h" {
+		t.Errorf("target threshold override: got %s, want 36h", got)
+	}
+	if fo := targets[0].fetchOptions(shared); fo.Timeout != 5*time.Second {
+		t.Errorf("file default timeout: got %s, want 5s", fo.Timeout)
+	}
+	if fo := targets[2].fetchOptions(shared); fo.StartTLS != "smtp" || fo.ExpectCNAME != "cdn.example.net" {
+		t.Errorf("expected starttls smtp and expect_cname cdn.example.net, got %q %q", fo.StartTLS, fo.ExpectCNAME)
+	}
+	if fo := targets[3].fetchOptions(shared); fo.ServerName != "sni.example" || targets[3].ipaddr != "192.0.2.9" {
+		t.Errorf("expected servername and ipaddr override, got %q / %q", fo.ServerName, targets[3].ipaddr)
+	}
+}
+
+// TestConfigTargets_Errors verifies malformed files and invalid settings are
+// rejected with an error naming the file.
+func TestConfigTargets_Errors(t *testing.T) {
+	cases := map[string]string{
+		"not json":         `targets:`,
+		"unknown key":      `{"targets": [{"domain": "a.example", "treshold": 5}]}`,
+		"no targets":       `{"targets": []}`,
+		"no domain":        `{"targets": [{"port": "443"}]}`,
+		"bad port":         `{"targets": [{"domain": "a.example", "port": "99999"}]}`,
+		"bad starttls":     `{"targets": [{"domain": "a.example", "starttls": "gopher"}]}`,
+		"cert without key": `{"targets": [{"domain": "a.example", "client_cert": "c.crt"}]}`,
+		"bad timeout":      `{"timeout": 0, "targets": [{"domain": "a.example"}]}`,
+		"bad pin":          `{"targets": [{"domain": "a.example", "pins": ["md5:00"]}]}`,
+		"cafile insecure":  `{"cafile": "r.pem", "targets": [{"domain": "a.example", "insecure": true}]}`,
+		"bad critical":     `{"critical_threshold": -1, "targets": [{"domain": "a.example"}]}`,
+		"bad expect_cname": `{"targets": [{"domain": "a.example", "expect_cname": "cdn example"}]}`,
+	}
+	for name, body := range cases {
+		path := writeConfig(t, body)
+		_, err := configTargets(flags.Config{ConfigFile: path, Port: "443"})
+		if err == nil {
+			t.Errorf("%s: expected an error, got nil", name)
+			continue
+		}
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("%s: error should name the file, got %v", name, err)
+		}
+	}
+
+	if _, err := configTargets(flags.Config{ConfigFile: filepath.Join(t.TempDir(), "nope.json")}); err == nil {
+		t.Error("expected an error for a missing config file, got nil")
+	}
+}
+
+// TestRunBatch_ConfigSettings verifies a batch judges each -config target by its
+// own expectations: a per-target pin mismatch exits 3, and a per-target
+// threshold applies only to its target.
+func TestRunBatch_ConfigSettings(t *testing.T) {
+	a := realCertInfo(t, "a.example", 20)
+	b := realCertInfo(t, "b.example", 20)
+	fetcher := &fakeFetcher{infos: map[string]*cert.CertInfo{"a.example": a, "b.example": b}}
+	cfg := flags.Config{Output: "text", Short: true, Concurrency: 1}
+	pin := "sha256:" + cert.Fingerprint(a.Cert)
+
+	run := func(body string) int {
+		targets, err := configTargets(flags.Config{ConfigFile: writeConfig(t, body), Port: "443"})
+		if err != nil {
+			t.Fatalf("configTargets: %v", err)
+		}
+		if err := resolveCheckSettings(targets, cert.FetchOptions{}, cert.PrintOptions{Short: true}); err != nil {
+			t.Fatalf("resolveCheckSettings: %v", err)
+		}
+		var code int
+		captureStdout(t, func() {
+			code = runBatch(fetcher, &cert.CertificatePrinterImpl{}, targets, cfg, cert.PrintOptions{Short: true}, cert.FetchOptions{})
+		})
+		return code
+	}
+
+	if code := run(`{"targets": [{"domain": "a.example", "pins": ["` + pin + `"]}, {"domain": "b.example"}]}`); code != exitOK {
+		t.Errorf("matching pin, no threshold: expected exit 0, got %d", code)
+	}
+	if code := run(`{"targets": [{"domain": "a.example"}, {"domain": "b.example", "pins": ["` + pin + `"]}]}`); code != exitMismatch {
+		t.Errorf("pin mismatch on b: expected exit 3, got %d", code)
+	}
+	if code := run(`{"targets": [{"domain": "a.example", "threshold": 30}, {"domain": "b.example"}]}`); code != exitSoft {
+		t.Errorf("per-target threshold: expected exit 2, got %d", code)
+	}
+}
@@ -17,44 +17,72 @@ type fetchResult struct {
 // fetchAll fetches every target's certificate, running up to concurrency fetches
 // at once, and returns the results in the same order as targets (so the rendered
 // output is deterministic regardless of completion order). A concurrency of 1 is
-// effectively sequential. The fetcher must be safe for concurrent use.
+// effectively sequential. A target from -config connects with its own address and
+// options; others use ipaddr and fetchOpts. The fetcher must be safe for
+// concurrent use.
 func fetchAll(fetcher cert.CertificateFetcher, targets []target, ipaddr string, fetchOpts cert.FetchOptions, concurrency int) []fetchResult {
+	results := make([]fetchResult, len(targets))
+	forEach(len(targets), concurrency, func(i int) {
+		t := targets[i]
+		addr := ipaddr
+		if t.ipaddr != "" {
+			addr = t.ipaddr
+		}
+		info, err := fetcher.Fetch(t.host, t.port, addr, t.fetchOptions(fetchOpts))
+		results[i] = fetchResult{target: t, info: info, err: err}
+	})
+	return results
+}
+
+// forEach calls fn for every index below n, with up to concurrency calls in
+// flight, and returns once all of them have.
+func forEach(n, concurrency int, fn func(i int)) {
 	if concurrency < 1 {
 		concurrency = 1
 	}
-	results := make([]fetchResult, len(targets))
 	sem := make(chan struct{}, concurrency)
 	var wg sync.WaitGroup
-	for i, t := range targets {
+	for i := 0; i < n; i++ {
 		wg.Add(1)
 		sem <- struct{}{}
-		go func(i int, t target) {
+		go func(i int) {
 			defer wg.Done()
 			defer func() { <-sem }()
-			info, err := fetcher.Fetch(t.host, t.port, ipaddr, fetchOpts)
-			results[i] = fetchResult{target: t, info: info, err: err}
-		}(i, t)
+			fn(i)
+		}(i)
 	}
 	wg.Wait()
-	return results
 }
 
-// collectSamples fetches every target (respecting -concurrency, order preserved)
-// and returns the per-target samples plus whether any failed to be retrieved or
-// expires within -threshold. Shared by the prometheus and csv report formats.
-func collectSamples(fetcher cert.CertificateFetcher, targets []target, cfg flags.Config, fetchOpts cert.FetchOptions) (samples []cert.PromSample, hadError, expiring bool) {
-	samples = make([]cert.PromSample, 0, len(targets))
+// collectSamples fetches every target (respecting -concurrency, order
+// preserved) and returns the per-target samples, each carrying the target's own
+// expectations if any. The aggregated exit code says whether any target failed
+// to be retrieved, is revoked, fails an explicit expectation (see
+// expectationFailed) or expires within its thresholds, as runBatch does. Shared
+// by the prometheus, csv and nagios formats; under -all-ips there is one sample
+// per address instead (see collectIPSamples).
+func collectSamples(fetcher cert.CertificateFetcher, targets []target, cfg flags.Config, opts cert.PrintOptions, fetchOpts cert.FetchOptions) ([]cert.PromSample, int) {
+	if cfg.AllIPs {
+		return collectIPSamples(fetcher, targets, cfg, opts, fetchOpts)
+	}
+	samples := make([]cert.PromSample, 0, len(targets))
+	code := exitOK
 	for _, r := range fetchAll(fetcher, targets, cfg.IPAddr, fetchOpts, cfg.Concurrency) {
 		label := r.target.label()
 		if r.err != nil {
-			hadError = true
-			samples = append(samples, cert.PromSample{Domain: label, Err: r.err})
+			code = worseExit(code, exitError)
+			samples = append(samples, cert.PromSample{Domain: label, Err: r.err, Opts: r.target.print})
 			continue
 		}
-		samples = append(samples, cert.PromSample{Domain: label, Info: r.info})
-		if cfg.Threshold > 0 && r.info.MinDaysUntilExpiry() < cfg.Threshold {
-			expiring = true
+		samples = append(samples, cert.PromSample{Domain: label, Info: r.info, Opts: r.target.print})
+		topts := r.target.printOptions(opts)
+		code = worseExit(code, expiryExit(r.info, topts.Threshold, topts.CriticalThreshold))
+		if expectationFailed(r.info, topts) {
+			code = worseExit(code, exitMismatch)
+		}
+		if r.info.RevokedBy() != nil {
+			code = worseExit(code, exitRevoked)
 		}
 	}
-	return samples, hadError, expiring
+	return samples, code
 }
@@ -35,7 +35,8 @@ func captureStdout(t *testing.T, fn func()) string {
 	return string(out)
 }
 
-// fakeFetcher returns canned certificate info or errors per domain.
+// fakeFetcher returns canned certificate info or errors per domain. Like the
+// real fetcher, it records the evaluation instant of opts on the result.
 type fakeFetcher struct {
 	infos map[string]*cert.CertInfo
 	errs  map[string]error
@@ -45,7 +46,13 @@ func (f *fakeFetcher) Fetch(domain, port, ipaddr string, opts cert.FetchOptions)
 	if err, ok := f.errs[domain]; ok {
 		return nil, err
 	}
-	return f.infos[domain], nil
+	info, ok := f.infos[domain]
+	if !ok || opts.At.IsZero() {
+		return info, nil
+	}
+	at := *info
+	at.At = opts.At
+	return &at, nil
 }
 
 // fakeLoader returns a canned CertInfo or error for the -certfile path.
@@ -56,15 +63,21 @@ type fakeLoader struct {
 
 func (f *fakeLoader) Load(string) (*cert.CertInfo, error) { return f.info, f.err }
 
+// testNow is the fixed instant the certificates built here are evaluated at
+// (CertInfo.At), so the expiry tests do not depend on when they run. -at
+// replaces it, as it would the current time.
+var testNow = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
 // leafInfo builds a CertInfo whose leaf expires in the given number of days.
 func leafInfo(cn string, days int) *cert.CertInfo {
 	return &cert.CertInfo{
 		Cert: &x509.Certificate{
 			Subject:      pkix.Name{CommonName: cn},
 			SerialNumber: big.NewInt(1),
-			NotAfter:     time.Now().Add(time.Duration(days)*24*time.Hour + time.Hour),
+			NotAfter:     testNow.Add(time.Duration(days)*24*time.Hour + time.Hour),
 		},
 		UsedIP: "192.0.2.1",
+		At:     testNow,
 	}
 }
 
@@ -79,8 +92,8 @@ func realCertInfo(t *testing.T, cn string, days int) *cert.CertInfo {
 	tmpl := x509.Certificate{
 		SerialNumber: big.NewInt(1),
 		Subject:      pkix.Name{CommonName: cn},
-		NotBefore:    time.Now().Add(-time.Hour),
-		NotAfter:     time.Now().Add(time.Duration(days)*24*time.Hour + time.Hour),
+		NotBefore:    testNow.Add(-time.Hour),
+		NotAfter:     testNow.Add(time.Duration(days)*24*time.Hour + time.Hour),
 	}
 	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
 	if err != nil {
@@ -90,7 +103,7 @@ func realCertInfo(t *testing.T, cn string, days int) *cert.CertInfo {
 	if err != nil {
 		t.Fatalf("failed to parse certificate: %v", err)
 	}
-	return &cert.CertInfo{Cert: c, Chain: []*x509.Certificate{c}}
+	return &cert.CertInfo{Cert: c, Chain: []*x509.Certificate{c}, At: testNow}
 }
 
 // futureCertInfo builds a CertInfo whose certificate is not valid yet (NotBefore
@@ -104,8 +117,8 @@ func futureCertInfo(t *testing.T, cn string) *cert.CertInfo {
 	tmpl := x509.Certificate{
 		SerialNumber: big.NewInt(1),
 		Subject:      pkix.Name{CommonName: cn},
-		NotBefore:    time.Now().Add(24 * time.Hour),
-		NotAfter:     time.Now().Add(72 * time.Hour),
+		NotBefore:    testNow.Add(24 * time.Hour),
+		NotAfter:     testNow.Add(72 * time.Hour),
 	}
 	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
 	if err != nil {
@@ -115,7 +128,7 @@ func futureCertInfo(t *testing.T, cn string) *cert.CertInfo {
 	if err != nil {
 		t.Fatalf("failed to parse certificate: %v", err)
 	}
-	return &cert.CertInfo{Cert: c, Chain: []*x509.Certificate{c}}
+	return &cert.CertInfo{Cert: c, Chain: []*x509.Certificate{c}, At: testNow}
 }
 
 // hostTargets builds default-port targets from bare hostnames, for batch tests.
@@ -0,0 +1,199 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/idesyatov/ssl-watch/internal/cert"
+	"github.com/idesyatov/ssl-watch/internal/flags"
+)
+
+// The known-certs actions.
+const (
+	knownList   = "list"
+	knownAccept = "accept"
+	knownRevoke = "revoke"
+)
+
+// knownStore is the -known-certs file for one check run: the pins it holds are
+// enforced through the -pin path, and the public key of every target it does not
+// know yet is recorded on first contact. It is safe for concurrent use.
+type knownStore struct {
+	path string
+
+	mu    sync.Mutex
+	known cert.KnownCerts
+	own   map[string]bool // host:port of -config targets with their own pins, left alone
+	added bool
+}
+
+// openKnown loads the -known-certs file at path (missing = empty).
+func openKnown(path string) (*knownStore, error) {
+	known, err := cert.LoadKnownCerts(path)
+	if err != nil {
+		return nil, err
+	}
+	return &knownStore{path: path, known: known, own: make(map[string]bool)}, nil
+}
+
+// enforce gives every target the file knows its recorded pins, so a mismatch
+// shows as a failed pin: exit 3 for text, JSON, Prometheus and CSV, CRITICAL
+// under -output nagios. A -config target with pins of its own keeps them and
+// stays out of the file.
+func (s *knownStore) enforce(targets []target, shared cert.PrintOptions) {
+	for i := range targets {
+		t := &targets[i]
+		po := t.printOptions(shared)
+		key := net.JoinHostPort(t.host, t.port)
+		if len(po.Pins) > 0 {
+			s.own[key] = true
+			continue
+		}
+		if pins, ok := s.known[key]; ok {
+			po.Pins = pins
+			t.print = &po
+		}
+	}
+}
+
+// observe records the public key of a target the file does not know yet.
+func (s *knownStore) observe(key string, info *cert.CertInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.known[key]; ok || s.own[key] {
+		return
+	}
+	s.known[key] = []string{cert.SPKIFingerprint(info.Cert)}
+	s.added = true
+	fmt.Fprintf(os.Stderr, "Recorded %s in %s (first use)\n", s.known.Line(key), s.path)
+}
+
+// save writes the file back when this run recorded a new target.
+func (s *knownStore) save() error {
+	if !s.added {
+		return nil
+	}
+	return cert.SaveKnownCerts(s.path, s.known)
+}
+
+// knownFetcher is a CertificateFetcher that records, on first contact, the
+// public key of every host:port the -known-certs file does not know yet.
+type knownFetcher struct {
+	cert.CertificateFetcher
+	store *knownStore
+}
+
+// Fetch fetches through the wrapped fetcher and records the certificate.
+func (f knownFetcher) Fetch(domain, port, ipaddr string, opts cert.FetchOptions) (*cert.CertInfo, error) {
+	info, err := f.CertificateFetcher.Fetch(domain, port, ipaddr, opts)
+	if err == nil {
+		f.store.observe(net.JoinHostPort(domain, port), info)
+	}
+	return info, err
+}
+
+// parseBackupPins parses -backup-pin, a comma-separated list of sha256 pins,
+// into normalized hex.
+func parseBackupPins(s string) ([]string, error) {
+	var pins []string
+	for _, p := range splitList(s) {
+		hex, err := cert.NormalizePin(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -backup-pin %q: %v", p, err)
+		}
+		pins = append(pins, hex)
+	}
+	return pins, nil
+}
+
+// knownEntry is the JSON view of one -known-certs entry.
+type knownEntry struct {
+	Target string   `json:"target"`
+	Pins   []string `json:"pins"` // "sha256:<hex>", the recorded key first
+}
+
+// runKnownCerts runs a known-certs action on the -known-certs file: list prints
+// its entries, accept fetches every target and records its current public key
+// (plus -backup-pin) over any previous entry, and revoke removes the targets'
+// entries. It returns 1 if a target could not be fetched or was not in the
+// file, otherwise 0.
+func runKnownCerts(fetcher cert.CertificateFetcher, targets []target, cfg flags.Config, fetchOpts cert.FetchOptions) int {
+	known, err := cert.LoadKnownCerts(cfg.KnownCerts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	if cfg.Action == knownList {
+		return listKnown(known, cfg.Output == "json")
+	}
+
+	code := exitOK
+	switch cfg.Action {
+	case knownAccept:
+		backups, _ := parseBackupPins(cfg.BackupPins) // validated
+		for _, r := range fetchAll(fetcher, targets, cfg.IPAddr, fetchOpts, cfg.Concurrency) {
+			key := net.JoinHostPort(r.target.host, r.target.port)
+			if r.err != nil {
+				fmt.Fprintf(os.Stderr, "Error retrieving certificate for %s: %v\n", r.target.label(), r.err)
+				code = exitError
+				continue
+			}
+			pins := []string{cert.SPKIFingerprint(r.info.Cert)}
+			for _, b := range backups {
+				if !slices.Contains(pins, b) {
+					pins = append(pins, b)
+				}
+			}
+			known[key] = pins
+			fmt.Printf("Accepted %s\n", known.Line(key))
+		}
+	case knownRevoke:
+		for _, t := range targets {
+			key := net.JoinHostPort(t.host, t.port)
+			if _, ok := known[key]; !ok {
+				fmt.Fprintf(os.Stderr, "Error: %s is not in %s\n", key, cfg.KnownCerts)
+				code = exitError
+				continue
+			}
+			delete(known, key)
+			fmt.Printf("Revoked %s\n", key)
+		}
+	}
+	if err := cert.SaveKnownCerts(cfg.KnownCerts, known); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	return code
+}
+
+// listKnown prints the entries of a -known-certs file in target order: one line
+// each as in the file, or a JSON array of knownEntry.
+func listKnown(known cert.KnownCerts, asJSON bool) int {
+	targets := slices.Sorted(maps.Keys(known))
+	if !asJSON {
+		for _, t := range targets {
+			fmt.Println(known.Line(t))
+		}
+		return exitOK
+	}
+	entries := make([]knownEntry, 0, len(targets))
+	for _, t := range targets {
+		e := knownEntry{Target: t}
+		for _, pin := range known[t] {
+			e.Pins = append(e.Pins, "sha256:"+pin)
+		}
+		entries = append(entries, e)
+	}
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to encode JSON: %v\n", err)
+		return exitError
+	}
+	fmt.Println(string(b))
+	return exitOK
+}
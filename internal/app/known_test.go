@@ -0,0 +1,70 @@
+package app
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/idesyatov/ssl-watch/internal/cert"
+)
+
+// TestRun_KnownCerts walks a -known-certs file through first use, a key
+// mismatch, accept with a backup pin, a rollover to the backup key, list and
+// revoke.
+func TestRun_KnownCerts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_certs")
+	a, b := realCertInfo(t, "a.example", 60), realCertInfo(t, "b.example", 60)
+	fetcher := &fakeFetcher{infos: map[string]*cert.CertInfo{"a.example": a, "b.example": b}}
+	check := []string{"-domain", "a.example,b.example", "-known-certs", path}
+
+	if code, _ := runArgs(t, check, fetcher, &fakeLoader{}); code != exitOK {
+		t.Fatalf("first use: expected exit %d, got %d", exitOK, code)
+	}
+	known, err := cert.LoadKnownCerts(path)
+	if err != nil || len(known) != 2 || known["a.example:443"][0] != cert.SPKIFingerprint(a.Cert) {
+		t.Fatalf("after first use: got %v, %v", known, err)
+	}
+
+	// A new key for a.example is a pin mismatch, for one target as for a batch.
+	fetcher.infos["a.example"] = realCertInfo(t, "a.example", 90)
+	if code, _ := runArgs(t, check, fetcher, &fakeLoader{}); code != exitMismatch {
+		t.Errorf("new key: expected exit %d, got %d", exitMismatch, code)
+	}
+	if code, _ := runArgs(t, append(check, "-output", "csv"), fetcher, &fakeLoader{}); code != exitMismatch {
+		t.Errorf("new key, csv: expected exit %d, got %d", exitMismatch, code)
+	}
+	code, out := runArgs(t, []string{"-domain", "a.example", "-known-certs", path}, fetcher, &fakeLoader{})
+	if code != exitMismatch || !strings.Contains(out, "MISMATCH") {
+		t.Errorf("new key, single target: expected exit %d and a pin mismatch, got %d: %s", exitMismatch, code, out)
+	}
+
+	// Accepting the new key with a backup for the next rollover.
+	next := realCertInfo(t, "a.example", 120)
+	backup := "sha256:" + cert.SPKIFingerprint(next.Cert)
+	code, out = runArgs(t, []string{"known-certs", "accept", "-known-certs", path, "-domain", "a.example", "-backup-pin", backup}, fetcher, &fakeLoader{})
+	if code != exitOK || !strings.Contains(out, "Accepted a.example:443 sha256:"+cert.SPKIFingerprint(fetcher.infos["a.example"].Cert)+" "+backup) {
+		t.Errorf("accept: code %d, output %s", code, out)
+	}
+	fetcher.infos["a.example"] = next
+	if code, _ := runArgs(t, check, fetcher, &fakeLoader{}); code != exitOK {
+		t.Errorf("rollover to the backup key: expected exit %d, got %d", exitOK, code)
+	}
+
+	code, out = runArgs(t, []string{"known-certs", "list", "-known-certs", path, "-output", "json"}, fetcher, &fakeLoader{})
+	var entries []knownEntry
+	if err := json.Unmarshal([]byte(out), &entries); err != nil || code != exitOK || len(entries) != 2 || len(entries[0].Pins) != 2 {
+		t.Errorf("list: code %d, %v in %s", code, err, out)
+	}
+
+	if code, _ := runArgs(t, []string{"known-certs", "revoke", "-known-certs", path, "-domain", "b.example"}, fetcher, &fakeLoader{}); code != exitOK {
+		t.Errorf("revoke: expected exit %d, got %d", exitOK, code)
+	}
+	if code, _ := runArgs(t, []string{"known-certs", "revoke", "-known-certs", path, "-domain", "b.example"}, fetcher, &fakeLoader{}); code != exitError {
+		t.Errorf("revoke of an unknown target: expected exit %d, got %d", exitError, code)
+	}
+	code, out = runArgs(t, []string{"known-certs", "list", "-known-certs", path}, fetcher, &fakeLoader{})
+	if code != exitOK || strings.Count(out, "\n") != 1 || !strings.HasPrefix(out, "a.example:443 ") {
+		t.Errorf("list after revoke: code %d, output %q", code, out)
+	}
+}
@@ -19,7 +19,7 @@ func TestRunPrometheus(t *testing.T) {
 
 	var code int
 	out := captureStdout(t, func() {
-		code = runPrometheus(fetcher, targets, flags.Config{Output: "prometheus", Concurrency: 1}, cert.FetchOptions{}, "")
+		code = runPrometheus(fetcher, targets, flags.Config{Output: "prometheus", Concurrency: 1}, cert.PrintOptions{}, cert.FetchOptions{})
 	})
 	if code != exitError {
 		t.Errorf("a failed domain should yield %d, got %d", exitError, code)
@@ -39,7 +39,7 @@ func TestRunCSV(t *testing.T) {
 
 	var code int
 	out := captureStdout(t, func() {
-		code = runCSV(fetcher, targets, flags.Config{Output: "csv", Threshold: 30, Concurrency: 1}, cert.FetchOptions{})
+		code = runCSV(fetcher, targets, flags.Config{Output: "csv", Threshold: "30", Concurrency: 1}, cert.PrintOptions{Threshold: cert.ThresholdDays(30)}, cert.FetchOptions{})
 	})
 	if code != exitSoft {
 		t.Errorf("a cert within threshold should yield %d, got %d", exitSoft, code)
@@ -47,6 +47,14 @@ func TestRunCSV(t *testing.T) {
 	if !strings.Contains(out, "domain,common_name") {
 		t.Errorf("expected CSV header, got:\n%s", out)
 	}
+
+	cfg := flags.Config{Output: "csv", Threshold: "30", CriticalThreshold: "7", Concurrency: 1}
+	captureStdout(t, func() {
+		code = runCSV(fetcher, targets, cfg, cert.PrintOptions{Threshold: cert.ThresholdDays(30), CriticalThreshold: cert.ThresholdDays(7)}, cert.FetchOptions{})
+	})
+	if code != exitCritical {
+		t.Errorf("a cert within the critical threshold should yield %d, got %d", exitCritical, code)
+	}
 }
 
 // TestRunNagios covers the nagios wrapper and its Nagios exit code.
@@ -65,3 +73,44 @@ func TestRunNagios(t *testing.T) {
 		t.Errorf("expected a Nagios CRITICAL line, got:\n%s", out)
 	}
 }
+
+// TestRunReports_Expectations checks that prometheus and csv exit 3 for every
+// failed expectation a batch exits 3 for: the issuer, DANE, CAA and the CNAME.
+func TestRunReports_Expectations(t *testing.T) {
+	issuer := leafInfo("issuer.example", 90)
+	dane := leafInfo("dane.example", 90)
+	dane.DANE = &cert.DANEResult{Secure: true, Records: []cert.TLSARecord{{Usage: 3, Selector: 1, MatchingType: 1}}}
+	caa := leafInfo("caa.example", 90)
+	caa.CAA = &cert.CAAResult{Domain: "caa.example", Property: "issue", Records: []cert.CAARecord{{Tag: "issue", Value: "ca.example"}}}
+	cname := leafInfo("cname.example", 90)
+	cname.DNS = &cert.DNSInfo{Expected: "cdn.example.net", Final: "origin.example"}
+	fetcher := &fakeFetcher{infos: map[string]*cert.CertInfo{
+		"issuer.example": issuer, "dane.example": dane, "caa.example": caa, "cname.example": cname,
+	}}
+
+	for _, tt := range []struct {
+		domain string
+		opts   cert.PrintOptions
+	}{
+		{"issuer.example", cert.PrintOptions{ExpectIssuer: "Some Other CA"}},
+		{"dane.example", cert.PrintOptions{}},
+		{"caa.example", cert.PrintOptions{}},
+		{"cname.example", cert.PrintOptions{}},
+	} {
+		targets := hostTargets(tt.domain)
+		for _, output := range []string{"prometheus", "csv"} {
+			cfg := flags.Config{Output: output, Concurrency: 1}
+			var code int
+			captureStdout(t, func() {
+				if output == "csv" {
+					code = runCSV(fetcher, targets, cfg, tt.opts, cert.FetchOptions{})
+				} else {
+					code = runPrometheus(fetcher, targets, cfg, tt.opts, cert.FetchOptions{})
+				}
+			})
+			if code != exitMismatch {
+				t.Errorf("%s, %s: expected exit %d, got %d", tt.domain, output, exitMismatch, code)
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/idesyatov/ssl-watch/internal/cert"
+	"github.com/idesyatov/ssl-watch/internal/flags"
+)
+
+// metricsCache holds the Prometheus exposition rendered by the last completed
+// check cycle, so a scrape is answered from memory and never waits on a live
+// TLS handshake. It is safe for concurrent use.
+type metricsCache struct {
+	mu      sync.RWMutex
+	body    []byte
+	updated time.Time // zero until the first cycle completes
+}
+
+// store replaces the cached exposition with the result of a finished cycle.
+func (c *metricsCache) store(body []byte, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.body = body
+	c.updated = at
+}
+
+// load returns the cached exposition and when it was produced.
+func (c *metricsCache) load() ([]byte, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.body, c.updated
+}
+
+// refreshMetrics runs one check cycle over every target (respecting
+// -concurrency) and stores the rendered exposition in the cache.
+func refreshMetrics(cache *metricsCache, fetcher cert.CertificateFetcher, targets []target, cfg flags.Config, opts cert.PrintOptions, fetchOpts cert.FetchOptions) {
+	samples, _ := collectSamples(fetcher, targets, cfg, opts, fetchOpts)
+	var buf bytes.Buffer
+	cert.WritePrometheus(&buf, samples, opts)
+	cache.store(buf.Bytes(), time.Now())
+}
+
+// checkLoop refreshes the cache immediately and then every interval until ctx
+// is cancelled. A cycle that overruns the interval delays the next one rather
+// than overlapping it.
+func checkLoop(ctx context.Context, interval time.Duration, refresh func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		refresh()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeHandler answers a blackbox-exporter style /probe request: the target is
+// taken from the query string (?target=host[:port]&starttls=proto&servername=sni),
+// fetched live, and reported in the WritePrometheus families plus the probe
+// duration. A failed fetch is still a 200 with ssl_cert_up 0; only a malformed
+// request is a 400.
+func probeHandler(fetcher cert.CertificateFetcher, cfg flags.Config, opts cert.PrintOptions, fetchOpts cert.FetchOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		raw := q.Get("target")
+		if raw == "" {
+			http.Error(w, "missing target parameter", http.StatusBadRequest)
+			return
+		}
+		probeCfg := cfg
+		if proto := q.Get("starttls"); proto != "" {
+			if _, ok := starttlsPorts[proto]; !ok {
+				http.Error(w, fmt.Sprintf("unknown starttls protocol %q", proto), http.StatusBadRequest)
+				return
+			}
+			probeCfg.StartTLS = proto
+		}
+		t, err := parseTarget(raw, effectiveDefaultPort(probeCfg))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		probeOpts := fetchOpts
+		probeOpts.StartTLS = probeCfg.StartTLS
+		if sni := q.Get("servername"); sni != "" {
+			probeOpts.ServerName = sni
+		}
+		start := time.Now()
+		info, err := fetcher.Fetch(t.host, t.port, "", probeOpts)
+		took := time.Since(start)
+
+		var buf bytes.Buffer
+		cert.WriteProbe(&buf, cert.PromSample{Domain: t.label(), Info: info, Err: err}, opts, took)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// serveMux builds the exporter's routes: /metrics serves the cached exposition
+// (503 until the first cycle completes), /probe checks the target named in the
+// query string on demand, and /healthz reports liveness.
+func serveMux(cache *metricsCache, probe http.Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/probe", probe)
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		body, updated := cache.load()
+		if updated.IsZero() {
+			http.Error(w, "first check cycle has not completed yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write(body)
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
+
+// runServe keeps running as a Prometheus exporter: it re-checks every target
+// each -interval in the background and serves the latest results on -listen
+// until interrupted (SIGINT/SIGTERM). Without configured targets it serves only
+// on-demand /probe requests. It returns 0 on a clean shutdown and 1 when the
+// listener cannot be opened or the server fails.
+func runServe(fetcher cert.CertificateFetcher, targets []target, cfg flags.Config, opts cert.PrintOptions, fetchOpts cert.FetchOptions) int {
+	ln, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to listen on %s: %v\n", cfg.Listen, err)
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cache := &metricsCache{}
+	interval := time.Duration(cfg.Interval) * time.Second
+	go checkLoop(ctx, interval, func() {
+		refreshMetrics(cache, fetcher, targets, cfg, opts, fetchOpts)
+	})
+
+	probe := probeHandler(fetcher, cfg, opts, fetchOpts)
+	srv := &http.Server{Handler: serveMux(cache, probe), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving metrics on http://%s/metrics (%d target(s), every %s)\n", ln.Addr(), len(targets), interval)
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
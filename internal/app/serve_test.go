@@ -0,0 +1,141 @@
+package app
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/idesyatov/ssl-watch/internal/cert"
+	"github.com/idesyatov/ssl-watch/internal/flags"
+)
+
+// TestServeMux verifies /metrics answers 503 until the first cycle completes and
+// then serves the cached exposition, and that /healthz always reports ok.
+func TestServeMux(t *testing.T) {
+	fetcher := &fakeFetcher{
+		infos: map[string]*cert.CertInfo{"a.example": leafInfo("a.example", 90)},
+		errs:  map[string]error{"bad.example": io.ErrUnexpectedEOF},
+	}
+	cache := &metricsCache{}
+	mux := serveMux(cache, http.NotFoundHandler())
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get("/metrics"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("before the first cycle /metrics should be 503, got %d", rec.Code)
+	}
+	if rec := get("/healthz"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "ok") {
+		t.Errorf("/healthz: code=%d body=%q", rec.Code, rec.Body.String())
+	}
+
+	cfg := flags.Config{Output: "prometheus", Concurrency: 2}
+	refreshMetrics(cache, fetcher, hostTargets("a.example", "bad.example"), cfg, cert.PrintOptions{}, cert.FetchOptions{})
+
+	rec := get("/metrics")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("after a cycle /metrics should be 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	for _, want := range []string{`ssl_cert_up{domain="a.example"} 1`, `ssl_cert_up{domain="bad.example"} 0`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, rec.Body.String())
+		}
+	}
+}
+
+// TestCheckLoop verifies the first cycle runs immediately, later cycles follow
+// the interval, and the loop exits when the context is cancelled.
+func TestCheckLoop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var cycles atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		checkLoop(ctx, 10*time.Millisecond, func() { cycles.Add(1) })
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for cycles.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("checkLoop did not stop after cancellation")
+	}
+	if n := cycles.Load(); n < 3 {
+		t.Errorf("expected at least 3 cycles, got %d", n)
+	}
+}
+
+// TestProbeHandler verifies /probe fetches the target from the query string with
+// the per-request port, STARTTLS protocol and SNI, reports a failed fetch as
+// ssl_cert_up 0, and rejects malformed requests with a 400.
+func TestProbeHandler(t *testing.T) {
+	fetcher := &recordingFetcher{fakeFetcher: fakeFetcher{
+		infos: map[string]*cert.CertInfo{"a.example": leafInfo("a.example", 90)},
+		errs:  map[string]error{"bad.example": io.ErrUnexpectedEOF},
+	}}
+	cfg := flags.Config{Port: "443", Output: "prometheus"}
+	h := probeHandler(fetcher, cfg, cert.PrintOptions{}, cert.FetchOptions{Timeout: time.Second})
+
+	probe := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/probe?"+query, nil))
+		return rec
+	}
+
+	rec := probe("target=a.example:8443&servername=sni.example")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("probe: expected 200, got %d", rec.Code)
+	}
+	for _, want := range []string{`ssl_cert_up{domain="a.example:8443"} 1`, "ssl_probe_duration_seconds"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("probe output missing %q:\n%s", want, rec.Body.String())
+		}
+	}
+	if fetcher.port != "8443" || fetcher.opts.ServerName != "sni.example" {
+		t.Errorf("expected port 8443 and SNI override, got port=%q sni=%q", fetcher.port, fetcher.opts.ServerName)
+	}
+
+	// STARTTLS without a port uses the protocol's default port.
+	probe("target=a.example&starttls=smtp")
+	if fetcher.port != "587" || fetcher.opts.StartTLS != "smtp" {
+		t.Errorf("expected smtp on 587, got port=%q starttls=%q", fetcher.port, fetcher.opts.StartTLS)
+	}
+
+	if rec := probe("target=bad.example"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `ssl_cert_up{domain="bad.example"} 0`) {
+		t.Errorf("failed fetch: code=%d body=%q", rec.Code, rec.Body.String())
+	}
+
+	for _, q := range []string{"", "target=a.example&starttls=gopher", "target=a.example:99999"} {
+		if rec := probe(q); rec.Code != http.StatusBadRequest {
+			t.Errorf("query %q: expected 400, got %d", q, rec.Code)
+		}
+	}
+}
+
+// recordingFetcher is a fakeFetcher that remembers the last port and options
+// it was asked to fetch with.
+type recordingFetcher struct {
+	fakeFetcher
+	port string
+	opts cert.FetchOptions
+}
+
+func (f *recordingFetcher) Fetch(domain, port, ipaddr string, opts cert.FetchOptions) (*cert.CertInfo, error) {
+	f.port, f.opts = port, opts
+	return f.fakeFetcher.Fetch(domain, port, ipaddr, opts)
+}
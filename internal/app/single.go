@@ -5,26 +5,64 @@ import (
 	"github.com/idesyatov/ssl-watch/internal/flags"
 )
 
-// printSingle prints one certificate and returns the process exit code: 3 when an
-// explicit expectation (a pin or the issuer) fails, 2 for a soft problem (a
-// warning under -strict, or expiry within -threshold), otherwise 0.
+// printSingle prints one certificate and returns the process exit code: the
+// more severe of singleExit and, with -state, the code of its changes since the
+// last run (see -state-exit).
 func printSingle(printer cert.CertificatePrinter, info *cert.CertInfo, cfg flags.Config, opts cert.PrintOptions) int {
 	printer.Print(info, opts)
+	codes, _ := parseStateExit(cfg.StateExit) // validated
+	return worseExit(singleExit(info, cfg, opts), stateExit(info.State, codes))
+}
+
+// singleExit is the exit code for one certificate: 4 when it is revoked, 3 when
+// an explicit expectation (a pin, the issuer, DANE, CAA or the CNAME) fails, 5
+// for expiry within -critical-threshold, 2 for a soft problem (a warning under
+// -strict, or expiry within -threshold), otherwise 0.
+func singleExit(info *cert.CertInfo, cfg flags.Config, opts cert.PrintOptions) int {
+	// A revoked certificate must not be trusted at all, whatever else holds.
+	if info.RevokedBy() != nil {
+		return exitRevoked
+	}
 	// Exit code 3 when an explicit expectation about the served certificate fails
-	// (a pinned fingerprint or the issuer) — a wrong cert is more urgent than an
-	// upcoming expiry, so it takes precedence.
-	if opts.Pin != "" && !cert.MatchesPin(info.Cert, opts.Pin) {
+	// — a wrong cert is more urgent than an upcoming expiry, so it takes precedence.
+	if expectationFailed(info, opts) {
 		return exitMismatch
 	}
-	if cfg.ExpectIssuer != "" && !cert.IssuerMatches(info.Cert, cfg.ExpectIssuer) {
-		return exitMismatch
+	// Exit code 5 when any certificate in the chain expires within
+	// -critical-threshold, then 2 for soft problems: with -strict any warning
+	// fails, and any certificate expiring within -threshold fails.
+	expiry := expiryExit(info, opts.Threshold, opts.CriticalThreshold)
+	if expiry == exitCritical {
+		return exitCritical
 	}
-	// Exit code 2 for soft problems: with -strict any warning fails, and any
-	// certificate in the chain expiring within -threshold fails.
 	if cfg.Strict && cert.HasWarnings(info) {
 		return exitSoft
 	}
-	if cfg.Threshold > 0 && info.MinDaysUntilExpiry() < cfg.Threshold {
+	return expiry
+}
+
+// expectationFailed reports whether an explicit expectation about info fails:
+// a pin (-pin, -known-certs or the target's own), the issuer (-expect-issuer),
+// DANE, CAA or the CNAME chain (-expect-cname). Every output format exits 3 on
+// it, except Nagios, which reports CRITICAL.
+func expectationFailed(info *cert.CertInfo, opts cert.PrintOptions) bool {
+	switch {
+	case len(opts.Pins) > 0 && !cert.MatchesAnyPin(info.Cert, opts.Pins):
+		return true
+	case opts.ExpectIssuer != "" && !cert.IssuerMatches(info.Cert, opts.ExpectIssuer):
+		return true
+	}
+	return info.DANE.Mismatch() || info.CAA.Unauthorized() || info.DNS.Mismatch()
+}
+
+// expiryExit is the exit code for info's certificates against the two
+// thresholds: 5 once any has reached critical, 2 once any has reached
+// threshold, otherwise 0. A zero threshold is disabled.
+func expiryExit(info *cert.CertInfo, threshold, critical cert.Threshold) int {
+	switch {
+	case info.Expiring(critical):
+		return exitCritical
+	case info.Expiring(threshold):
 		return exitSoft
 	}
 	return exitOK
@@ -2,13 +2,14 @@ package app
 
 import (
 	"testing"
+	"time"
 
 	"github.com/idesyatov/ssl-watch/internal/cert"
 	"github.com/idesyatov/ssl-watch/internal/flags"
 )
 
-// TestPrintSingle covers the exit-code branches: OK, threshold, pin mismatch,
-// issuer mismatch and a strict warning.
+// TestPrintSingle covers the exit-code branches: OK, threshold, critical
+// threshold, pin mismatch, issuer mismatch and a strict warning.
 func TestPrintSingle(t *testing.T) {
 	info := realCertInfo(t, "x.example", 90)
 	printer := &cert.CertificatePrinterImpl{}
@@ -22,15 +23,35 @@ func TestPrintSingle(t *testing.T) {
 	if code := run(flags.Config{}, cert.PrintOptions{}); code != exitOK {
 		t.Errorf("healthy cert: expected %d, got %d", exitOK, code)
 	}
-	if code := run(flags.Config{Threshold: 120}, cert.PrintOptions{Threshold: 120}); code != exitSoft {
+	if code := run(flags.Config{Threshold: "120"}, cert.PrintOptions{Threshold: cert.ThresholdDays(120)}); code != exitSoft {
 		t.Errorf("expiry within threshold: expected %d, got %d", exitSoft, code)
 	}
-	if code := run(flags.Config{}, cert.PrintOptions{Pin: "00deadbeef"}); code != exitMismatch {
+	if code := run(flags.Config{Threshold: "120", CriticalThreshold: "100"}, cert.PrintOptions{Threshold: cert.ThresholdDays(120), CriticalThreshold: cert.ThresholdDays(100)}); code != exitCritical {
+		t.Errorf("expiry within critical threshold: expected %d, got %d", exitCritical, code)
+	}
+	if code := run(flags.Config{Threshold: "120", CriticalThreshold: "30"}, cert.PrintOptions{Threshold: cert.ThresholdDays(120), CriticalThreshold: cert.ThresholdDays(30)}); code != exitSoft {
+		t.Errorf("expiry within threshold only: expected %d, got %d", exitSoft, code)
+	}
+	// Nearly all of the 90-day lifetime is left, well above a 50% share.
+	if code := run(flags.Config{}, cert.PrintOptions{Threshold: cert.Threshold{Percent: 50}, CriticalThreshold: cert.Threshold{Remaining: 36 * time.Hour}}); code != exitOK {
+		t.Errorf("fresh cert against a percentage: expected %d, got %d", exitOK, code)
+	}
+	if code := run(flags.Config{}, cert.PrintOptions{Pins: []string{"00deadbeef"}}); code != exitMismatch {
 		t.Errorf("pin mismatch: expected %d, got %d", exitMismatch, code)
 	}
-	if code := run(flags.Config{ExpectIssuer: "Some Other CA"}, cert.PrintOptions{}); code != exitMismatch {
+	if code := run(flags.Config{ExpectIssuer: "Some Other CA"}, cert.PrintOptions{ExpectIssuer: "Some Other CA"}); code != exitMismatch {
 		t.Errorf("issuer mismatch: expected %d, got %d", exitMismatch, code)
 	}
+	// A revoked certificate outranks every other verdict.
+	revoked := realCertInfo(t, "revoked.example", 90)
+	revoked.Revocation = &cert.Revocation{Source: "ocsp", Status: cert.RevocationRevoked}
+	var rcode int
+	captureStdout(t, func() {
+		rcode = printSingle(printer, revoked, flags.Config{}, cert.PrintOptions{Pins: []string{"00deadbeef"}})
+	})
+	if rcode != exitRevoked {
+		t.Errorf("revoked: expected %d, got %d", exitRevoked, rcode)
+	}
 	// A not-yet-valid certificate trips a warning, so -strict makes it a soft fail.
 	nyv := futureCertInfo(t, "future.example")
 	var scode int
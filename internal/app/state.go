@@ -0,0 +1,117 @@
+package app
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/idesyatov/ssl-watch/internal/cert"
+)
+
+// defaultStateExit is the exit code of each -state change kind unless
+// -state-exit says otherwise: a renewal, a new key or new intermediates are
+// routine, a new issuer deserves a look, and an older certificate coming back
+// is treated like a failed expectation.
+var defaultStateExit = map[string]int{
+	cert.ChangeRenewed:    exitOK,
+	cert.ChangeDowngrade:  exitMismatch,
+	cert.ChangeKeyRotated: exitOK,
+	cert.ChangeIssuer:     exitSoft,
+	cert.ChangeChain:      exitOK,
+}
+
+// parseStateExit parses -state-exit, a comma-separated list of kind=code
+// pairs, over defaultStateExit. A code is one the tool already exits with for
+// a check result: 0, 2, 3 or 5.
+func parseStateExit(s string) (map[string]int, error) {
+	codes := maps.Clone(defaultStateExit)
+	for _, pair := range splitList(s) {
+		kind, value, ok := strings.Cut(pair, "=")
+		if _, known := codes[kind]; !ok || !known {
+			return nil, fmt.Errorf("invalid -state-exit entry %q (expected kind=code, kind one of %s)", pair, strings.Join(cert.ChangeKinds, ", "))
+		}
+		code, err := strconv.Atoi(value)
+		if err != nil || (code != exitOK && code != exitSoft && code != exitMismatch && code != exitCritical) {
+			return nil, fmt.Errorf("invalid -state-exit code %q for %s (expected 0, 2, 3 or 5)", value, kind)
+		}
+		codes[kind] = code
+	}
+	return codes, nil
+}
+
+// stateExit is the exit code for a target's changes since the last run: the
+// most severe of their codes, 0 when nothing changed or without -state.
+func stateExit(s *cert.StateChange, codes map[string]int) int {
+	code := exitOK
+	if s == nil {
+		return code
+	}
+	for _, c := range s.Changes {
+		code = worseExit(code, codes[c])
+	}
+	return code
+}
+
+// stateTracker is the -state file for one run: the records the previous run
+// left, compared with every certificate fetched now, and the new records to
+// save once the run is done. It is safe for concurrent use.
+type stateTracker struct {
+	path string
+	prev map[string]cert.CertState
+
+	mu   sync.Mutex
+	seen map[string]cert.CertState
+}
+
+// openState loads the -state file at path (missing = empty).
+func openState(path string) (*stateTracker, error) {
+	prev, err := cert.LoadState(path)
+	if err != nil {
+		return nil, err
+	}
+	return &stateTracker{path: path, prev: prev, seen: make(map[string]cert.CertState)}, nil
+}
+
+// observe compares info with the record for key, attaches the result to info
+// and keeps info as the record for the next run.
+func (s *stateTracker) observe(key string, info *cert.CertInfo) {
+	cur := cert.NewCertState(info, time.Now())
+	var prev *cert.CertState
+	if p, ok := s.prev[key]; ok {
+		prev = &p
+	}
+	info.State = cert.CompareState(prev, cur)
+	s.mu.Lock()
+	s.seen[key] = cur
+	s.mu.Unlock()
+}
+
+// save writes the records of this run over the previous ones; a target that
+// was not checked, or could not be retrieved, keeps its old record.
+func (s *stateTracker) save() error {
+	out := maps.Clone(s.prev)
+	s.mu.Lock()
+	maps.Copy(out, s.seen)
+	s.mu.Unlock()
+	return cert.SaveState(s.path, out)
+}
+
+// stateFetcher is a CertificateFetcher that compares every certificate it
+// returns with the -state record of its host:port.
+type stateFetcher struct {
+	cert.CertificateFetcher
+	tracker *stateTracker
+}
+
+// Fetch fetches through the wrapped fetcher and records the certificate.
+func (f stateFetcher) Fetch(domain, port, ipaddr string, opts cert.FetchOptions) (*cert.CertInfo, error) {
+	info, err := f.CertificateFetcher.Fetch(domain, port, ipaddr, opts)
+	if err == nil {
+		f.tracker.observe(net.JoinHostPort(domain, port), info)
+	}
+	return info, err
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/idesyatov/ssl-watch/internal/cert"
+)
+
+func TestParseStateExit(t *testing.T) {
+	codes, err := parseStateExit("")
+	if err != nil || codes[cert.ChangeDowngrade] != exitMismatch || codes[cert.ChangeRenewed] != exitOK {
+		t.Errorf("defaults: got %v, %v", codes, err)
+	}
+	codes, err = parseStateExit("renewed=2, key_rotated=5")
+	if err != nil || codes[cert.ChangeRenewed] != exitSoft || codes[cert.ChangeKeyRotated] != exitCritical || codes[cert.ChangeIssuer] != exitSoft {
+		t.Errorf("overrides: got %v, %v", codes, err)
+	}
+	for _, in := range []string{"renewed", "expired=2", "renewed=1", "renewed=x"} {
+		if _, err := parseStateExit(in); err == nil {
+			t.Errorf("parseStateExit(%q): expected an error", in)
+		}
+	}
+
+	s := &cert.StateChange{Previous: &cert.CertState{}, Changes: []string{cert.ChangeRenewed, cert.ChangeIssuer}}
+	if got := stateExit(s, defaultStateExit); got != exitSoft {
+		t.Errorf("stateExit = %d, want %d", got, exitSoft)
+	}
+	if got := stateExit(nil, defaultStateExit); got != exitOK {
+		t.Errorf("stateExit(nil) = %d, want %d", got, exitOK)
+	}
+}
+
+// TestRun_State runs twice over the same -state file: the first run records
+// both targets, the second sees one renewed and one downgraded.
+func TestRun_State(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	fetcher := &fakeFetcher{infos: map[string]*cert.CertInfo{
+		"a.example": realCertInfo(t, "a.example", 60),
+		"b.example": realCertInfo(t, "b.example", 60),
+	}}
+	args := []string{"-domain", "a.example,b.example", "-state", path, "-output", "json"}
+	code, out := runArgs(t, args, fetcher, &fakeLoader{})
+	if code != exitOK || strings.Count(out, `"first_seen": true`) != 2 {
+		t.Fatalf("first run: code %d, output %s", code, out)
+	}
+	recorded, err := cert.LoadState(path)
+	if err != nil || len(recorded) != 2 {
+		t.Fatalf("state file after the first run: %v, %v", recorded, err)
+	}
+
+	fetcher.infos["a.example"] = realCertInfo(t, "a.example", 90)
+	fetcher.infos["b.example"] = realCertInfo(t, "b.example", 30)
+	code, out = runArgs(t, args, fetcher, &fakeLoader{})
+	if code != exitMismatch {
+		t.Errorf("second run: expected exit %d for the downgrade, got %d", exitMismatch, code)
+	}
+	var entries []struct {
+		Domain string `json:"domain"`
+		State  struct {
+			Changes []string `json:"changes"`
+		} `json:"state"`
+	}
+	if err := json.Unmarshal([]byte(out), &entries); err != nil || len(entries) != 2 {
+		t.Fatalf("second run: %v in %s", err, out)
+	}
+	want := [][]string{{"renewed", "key_rotated"}, {"downgrade", "key_rotated"}}
+	for i, e := range entries {
+		if !slices.Equal(e.State.Changes, want[i]) {
+			t.Errorf("%s: changes = %v, want %v", e.Domain, e.State.Changes, want[i])
+		}
+	}
+
+	// A single target, nothing changed since the second run; -state-exit
+	// applies to the text output too.
+	code, out = runArgs(t, []string{"-domain", "a.example", "-state", path}, fetcher, &fakeLoader{})
+	if code != exitOK || !strings.Contains(out, "State: unchanged since") {
+		t.Errorf("third run: code %d, output %s", code, out)
+	}
+	fetcher.infos["a.example"] = realCertInfo(t, "a.example", 120)
+	code, _ = runArgs(t, []string{"-domain", "a.example", "-state", path, "-state-exit", "renewed=2"}, fetcher, &fakeLoader{})
+	if code != exitSoft {
+		t.Errorf("renewal with renewed=2: expected exit %d, got %d", exitSoft, code)
+	}
+}
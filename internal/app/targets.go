@@ -7,9 +7,11 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/idesyatov/ssl-watch/internal/cert"
 	"github.com/idesyatov/ssl-watch/internal/flags"
 )
 
@@ -19,10 +21,30 @@ const defaultPort = "443"
 
 // starttlsPorts maps each supported STARTTLS protocol to its standard port.
 var starttlsPorts = map[string]string{
-	"smtp": "587",
-	"imap": "143",
-	"pop3": "110",
-	"ftp":  "21",
+	"smtp":        "587",
+	"imap":        "143",
+	"pop3":        "110",
+	"ftp":         "21",
+	"xmpp":        "5222",
+	"xmpp-server": "5269",
+	"ldap":        "389",
+	"postgres":    "5432",
+	"mysql":       "3306",
+	"lmtp":        "24",
+	"nntp":        "119",
+	"sieve":       "4190",
+	"irc":         "6667",
+	"mssql":       "1433",
+}
+
+// starttlsNames lists the supported STARTTLS protocols, sorted, for error messages.
+func starttlsNames() string {
+	names := make([]string, 0, len(starttlsPorts))
+	for p := range starttlsPorts {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
 }
 
 // effectiveDefaultPort is the port used for targets that do not carry their own:
@@ -41,9 +63,37 @@ func effectiveDefaultPort(cfg flags.Config) string {
 // target is a single check target: the hostname to connect to and verify against
 // (used for SNI) plus the port. The port comes from the target token itself
 // (host:port or a URL) or, for a bare host, from the default port.
+//
+// A target read from a -config file also carries its own settings: spec holds
+// them as written, and resolveCheckSettings turns them into ipaddr, fetch and
+// print. Targets from -domain/-domain-file leave these empty and use the shared
+// command-line options.
 type target struct {
 	host string
 	port string
+
+	spec   *checkSettings     // raw per-target settings from -config; nil otherwise
+	ipaddr string             // address to connect to instead of resolving host; empty = -ipaddr
+	fetch  *cert.FetchOptions // connection options; nil = the shared ones
+	print  *cert.PrintOptions // rendering and expectations; nil = the shared ones
+}
+
+// fetchOptions returns the connection options for t: its own (from -config) or
+// the shared ones.
+func (t target) fetchOptions(shared cert.FetchOptions) cert.FetchOptions {
+	if t.fetch != nil {
+		return *t.fetch
+	}
+	return shared
+}
+
+// printOptions returns the rendering options and expectations (pins, issuer,
+// threshold) for t: its own (from -config) or the shared ones.
+func (t target) printOptions(shared cert.PrintOptions) cert.PrintOptions {
+	if t.print != nil {
+		return *t.print
+	}
+	return shared
 }
 
 // label renders the target for output: the bare host on the standard HTTPS port,
@@ -103,7 +153,8 @@ func validatePort(p string) error {
 // comma-separated -domain flag and the -domain-file flag (one per line, "-"
 // reads stdin; blank lines and lines starting with "#" are ignored). defaultPort
 // is used for tokens that do not carry their own port. De-duplication is by the
-// resolved host:port pair, so "a.com" and "a.com:443" collapse to one.
+// resolved host:port pair, so "a.com" and "a.com:443" collapse to one. Targets
+// from a -config file follow, as listed (see configTargets).
 func resolveTargets(cfg flags.Config, defaultPort string) ([]target, error) {
 	var out []target
 	seen := make(map[string]bool)
@@ -143,6 +194,13 @@ func resolveTargets(cfg flags.Config, defaultPort string) ([]target, error) {
 	if firstErr != nil {
 		return nil, firstErr
 	}
+	if cfg.ConfigFile != "" {
+		fileTargets, err := configTargets(cfg)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, fileTargets...)
+	}
 	return out, nil
 }
 
@@ -173,3 +231,15 @@ func readDomainFile(path string) ([]string, error) {
 	}
 	return lines, nil
 }
+
+// splitList splits a comma-separated flag value, trimming blanks and dropping
+// empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -11,10 +11,12 @@ import (
 // TestEffectiveDefaultPort covers the -port / -starttls default-port resolution.
 func TestEffectiveDefaultPort(t *testing.T) {
 	cases := []struct{ port, starttls, want string }{
-		{"443", "", "443"},       // no starttls → -port
-		{"443", "smtp", "587"},   // default port + starttls → protocol port
-		{"8443", "smtp", "8443"}, // explicit port overrides the protocol default
-		{"443", "bogus", "443"},  // unknown protocol left for validate to reject
+		{"443", "", "443"},             // no starttls → -port
+		{"443", "smtp", "587"},         // default port + starttls → protocol port
+		{"443", "postgres", "5432"},    // binary protocols too
+		{"443", "xmpp-server", "5269"}, // server-to-server XMPP differs from client
+		{"8443", "smtp", "8443"},       // explicit port overrides the protocol default
+		{"443", "bogus", "443"},        // unknown protocol left for validate to reject
 	}
 	for _, c := range cases {
 		got := effectiveDefaultPort(flags.Config{Port: c.port, StartTLS: c.starttls})
@@ -76,7 +78,7 @@ func TestResolveTargets(t *testing.T) {
 	}
 
 	want := []target{
-		{"a.com", "443"}, {"b.com", "443"}, {"c.com", "443"}, {"d.com", "8443"},
+		{host: "a.com", port: "443"}, {host: "b.com", port: "443"}, {host: "c.com", port: "443"}, {host: "d.com", port: "8443"},
 	}
 	if len(got) != len(want) {
 		t.Fatalf("expected %d targets, got %d: %+v", len(want), len(got), got)
@@ -3,7 +3,11 @@ package app
 import (
 	"errors"
 	"fmt"
+	"net"
+	"strings"
+	"time"
 
+	"github.com/idesyatov/ssl-watch/internal/cert"
 	"github.com/idesyatov/ssl-watch/internal/flags"
 	"github.com/idesyatov/ssl-watch/internal/validation"
 )
@@ -11,13 +15,18 @@ import (
 // validate reports the first unsupported flag combination in cfg, or nil. It is
 // pure — no I/O and no process exit — so every guard is unit-testable.
 func validate(cfg flags.Config, targets []target) error {
-	// At least one target (a domain or a certificate file) must be specified.
+	// At least one target (a domain or a certificate file) must be specified,
+	// except in serve mode, which can run for on-demand /probe requests alone,
+	// and for known-certs list, which takes none.
 	domainArg := ""
 	if len(targets) > 0 {
 		domainArg = targets[0].host
 	}
-	if err := validation.NewDefaultInputValidator().Validate(domainArg, cfg.CertFile); err != nil {
-		return err
+	needsTarget := cfg.Command != flags.CommandServe && !(cfg.Command == flags.CommandKnownCerts && cfg.Action == knownList)
+	if needsTarget || cfg.CertFile != "" {
+		if err := validation.NewDefaultInputValidator().Validate(domainArg, cfg.CertFile); err != nil {
+			return err
+		}
 	}
 	if cfg.Output != "text" && cfg.Output != "json" && cfg.Output != "prometheus" && cfg.Output != "csv" && cfg.Output != "nagios" {
 		return fmt.Errorf("invalid -output %q (expected \"text\", \"json\", \"prometheus\", \"csv\" or \"nagios\")", cfg.Output)
@@ -28,6 +37,13 @@ func validate(cfg flags.Config, targets []target) error {
 	if cfg.Concurrency < 1 {
 		return fmt.Errorf("invalid -concurrency %d (expected a positive number)", cfg.Concurrency)
 	}
+	threshold, critical, err := parseThresholds(cfg)
+	if err != nil {
+		return err
+	}
+	if _, err := parseAt(cfg.At); err != nil {
+		return err
+	}
 	if cfg.IPAddr != "" && len(targets) > 1 {
 		return errors.New("-ipaddr cannot be combined with multiple domains")
 	}
@@ -41,8 +57,6 @@ func validate(cfg flags.Config, targets []target) error {
 			return errors.New("-all-ips cannot be combined with -short")
 		case cfg.ExpectIssuer != "" || cfg.Strict:
 			return errors.New("-all-ips cannot be combined with -expect-issuer/-strict")
-		case len(targets) != 1:
-			return errors.New("-all-ips requires exactly one domain")
 		}
 	}
 	if cfg.IPv4Only && cfg.IPv6Only {
@@ -54,6 +68,14 @@ func validate(cfg flags.Config, targets []target) error {
 	if cfg.CAFile != "" && cfg.Insecure {
 		return errors.New("-cafile cannot be combined with -insecure")
 	}
+	if cfg.AIAFetch {
+		switch {
+		case cfg.Insecure:
+			return errors.New("-aia-fetch cannot be combined with -insecure")
+		case cfg.CertFile != "":
+			return errors.New("-aia-fetch cannot be combined with -certfile")
+		}
+	}
 	if (cfg.CAFile != "" || cfg.ServerName != "") && cfg.CertFile != "" {
 		return errors.New("-cafile/-servername cannot be combined with -certfile")
 	}
@@ -66,6 +88,27 @@ func validate(cfg flags.Config, targets []target) error {
 	if cfg.Proxy != "" && cfg.CertFile != "" {
 		return errors.New("-proxy cannot be combined with -certfile")
 	}
+	if err := validateProxyProtocol(cfg); err != nil {
+		return err
+	}
+	if err := validateResolvers(cfg); err != nil {
+		return err
+	}
+	if cfg.ProxyFromEnv {
+		switch {
+		case cfg.Proxy != "":
+			return errors.New("-proxy-from-env cannot be combined with -proxy")
+		case cfg.CertFile != "":
+			return errors.New("-proxy-from-env cannot be combined with -certfile")
+		}
+	}
+	// -config targets may name their own https:// proxy, and the environment may.
+	if (cfg.ProxyCAFile != "" || cfg.ProxyInsecure) && cfg.Proxy == "" && !cfg.ProxyFromEnv && cfg.ConfigFile == "" {
+		return errors.New("-proxy-cafile/-proxy-insecure require -proxy")
+	}
+	if cfg.ProxyCAFile != "" && cfg.ProxyInsecure {
+		return errors.New("-proxy-cafile and -proxy-insecure cannot be combined")
+	}
 	if cfg.ServerName != "" && len(targets) > 1 {
 		return errors.New("-servername cannot be combined with multiple domains")
 	}
@@ -84,24 +127,340 @@ func validate(cfg flags.Config, targets []target) error {
 			return errors.New("-pem/-export require a single target")
 		case cfg.Pin != "":
 			return errors.New("-pem/-export cannot be combined with -pin")
-		case cfg.Threshold > 0:
-			return errors.New("-pem/-export cannot be combined with -threshold")
+		case !threshold.IsZero() || !critical.IsZero():
+			return errors.New("-pem/-export cannot be combined with -threshold/-critical-threshold")
 		case cfg.ExpectIssuer != "" || cfg.Strict:
 			return errors.New("-pem/-export cannot be combined with -expect-issuer/-strict")
 		}
 	}
-	if cfg.Output == "prometheus" || cfg.Output == "csv" || cfg.Output == "nagios" {
+	if (cfg.Output == "prometheus" || cfg.Output == "csv" || cfg.Output == "nagios") && cfg.CertFile != "" {
+		return fmt.Errorf("-output %s cannot be combined with -certfile", cfg.Output)
+	}
+	if cfg.OCSP {
+		switch {
+		case cfg.CertFile != "":
+			return errors.New("-ocsp cannot be combined with -certfile")
+		case cfg.AllIPs:
+			return errors.New("-ocsp cannot be combined with -all-ips")
+		case cfg.Pem || cfg.Export != "":
+			return errors.New("-ocsp cannot be combined with -pem/-export")
+		}
+	}
+	if cfg.CRL || cfg.CRLFile != "" {
+		switch {
+		case cfg.CertFile != "":
+			return errors.New("-crl/-crlfile cannot be combined with -certfile")
+		case cfg.AllIPs:
+			return errors.New("-crl/-crlfile cannot be combined with -all-ips")
+		case cfg.Pem || cfg.Export != "":
+			return errors.New("-crl/-crlfile cannot be combined with -pem/-export")
+		}
+	}
+	if cfg.ScanVersions {
+		switch {
+		case cfg.CertFile != "":
+			return errors.New("-scan-versions cannot be combined with -certfile")
+		case cfg.AllIPs:
+			return errors.New("-scan-versions cannot be combined with -all-ips")
+		case cfg.Pem || cfg.Export != "":
+			return errors.New("-scan-versions cannot be combined with -pem/-export")
+		}
+	}
+	if cfg.ScanCiphers {
+		switch {
+		case cfg.CertFile != "":
+			return errors.New("-scan-ciphers cannot be combined with -certfile")
+		case cfg.AllIPs:
+			return errors.New("-scan-ciphers cannot be combined with -all-ips")
+		case cfg.Pem || cfg.Export != "":
+			return errors.New("-scan-ciphers cannot be combined with -pem/-export")
+		}
+	}
+	if cfg.KeyTypes {
+		switch {
+		case cfg.CertFile != "":
+			return errors.New("-key-types cannot be combined with -certfile")
+		case cfg.AllIPs:
+			return errors.New("-key-types cannot be combined with -all-ips")
+		case cfg.Pem || cfg.Export != "":
+			return errors.New("-key-types cannot be combined with -pem/-export")
+		}
+	}
+	if cfg.DANE {
+		switch {
+		case cfg.CertFile != "":
+			return errors.New("-dane cannot be combined with -certfile")
+		case cfg.AllIPs:
+			return errors.New("-dane cannot be combined with -all-ips")
+		case cfg.Pem || cfg.Export != "":
+			return errors.New("-dane cannot be combined with -pem/-export")
+		}
+	}
+	if cfg.CAA {
+		switch {
+		case cfg.CertFile != "":
+			return errors.New("-caa cannot be combined with -certfile")
+		case cfg.AllIPs:
+			return errors.New("-caa cannot be combined with -all-ips")
+		case cfg.Pem || cfg.Export != "":
+			return errors.New("-caa cannot be combined with -pem/-export")
+		}
+	}
+	if cfg.ExpectCNAME != "" {
+		if err := validateCNAMESuffix(cfg.ExpectCNAME); err != nil {
+			return fmt.Errorf("invalid -expect-cname: %v", err)
+		}
+	}
+	if cfg.DNSInfo || cfg.ExpectCNAME != "" {
+		switch {
+		case cfg.CertFile != "":
+			return errors.New("-dns-info/-expect-cname cannot be combined with -certfile")
+		case cfg.AllIPs:
+			return errors.New("-dns-info/-expect-cname cannot be combined with -all-ips")
+		case cfg.Pem || cfg.Export != "":
+			return errors.New("-dns-info/-expect-cname cannot be combined with -pem/-export")
+		}
+	}
+	// -config targets may turn on dane/caa/dns_info themselves, so they count too.
+	if cfg.DNSResolver != "" && !cfg.DANE && !cfg.CAA && !cfg.DNSInfo && cfg.ExpectCNAME == "" && cfg.ConfigFile == "" {
+		return errors.New("-dns-resolver requires -dane, -caa, -dns-info, -expect-cname or -config")
+	}
+	if cfg.CAAMap != "" && !cfg.CAA && cfg.ConfigFile == "" {
+		return errors.New("-caa-map requires -caa or -config")
+	}
+	if cfg.CRLCache != "" && !cfg.CRL {
+		return errors.New("-crl-cache requires -crl")
+	}
+	if cfg.ConfigFile != "" {
+		switch {
+		case cfg.CertFile != "":
+			return errors.New("-config cannot be combined with -certfile")
+		case cfg.AllIPs:
+			return errors.New("-config cannot be combined with -all-ips")
+		case cfg.Pem || cfg.Export != "":
+			return errors.New("-config cannot be combined with -pem/-export")
+		}
+	}
+	if cfg.StateExit != "" && cfg.State == "" {
+		return errors.New("-state-exit requires -state")
+	}
+	if _, err := parseStateExit(cfg.StateExit); err != nil {
+		return err
+	}
+	if cfg.State != "" {
 		switch {
+		case cfg.CertFile != "":
+			return errors.New("-state cannot be combined with -certfile")
 		case cfg.AllIPs:
-			return fmt.Errorf("-output %s cannot be combined with -all-ips", cfg.Output)
+			return errors.New("-state cannot be combined with -all-ips")
+		case cfg.Pem || cfg.Export != "":
+			return errors.New("-state cannot be combined with -pem/-export")
+		case cfg.Command == flags.CommandServe:
+			return errors.New("-state cannot be combined with serve")
+		case cfg.Output != "text" && cfg.Output != "json":
+			return fmt.Errorf("-state cannot be combined with -output %s", cfg.Output)
+		}
+	}
+	if cfg.Command == flags.CommandKnownCerts {
+		if err := validateKnownCerts(cfg, targets); err != nil {
+			return err
+		}
+	} else if cfg.Action != "" {
+		return fmt.Errorf("unexpected argument %q", cfg.Action)
+	}
+	if cfg.BackupPins != "" && (cfg.Command != flags.CommandKnownCerts || cfg.Action != knownAccept) {
+		return errors.New("-backup-pin can only be used with known-certs accept")
+	}
+	if _, err := parseBackupPins(cfg.BackupPins); err != nil {
+		return err
+	}
+	if cfg.KnownCerts != "" && cfg.Command != flags.CommandKnownCerts {
+		switch {
 		case cfg.CertFile != "":
-			return fmt.Errorf("-output %s cannot be combined with -certfile", cfg.Output)
+			return errors.New("-known-certs cannot be combined with -certfile")
+		case cfg.AllIPs:
+			return errors.New("-known-certs cannot be combined with -all-ips")
+		case cfg.Pin != "":
+			return errors.New("-known-certs cannot be combined with -pin")
+		case cfg.Pem || cfg.Export != "":
+			return errors.New("-known-certs cannot be combined with -pem/-export")
+		case cfg.Command == flags.CommandServe:
+			return errors.New("-known-certs cannot be combined with serve")
+		}
+	}
+	if cfg.Command == flags.CommandServe {
+		switch {
+		case cfg.CertFile != "":
+			return errors.New("serve cannot be combined with -certfile")
+		case cfg.AllIPs:
+			return errors.New("serve cannot be combined with -all-ips")
+		case cfg.Pem || cfg.Export != "":
+			return errors.New("serve cannot be combined with -pem/-export")
+		case cfg.At != "":
+			return errors.New("serve cannot be combined with -at")
+		case cfg.Output != "text" && cfg.Output != "prometheus":
+			return fmt.Errorf("serve always emits prometheus and cannot be combined with -output %s", cfg.Output)
+		case cfg.Interval < 1:
+			return fmt.Errorf("invalid -interval %d (expected a positive number of seconds)", cfg.Interval)
 		}
 	}
 	if cfg.StartTLS != "" {
 		if _, ok := starttlsPorts[cfg.StartTLS]; !ok {
-			return fmt.Errorf("invalid -starttls %q (expected smtp, imap, pop3 or ftp)", cfg.StartTLS)
+			return fmt.Errorf("invalid -starttls %q (expected one of %s)", cfg.StartTLS, starttlsNames())
+		}
+	}
+	return nil
+}
+
+// validateKnownCerts checks the known-certs command: a known action on a
+// -known-certs file, targets for accept and revoke only, and no other mode.
+func validateKnownCerts(cfg flags.Config, targets []target) error {
+	switch {
+	case cfg.Action != knownList && cfg.Action != knownAccept && cfg.Action != knownRevoke:
+		return fmt.Errorf("invalid known-certs action %q (expected list, accept or revoke)", cfg.Action)
+	case cfg.KnownCerts == "":
+		return errors.New("known-certs requires -known-certs <file>")
+	case cfg.CertFile != "":
+		return errors.New("known-certs cannot be combined with -certfile")
+	case cfg.ConfigFile != "":
+		return errors.New("known-certs cannot be combined with -config")
+	case cfg.AllIPs:
+		return errors.New("known-certs cannot be combined with -all-ips")
+	case cfg.Pem || cfg.Export != "" || cfg.State != "":
+		return errors.New("known-certs cannot be combined with -pem/-export/-state")
+	case cfg.Output != "text" && cfg.Output != "json":
+		return fmt.Errorf("known-certs cannot be combined with -output %s", cfg.Output)
+	case cfg.Action == knownList && len(targets) > 0:
+		return errors.New("known-certs list takes no -domain/-domain-file")
+	}
+	return nil
+}
+
+// validateProxyProtocol checks the -proxy-protocol flags: a known version, and
+// ip:port overrides of the same address family that only come with it.
+func validateProxyProtocol(cfg flags.Config) error {
+	if cfg.ProxyProtocol == "" {
+		if cfg.ProxyProtocolSrc != "" || cfg.ProxyProtocolDst != "" {
+			return errors.New("-proxy-protocol-src/-proxy-protocol-dst require -proxy-protocol")
+		}
+		return nil
+	}
+	if cfg.ProxyProtocol != "v1" && cfg.ProxyProtocol != "v2" {
+		return fmt.Errorf("invalid -proxy-protocol %q (expected v1 or v2)", cfg.ProxyProtocol)
+	}
+	if cfg.CertFile != "" {
+		return errors.New("-proxy-protocol cannot be combined with -certfile")
+	}
+	var family []bool
+	for _, a := range []struct{ flag, value string }{{"-proxy-protocol-src", cfg.ProxyProtocolSrc}, {"-proxy-protocol-dst", cfg.ProxyProtocolDst}} {
+		if a.value == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(a.value)
+		ip := net.ParseIP(host)
+		if err != nil || ip == nil || validatePort(port) != nil {
+			return fmt.Errorf("invalid %s %q (expected ip:port)", a.flag, a.value)
 		}
+		family = append(family, ip.To4() != nil)
+	}
+	if len(family) == 2 && family[0] != family[1] {
+		return errors.New("-proxy-protocol-src and -proxy-protocol-dst must be the same address family")
+	}
+	return nil
+}
+
+// validateResolvers checks -resolver: a list of host[:port] or tls://host[:port]
+// entries, used to resolve the targets, so not with -ipaddr or -certfile. The
+// single -dns-resolver takes the same form.
+func validateResolvers(cfg flags.Config) error {
+	if cfg.DNSResolver != "" {
+		if err := validateResolver(cfg.DNSResolver); err != nil {
+			return fmt.Errorf("invalid -dns-resolver: %v", err)
+		}
+	}
+	if cfg.Resolver == "" {
+		return nil
+	}
+	switch {
+	case cfg.CertFile != "":
+		return errors.New("-resolver cannot be combined with -certfile")
+	case cfg.IPAddr != "":
+		return errors.New("-resolver cannot be combined with -ipaddr")
+	case cfg.Proxy != "" && !cfg.AllIPs:
+		// The proxy resolves the name itself; resolving it here would hide it.
+		// -all-ips resolves here on purpose and dials each address through it.
+		return errors.New("-resolver cannot be combined with -proxy without -all-ips")
+	}
+	for _, r := range splitList(cfg.Resolver) {
+		if err := validateResolver(r); err != nil {
+			return fmt.Errorf("invalid -resolver: %v", err)
+		}
+	}
+	return nil
+}
+
+// validateResolver checks one resolver: host[:port], or tls://host[:port].
+func validateResolver(r string) error {
+	addr := strings.TrimPrefix(r, "tls://")
+	if strings.Contains(addr, "://") {
+		return fmt.Errorf("%q: unsupported scheme (expected host[:port] or tls://host[:port])", r)
+	}
+	host := strings.Trim(addr, "[]")
+	if h, port, err := net.SplitHostPort(addr); err == nil {
+		if err := validatePort(port); err != nil {
+			return fmt.Errorf("%q: %v", r, err)
+		}
+		host = h
+	}
+	if host == "" {
+		return fmt.Errorf("%q: missing host", r)
+	}
+	return nil
+}
+
+// validateCNAMESuffix checks an -expect-cname suffix: a DNS name, optionally
+// with a leading dot.
+func validateCNAMESuffix(s string) error {
+	name := strings.Trim(s, ".")
+	if name == "" || strings.ContainsAny(name, " \t/:@") || strings.Contains(name, "..") {
+		return fmt.Errorf("%q is not a DNS name suffix", s)
+	}
+	return nil
+}
+
+// parseThresholds parses -threshold and -critical-threshold and checks them
+// with validateThresholds.
+func parseThresholds(cfg flags.Config) (threshold, critical cert.Threshold, err error) {
+	if threshold, err = cert.ParseThreshold(cfg.Threshold); err != nil {
+		return threshold, critical, fmt.Errorf("invalid -threshold: %v", err)
+	}
+	if critical, err = cert.ParseThreshold(cfg.CriticalThreshold); err != nil {
+		return threshold, critical, fmt.Errorf("invalid -critical-threshold: %v", err)
+	}
+	return threshold, critical, validateThresholds(threshold, critical)
+}
+
+// parseAt parses -at: an RFC 3339 time, or a date taken as midnight UTC. An
+// empty value is the zero time (evaluate now).
+func parseAt(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid -at %q (expected an RFC 3339 time or a date, e.g. 2026-12-20)", s)
+}
+
+// validateThresholds checks the two expiry levels: the critical one, when both
+// are set and of the same kind, may not exceed the warning one. A duration and
+// a percentage are left to apply independently.
+func validateThresholds(threshold, critical cert.Threshold) error {
+	if critical.Exceeds(threshold) {
+		return fmt.Errorf("critical threshold %s exceeds the threshold %s", critical, threshold)
 	}
 	return nil
 }
@@ -23,11 +23,28 @@ func TestValidate(t *testing.T) {
 		{"no target", ok, nil, true},
 		{"bad output", flags.Config{Output: "yaml", Timeout: 10, Concurrency: 1}, one, true},
 		{"bad timeout", flags.Config{Output: "text", Timeout: 0, Concurrency: 1}, one, true},
+		{"thresholds", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Threshold: "30", CriticalThreshold: "7"}, one, false},
+		{"critical threshold alone", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, CriticalThreshold: "7"}, one, false},
+		{"critical above threshold", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Threshold: "7", CriticalThreshold: "30"}, one, true},
+		{"duration and percentage thresholds", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Threshold: "33%", CriticalThreshold: "36h"}, one, false},
+		{"critical duration above threshold", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Threshold: "36h", CriticalThreshold: "2"}, one, true},
+		{"at date", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, At: "2026-12-20"}, one, false},
+		{"at rfc3339", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, At: "2026-12-20T08:00:00+01:00"}, one, false},
+		{"malformed at", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, At: "next week"}, one, true},
+		{"malformed threshold", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Threshold: "soon"}, one, true},
 		{"bad concurrency", flags.Config{Output: "text", Timeout: 10, Concurrency: 0}, one, true},
 		{"ipaddr multi", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, IPAddr: "1.2.3.4"}, two, true},
 		{"all-ips + certfile", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, AllIPs: true, CertFile: "c.pem"}, one, true},
 		{"all-ips + strict", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, AllIPs: true, Strict: true}, one, true},
-		{"all-ips multi", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, AllIPs: true}, two, true},
+		{"resolvers", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Resolver: "10.0.0.53, [2001:db8::53]:5353, tls://dns.example"}, two, false},
+		{"resolver bad scheme", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Resolver: "https://dns.example"}, one, true},
+		{"resolver bad port", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Resolver: "10.0.0.53:0"}, one, true},
+		{"resolver + proxy", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Resolver: "10.0.0.53", Proxy: "socks5h://127.0.0.1:1080"}, one, true},
+		{"resolver + proxy + all-ips", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Resolver: "10.0.0.53", Proxy: "socks5h://127.0.0.1:1080", AllIPs: true}, one, false},
+		{"resolver + proxy-from-env", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Resolver: "10.0.0.53", ProxyFromEnv: true}, one, false},
+		{"resolver + ipaddr", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Resolver: "10.0.0.53", IPAddr: "192.0.2.1"}, one, true},
+		{"dns-resolver over tls", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, DANE: true, DNSResolver: "tls://1.1.1.1"}, one, false},
+		{"all-ips multi", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, AllIPs: true}, two, false},
 		{"-4 without all-ips", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, IPv4Only: true}, one, true},
 		{"cafile + insecure", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, CAFile: "r.pem", Insecure: true}, one, true},
 		{"client-cert without key", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ClientCert: "c.crt"}, one, true},
@@ -35,18 +52,92 @@ func TestValidate(t *testing.T) {
 		{"client-cert + key ok", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ClientCert: "c.crt", ClientKey: "c.key"}, one, false},
 		{"proxy + certfile", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Proxy: "http://127.0.0.1:3128", CertFile: "f.pem"}, nil, true},
 		{"proxy ok", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Proxy: "http://127.0.0.1:3128"}, one, false},
+		{"https proxy with CA", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Proxy: "https://proxy.example:8443", ProxyCAFile: "p.pem"}, one, false},
+		{"proxy-from-env ok", flags.Config{Output: "json", Timeout: 10, Concurrency: 1, ProxyFromEnv: true, ProxyCAFile: "p.pem"}, two, false},
+		{"proxy-from-env + proxy", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ProxyFromEnv: true, Proxy: "http://127.0.0.1:3128"}, one, true},
+		{"proxy-protocol ok", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ProxyProtocol: "v2", ProxyProtocolSrc: "203.0.113.7:50000", ProxyProtocolDst: "10.0.0.5:443"}, one, false},
+		{"proxy-protocol bad version", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ProxyProtocol: "v3"}, one, true},
+		{"proxy-protocol-src without proxy-protocol", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ProxyProtocolSrc: "203.0.113.7:50000"}, one, true},
+		{"proxy-protocol-src not ip:port", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ProxyProtocol: "v1", ProxyProtocolSrc: "client.example:5000"}, one, true},
+		{"proxy-protocol mixed families", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ProxyProtocol: "v1", ProxyProtocolSrc: "203.0.113.7:50000", ProxyProtocolDst: "[2001:db8::1]:443"}, one, true},
+		{"proxy-cafile without proxy", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ProxyCAFile: "p.pem"}, one, true},
+		{"proxy-cafile + proxy-insecure", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Proxy: "https://proxy.example", ProxyCAFile: "p.pem", ProxyInsecure: true}, one, true},
 		{"servername multi", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ServerName: "x"}, two, true},
 		{"pin multi", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Pin: "sha256:ab"}, two, true},
 		{"pem + json", flags.Config{Output: "json", Timeout: 10, Concurrency: 1, Pem: true}, one, true},
 		{"pem + export", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Pem: true, Export: "f"}, one, true},
-		{"prometheus + all-ips", flags.Config{Output: "prometheus", Timeout: 10, Concurrency: 1, AllIPs: true}, one, true},
+		{"prometheus + all-ips", flags.Config{Output: "prometheus", Timeout: 10, Concurrency: 1, AllIPs: true}, two, false},
 		{"csv ok", flags.Config{Output: "csv", Timeout: 10, Concurrency: 1}, two, false},
-		{"csv + all-ips", flags.Config{Output: "csv", Timeout: 10, Concurrency: 1, AllIPs: true}, one, true},
+		{"csv + all-ips", flags.Config{Output: "csv", Timeout: 10, Concurrency: 1, AllIPs: true}, two, false},
 		{"csv + certfile", flags.Config{Output: "csv", Timeout: 10, Concurrency: 1, CertFile: "c.pem"}, nil, true},
 		{"nagios ok", flags.Config{Output: "nagios", Timeout: 10, Concurrency: 1}, one, false},
-		{"nagios + all-ips", flags.Config{Output: "nagios", Timeout: 10, Concurrency: 1, AllIPs: true}, one, true},
+		{"nagios + all-ips", flags.Config{Output: "nagios", Timeout: 10, Concurrency: 1, AllIPs: true}, one, false},
 		{"nagios + certfile", flags.Config{Output: "nagios", Timeout: 10, Concurrency: 1, CertFile: "c.pem"}, nil, true},
 		{"bad starttls", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, StartTLS: "gopher"}, one, true},
+		{"serve ok", flags.Config{Command: "serve", Output: "text", Timeout: 10, Concurrency: 1, Interval: 60}, two, false},
+		{"serve probe only", flags.Config{Command: "serve", Output: "text", Timeout: 10, Concurrency: 1, Interval: 60}, nil, false},
+		{"serve + certfile", flags.Config{Command: "serve", Output: "text", Timeout: 10, Concurrency: 1, Interval: 60, CertFile: "c.pem"}, nil, true},
+		{"serve + at", flags.Config{Command: "serve", Output: "text", Timeout: 10, Concurrency: 1, Interval: 60, At: "2026-12-20"}, two, true},
+		{"serve + all-ips", flags.Config{Command: "serve", Output: "text", Timeout: 10, Concurrency: 1, Interval: 60, AllIPs: true}, one, true},
+		{"serve + csv", flags.Config{Command: "serve", Output: "csv", Timeout: 10, Concurrency: 1, Interval: 60}, one, true},
+		{"serve bad interval", flags.Config{Command: "serve", Output: "text", Timeout: 10, Concurrency: 1}, one, true},
+		{"ocsp ok", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, OCSP: true}, two, false},
+		{"ocsp + certfile", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, OCSP: true, CertFile: "c.pem"}, nil, true},
+		{"ocsp + all-ips", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, OCSP: true, AllIPs: true}, one, true},
+		{"ocsp + export", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, OCSP: true, Export: "f"}, one, true},
+		{"aia-fetch ok", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, AIAFetch: true, Export: "f"}, one, false},
+		{"aia-fetch + insecure", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, AIAFetch: true, Insecure: true}, one, true},
+		{"aia-fetch + certfile", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, AIAFetch: true, CertFile: "c.pem"}, nil, true},
+		{"scan-versions ok", flags.Config{Output: "csv", Timeout: 10, Concurrency: 1, ScanVersions: true}, two, false},
+		{"scan-versions + certfile", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ScanVersions: true, CertFile: "c.pem"}, nil, true},
+		{"scan-versions + all-ips", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ScanVersions: true, AllIPs: true}, one, true},
+		{"scan-ciphers ok", flags.Config{Output: "nagios", Timeout: 10, Concurrency: 1, ScanCiphers: true}, one, false},
+		{"scan-ciphers + certfile", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ScanCiphers: true, CertFile: "c.pem"}, nil, true},
+		{"scan-ciphers + pem", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ScanCiphers: true, Pem: true}, one, true},
+		{"key-types ok", flags.Config{Output: "prometheus", Timeout: 10, Concurrency: 1, KeyTypes: true, Threshold: "30"}, two, false},
+		{"key-types + certfile", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, KeyTypes: true, CertFile: "c.pem"}, nil, true},
+		{"key-types + all-ips", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, KeyTypes: true, AllIPs: true}, one, true},
+		{"dane ok", flags.Config{Output: "json", Timeout: 10, Concurrency: 1, DANE: true, DNSResolver: "127.0.0.1:5353", StartTLS: "smtp"}, two, false},
+		{"dane + certfile", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, DANE: true, CertFile: "c.pem"}, nil, true},
+		{"dns-resolver without dane", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, DNSResolver: "127.0.0.1"}, one, true},
+		{"dns-resolver with caa", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, CAA: true, DNSResolver: "127.0.0.1"}, one, false},
+		{"caa + all-ips", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, CAA: true, AllIPs: true}, one, true},
+		{"caa-map without caa", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, CAAMap: "caa.json"}, one, true},
+		{"expect-cname ok", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ExpectCNAME: ".cdn.example.net", DNSResolver: "tls://1.1.1.1"}, two, false},
+		{"expect-cname not a name", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ExpectCNAME: "https://cdn.example.net"}, one, true},
+		{"dns-info + all-ips", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, DNSInfo: true, AllIPs: true}, one, true},
+		{"dns-info + certfile", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, DNSInfo: true, CertFile: "c.pem"}, nil, true},
+		{"caa-map with config", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, CAAMap: "caa.json", ConfigFile: "t.json"}, two, false},
+		{"crl ok", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, CRL: true, CRLFile: "a.crl", CRLCache: "/tmp/crl"}, two, false},
+		{"crlfile + certfile", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, CRLFile: "a.crl", CertFile: "c.pem"}, nil, true},
+		{"crl + all-ips", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, CRL: true, AllIPs: true}, one, true},
+		{"crl + pem", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, CRL: true, Pem: true}, one, true},
+		{"crl-cache without crl", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, CRLCache: "/tmp/crl"}, one, true},
+		{"state ok", flags.Config{Output: "json", Timeout: 10, Concurrency: 1, State: "s.json", StateExit: "renewed=2,chain_changed=5"}, two, false},
+		{"state-exit without state", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, StateExit: "renewed=2"}, one, true},
+		{"state-exit unknown kind", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, State: "s.json", StateExit: "expired=2"}, one, true},
+		{"state-exit bad code", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, State: "s.json", StateExit: "renewed=4"}, one, true},
+		{"state + certfile", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, State: "s.json", CertFile: "c.pem"}, nil, true},
+		{"state + all-ips", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, State: "s.json", AllIPs: true}, one, true},
+		{"state + csv", flags.Config{Output: "csv", Timeout: 10, Concurrency: 1, State: "s.json"}, two, true},
+		{"known-certs check ok", flags.Config{Output: "nagios", Timeout: 10, Concurrency: 1, KnownCerts: "kc"}, two, false},
+		{"known-certs + pin", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, KnownCerts: "kc", Pin: "sha256:abababababababababababababababababababababababababababababababab"}, one, true},
+		{"known-certs + all-ips", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, KnownCerts: "kc", AllIPs: true}, one, true},
+		{"known-certs + serve", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Command: "serve", Interval: 60, KnownCerts: "kc"}, two, true},
+		{"known-certs list", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Command: "known-certs", Action: "list", KnownCerts: "kc"}, nil, false},
+		{"known-certs list with domains", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Command: "known-certs", Action: "list", KnownCerts: "kc"}, one, true},
+		{"known-certs accept", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Command: "known-certs", Action: "accept", KnownCerts: "kc", BackupPins: "sha256:abababababababababababababababababababababababababababababababab"}, two, false},
+		{"known-certs accept without domain", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Command: "known-certs", Action: "accept", KnownCerts: "kc"}, nil, true},
+		{"known-certs without file", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Command: "known-certs", Action: "revoke"}, one, true},
+		{"known-certs unknown action", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Command: "known-certs", Action: "remove", KnownCerts: "kc"}, one, true},
+		{"known-certs + config", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Command: "known-certs", Action: "accept", KnownCerts: "kc", ConfigFile: "t.json"}, two, true},
+		{"backup-pin outside accept", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, KnownCerts: "kc", BackupPins: "sha256:abababababababababababababababababababababababababababababababab"}, one, true},
+		{"malformed backup-pin", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Command: "known-certs", Action: "accept", KnownCerts: "kc", BackupPins: "sha256:zz"}, one, true},
+		{"stray argument", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, Action: "list"}, one, true},
+		{"config ok", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ConfigFile: "t.json"}, two, false},
+		{"config + certfile", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ConfigFile: "t.json", CertFile: "c.pem"}, one, true},
+		{"config + all-ips", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ConfigFile: "t.json", AllIPs: true}, one, true},
+		{"config + pem", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, ConfigFile: "t.json", Pem: true}, one, true},
 		{"good starttls", flags.Config{Output: "text", Timeout: 10, Concurrency: 1, StartTLS: "smtp"}, one, false},
 	}
 	for _, tc := range cases {
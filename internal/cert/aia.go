@@ -0,0 +1,135 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxAIAHops bounds how many issuers -aia-fetch follows up from the point where
+// the served chain breaks.
+const maxAIAHops = 4
+
+// maxAIAResponse bounds how much of a caIssuers download is read.
+const maxAIAResponse = 1 << 20
+
+// pkcs7ContentInfo and pkcs7SignedData are the parts of a "certs-only" PKCS#7
+// bundle (RFC 2315) that carry certificates, as some CAs publish caIssuers.
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+var oidPKCS7SignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+// parseIssuerCerts decodes a caIssuers download: a DER certificate, a PKCS#7
+// certs-only bundle, or either of them PEM-encoded.
+func parseIssuerCerts(data []byte) ([]*x509.Certificate, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	if c, err := x509.ParseCertificate(data); err == nil {
+		return []*x509.Certificate{c}, nil
+	}
+	var ci pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(data, &ci); err != nil || !ci.ContentType.Equal(oidPKCS7SignedData) {
+		return nil, errors.New("neither a certificate nor a PKCS#7 bundle")
+	}
+	var sd pkcs7SignedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("malformed PKCS#7 bundle: %v", err)
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("malformed certificate in PKCS#7 bundle: %v", err)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("PKCS#7 bundle holds no certificates")
+	}
+	return certs, nil
+}
+
+// fetchIssuer downloads c's caIssuers URLs in turn and returns the first
+// certificate found there that signed c.
+func fetchIssuer(c *x509.Certificate, timeout time.Duration, proxy Proxy) (*x509.Certificate, error) {
+	if len(c.IssuingCertificateURL) == 0 {
+		return nil, fmt.Errorf("%s names no caIssuers URL", subjectName(c))
+	}
+	client, err := httpClient(timeout, proxy)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, u := range c.IssuingCertificateURL {
+		data, err := downloadIssuer(client, u)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		certs, err := parseIssuerCerts(data)
+		if err != nil {
+			lastErr = fmt.Errorf("caIssuers %s: %v", u, err)
+			continue
+		}
+		if issuer := findIssuer(c, certs); issuer != nil {
+			return issuer, nil
+		}
+		lastErr = fmt.Errorf("caIssuers %s does not hold the issuer of %s", u, subjectName(c))
+	}
+	return nil, lastErr
+}
+
+// downloadIssuer GETs a caIssuers URL, bounded by maxAIAResponse.
+func downloadIssuer(client *http.Client, u string) ([]byte, error) {
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, fmt.Errorf("caIssuers download failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("caIssuers %s returned HTTP %d", u, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxAIAResponse))
+}
+
+// repairChain follows the caIssuers URLs up from the certificate where the
+// served chain breaks and returns the intermediates that, added to the served
+// chain, make it verify for name. It fails when a download fails, the trail
+// ends at an untrusted root, or maxAIAHops issuers are not enough.
+func repairChain(certs []*x509.Certificate, name string, opts FetchOptions) ([]*x509.Certificate, error) {
+	brk, _, selfSigned, ok := chainBreak(&CertInfo{Chain: certs})
+	if !ok || selfSigned {
+		return nil, errors.New("the served chain ends at a self-signed root; nothing to fetch")
+	}
+	var fetched []*x509.Certificate
+	for cur := brk; len(fetched) < maxAIAHops; {
+		issuer, err := fetchIssuer(cur, opts.Timeout, opts.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		fetched = append(fetched, issuer)
+		if verifyChain(append(append([]*x509.Certificate(nil), certs...), fetched...), name, opts.Roots, opts.At) == nil {
+			return fetched, nil
+		}
+		if bytes.Equal(issuer.RawSubject, issuer.RawIssuer) {
+			return nil, fmt.Errorf("fetched root %s is not trusted", subjectName(issuer))
+		}
+		cur = issuer
+	}
+	return nil, fmt.Errorf("chain still incomplete after fetching %d issuers", maxAIAHops)
+}
@@ -0,0 +1,108 @@
+package cert
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pkcs7Bundle wraps certs in a certs-only PKCS#7 SignedData, as some CAs serve
+// their caIssuers.
+func pkcs7Bundle(t *testing.T, certs ...*x509.Certificate) []byte {
+	t.Helper()
+	var raw []byte
+	for _, c := range certs {
+		raw = append(raw, c.Raw...)
+	}
+	data, _ := asn1.Marshal(asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1})
+	sd, err := asn1.Marshal(pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true},
+		ContentInfo:      asn1.RawValue{Tag: asn1.TagSequence, IsCompound: true, Bytes: data},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: raw},
+		SignerInfos:      asn1.RawValue{Tag: asn1.TagSet, IsCompound: true},
+	})
+	if err != nil {
+		t.Fatalf("marshal SignedData: %v", err)
+	}
+	oid, _ := asn1.Marshal(oidPKCS7SignedData)
+	content, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd})
+	der, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSequence, IsCompound: true, Bytes: append(oid, content...)})
+	if err != nil {
+		t.Fatalf("marshal ContentInfo: %v", err)
+	}
+	return der
+}
+
+// TestRepairChain serves a leaf without its intermediate and verifies
+// repairChain fetches the intermediate from the leaf's caIssuers URL (as DER or
+// PKCS#7), after which the failure is classified as an incomplete chain naming
+// the missing certificate, and the export includes it.
+func TestRepairChain(t *testing.T) {
+	root := newOCSPCA(t)
+	interCert, interKey := root.issue(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "AIA Intermediate"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	inter := ocspCA{cert: interCert, key: interKey}
+
+	var body []byte
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	leaf, _ := inter.issue(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(3),
+		Subject:               pkix.Name{CommonName: "aia.example"},
+		DNSNames:              []string{"aia.example"},
+		IssuingCertificateURL: []string{srv.URL + "/inter.crt"},
+	})
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+	served := []*x509.Certificate{leaf}
+	opts := FetchOptions{Roots: roots, Timeout: 5 * time.Second}
+
+	chainErr := verifyChain(served, "aia.example", roots, time.Time{})
+	var unknown x509.UnknownAuthorityError
+	if !errors.As(chainErr, &unknown) {
+		t.Fatalf("expected the served chain to be unanchored, got %v", chainErr)
+	}
+
+	for name, payload := range map[string][]byte{"der": interCert.Raw, "pkcs7": pkcs7Bundle(t, root.cert, interCert)} {
+		body = payload
+		fetched, err := repairChain(served, "aia.example", opts)
+		if err != nil || len(fetched) != 1 || !fetched[0].Equal(interCert) {
+			t.Fatalf("%s: expected the intermediate to be fetched, got %v (%v)", name, fetched, err)
+		}
+		info := &CertInfo{Cert: leaf, Chain: served, Verified: true, ChainErr: chainErr, AIAFetched: fetched}
+		kind, reason := classifyChainErr(info)
+		if kind != "incomplete_chain" || !strings.Contains(reason, `server must also send "AIA Intermediate"`) {
+			t.Errorf("%s: got kind=%q reason=%q", name, kind, reason)
+		}
+		if n := strings.Count(string(ChainPEM(info)), "BEGIN CERTIFICATE"); n != 2 {
+			t.Errorf("%s: expected the repaired chain (2 certificates) in the export, got %d", name, n)
+		}
+	}
+
+	status = http.StatusNotFound
+	if _, err := repairChain(served, "aia.example", opts); err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("expected the failed download to be reported, got %v", err)
+	}
+	info := &CertInfo{Cert: leaf, Chain: served, Verified: true, ChainErr: chainErr}
+	if kind, _ := classifyChainErr(info); kind != "unanchored" {
+		t.Errorf("without a repair the chain should stay unanchored, got %q", kind)
+	}
+}
@@ -4,16 +4,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 )
 
 // IPResult is the certificate (or error) obtained from one resolved address.
 // Skipped marks an address that is unreachable from this host (no route to its
 // family) — a benign condition rather than a real failure.
 type IPResult struct {
-	IP      string
-	Info    *CertInfo // nil when Err is set
-	Err     error
-	Skipped bool
+	IP        string
+	Resolvers []string  // With -resolver: the resolvers whose answer held IP
+	Info      *CertInfo // nil when Err is set
+	Err       error
+	Skipped   bool
 }
 
 // AllIPsResult summarizes an all-ips run, for the caller's exit code.
@@ -50,33 +52,40 @@ func tallyIPs(results []IPResult) (distinct, reachable, skipped int, hadError bo
 // PrintAllIPs renders the per-address results for a domain (text or JSON) and
 // reports whether all reachable addresses serve the same certificate.
 func PrintAllIPs(domain string, results []IPResult, opts PrintOptions) AllIPsResult {
-	distinct, reachable, skipped, hadError, minDays, _ := tallyIPs(results)
+	distinct, reachable, skipped, _, _, _ := tallyIPs(results)
 	if opts.JSON {
 		printAllIPsJSON(domain, results, distinct, opts)
 	} else {
 		printAllIPsText(domain, results, distinct, reachable, skipped, opts)
 	}
+	return SummarizeIPs(results, opts.Pins)
+}
+
+// SummarizeIPs reports the verdict on a domain's per-address results without
+// rendering them, for callers that render them in another form.
+func SummarizeIPs(results []IPResult, pins []string) AllIPsResult {
+	distinct, reachable, skipped, hadError, minDays, _ := tallyIPs(results)
 	return AllIPsResult{
 		AllMatch:    distinct <= 1,
 		HadError:    hadError,
 		Reachable:   reachable,
 		Skipped:     skipped,
 		MinDays:     minDays,
-		PinMismatch: anyPinMismatch(results, opts.Pin),
+		PinMismatch: anyPinMismatch(results, pins),
 	}
 }
 
 // anyPinMismatch reports whether -pin was set and at least one reachable address
 // served a certificate that does not match the pin.
-func anyPinMismatch(results []IPResult, pin string) bool {
-	if pin == "" {
+func anyPinMismatch(results []IPResult, pins []string) bool {
+	if len(pins) == 0 {
 		return false
 	}
 	for _, r := range results {
 		if r.Skipped || r.Err != nil {
 			continue
 		}
-		if !MatchesPin(r.Info.Cert, pin) {
+		if !MatchesAnyPin(r.Info.Cert, pins) {
 			return true
 		}
 	}
@@ -104,15 +113,15 @@ func printAllIPsText(domain string, results []IPResult, distinct, reachable, ski
 				}
 			}
 			pin := ""
-			if opts.Pin != "" {
-				if MatchesPin(c, opts.Pin) {
+			if len(opts.Pins) > 0 {
+				if MatchesAnyPin(c, opts.Pins) {
 					pin = "  " + maybeColor("PIN-OK", colorGreen, opts.Color)
 				} else {
 					pin = "  " + maybeColor("PIN-MISMATCH", colorRed, opts.Color)
 				}
 			}
 			fmt.Printf("  %-39s  %s  %s days  expires %s  %s%s\n",
-				r.IP, Fingerprint(c)[:16], colorizeDays(DaysUntilExpiry(c), opts.Threshold, opts.Color),
+				r.IP, Fingerprint(c)[:16], colorizeDays(c, r.Info.Now(), opts),
 				c.NotAfter.Format(dateFormat), chain, pin)
 		}
 	}
@@ -126,44 +135,86 @@ func printAllIPsText(domain string, results []IPResult, distinct, reachable, ski
 	if skipped > 0 {
 		fmt.Printf("(%d address(es) skipped — unreachable from this host)\n", skipped)
 	}
+	printResolversText(results)
+}
+
+// printResolversText lists, with -resolver, the addresses each resolver
+// returned, and notes when they disagree (split horizon, GeoDNS).
+func printResolversText(results []IPResult) {
+	var order []string
+	byResolver := make(map[string][]string)
+	for _, r := range results {
+		for _, res := range r.Resolvers {
+			if _, ok := byResolver[res]; !ok {
+				order = append(order, res)
+			}
+			byResolver[res] = append(byResolver[res], r.IP)
+		}
+	}
+	if len(order) == 0 {
+		return
+	}
+	for _, res := range order {
+		fmt.Printf("  via %s: %s\n", res, strings.Join(byResolver[res], ", "))
+	}
+	for _, res := range order {
+		if len(byResolver[res]) != len(results) {
+			fmt.Println("Resolvers disagree on the address set.")
+			return
+		}
+	}
 }
 
 // printAllIPsJSON renders the addresses as a JSON object with a match verdict.
 // distinct is computed once by the caller.
 func printAllIPsJSON(domain string, results []IPResult, distinct int, opts PrintOptions) {
+	b, err := json.MarshalIndent(allIPsPayload(domain, results, distinct, opts), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to encode JSON: %v\n", err)
+		return
+	}
+	fmt.Println(string(b))
+}
+
+// AllIPsPayload returns the JSON object PrintAllIPs renders for a domain, for an
+// array of several domains' results.
+func AllIPsPayload(domain string, results []IPResult, opts PrintOptions) any {
+	distinct, _, _, _, _, _ := tallyIPs(results)
+	return allIPsPayload(domain, results, distinct, opts)
+}
+
+// allIPsPayload builds the per-domain JSON object: one entry per address and the
+// match verdict.
+func allIPsPayload(domain string, results []IPResult, distinct int, opts PrintOptions) any {
 	addresses := make([]any, 0, len(results))
 	for _, r := range results {
 		switch {
 		case r.Skipped:
 			addresses = append(addresses, struct {
-				IP      string `json:"ip"`
-				Skipped bool   `json:"skipped"`
-				Error   string `json:"error"`
-			}{IP: r.IP, Skipped: true, Error: r.Err.Error()})
+				IP        string   `json:"ip"`
+				Resolvers []string `json:"resolvers,omitempty"`
+				Skipped   bool     `json:"skipped"`
+				Error     string   `json:"error"`
+			}{IP: r.IP, Resolvers: r.Resolvers, Skipped: true, Error: r.Err.Error()})
 		case r.Err != nil:
 			addresses = append(addresses, struct {
-				IP    string `json:"ip"`
-				Error string `json:"error"`
-			}{IP: r.IP, Error: r.Err.Error()})
+				IP        string   `json:"ip"`
+				Resolvers []string `json:"resolvers,omitempty"`
+				Error     string   `json:"error"`
+			}{IP: r.IP, Resolvers: r.Resolvers, Error: r.Err.Error()})
 		default:
-			p := buildPayload(r.Info, "", payloadOptions{IncludeChain: opts.Chain, IncludeFingerprint: opts.Fingerprint, Pin: opts.Pin})
+			p := buildPayload(r.Info, "", payloadOptions{IncludeChain: opts.Chain, IncludeFingerprint: opts.Fingerprint, Pins: opts.Pins})
 			p.IP = r.IP
+			p.Resolvers = r.Resolvers
 			p.UsedIP = "" // redundant in -all-ips: identical to ip
 			p.Fingerprint = Fingerprint(r.Info.Cert)
 			addresses = append(addresses, p)
 		}
 	}
 
-	out := struct {
+	return struct {
 		Domain            string `json:"domain"`
 		CertificatesMatch bool   `json:"certificates_match"`
 		Addresses         []any  `json:"addresses"`
 	}{Domain: domain, CertificatesMatch: distinct <= 1, Addresses: addresses}
-
-	b, err := json.MarshalIndent(out, "", "  ")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: failed to encode JSON: %v\n", err)
-		return
-	}
-	fmt.Println(string(b))
 }
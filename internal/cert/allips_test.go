@@ -12,13 +12,13 @@ import (
 // TestPrintAllIPs verifies the per-address table, the "differ" verdict and the
 // JSON object, including the AllIPsResult summary.
 func TestPrintAllIPs(t *testing.T) {
-	now := time.Now()
+	now := testNow
 	same := genCert(t, "example.com", now.Add(90*24*time.Hour))
 	diff := genCert(t, "example.com", now.Add(8*24*time.Hour))
 	results := []IPResult{
-		{IP: "203.0.113.10", Info: &CertInfo{Cert: same, Chain: []*x509.Certificate{same}, Verified: true, UsedIP: "203.0.113.10"}},
-		{IP: "203.0.113.11", Info: &CertInfo{Cert: same, Chain: []*x509.Certificate{same}, Verified: true}},
-		{IP: "203.0.113.12", Info: &CertInfo{Cert: diff, Chain: []*x509.Certificate{diff}, Verified: true}},
+		{IP: "203.0.113.10", Info: &CertInfo{Cert: same, Chain: []*x509.Certificate{same}, Verified: true, UsedIP: "203.0.113.10", At: now}},
+		{IP: "203.0.113.11", Info: &CertInfo{Cert: same, Chain: []*x509.Certificate{same}, Verified: true, At: now}},
+		{IP: "203.0.113.12", Info: &CertInfo{Cert: diff, Chain: []*x509.Certificate{diff}, Verified: true, At: now}},
 		{IP: "203.0.113.13", Err: errors.New("connection refused")},
 	}
 
@@ -126,17 +126,17 @@ func TestAnyPinMismatch(t *testing.T) {
 	c := genCert(t, "a.example", time.Now().Add(24*time.Hour))
 	results := []IPResult{{IP: "203.0.113.1", Info: &CertInfo{Cert: c, Chain: []*x509.Certificate{c}}}}
 
-	if anyPinMismatch(results, "") {
+	if anyPinMismatch(results, nil) {
 		t.Error("empty pin should never report a mismatch")
 	}
-	if !anyPinMismatch(results, "00deadbeef") {
+	if !anyPinMismatch(results, []string{"00deadbeef"}) {
 		t.Error("a non-matching pin should report a mismatch")
 	}
-	if anyPinMismatch(results, Fingerprint(c)) {
+	if anyPinMismatch(results, []string{Fingerprint(c)}) {
 		t.Error("the matching fingerprint should not report a mismatch")
 	}
 	skipped := []IPResult{{IP: "2001:db8::1", Err: errors.New("unreachable"), Skipped: true}}
-	if anyPinMismatch(skipped, "00ff") {
+	if anyPinMismatch(skipped, []string{"00ff"}) {
 		t.Error("skipped/errored addresses must be ignored")
 	}
 }
@@ -0,0 +1,290 @@
+package cert
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CAA property tags the check interprets (RFC 8659 §4). Other tags are shown
+// but only matter when flagged critical.
+const (
+	caaIssue     = "issue"
+	caaIssueWild = "issuewild"
+	caaIodef     = "iodef"
+	caaCritical  = 0x80
+)
+
+// caaIssuers maps a substring of the issuer's organization or common name to the
+// CAA identifiers (issuer domain names) the CA publishes. Checked after any
+// -caa-map entries; matching is case-insensitive and every matching row counts.
+var caaIssuers = []struct {
+	match string
+	ids   []string
+}{
+	{"Let's Encrypt", []string{"letsencrypt.org"}},
+	{"Google Trust Services", []string{"pki.goog"}},
+	{"DigiCert", []string{"digicert.com", "www.digicert.com"}},
+	{"GeoTrust", []string{"digicert.com", "geotrust.com"}},
+	{"Thawte", []string{"digicert.com", "thawte.com"}},
+	{"RapidSSL", []string{"digicert.com", "rapidssl.com"}},
+	{"Sectigo", []string{"sectigo.com", "comodoca.com"}},
+	{"COMODO", []string{"sectigo.com", "comodoca.com"}},
+	{"ZeroSSL", []string{"sectigo.com", "zerossl.com"}},
+	{"GlobalSign", []string{"globalsign.com"}},
+	{"Amazon", []string{"amazon.com", "amazontrust.com", "awstrust.com", "amazonaws.com"}},
+	{"GoDaddy", []string{"godaddy.com"}},
+	{"Starfield", []string{"starfieldtech.com"}},
+	{"Entrust", []string{"entrust.net", "affirmtrust.com"}},
+	{"Buypass", []string{"buypass.com", "buypass.no"}},
+	{"SSL.com", []string{"ssl.com"}},
+	{"IdenTrust", []string{"identrust.com"}},
+	{"Microsoft", []string{"microsoft.com"}},
+	{"Certum", []string{"certum.pl", "certum.eu"}},
+	{"Actalis", []string{"actalis.it"}},
+	{"HARICA", []string{"harica.gr"}},
+	{"Telia", []string{"telia.com"}},
+	{"SwissSign", []string{"swisssign.com"}},
+}
+
+// CAAMap maps an issuer substring to CAA identifiers, loaded from -caa-map.
+type CAAMap map[string][]string
+
+// LoadCAAMap reads a -caa-map file: a JSON object from an issuer substring
+// (matched against the issuer's organization and common name) to the CAA
+// identifiers of that CA, e.g. {"Example Corp CA": ["ca.example.net"]}.
+func LoadCAAMap(path string) (CAAMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CAA map: %v", err)
+	}
+	var m CAAMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse CAA map %s: %v", path, err)
+	}
+	for issuer, ids := range m {
+		if strings.TrimSpace(issuer) == "" || len(ids) == 0 {
+			return nil, fmt.Errorf("CAA map %s: every entry needs an issuer and at least one identifier", path)
+		}
+	}
+	return m, nil
+}
+
+// CAARecord is one CAA resource record.
+type CAARecord struct {
+	Flags uint8
+	Tag   string
+	Value string
+}
+
+// Critical reports whether the issuer-critical flag is set.
+func (r CAARecord) Critical() bool { return r.Flags&caaCritical != 0 }
+
+// String renders the record in zone-file form, e.g. `0 issue "letsencrypt.org"`.
+func (r CAARecord) String() string {
+	return fmt.Sprintf("%d %s %s", r.Flags, r.Tag, strconv.Quote(r.Value))
+}
+
+// CAAResult is the outcome of -caa: the CAA record set that governs the domain
+// and whether the leaf's issuer is among the CAs it authorizes. Err is set when
+// the records could not be obtained or the issuer has no known CAA identifier.
+type CAAResult struct {
+	Domain     string      // Where the record set was found: the domain or its closest ancestor with CAA records
+	Records    []CAARecord // The relevant record set; empty when no CAA records exist up to the TLD
+	Property   string      // The property that governed issuance: issue or issuewild
+	IssuerIDs  []string    // CAA identifiers of the leaf's issuer
+	Authorized bool
+	Err        error
+}
+
+// Unauthorized reports whether the CAA policy was determined and does not allow
+// the leaf's issuer — reported with the mismatch exit code.
+func (c *CAAResult) Unauthorized() bool {
+	return c != nil && c.Err == nil && !c.Authorized
+}
+
+// Inconclusive reports whether -caa ran but could not decide. Treated as a
+// warning (see HasWarnings).
+func (c *CAAResult) Inconclusive() bool {
+	return c != nil && c.Err != nil
+}
+
+// parseCAA decodes CAA RDATA: flags, tag length, tag, then the value.
+func parseCAA(rdata []byte) (CAARecord, error) {
+	if len(rdata) < 2 || len(rdata) < 2+int(rdata[1]) {
+		return CAARecord{}, errors.New("CAA record too short")
+	}
+	n := int(rdata[1])
+	return CAARecord{Flags: rdata[0], Tag: strings.ToLower(string(rdata[2 : 2+n])), Value: string(rdata[2+n:])}, nil
+}
+
+// lookupCAA climbs from domain towards the TLD and returns the first non-empty
+// CAA record set (RFC 8659 §3) and the name it was found at.
+func lookupCAA(domain, resolver string, timeout time.Duration) (string, []CAARecord, error) {
+	labels := strings.Split(strings.TrimSuffix(domain, "."), ".")
+	for i := range labels {
+		name := strings.Join(labels[i:], ".")
+		resp, err := queryDNS(resolver, name, dnsTypeCAA, timeout)
+		if err != nil {
+			return name, nil, err
+		}
+		var records []CAARecord
+		for _, rr := range resp.Answers {
+			if rr.Type != dnsTypeCAA || rr.Class != dnsClassIN {
+				continue
+			}
+			if rec, err := parseCAA(rr.Data); err == nil {
+				records = append(records, rec)
+			}
+		}
+		if len(records) > 0 {
+			return name, records, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// checkCAA looks up the CAA policy for domain through resolver (the system's
+// when empty) and decides whether the issuer of info's leaf may issue for it.
+func checkCAA(info *CertInfo, domain, resolver string, extra CAAMap, timeout time.Duration) *CAAResult {
+	out := &CAAResult{Property: caaIssue, IssuerIDs: caaIdentifiers(info.Cert, extra)}
+	if net.ParseIP(domain) != nil {
+		out.Err = errors.New("CAA does not apply to an IP address")
+		return out
+	}
+	var err error
+	if out.Domain, out.Records, err = lookupCAA(domain, resolverAddress(resolver), timeout); err != nil {
+		out.Err = err
+		return out
+	}
+	if len(out.Records) == 0 {
+		out.Authorized = true // no CAA records: any CA may issue
+		return out
+	}
+	if coveredByWildcardOnly(info.Cert, domain) && hasCAATag(out.Records, caaIssueWild) {
+		out.Property = caaIssueWild
+	}
+	for _, r := range out.Records {
+		if r.Critical() && r.Tag != caaIssue && r.Tag != caaIssueWild && r.Tag != caaIodef {
+			return out // an unknown critical property forbids every CA (RFC 8659 §4.1)
+		}
+	}
+	if !hasCAATag(out.Records, out.Property) {
+		// Only issue/issuewild records restrict issuance, and issuewild falls
+		// back to issue (RFC 8659 §4.2): a set of iodef records alone, or
+		// issuewild alone for a name not covered by a wildcard, allows any CA.
+		out.Authorized = true
+		return out
+	}
+	if len(out.IssuerIDs) == 0 {
+		out.Err = fmt.Errorf("no CAA identifier known for issuer %q (add one with -caa-map)", issuerName(info.Cert))
+		return out
+	}
+	for _, r := range out.Records {
+		if r.Tag != out.Property {
+			continue
+		}
+		id := caaIssuerDomain(r.Value)
+		for _, want := range out.IssuerIDs {
+			if id != "" && strings.EqualFold(id, want) {
+				out.Authorized = true
+			}
+		}
+	}
+	return out
+}
+
+// Allowed returns the CA identifiers the governing property authorizes; an
+// empty value (";") authorizes none and is left out.
+func (c *CAAResult) Allowed() []string {
+	var out []string
+	for _, r := range c.Records {
+		if r.Tag == c.Property {
+			if id := caaIssuerDomain(r.Value); id != "" {
+				out = append(out, id)
+			}
+		}
+	}
+	return out
+}
+
+// caaIssuerDomain returns the issuer domain name of an issue/issuewild value,
+// dropping its parameters: "letsencrypt.org; validationmethods=dns-01".
+func caaIssuerDomain(value string) string {
+	id, _, _ := strings.Cut(value, ";")
+	return strings.ToLower(strings.TrimSpace(id))
+}
+
+// hasCAATag reports whether records has a record with tag.
+func hasCAATag(records []CAARecord, tag string) bool {
+	for _, r := range records {
+		if r.Tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// coveredByWildcardOnly reports whether c covers domain only through a wildcard
+// name, in which case the issuewild property governs its issuance.
+func coveredByWildcardOnly(c *x509.Certificate, domain string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	wildcard := false
+	for _, n := range c.DNSNames {
+		n = strings.ToLower(n)
+		if n == domain {
+			return false
+		}
+		if rest, ok := strings.CutPrefix(n, "*."); ok {
+			if _, parent, found := strings.Cut(domain, "."); found && parent == rest {
+				wildcard = true
+			}
+		}
+	}
+	return wildcard
+}
+
+// caaIdentifiers returns the CAA identifiers of c's issuer: the -caa-map entries
+// first, then the built-in table, without duplicates.
+func caaIdentifiers(c *x509.Certificate, extra CAAMap) []string {
+	name := strings.ToLower(caaIssuerText(c))
+	var out []string
+	add := func(ids []string) {
+		for _, id := range ids {
+			id = strings.ToLower(strings.TrimSpace(id))
+			if id != "" && !slices.Contains(out, id) {
+				out = append(out, id)
+			}
+		}
+	}
+	matches := make([]string, 0, len(extra))
+	for match := range extra {
+		matches = append(matches, match)
+	}
+	sort.Strings(matches)
+	for _, match := range matches {
+		if strings.Contains(name, strings.ToLower(match)) {
+			add(extra[match])
+		}
+	}
+	for _, row := range caaIssuers {
+		if strings.Contains(name, strings.ToLower(row.match)) {
+			add(row.ids)
+		}
+	}
+	return out
+}
+
+// caaIssuerText joins the issuer's organizations and common name, the text the
+// CAA table is matched against.
+func caaIssuerText(c *x509.Certificate) string {
+	return strings.TrimSpace(strings.Join(append(append([]string{}, c.Issuer.Organization...), c.Issuer.CommonName), " "))
+}
@@ -0,0 +1,114 @@
+package cert
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// caaStub is a dnsStub publishing zone as the CAA records of each name.
+func caaStub(t *testing.T, zone map[string][]CAARecord) string {
+	return dnsStub(t, true, func(name string, qtype uint16) [][]byte {
+		var out [][]byte
+		for _, r := range zone[name] {
+			if qtype == dnsTypeCAA {
+				out = append(out, append(append([]byte{r.Flags, byte(len(r.Tag))}, r.Tag...), r.Value...))
+			}
+		}
+		return out
+	})
+}
+
+func TestCAAIdentifiers(t *testing.T) {
+	le := &x509.Certificate{Issuer: pkix.Name{Organization: []string{"Let's Encrypt"}, CommonName: "R11"}}
+	if got := caaIdentifiers(le, nil); len(got) != 1 || got[0] != "letsencrypt.org" {
+		t.Errorf("Let's Encrypt: got %v", got)
+	}
+	corp := &x509.Certificate{Issuer: pkix.Name{CommonName: "Example Corp Issuing CA 2"}}
+	if got := caaIdentifiers(corp, nil); len(got) != 0 {
+		t.Errorf("unknown issuer: got %v", got)
+	}
+	if got := caaIdentifiers(corp, CAAMap{"example corp": {"CA.Example.net", "ca.example.net"}}); len(got) != 1 || got[0] != "ca.example.net" {
+		t.Errorf("mapped issuer: got %v", got)
+	}
+}
+
+func TestLoadCAAMap(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	if err := os.WriteFile(good, []byte(`{"Example Corp": ["ca.example.net"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadCAAMap(good)
+	if err != nil || len(m["Example Corp"]) != 1 {
+		t.Errorf("LoadCAAMap = %v, %v", m, err)
+	}
+	empty := filepath.Join(dir, "empty.json")
+	if err := os.WriteFile(empty, []byte(`{"Example Corp": []}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCAAMap(empty); err == nil {
+		t.Error("expected an error for an entry without identifiers")
+	}
+}
+
+func TestCheckCAA(t *testing.T) {
+	le := &x509.Certificate{Issuer: pkix.Name{Organization: []string{"Let's Encrypt"}}, DNSNames: []string{"www.example.com"}}
+	wild := &x509.Certificate{Issuer: pkix.Name{Organization: []string{"Let's Encrypt"}}, DNSNames: []string{"*.example.com"}}
+	unknown := &x509.Certificate{Issuer: pkix.Name{CommonName: "Example Corp CA"}, DNSNames: []string{"www.example.com"}}
+	zone := map[string][]CAARecord{
+		"example.com": {
+			{Tag: "issue", Value: "letsencrypt.org; validationmethods=dns-01"},
+			{Tag: "issuewild", Value: "digicert.com"},
+			{Tag: "iodef", Value: "mailto:security@example.com"},
+		},
+		"strict.org":   {{Flags: caaCritical, Tag: "future", Value: "x"}, {Tag: "issue", Value: "letsencrypt.org"}},
+		"report.org":   {{Tag: "iodef", Value: "mailto:security@report.org"}},
+		"wildonly.org": {{Tag: "issuewild", Value: "digicert.com"}},
+	}
+	resolver := caaStub(t, zone)
+	check := func(c *x509.Certificate, domain string) *CAAResult {
+		return checkCAA(&CertInfo{Cert: c}, domain, resolver, nil, 2*time.Second)
+	}
+
+	// The records are inherited from the parent.
+	r := check(le, "www.example.com")
+	if r.Err != nil || !r.Authorized || r.Domain != "example.com" || r.Property != caaIssue || len(r.Records) != 3 {
+		t.Errorf("issue: unexpected result %+v", r)
+	}
+	if r := check(wild, "www.example.com"); r.Err != nil || !r.Unauthorized() || r.Property != caaIssueWild {
+		t.Errorf("issuewild: expected unauthorized, got %+v", r)
+	}
+	if r := check(le, "www.example.net"); r.Err != nil || !r.Authorized || len(r.Records) != 0 {
+		t.Errorf("no records: expected any CA authorized, got %+v", r)
+	}
+	// Without a record of the governing property nothing is restricted, even
+	// for an issuer with no known identifier (RFC 8659 §4.2).
+	if r := check(unknown, "report.org"); r.Err != nil || !r.Authorized {
+		t.Errorf("iodef only: expected any CA authorized, got %+v", r)
+	}
+	nonWild := &x509.Certificate{Issuer: le.Issuer, DNSNames: []string{"wildonly.org"}}
+	if r := check(nonWild, "wildonly.org"); r.Err != nil || !r.Authorized || r.Property != caaIssue {
+		t.Errorf("issuewild only, non-wildcard name: expected authorized under issue, got %+v", r)
+	}
+	if r := check(le, "strict.org"); !r.Unauthorized() {
+		t.Errorf("unknown critical tag: expected unauthorized, got %+v", r)
+	}
+	if r := check(unknown, "www.example.com"); !r.Inconclusive() || !strings.Contains(r.Err.Error(), "-caa-map") {
+		t.Errorf("unknown issuer: expected an error, got %+v", r)
+	}
+	if r := checkCAA(&CertInfo{Cert: unknown}, "www.example.com", resolver, CAAMap{"Example Corp": {"letsencrypt.org"}}, 2*time.Second); !r.Authorized {
+		t.Errorf("mapped issuer: expected authorized, got %+v", r)
+	}
+
+	out := captureStdout(t, func() { printCAAText(r, false) })
+	for _, want := range []string{"CAA: AUTHORIZED (issue at example.com allows letsencrypt.org)", `0 iodef "mailto:security@example.com"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
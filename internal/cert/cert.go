@@ -1,17 +1,33 @@
 // Package cert is the certificate domain: it fetches certificates over TLS
-// (optionally via STARTTLS or an HTTP CONNECT proxy), loads them from PEM,
+// (optionally via STARTTLS or a proxy), loads them from PEM,
 // inspects trust/expiry/crypto, and renders the results as text, JSON,
 // Prometheus, CSV or a Nagios plugin line.
 //
 // File map (acquire → analyze → render):
-//   - cert.go: core types (CertInfo, FetchOptions, PrintOptions, interfaces) and day arithmetic
-//   - fetch.go: acquire a certificate over TLS — dial, HTTP CONNECT proxy, chain verification
-//   - starttls.go: STARTTLS upgrade for smtp/imap/pop3/ftp
+//   - cert.go: core types (CertInfo, FetchOptions, PrintOptions, interfaces), the evaluation instant (-at) and day arithmetic
+//   - threshold.go: expiry thresholds in days, as a duration or as a share of the lifetime
+//   - fetch.go: acquire a certificate over TLS — dial, chain verification
+//   - proxy.go: tunnel through an HTTP CONNECT (http/https) or SOCKS5 proxy
+//   - proxyproto.go: PROXY protocol v1/v2 header for backends behind a load balancer
+//   - starttls.go: STARTTLS upgrade for smtp/lmtp/imap/pop3/ftp, nntp, sieve, irc, xmpp, ldap, postgres, mysql
+//   - tds.go: STARTTLS for mssql — TDS prelogin and a conn that frames the handshake in TDS
 //   - load.go: acquire from disk — PEM file/stdin, client certificate, CA pool
+//   - ocsp.go: revocation check of the leaf against its OCSP responder
+//   - crl.go: revocation check of the chain against CRLs, with an on-disk cache
+//   - aia.go: chain repair — fetch missing intermediates via AIA caIssuers
+//   - versions.go: protocol version scan — one pinned handshake per TLS version
+//   - ciphers.go: cipher suite scan — accepted suites, server preference, weakness grade
+//   - keytypes.go: dual-certificate scan — the RSA and the ECDSA certificate a server presents
+//   - dane.go: DANE — match the served chain against the service's TLSA records
+//   - caa.go: CAA — whether the domain's CAA policy authorizes the leaf's issuer
+//   - cname.go: the domain's CNAME chain, final addresses and TTLs, and -expect-cname
+//   - dns.go: minimal DNS client (UDP with TCP fallback, or DNS over TLS) for the record types the tool needs
 //   - inspect.go: analyze a certificate — expiry, weak crypto, chain trust, fingerprints, pins
 //   - render.go: human-readable text and JSON output
 //   - report.go: monitoring formats — Prometheus, CSV, Nagios
 //   - allips.go: compare and render results across a domain's IP addresses
+//   - state.go: the -state file and the changes between runs
+//   - known.go: the -known-certs trust-on-first-use store of pins per host:port
 package cert
 
 import (
@@ -26,24 +42,81 @@ type CertInfo struct {
 	Cert        *x509.Certificate   // The retrieved certificate (leaf)
 	Chain       []*x509.Certificate // Full peer chain (leaf first); nil when loaded from a file
 	UsedIP      string              // Remote IP address; empty when loaded from a file
+	Proxy       string              // With -proxy-from-env: the proxy chosen (credentials redacted) or "direct"
+	Resolver    string              // With -resolver: the resolver whose answer gave UsedIP
 	TLSVersion  string              // Negotiated TLS version; empty when loaded from a file
 	CipherSuite string              // Negotiated cipher suite; empty when loaded from a file
 	CheckedName string              // Hostname the cert was requested for; empty when loaded from a file
 	FromFile    bool                // True when the certificate was loaded from a local file
 	Verified    bool                // True when chain verification was attempted
 	ChainErr    error               // Chain verification error; nil means valid (only meaningful when Verified)
+	Revocation  *Revocation         // Revocation check of the leaf; nil when not checked
+	Staple      *Revocation         // OCSP response stapled to the handshake; nil when none was sent
+	CRL         *Revocation         // CRL check of the chain; nil when not checked
+	AIAFetched  []*x509.Certificate // Intermediates fetched via AIA caIssuers that complete the chain (-aia-fetch)
+	AIAErr      error               // Why -aia-fetch could not complete the chain
+	Versions    []VersionSupport    // Protocol versions the server accepts, oldest first; nil when not scanned
+	Ciphers     *CipherScan         // Cipher suites the server accepts and their grade; nil when not scanned
+	KeyTypes    []KeyTypeCert       // The certificate presented per key type (RSA, ECDSA); nil when not scanned
+	DANE        *DANEResult         // TLSA records of the service and which the chain matched; nil when not checked
+	CAA         *CAAResult          // The domain's CAA policy and whether it authorizes the issuer; nil when not checked
+	DNS         *DNSInfo            // CNAME chain, final addresses and TTLs of the domain; nil when not looked up
+	State       *StateChange        // Comparison with the -state record of the previous run; nil without -state
+	At          time.Time           // Instant the expiry, validity and chain checks are evaluated at (-at); zero = the current time
+}
+
+// RevokedBy returns the revocation result that reports the certificate revoked —
+// the queried OCSP answer, the verified staple or the CRL check — or nil when
+// none does.
+func (info *CertInfo) RevokedBy() *Revocation {
+	for _, r := range info.revocations() {
+		if r.Revoked() {
+			return r
+		}
+	}
+	return nil
+}
+
+// revocations lists the revocation results obtained for info (OCSP, staple,
+// CRL, in that order), skipping the checks that did not run.
+func (info *CertInfo) revocations() []*Revocation {
+	var out []*Revocation
+	for _, r := range []*Revocation{info.Revocation, info.Staple, info.CRL} {
+		if r != nil {
+			out = append(out, r)
+		}
+	}
+	return out
 }
 
 // FetchOptions controls how Fetch connects and verifies. The zero value dials
 // direct TLS, verifies against the system roots, and uses the domain as the SNI.
 type FetchOptions struct {
-	Insecure   bool             // Skip chain verification (still retrieves the cert)
-	Timeout    time.Duration    // Bounds the connection (and STARTTLS negotiation)
-	StartTLS   string           // smtp/imap/pop3/ftp to upgrade via STARTTLS; empty = direct TLS
-	ServerName string           // SNI and hostname to verify against; empty = use domain
-	Roots      *x509.CertPool   // Trust anchors for verification; nil = system roots
-	ClientCert *tls.Certificate // Client certificate for mutual TLS; nil = none
-	Proxy      string           // HTTP CONNECT proxy URL; empty = direct connection
+	Insecure     bool             // Skip chain verification (still retrieves the cert)
+	Timeout      time.Duration    // Bounds the connection (and STARTTLS negotiation)
+	StartTLS     string           // Protocol to upgrade via STARTTLS (see negotiateStartTLS); empty = direct TLS
+	ServerName   string           // SNI and hostname to verify against; empty = use domain
+	Roots        *x509.CertPool   // Trust anchors for verification; nil = system roots
+	ClientCert   *tls.Certificate // Client certificate for mutual TLS; nil = none
+	Proxy        Proxy            // Proxy to connect through (HTTP CONNECT, HTTPS or SOCKS5); zero = direct connection
+	ProxyFromEnv bool             // Without Proxy.URL, pick the proxy per target from HTTPS_PROXY/NO_PROXY
+	ProxyHeader  ProxyHeader      // PROXY protocol header sent before the handshake; zero = none
+	OCSP         bool             // Check the leaf's revocation status with its OCSP responder
+	CRL          bool             // Download the CRLs named by the chain's distribution points
+	CRLs         []CRLFile        // CRLs loaded from disk, consulted before any download
+	CRLCache     string           // Directory caching downloaded CRLs; empty = no cache
+	AIAFetch     bool             // Fetch missing intermediates via AIA caIssuers when the chain is incomplete
+	ScanVersions bool             // Probe which TLS versions the server accepts, one handshake each
+	ScanCiphers  bool             // Probe which cipher suites the server accepts below TLS 1.3, and grade them
+	KeyTypes     bool             // Fetch the certificate the server presents for each key type (RSA, ECDSA)
+	DANE         bool             // Match the served chain against the TLSA records at _port._tcp.domain
+	CAA          bool             // Check the domain's CAA records authorize the leaf's issuer
+	CAAMap       CAAMap           // Issuer → CAA identifier entries checked before the built-in table
+	DNSInfo      bool             // Look up the domain\'s CNAME chain and final addresses
+	ExpectCNAME  string           // Suffix the CNAME chain must end under; implies DNSInfo
+	DNSResolver  string           // DNS resolver (host[:port]) for the TLSA, CAA and CNAME lookups; empty = the system's
+	Resolvers    []string         // Resolvers tried in turn for the target's address when none is given; empty = the system's
+	At           time.Time        // Evaluate expiry, validity and the chain at this instant (-at); zero = the current time
 }
 
 // CertificateFetcher defines an interface for fetching certificates from a domain or IP address.
@@ -63,15 +136,16 @@ type CertificateLoader interface {
 
 // PrintOptions controls how certificate information is rendered.
 type PrintOptions struct {
-	Short     bool // Print only the number of days remaining
-	JSON      bool // Print machine-readable JSON
-	Threshold int  // Days threshold for the expiry warning highlight (0 = disabled)
-	Color     bool // Colorize the human-readable output
-	Chain     bool // Print every certificate in the chain
+	Short             bool      // Print only the number of days remaining
+	JSON              bool      // Print machine-readable JSON
+	Threshold         Threshold // Expiry warning level (zero = disabled)
+	CriticalThreshold Threshold // Expiry critical level, at most Threshold (zero = disabled)
+	Color             bool      // Colorize the human-readable output
+	Chain             bool      // Print every certificate in the chain
 
-	Fingerprint  bool   // Print the certificate and public-key SHA-256 fingerprints
-	Pin          string // Normalized hex pin to verify against (empty = disabled)
-	ExpectIssuer string // Warn when the issuer does not contain this substring (empty = disabled)
+	Fingerprint  bool     // Print the certificate and public-key SHA-256 fingerprints
+	Pins         []string // Normalized hex pins; the certificate must match one of them (empty = disabled)
+	ExpectIssuer string   // Warn when the issuer does not contain this substring (empty = disabled)
 }
 
 // CertificatePrinter defines an interface for printing certificate details.
@@ -80,22 +154,46 @@ type CertificatePrinter interface {
 	Print(info *CertInfo, opts PrintOptions)
 }
 
-// DaysUntilExpiry returns the whole number of days until the certificate expires.
-// The value is negative if the certificate has already expired.
-func DaysUntilExpiry(cert *x509.Certificate) int {
-	return int(time.Until(cert.NotAfter).Hours() / 24)
+// Now returns the instant info's expiry, validity and threshold checks are
+// evaluated at: At when it is set (-at), else the current time. Revocation
+// freshness, the CRL cache and network deadlines stay on the wall clock.
+func (info *CertInfo) Now() time.Time {
+	if !info.At.IsZero() {
+		return info.At
+	}
+	return time.Now()
+}
+
+// DaysUntilExpiry returns the whole number of days from now until the
+// certificate expires. The value is negative if it has already expired.
+func DaysUntilExpiry(cert *x509.Certificate, now time.Time) int {
+	return int(cert.NotAfter.Sub(now).Hours() / 24)
+}
+
+// SecondsUntilExpiry returns the exact number of seconds from now until the
+// certificate expires, negative once it has.
+func SecondsUntilExpiry(cert *x509.Certificate, now time.Time) int64 {
+	return int64(cert.NotAfter.Sub(now) / time.Second)
 }
 
 // MinDaysUntilExpiry returns the smallest days-until-expiry across the whole
-// chain (leaf plus any intermediates). When no chain is recorded (file load) it
-// falls back to the leaf, so callers can drive the expiry exit code off the
-// weakest link rather than the leaf alone.
+// chain (leaf plus any intermediates) and the chains found by -key-types. When
+// no chain is recorded (file load) it falls back to the leaf, so callers can
+// drive the expiry exit code off the weakest link rather than the leaf alone.
 func (info *CertInfo) MinDaysUntilExpiry() int {
-	min := DaysUntilExpiry(info.Cert)
+	now := info.Now()
+	min := DaysUntilExpiry(info.Cert, now)
 	for _, c := range info.Chain {
-		if d := DaysUntilExpiry(c); d < min {
+		if d := DaysUntilExpiry(c, now); d < min {
 			min = d
 		}
 	}
+	for _, k := range info.KeyTypes {
+		if k.Info != nil {
+			if d := k.Info.MinDaysUntilExpiry(); d < min {
+				min = d
+			}
+		}
+	}
 	return min
 }
@@ -2,34 +2,76 @@ package cert
 
 import (
 	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 )
 
 // TestDaysUntilExpiry verifies the day arithmetic for future and past expiry.
 func TestDaysUntilExpiry(t *testing.T) {
-	future := &x509.Certificate{NotAfter: time.Now().Add(10*24*time.Hour + time.Hour)}
-	if got := DaysUntilExpiry(future); got != 10 {
+	future := &x509.Certificate{NotAfter: testNow.Add(10*24*time.Hour + time.Hour)}
+	if got := DaysUntilExpiry(future, testNow); got != 10 {
 		t.Errorf("expected 10 days remaining, got %d", got)
 	}
-	past := &x509.Certificate{NotAfter: time.Now().Add(-2 * 24 * time.Hour)}
-	if got := DaysUntilExpiry(past); got >= 0 {
-		t.Errorf("expected negative days for expired cert, got %d", got)
+	if got := SecondsUntilExpiry(future, testNow); got != 10*86400+3600 {
+		t.Errorf("expected %d seconds remaining, got %d", 10*86400+3600, got)
+	}
+	past := &x509.Certificate{NotAfter: testNow.Add(-2 * 24 * time.Hour)}
+	if got := DaysUntilExpiry(past, testNow); got != -2 {
+		t.Errorf("expected -2 days for expired cert, got %d", got)
+	}
+}
+
+// TestCertInfo_At moves the evaluation instant past a certificate's expiry and
+// before its start: days, validity, thresholds and chain verification all
+// follow it, and a zero At falls back to the current time.
+func TestCertInfo_At(t *testing.T) {
+	c := genCert(t, "at.example", testNow.Add(30*24*time.Hour))
+	roots := x509.NewCertPool()
+	roots.AddCert(c)
+	if err := verifyChain([]*x509.Certificate{c}, "at.example", roots, testNow); err != nil {
+		t.Fatalf("verify at testNow: %v", err)
+	}
+	if now := (&CertInfo{}).Now(); time.Since(now) > time.Minute {
+		t.Errorf("zero At: expected the current time, got %v", now)
+	}
+
+	info := &CertInfo{Cert: c, At: testNow.Add(40 * 24 * time.Hour)}
+	if got := info.MinDaysUntilExpiry(); got != -10 {
+		t.Errorf("expected -10 days at -at, got %d", got)
+	}
+	if !info.Expiring(ThresholdDays(1)) {
+		t.Error("expected the threshold to be reached at -at")
+	}
+	var invalid x509.CertificateInvalidError
+	if err := verifyChain([]*x509.Certificate{c}, "at.example", roots, info.At); !errors.As(err, &invalid) || invalid.Reason != x509.Expired {
+		t.Errorf("expected an expired chain at -at, got %v", err)
+	}
+	b, _ := json.Marshal(Payload(info, "", false, false))
+	if !strings.Contains(string(b), `"evaluated_at":"2026-07-11T12:00:00Z"`) || !strings.Contains(string(b), `"days_remaining":-10`) {
+		t.Errorf("expected evaluated_at and the shifted days in JSON, got %s", b)
+	}
+
+	info.At = testNow.Add(-24 * time.Hour)
+	if !notYetValid(c, info.Now()) {
+		t.Error("expected the certificate not to be valid yet before its NotBefore")
 	}
 }
 
 // TestMinDaysUntilExpiry verifies the minimum is taken across the whole chain
 // and falls back to the leaf when no chain is recorded.
 func TestMinDaysUntilExpiry(t *testing.T) {
-	leaf := &x509.Certificate{NotAfter: time.Now().Add(90*24*time.Hour + time.Hour)}
-	inter := &x509.Certificate{NotAfter: time.Now().Add(20*24*time.Hour + time.Hour)}
+	leaf := &x509.Certificate{NotAfter: testNow.Add(90*24*time.Hour + time.Hour)}
+	inter := &x509.Certificate{NotAfter: testNow.Add(20*24*time.Hour + time.Hour)}
 
-	withChain := &CertInfo{Cert: leaf, Chain: []*x509.Certificate{leaf, inter}}
+	withChain := &CertInfo{Cert: leaf, Chain: []*x509.Certificate{leaf, inter}, At: testNow}
 	if got := withChain.MinDaysUntilExpiry(); got != 20 {
 		t.Errorf("expected min 20 across chain, got %d", got)
 	}
 
-	leafOnly := &CertInfo{Cert: leaf}
+	leafOnly := &CertInfo{Cert: leaf, At: testNow}
 	if got := leafOnly.MinDaysUntilExpiry(); got != 90 {
 		t.Errorf("expected leaf fallback 90, got %d", got)
 	}
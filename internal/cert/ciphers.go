@@ -0,0 +1,203 @@
+package cert
+
+import (
+	"crypto/tls"
+	"strings"
+)
+
+// Cipher weakness severities, worst first. High and medium make a warning (see
+// HasWarnings); low is reported only.
+const (
+	severityHigh   = "high"
+	severityMedium = "medium"
+	severityLow    = "low"
+)
+
+// CipherScan is the result of -scan-ciphers: the suites accepted per protocol
+// version below TLS 1.3 (whose suites are not negotiable) and the overall grade.
+type CipherScan struct {
+	Versions []CipherVersion // One entry per scanned version, oldest first
+	Grade    string          // A, B, C, D or F (see cipherGrade)
+}
+
+// CipherVersion lists the cipher suites a server accepts with one protocol
+// version. Suites are in the server's preference order when it enforces one,
+// otherwise in Go's order.
+type CipherVersion struct {
+	Version          string
+	ServerPreference bool // The server picks its own favourite rather than the client's
+	Suites           []CipherSupport
+}
+
+// CipherSupport is one accepted suite and, for a weak one, why.
+type CipherSupport struct {
+	Name     string
+	Severity string // severityHigh/Medium/Low; empty when the suite is sound
+	Reason   string
+}
+
+// cipherWeakness classifies a suite by name: RC4 and 3DES are broken (high),
+// static-RSA key exchange lacks forward secrecy and CBC with SHA-1 is legacy
+// (medium), and other CBC suites are padding-oracle prone (low).
+func cipherWeakness(name string) (severity, reason string) {
+	switch {
+	case strings.Contains(name, "_RC4_"):
+		return severityHigh, "RC4 is broken"
+	case strings.Contains(name, "_3DES_"):
+		return severityHigh, "3DES has a 64-bit block (Sweet32)"
+	case strings.HasPrefix(name, "TLS_RSA_"):
+		return severityMedium, "no forward secrecy"
+	case strings.Contains(name, "_CBC_") && strings.HasSuffix(name, "_SHA"):
+		return severityMedium, "CBC with SHA-1"
+	case strings.Contains(name, "_CBC_"):
+		return severityLow, "CBC mode"
+	}
+	return "", ""
+}
+
+// candidateSuites returns every suite Go can offer with version v, secure ones
+// first, including tls.InsecureCipherSuites.
+func candidateSuites(v uint16) []*tls.CipherSuite {
+	var out []*tls.CipherSuite
+	for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		for _, sv := range s.SupportedVersions {
+			if sv == v {
+				out = append(out, s)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// negotiate runs one handshake with version v offering suites in order and
+// returns the suite the server chose; ok is false when the handshake failed.
+func negotiate(address string, opts FetchOptions, base *tls.Config, v uint16, suites []uint16) (chosen uint16, ok bool) {
+	cfg := base.Clone()
+	cfg.MinVersion, cfg.MaxVersion = v, v
+	cfg.CipherSuites = suites
+	conn, err := dialTLS(address, opts, cfg)
+	if err != nil {
+		return 0, false
+	}
+	defer conn.Close()
+	return conn.ConnectionState().CipherSuite, true
+}
+
+// scanCiphers probes, for TLS 1.0 to 1.2, which suites the server accepts with
+// one handshake per suite, then derives its preference order and grades the
+// result.
+func scanCiphers(address string, opts FetchOptions, base *tls.Config) *CipherScan {
+	out := &CipherScan{}
+	for _, v := range scannedVersions {
+		if v == tls.VersionTLS13 {
+			continue
+		}
+		var accepted []uint16
+		for _, s := range candidateSuites(v) {
+			if _, ok := negotiate(address, opts, base, v, []uint16{s.ID}); ok {
+				accepted = append(accepted, s.ID)
+			}
+		}
+		cv := CipherVersion{Version: tls.VersionName(v)}
+		order := accepted
+		if len(accepted) > 1 {
+			order, cv.ServerPreference = preferenceOrder(address, opts, base, v, accepted)
+		}
+		for _, id := range order {
+			name := tls.CipherSuiteName(id)
+			sev, reason := cipherWeakness(name)
+			cv.Suites = append(cv.Suites, CipherSupport{Name: name, Severity: sev, Reason: reason})
+		}
+		out.Versions = append(out.Versions, cv)
+	}
+	out.Grade = cipherGrade(out)
+	return out
+}
+
+// preferenceOrder works out whether the server imposes its own order on the
+// accepted suites: offered in two opposite orders, a server that honours the
+// client picks each list's first entry. If it does not, its order is recovered
+// by repeatedly offering the remaining suites and removing the one it picks.
+func preferenceOrder(address string, opts FetchOptions, base *tls.Config, v uint16, accepted []uint16) ([]uint16, bool) {
+	reversed := make([]uint16, len(accepted))
+	for i, id := range accepted {
+		reversed[len(accepted)-1-i] = id
+	}
+	first, ok1 := negotiate(address, opts, base, v, accepted)
+	last, ok2 := negotiate(address, opts, base, v, reversed)
+	if !ok1 || !ok2 || (first == accepted[0] && last == reversed[0]) {
+		return accepted, false
+	}
+	remaining := append([]uint16(nil), accepted...)
+	var order []uint16
+	for len(remaining) > 0 {
+		chosen, ok := negotiate(address, opts, base, v, remaining)
+		if !ok {
+			return append(order, remaining...), true
+		}
+		order = append(order, chosen)
+		for i, id := range remaining {
+			if id == chosen {
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+	}
+	return order, true
+}
+
+// cipherGrade grades a scan: F when RC4 is accepted, D for 3DES, C for suites
+// without forward secrecy or CBC with SHA-1, B for other CBC suites or any
+// suite accepted with TLS 1.0/1.1, and A otherwise.
+func cipherGrade(scan *CipherScan) string {
+	grade := "A"
+	lower := func(g string) {
+		if g > grade {
+			grade = g
+		}
+	}
+	for _, v := range scan.Versions {
+		if len(v.Suites) > 0 && (v.Version == "TLS 1.0" || v.Version == "TLS 1.1") {
+			lower("B")
+		}
+		for _, s := range v.Suites {
+			switch {
+			case strings.Contains(s.Name, "_RC4_"):
+				lower("F")
+			case s.Severity == severityHigh:
+				lower("D")
+			case s.Severity == severityMedium:
+				lower("C")
+			case s.Severity == severityLow:
+				lower("B")
+			}
+		}
+	}
+	return grade
+}
+
+// weakCiphers returns the accepted suites of high or medium severity (each
+// listed once, across versions), worst first.
+func weakCiphers(info *CertInfo) []CipherSupport {
+	if info.Ciphers == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var high, medium []CipherSupport
+	for _, v := range info.Ciphers.Versions {
+		for _, s := range v.Suites {
+			if seen[s.Name] {
+				continue
+			}
+			seen[s.Name] = true
+			switch s.Severity {
+			case severityHigh:
+				high = append(high, s)
+			case severityMedium:
+				medium = append(medium, s)
+			}
+		}
+	}
+	return append(high, medium...)
+}
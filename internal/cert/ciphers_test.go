@@ -0,0 +1,121 @@
+package cert
+
+import (
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCipherWeakness verifies the severity assigned to representative suites.
+func TestCipherWeakness(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", ""},
+		{"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256", ""},
+		{"TLS_ECDHE_RSA_WITH_RC4_128_SHA", severityHigh},
+		{"TLS_RSA_WITH_3DES_EDE_CBC_SHA", severityHigh},
+		{"TLS_RSA_WITH_AES_128_GCM_SHA256", severityMedium},
+		{"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA", severityMedium},
+		{"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256", severityLow},
+	}
+	for _, tt := range tests {
+		if got, _ := cipherWeakness(tt.name); got != tt.want {
+			t.Errorf("cipherWeakness(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestCipherGrade verifies the grade scale on hand-built scans.
+func TestCipherGrade(t *testing.T) {
+	suite := func(name string) CipherSupport {
+		sev, reason := cipherWeakness(name)
+		return CipherSupport{Name: name, Severity: sev, Reason: reason}
+	}
+	scan := func(version string, names ...string) *CipherScan {
+		v := CipherVersion{Version: version}
+		for _, n := range names {
+			v.Suites = append(v.Suites, suite(n))
+		}
+		return &CipherScan{Versions: []CipherVersion{{Version: "TLS 1.0"}, v}}
+	}
+	tests := []struct {
+		name string
+		scan *CipherScan
+		want string
+	}{
+		{"aead only", scan("TLS 1.2", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"), "A"},
+		{"cbc sha256", scan("TLS 1.2", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256"), "B"},
+		{"tls 1.1 enabled", scan("TLS 1.1", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"), "B"},
+		{"static rsa", scan("TLS 1.2", "TLS_RSA_WITH_AES_128_GCM_SHA256"), "C"},
+		{"3des", scan("TLS 1.2", "TLS_RSA_WITH_AES_128_GCM_SHA256", "TLS_RSA_WITH_3DES_EDE_CBC_SHA"), "D"},
+		{"rc4", scan("TLS 1.2", "TLS_RSA_WITH_3DES_EDE_CBC_SHA", "TLS_ECDHE_RSA_WITH_RC4_128_SHA"), "F"},
+	}
+	for _, tt := range tests {
+		if got := cipherGrade(tt.scan); got != tt.want {
+			t.Errorf("%s: grade %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestFetch_ScanCiphers runs -scan-ciphers against local servers restricted to
+// known suites and verifies the accepted set, the grade and the warning.
+func TestFetch_ScanCiphers(t *testing.T) {
+	scan := func(suites []uint16) *CertInfo {
+		t.Helper()
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		srv.TLS = &tls.Config{MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS12, CipherSuites: suites}
+		srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+		srv.StartTLS()
+		defer srv.Close()
+		host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+		info, err := (&CertificateFetcherImpl{}).Fetch(host, port, "", FetchOptions{Insecure: true, Timeout: 5 * time.Second, ScanCiphers: true})
+		if err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		return info
+	}
+	accepted := func(info *CertInfo) map[string]bool {
+		out := make(map[string]bool)
+		for _, v := range info.Ciphers.Versions {
+			for _, s := range v.Suites {
+				out[v.Version+" "+s.Name] = true
+			}
+		}
+		return out
+	}
+
+	modern := scan([]uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384})
+	if got := accepted(modern); len(got) != 2 || !got["TLS 1.2 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"] || !got["TLS 1.2 TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"] {
+		t.Errorf("modern server: accepted %v", got)
+	}
+	if modern.Ciphers.Grade != "A" || HasWarnings(modern) {
+		t.Errorf("modern server: grade %s, warnings %v", modern.Ciphers.Grade, HasWarnings(modern))
+	}
+
+	legacy := scan([]uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_AES_128_CBC_SHA, tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA})
+	if got := accepted(legacy); len(got) != 3 || !got["TLS 1.2 TLS_RSA_WITH_3DES_EDE_CBC_SHA"] {
+		t.Errorf("legacy server: accepted %v", got)
+	}
+	if legacy.Ciphers.Grade != "D" || !HasWarnings(legacy) {
+		t.Errorf("legacy server: grade %s, warnings %v", legacy.Ciphers.Grade, HasWarnings(legacy))
+	}
+	weak := weakCiphers(legacy)
+	if len(weak) != 2 || weak[0].Name != "TLS_RSA_WITH_3DES_EDE_CBC_SHA" {
+		t.Errorf("legacy server: weak suites %+v", weak)
+	}
+
+	out := captureStdout(t, func() { (&CertificatePrinterImpl{}).Print(legacy, PrintOptions{}) })
+	for _, want := range []string{"Cipher grade: D", "TLS_RSA_WITH_3DES_EDE_CBC_SHA [high: 3DES has a 64-bit block (Sweet32)]", "WARNING: 2 weak cipher suite(s) accepted, worst TLS_RSA_WITH_3DES_EDE_CBC_SHA"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package cert
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// maxCNAMEHops bounds how many CNAME records are followed from the domain,
+// in case a misconfigured zone loops.
+const maxCNAMEHops = 16
+
+// CNAMEHop is one CNAME record of the chain a domain resolves through.
+type CNAMEHop struct {
+	Name   string // Owner name
+	Target string // Canonical name it points to
+	TTL    uint32 // Seconds
+}
+
+// DNSAddr is one A/AAAA record at the end of the chain.
+type DNSAddr struct {
+	IP  string
+	TTL uint32 // Seconds
+}
+
+// DNSInfo is the outcome of -dns-info/-expect-cname: the CNAME chain the domain
+// resolves through, the address set it ends at and, with an expected suffix,
+// whether the chain ends under it. Err is set when the lookup failed.
+type DNSInfo struct {
+	Resolver  string     // The DNS resolver queried
+	CNAMEs    []CNAMEHop // In resolution order; empty when the domain has addresses of its own
+	Final     string     // The name the chain ends at: the last CNAME target, or the domain itself
+	Addresses []DNSAddr  // A records, then AAAA, of Final
+	Expected  string     // -expect-cname suffix; empty = report only
+	Match     bool       // Final equals Expected or ends in ".Expected"
+	Err       error
+}
+
+// Mismatch reports whether an expected suffix was given and the chain, looked
+// up successfully, does not end under it — reported with the mismatch exit code.
+func (d *DNSInfo) Mismatch() bool {
+	return d != nil && d.Expected != "" && d.Err == nil && !d.Match
+}
+
+// Inconclusive reports whether -expect-cname ran but the lookup failed. Treated
+// as a warning (see HasWarnings); a failed lookup that only reports is not.
+func (d *DNSInfo) Inconclusive() bool {
+	return d != nil && d.Expected != "" && d.Err != nil
+}
+
+// checkDNSInfo queries resolver (the system's when empty) for domain's A and
+// AAAA records, follows the CNAME chain in the answers and matches where it
+// ends against expected.
+func checkDNSInfo(domain, resolver, expected string, timeout time.Duration) *DNSInfo {
+	out := &DNSInfo{Resolver: resolverAddress(resolver), Expected: expected}
+	if net.ParseIP(domain) != nil {
+		out.Err = errors.New("CNAME lookup does not apply to an IP address")
+		return out
+	}
+	out.Final = strings.TrimSuffix(domain, ".")
+	for _, qtype := range []uint16{dnsTypeA, dnsTypeAAAA} {
+		resp, err := queryDNS(out.Resolver, domain, qtype, timeout)
+		if err != nil {
+			out.Err = err
+			return out
+		}
+		hops, final := followCNAMEs(resp.Answers, strings.TrimSuffix(domain, "."))
+		if len(hops) > len(out.CNAMEs) {
+			out.CNAMEs, out.Final = hops, final
+		}
+		for _, rr := range resp.Answers {
+			if rr.Type == qtype && rr.Class == dnsClassIN && strings.EqualFold(rr.Name, final) {
+				out.Addresses = append(out.Addresses, DNSAddr{IP: net.IP(rr.Data).String(), TTL: rr.TTL})
+			}
+		}
+	}
+	if len(out.CNAMEs) == 0 && len(out.Addresses) == 0 {
+		out.Err = fmt.Errorf("no CNAME or address records for %s", out.Final)
+		return out
+	}
+	out.Match = cnameMatches(out.Final, expected)
+	return out
+}
+
+// followCNAMEs walks the CNAME records in answers from name and returns the
+// hops taken and the name the chain ends at.
+func followCNAMEs(answers []dnsAnswer, name string) ([]CNAMEHop, string) {
+	var hops []CNAMEHop
+	for len(hops) < maxCNAMEHops {
+		next := -1
+		for i, rr := range answers {
+			if rr.Type == dnsTypeCNAME && strings.EqualFold(rr.Name, name) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+		rr := answers[next]
+		hops = append(hops, CNAMEHop{Name: rr.Name, Target: rr.Target, TTL: rr.TTL})
+		name = rr.Target
+	}
+	return hops, name
+}
+
+// cnameMatches reports whether name is suffix or a subdomain of it,
+// case-insensitively. A leading dot on suffix is ignored.
+func cnameMatches(name, suffix string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	suffix = strings.ToLower(strings.Trim(suffix, "."))
+	return name == suffix || strings.HasSuffix(name, "."+suffix)
+}
@@ -0,0 +1,192 @@
+package cert
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cnameRR is one record the CNAME stub serves: a CNAME when target is set,
+// otherwise an A or AAAA record for ip.
+type cnameRR struct {
+	name, target, ip string
+	ttl              uint32
+}
+
+// cnameStub is a UDP DNS server that answers a query the way a recursive
+// resolver does: the CNAME chain from the question name, then the records of
+// the query type at the end of it. Owner names are written out in full.
+func cnameStub(t *testing.T, zone []cnameRR) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	encode := func(name string) []byte {
+		var out []byte
+		for _, l := range strings.Split(name, ".") {
+			out = append(append(out, byte(len(l))), l...)
+		}
+		return append(out, 0)
+	}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			q := buf[:n]
+			name, err := readDNSName(q, 12)
+			if err != nil {
+				continue
+			}
+			off, _ := skipDNSName(q, 12)
+			qtype := binary.BigEndian.Uint16(q[off:])
+			var records [][]byte
+			rr := func(owner string, typ uint16, ttl uint32, rdata []byte) {
+				r := encode(owner)
+				r = binary.BigEndian.AppendUint16(r, typ)
+				r = binary.BigEndian.AppendUint16(r, dnsClassIN)
+				r = binary.BigEndian.AppendUint32(r, ttl)
+				r = binary.BigEndian.AppendUint16(r, uint16(len(rdata)))
+				records = append(records, append(r, rdata...))
+			}
+			for _, z := range zone {
+				if z.name == name && z.target != "" {
+					rr(z.name, dnsTypeCNAME, z.ttl, encode(z.target))
+					name = z.target
+				}
+			}
+			for _, z := range zone {
+				ip := net.ParseIP(z.ip)
+				switch {
+				case z.name != name || ip == nil:
+				case qtype == dnsTypeA && ip.To4() != nil:
+					rr(z.name, dnsTypeA, z.ttl, ip.To4())
+				case qtype == dnsTypeAAAA && ip.To4() == nil:
+					rr(z.name, dnsTypeAAAA, z.ttl, ip.To16())
+				}
+			}
+			resp := append([]byte{}, q[:2]...)
+			resp = binary.BigEndian.AppendUint16(resp, 0x8180)
+			resp = binary.BigEndian.AppendUint16(resp, 1)
+			resp = binary.BigEndian.AppendUint16(resp, uint16(len(records)))
+			resp = append(resp, 0, 0, 0, 0)
+			resp = append(resp, q[12:off+4]...)
+			for _, r := range records {
+				resp = append(resp, r...)
+			}
+			_, _ = pc.WriteTo(resp, addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func TestCheckDNSInfo(t *testing.T) {
+	resolver := cnameStub(t, []cnameRR{
+		{name: "www.example.com", target: "www.example.com.cdn.net", ttl: 300},
+		{name: "www.example.com.cdn.net", target: "edge.cdn.net", ttl: 60},
+		{name: "edge.cdn.net", ip: "192.0.2.1", ttl: 20},
+		{name: "edge.cdn.net", ip: "2001:db8::1", ttl: 20},
+		{name: "plain.example.com", ip: "192.0.2.9", ttl: 3600},
+	})
+
+	d := checkDNSInfo("www.example.com", resolver, "CDN.net", 2*time.Second)
+	if d.Err != nil || len(d.CNAMEs) != 2 || d.Final != "edge.cdn.net" || !d.Match || d.Mismatch() {
+		t.Fatalf("chain: unexpected result %+v", d)
+	}
+	if h := d.CNAMEs[0]; h.Name != "www.example.com" || h.Target != "www.example.com.cdn.net" || h.TTL != 300 {
+		t.Errorf("first hop %+v", h)
+	}
+	if len(d.Addresses) != 2 || d.Addresses[0] != (DNSAddr{IP: "192.0.2.1", TTL: 20}) || d.Addresses[1].IP != "2001:db8::1" {
+		t.Errorf("addresses %+v", d.Addresses)
+	}
+	out := captureStdout(t, func() { printDNSText(d, false) })
+	for _, want := range []string{
+		"DNS: www.example.com → www.example.com.cdn.net (CNAME, TTL 300s) → edge.cdn.net (CNAME, TTL 60s)",
+		"Addresses: 192.0.2.1 (TTL 20s), 2001:db8::1 (TTL 20s)",
+		"CNAME: MATCH (ends at edge.cdn.net, expected suffix CDN.net)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	if d := checkDNSInfo("www.example.com", resolver, "other.net", 2*time.Second); !d.Mismatch() {
+		t.Errorf("other suffix: expected a mismatch, got %+v", d)
+	}
+	// A name without a CNAME ends at itself.
+	if d := checkDNSInfo("plain.example.com", resolver, "example.com", 2*time.Second); d.Err != nil || len(d.CNAMEs) != 0 || d.Final != "plain.example.com" || !d.Match {
+		t.Errorf("no CNAME: unexpected result %+v", d)
+	}
+	if d := checkDNSInfo("missing.example.com", resolver, "cdn.net", 2*time.Second); !d.Inconclusive() || d.Mismatch() {
+		t.Errorf("no records: expected an inconclusive result, got %+v", d)
+	}
+	if d := checkDNSInfo("missing.example.com", resolver, "", 2*time.Second); d.Err == nil || d.Inconclusive() {
+		t.Errorf("no records, report only: expected an error but no warning, got %+v", d)
+	}
+}
+
+func TestCNAMEMatches(t *testing.T) {
+	for _, tt := range []struct {
+		name, suffix string
+		want         bool
+	}{
+		{"edge.cdn.net", "cdn.net", true},
+		{"edge.cdn.net.", ".cdn.net", true},
+		{"cdn.net", "cdn.net", true},
+		{"edge.notcdn.net", "cdn.net", false},
+		{"cdn.net.evil.com", "cdn.net", false},
+	} {
+		if got := cnameMatches(tt.name, tt.suffix); got != tt.want {
+			t.Errorf("cnameMatches(%q, %q) = %v, want %v", tt.name, tt.suffix, got, tt.want)
+		}
+	}
+}
+
+// TestFetch_ExpectCNAME looks the chain up through the resolver that gave the
+// address and carries it into the JSON payload.
+func TestFetch_ExpectCNAME(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	resolver := cnameStub(t, []cnameRR{
+		{name: "www.example.com", target: "edge.cdn.net", ttl: 300},
+		{name: "edge.cdn.net", ip: "127.0.0.1", ttl: 20},
+	})
+
+	info, err := (&CertificateFetcherImpl{}).Fetch("www.example.com", port, "", FetchOptions{Insecure: true, Timeout: 5 * time.Second, Resolvers: []string{resolver}, ExpectCNAME: "other.net"})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if info.DNS == nil || !info.DNS.Mismatch() || info.DNS.Final != "edge.cdn.net" {
+		t.Fatalf("expected a CNAME mismatch, got %+v", info.DNS)
+	}
+	var got struct {
+		DNS struct {
+			CNAMEs []struct {
+				Target string `json:"target"`
+				TTL    uint32 `json:"ttl"`
+			} `json:"cnames"`
+			FinalName  string `json:"final_name"`
+			CNAMEMatch *bool  `json:"cname_match"`
+		} `json:"dns"`
+	}
+	b, _ := json.Marshal(Payload(info, "www.example.com", false, false))
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(got.DNS.CNAMEs) != 1 || got.DNS.CNAMEs[0].TTL != 300 || got.DNS.FinalName != "edge.cdn.net" || got.DNS.CNAMEMatch == nil || *got.DNS.CNAMEMatch {
+		t.Errorf("unexpected dns payload %s", b)
+	}
+	if code, d := nagiosEval(PromSample{Domain: "www.example.com", Info: info}, PrintOptions{}, false); code != nagiosCritical || !strings.Contains(d, "CNAME") {
+		t.Errorf("nagios: code=%d detail=%q", code, d)
+	}
+}
@@ -0,0 +1,267 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CRL error kinds, reported next to the chain error kinds (see classifyCRLErr).
+const (
+	crlExpired      = "crl_expired"      // the CRL is past its NextUpdate
+	crlUnverifiable = "crl_unverifiable" // the CRL's issuer or signature does not check out
+	crlUnavailable  = "crl_unavailable"  // no CRL could be obtained
+)
+
+// crlError is why a CRL check gave no verdict, tagged with its kind.
+type crlError struct {
+	kind string
+	err  error
+}
+
+func (e *crlError) Error() string { return e.err.Error() }
+
+// CRLFile is a certificate revocation list loaded from disk (-crlfile).
+type CRLFile struct {
+	Path string
+	List *x509.RevocationList
+}
+
+// maxCRLSize bounds how much of a downloaded CRL is read; CRLs of large public
+// CAs run to several megabytes.
+const maxCRLSize = 32 << 20
+
+// parseCRL decodes a CRL in DER or PEM ("X509 CRL") form.
+func parseCRL(data []byte) (*x509.RevocationList, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+		}
+		data = block.Bytes
+	}
+	return x509.ParseRevocationList(data)
+}
+
+// DefaultCRLCacheDir is where downloaded CRLs are cached when -crl-cache is not
+// given: ssl-watch/crl under the user's cache directory. Empty when the system
+// has no cache directory, which disables caching.
+func DefaultCRLCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ssl-watch", "crl")
+}
+
+// anchoredChain returns the served chain extended to its trust anchor when it
+// verifies against roots (nil = the system store), so the CRL of a certificate
+// issued by a root the server did not send can still be verified. Otherwise it
+// returns the served chain unchanged.
+func anchoredChain(certs []*x509.Certificate, roots *x509.CertPool) []*x509.Certificate {
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	chains, err := certs[0].Verify(x509.VerifyOptions{
+		Intermediates: intermediates,
+		Roots:         roots,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil || len(chains) == 0 {
+		return certs
+	}
+	return chains[0]
+}
+
+// checkCRL checks every certificate of the chain below its root against a CRL:
+// a -crlfile naming its issuer, else (with opts.CRL) the CRL at its distribution
+// points. The verdict is the first revoked certificate, else the first check
+// that failed, else the leaf's good status. Certificates no CRL covers are
+// skipped; when none is covered the check fails as crl_unavailable.
+func checkCRL(certs []*x509.Certificate, opts FetchOptions) *Revocation {
+	chain := anchoredChain(certs, opts.Roots)
+	var verdict *Revocation
+	for i, c := range chain {
+		if bytes.Equal(c.RawSubject, c.RawIssuer) {
+			continue // a root is trusted as such, not revoked by a CRL
+		}
+		r := checkCertCRL(c, chain, opts)
+		if r == nil {
+			continue
+		}
+		if i > 0 {
+			r.Subject = subjectName(c)
+		}
+		if r.Revoked() {
+			return r
+		}
+		if verdict == nil || (verdict.Err == nil && r.Err != nil) {
+			verdict = r
+		}
+	}
+	if verdict == nil {
+		return &Revocation{Source: "crl", Err: &crlError{crlUnavailable, errors.New("no CRL covers the certificate chain")}}
+	}
+	return verdict
+}
+
+// checkCertCRL returns c's status from the first CRL that gives a verdict, or
+// the last failure; nil when no CRL source covers c.
+func checkCertCRL(c *x509.Certificate, chain []*x509.Certificate, opts FetchOptions) *Revocation {
+	issuer := findIssuer(c, chain)
+	var out *Revocation
+	for _, f := range opts.CRLs {
+		if !bytes.Equal(f.List.RawIssuer, c.RawIssuer) {
+			continue
+		}
+		if out = evalCRL(f.List, f.Path, c, issuer); out.Err == nil {
+			return out
+		}
+	}
+	if !opts.CRL {
+		return out
+	}
+	for _, u := range c.CRLDistributionPoints {
+		list, err := fetchCRL(u, opts)
+		if err != nil {
+			out = &Revocation{Source: "crl", Responder: u, Err: &crlError{crlUnavailable, err}}
+			continue
+		}
+		if out = evalCRL(list, u, c, issuer); out.Err == nil {
+			return out
+		}
+	}
+	return out
+}
+
+// evalCRL verifies list against issuer and looks c's serial up in it. A listed
+// serial is reported revoked even from an expired CRL — revocation is final —
+// while an unlisted one only counts as good while the CRL is current.
+func evalCRL(list *x509.RevocationList, where string, c, issuer *x509.Certificate) *Revocation {
+	out := &Revocation{Source: "crl", Responder: where, ThisUpdate: list.ThisUpdate, NextUpdate: list.NextUpdate}
+	switch {
+	case issuer == nil:
+		out.Err = &crlError{crlUnverifiable, errors.New("issuer certificate not available; cannot verify the CRL")}
+		return out
+	case !bytes.Equal(list.RawIssuer, issuer.RawSubject):
+		out.Err = &crlError{crlUnverifiable, fmt.Errorf("CRL is issued by %s, not by the certificate's issuer", list.Issuer)}
+		return out
+	}
+	if err := list.CheckSignatureFrom(issuer); err != nil {
+		out.Err = &crlError{crlUnverifiable, fmt.Errorf("CRL signature does not verify: %v", err)}
+		return out
+	}
+	for _, e := range list.RevokedCertificateEntries {
+		if e.SerialNumber.Cmp(c.SerialNumber) == 0 {
+			out.Status, out.RevokedAt = RevocationRevoked, e.RevocationTime
+			if e.ReasonCode != 0 {
+				out.Reason = reasonName(e.ReasonCode)
+			}
+			return out
+		}
+	}
+	if !list.NextUpdate.IsZero() && time.Now().After(list.NextUpdate) {
+		out.Err = &crlError{crlExpired, fmt.Errorf("CRL expired on %s", list.NextUpdate.Format(dateFormat))}
+		return out
+	}
+	out.Status = RevocationGood
+	return out
+}
+
+// crlCachePath is the file caching the CRL downloaded from u, or "" when dir is
+// empty (caching disabled).
+func crlCachePath(dir, u string) string {
+	if dir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(u))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".crl")
+}
+
+// fetchCRL returns the CRL published at u. A cached copy is used while it is
+// before its NextUpdate; otherwise the CRL is downloaded (through -proxy, when
+// set) and the cache refreshed. When the download fails an outdated cached copy
+// is returned instead, so the check reports an expired CRL rather than none.
+func fetchCRL(u string, opts FetchOptions) (*x509.RevocationList, error) {
+	path := crlCachePath(opts.CRLCache, u)
+	var cached *x509.RevocationList
+	if path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			if list, err := parseCRL(data); err == nil {
+				if !list.NextUpdate.IsZero() && time.Now().Before(list.NextUpdate) {
+					return list, nil
+				}
+				cached = list
+			}
+		}
+	}
+	data, err := downloadCRL(u, opts.Timeout, opts.Proxy)
+	if err == nil {
+		var list *x509.RevocationList
+		if list, err = parseCRL(data); err == nil {
+			if path != "" {
+				writeCRLCache(path, data)
+			}
+			return list, nil
+		}
+		err = fmt.Errorf("failed to parse CRL from %s: %v", u, err)
+	}
+	if cached != nil {
+		return cached, nil
+	}
+	return nil, err
+}
+
+// downloadCRL fetches the raw CRL at u over HTTP(S); other schemes (ldap) are
+// not supported.
+func downloadCRL(u string, timeout time.Duration, proxy Proxy) ([]byte, error) {
+	parsed, err := url.Parse(u)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, fmt.Errorf("unsupported CRL distribution point %q", u)
+	}
+	client, err := httpClient(timeout, proxy)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, fmt.Errorf("CRL download failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CRL download from %s returned HTTP %d", u, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCRLSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CRL from %s: %v", u, err)
+	}
+	return data, nil
+}
+
+// writeCRLCache stores a downloaded CRL at path, via a temporary file renamed
+// into place so concurrent checks never read a partial file. The cache is best
+// effort: a failure to write it only costs a download next time.
+func writeCRLCache(path string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".crl-*")
+	if err != nil {
+		return
+	}
+	_, werr := tmp.Write(data)
+	cerr := tmp.Close()
+	if werr != nil || cerr != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,140 @@
+package cert
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// signCRL issues a DER CRL from ca listing revoked, valid until next.
+func signCRL(t *testing.T, ca ocspCA, next time.Time, revoked ...x509.RevocationListEntry) []byte {
+	t.Helper()
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                next.Add(-24 * time.Hour),
+		NextUpdate:                next,
+		RevokedCertificateEntries: revoked,
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatalf("create CRL: %v", err)
+	}
+	return der
+}
+
+// TestCheckCRL downloads the leaf's CRL from its distribution point and reports
+// good / revoked (with time and reason), caches it until its NextUpdate, and
+// classifies an expired or forged CRL as a distinct warning kind.
+func TestCheckCRL(t *testing.T) {
+	ca := newOCSPCA(t)
+	var answer []byte
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_, _ = w.Write(answer)
+	}))
+	defer srv.Close()
+
+	leaf, _ := ca.issue(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(4242),
+		Subject:               pkix.Name{CommonName: "leaf.example"},
+		CRLDistributionPoints: []string{srv.URL + "/ca.crl"},
+	})
+	chain := []*x509.Certificate{leaf, ca.cert}
+	revokedAt := time.Now().UTC().Add(-48 * time.Hour).Truncate(time.Second)
+	entry := x509.RevocationListEntry{SerialNumber: leaf.SerialNumber, RevocationTime: revokedAt, ReasonCode: 1}
+	check := func(cache string) *Revocation {
+		return checkCRL(chain, FetchOptions{CRL: true, CRLCache: cache, Timeout: 5 * time.Second})
+	}
+
+	t.Run("good and cached", func(t *testing.T) {
+		cache := t.TempDir()
+		answer = signCRL(t, ca, time.Now().Add(24*time.Hour))
+		hits.Store(0)
+		r := check(cache)
+		if r.Err != nil || r.Status != RevocationGood || r.Source != "crl" || r.Responder != srv.URL+"/ca.crl" {
+			t.Fatalf("expected good from the distribution point, got %+v", r)
+		}
+		if r2 := check(cache); r2.Status != RevocationGood || hits.Load() != 1 {
+			t.Errorf("expected the cached CRL to be reused, got %d downloads (%+v)", hits.Load(), r2)
+		}
+		if files, _ := filepath.Glob(filepath.Join(cache, "*.crl")); len(files) != 1 {
+			t.Errorf("expected one cached CRL, got %v", files)
+		}
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		answer = signCRL(t, ca, time.Now().Add(24*time.Hour), entry)
+		r := check("")
+		if !r.Revoked() || !r.RevokedAt.Equal(revokedAt) || r.Reason != "keyCompromise" || r.Subject != "" {
+			t.Fatalf("expected revoked on %s (keyCompromise), got %+v", revokedAt, r)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		cache := t.TempDir()
+		answer = signCRL(t, ca, time.Now().Add(-time.Hour))
+		hits.Store(0)
+		info := &CertInfo{Cert: leaf, CRL: check(cache)}
+		if kind, _ := classifyCRLErr(info); kind != crlExpired || !HasWarnings(info) {
+			t.Fatalf("expected %s, got %+v", crlExpired, info.CRL)
+		}
+		// An expired CRL is not served from the cache.
+		check(cache)
+		if hits.Load() != 2 {
+			t.Errorf("expected an expired cached CRL to be downloaded again, got %d downloads", hits.Load())
+		}
+	})
+
+	t.Run("forged signature", func(t *testing.T) {
+		answer = signCRL(t, newOCSPCA(t), time.Now().Add(24*time.Hour), entry)
+		info := &CertInfo{Cert: leaf, CRL: check("")}
+		if kind, _ := classifyCRLErr(info); kind != crlUnverifiable || info.CRL.Revoked() {
+			t.Fatalf("expected %s, got %+v", crlUnverifiable, info.CRL)
+		}
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		answer = []byte("not a CRL")
+		info := &CertInfo{Cert: leaf, CRL: check("")}
+		if kind, _ := classifyCRLErr(info); kind != crlUnavailable {
+			t.Fatalf("expected %s, got %+v", crlUnavailable, info.CRL)
+		}
+	})
+}
+
+// TestCheckCRL_File verifies -crlfile CRLs are matched by issuer and checked
+// without any download, and that a chain no CRL covers is reported unavailable.
+func TestCheckCRL_File(t *testing.T) {
+	ca := newOCSPCA(t)
+	leaf, _ := ca.issue(t, &x509.Certificate{SerialNumber: big.NewInt(7), Subject: pkix.Name{CommonName: "file.example"}})
+	chain := []*x509.Certificate{leaf, ca.cert}
+
+	path := filepath.Join(t.TempDir(), "ca.crl")
+	der := signCRL(t, ca, time.Now().Add(24*time.Hour), x509.RevocationListEntry{SerialNumber: big.NewInt(7), RevocationTime: time.Now().Add(-time.Hour)})
+	if err := os.WriteFile(path, der, 0o644); err != nil {
+		t.Fatalf("write CRL: %v", err)
+	}
+	files, err := LoadCRLFiles([]string{path})
+	if err != nil {
+		t.Fatalf("LoadCRLFiles: %v", err)
+	}
+
+	if r := checkCRL(chain, FetchOptions{CRLs: files}); !r.Revoked() || r.Responder != path {
+		t.Errorf("expected revoked from %s, got %+v", path, r)
+	}
+	info := &CertInfo{Cert: leaf, CRL: checkCRL(chain, FetchOptions{})}
+	if kind, _ := classifyCRLErr(info); kind != crlUnavailable {
+		t.Errorf("expected %s without any CRL source, got %+v", crlUnavailable, info.CRL)
+	}
+	if _, err := LoadCRLFiles([]string{filepath.Join(t.TempDir(), "missing.crl")}); err == nil {
+		t.Error("expected an error for a missing CRL file")
+	}
+}
@@ -0,0 +1,209 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TLSA certificate usages (RFC 6698 §2.1.1). The PKIX usages also require the
+// chain to verify against the trust store; the DANE usages stand on their own.
+const (
+	tlsaPKIXTA = 0 // CA constraint: a CA in the verified chain
+	tlsaPKIXEE = 1 // service certificate constraint: the leaf, verified
+	tlsaDANETA = 2 // trust anchor assertion: a certificate of the served chain
+	tlsaDANEEE = 3 // domain-issued certificate: the leaf itself
+)
+
+// tlsaUsageNames and friends give the RFC 7218 acronyms used in the output.
+var (
+	tlsaUsageNames    = []string{"PKIX-TA", "PKIX-EE", "DANE-TA", "DANE-EE"}
+	tlsaSelectorNames = []string{"Cert", "SPKI"}
+	tlsaMatchingNames = []string{"Full", "SHA2-256", "SHA2-512"}
+)
+
+// TLSARecord is one TLSA resource record and whether the served chain matched it.
+type TLSARecord struct {
+	Usage        uint8
+	Selector     uint8
+	MatchingType uint8
+	Data         []byte
+	Matched      bool
+}
+
+// String renders the record in zone-file order with its mnemonics, e.g.
+// "3 1 1 (DANE-EE SPKI SHA2-256) 8f3a…".
+func (r TLSARecord) String() string {
+	data := hex.EncodeToString(r.Data)
+	if len(data) > 16 {
+		data = data[:16] + "…"
+	}
+	return fmt.Sprintf("%d %d %d (%s %s %s) %s", r.Usage, r.Selector, r.MatchingType,
+		tlsaName(tlsaUsageNames, r.Usage), tlsaName(tlsaSelectorNames, r.Selector), tlsaName(tlsaMatchingNames, r.MatchingType), data)
+}
+
+// tlsaName returns names[v], or "?" for a value outside the registry.
+func tlsaName(names []string, v uint8) string {
+	if int(v) < len(names) {
+		return names[v]
+	}
+	return "?"
+}
+
+// DANEResult is the outcome of -dane: the TLSA records published for the
+// service and which of them the served chain matched. Err is set, and Records
+// empty, when the records could not be obtained or none exist.
+type DANEResult struct {
+	Name     string       // The TLSA owner name, _port._tcp.host
+	Resolver string       // The DNS resolver queried
+	Secure   bool         // The resolver validated the records with DNSSEC (AD bit)
+	Records  []TLSARecord // Every TLSA record at Name
+	Err      error
+}
+
+// Matched returns the records the served chain matched.
+func (d *DANEResult) Matched() []TLSARecord {
+	var out []TLSARecord
+	for _, r := range d.Records {
+		if r.Matched {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Mismatch reports whether TLSA records were found but the served chain matches
+// none of them — the failure DANE exists to catch, reported with the mismatch
+// exit code.
+func (d *DANEResult) Mismatch() bool {
+	return d != nil && d.Err == nil && len(d.Records) > 0 && len(d.Matched()) == 0
+}
+
+// Inconclusive reports whether -dane ran but could not vouch for the chain: the
+// lookup failed, no records exist, or they match but are not DNSSEC-validated.
+// Treated as a warning (see HasWarnings).
+func (d *DANEResult) Inconclusive() bool {
+	return d != nil && !d.Mismatch() && (d.Err != nil || !d.Secure)
+}
+
+// parseTLSA decodes TLSA RDATA: usage, selector, matching type, then the
+// certificate association data.
+func parseTLSA(rdata []byte) (TLSARecord, error) {
+	if len(rdata) < 4 {
+		return TLSARecord{}, errors.New("TLSA record too short")
+	}
+	return TLSARecord{Usage: rdata[0], Selector: rdata[1], MatchingType: rdata[2], Data: rdata[3:]}, nil
+}
+
+// checkDANE looks up the TLSA records at _port._tcp.host through resolver (the
+// system's when empty) and matches them against info's served chain and the
+// root it verifies to under roots.
+func checkDANE(info *CertInfo, host, port, resolver string, roots *x509.CertPool, timeout time.Duration) *DANEResult {
+	out := &DANEResult{Name: fmt.Sprintf("_%s._tcp.%s", port, strings.TrimSuffix(host, ".")), Resolver: resolverAddress(resolver)}
+	resp, err := queryDNS(out.Resolver, out.Name, dnsTypeTLSA, timeout)
+	if err != nil {
+		out.Err = err
+		return out
+	}
+	out.Secure = resp.Authenticated
+	anchor := trustAnchor(info, roots)
+	for _, rr := range resp.Answers {
+		if rr.Type != dnsTypeTLSA || rr.Class != dnsClassIN {
+			continue
+		}
+		rec, err := parseTLSA(rr.Data)
+		if err != nil {
+			continue
+		}
+		rec.Matched = matchTLSA(rec, info, anchor)
+		out.Records = append(out.Records, rec)
+	}
+	if len(out.Records) == 0 {
+		out.Err = fmt.Errorf("no TLSA records at %s", out.Name)
+	}
+	return out
+}
+
+// matchTLSA reports whether rec matches info's served chain: the leaf for the
+// end-entity usages, any certificate above it for the trust-anchor ones, plus
+// for PKIX-TA the anchor the chain verified to (nil when it did not). The PKIX
+// usages also need the chain to have verified (when it was checked).
+func matchTLSA(rec TLSARecord, info *CertInfo, anchor *x509.Certificate) bool {
+	if (rec.Usage == tlsaPKIXTA || rec.Usage == tlsaPKIXEE) && info.Verified && info.ChainErr != nil {
+		return false
+	}
+	var candidates []*x509.Certificate
+	switch rec.Usage {
+	case tlsaPKIXEE, tlsaDANEEE:
+		candidates = []*x509.Certificate{info.Cert}
+	case tlsaPKIXTA, tlsaDANETA:
+		chain := chainList(info)
+		candidates = append(chain[1:len(chain):len(chain)], info.AIAFetched...)
+		if rec.Usage == tlsaPKIXTA && anchor != nil {
+			candidates = append(candidates, anchor)
+		}
+	default:
+		return false
+	}
+	for _, c := range candidates {
+		if tlsaDataMatches(rec, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// trustAnchor returns the root info's chain, with any -aia-fetch intermediates,
+// verifies to under roots (the system's when nil), or nil when it does not
+// verify. Servers usually leave the root out, so a PKIX-TA record pinning it
+// can only match this.
+func trustAnchor(info *CertInfo, roots *x509.CertPool) *x509.Certificate {
+	intermediates := x509.NewCertPool()
+	chain := chainList(info)
+	for _, c := range append(chain[1:len(chain):len(chain)], info.AIAFetched...) {
+		intermediates.AddCert(c)
+	}
+	chains, err := info.Cert.Verify(x509.VerifyOptions{Intermediates: intermediates, Roots: roots, CurrentTime: info.At})
+	if err != nil || len(chains) == 0 {
+		return nil
+	}
+	return chains[0][len(chains[0])-1]
+}
+
+// tlsaDataMatches compares c, as picked by the record's selector, with the
+// record's association data under its matching type.
+func tlsaDataMatches(rec TLSARecord, c *x509.Certificate) bool {
+	switch rec.MatchingType {
+	case 0:
+		switch rec.Selector {
+		case 0:
+			return bytes.Equal(c.Raw, rec.Data)
+		case 1:
+			return bytes.Equal(c.RawSubjectPublicKeyInfo, rec.Data)
+		}
+	case 1:
+		switch rec.Selector {
+		case 0:
+			return Fingerprint(c) == hex.EncodeToString(rec.Data)
+		case 1:
+			return SPKIFingerprint(c) == hex.EncodeToString(rec.Data)
+		}
+	case 2:
+		var sum [64]byte
+		switch rec.Selector {
+		case 0:
+			sum = sha512.Sum512(c.Raw)
+		case 1:
+			sum = sha512.Sum512(c.RawSubjectPublicKeyInfo)
+		default:
+			return false
+		}
+		return bytes.Equal(sum[:], rec.Data)
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dnsStub is a UDP DNS server answering each query with the RDATA answer
+// returns for its name and type, setting the AD bit when secure.
+func dnsStub(t *testing.T, secure bool, answer func(name string, qtype uint16) [][]byte) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := dnsReply(buf[:n], secure, answer); resp != nil {
+				_, _ = pc.WriteTo(resp, addr)
+			}
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+// dnsReply builds the stub's response to query q, or nil for a malformed one.
+func dnsReply(q []byte, secure bool, answer func(name string, qtype uint16) [][]byte) []byte {
+	var labels []string
+	off := 12
+	for off < len(q) && q[off] != 0 {
+		l := int(q[off])
+		if off+1+l > len(q) {
+			break
+		}
+		labels = append(labels, string(q[off+1:off+1+l]))
+		off += 1 + l
+	}
+	end := off + 5
+	if end > len(q) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(q[off+1:])
+	rdatas := answer(strings.Join(labels, "."), qtype)
+	flags := uint16(0x8180) // QR, RD, RA
+	if secure {
+		flags |= dnsFlagAD
+	}
+	resp := append([]byte{}, q[:2]...)
+	resp = binary.BigEndian.AppendUint16(resp, flags)
+	resp = binary.BigEndian.AppendUint16(resp, 1)
+	resp = binary.BigEndian.AppendUint16(resp, uint16(len(rdatas)))
+	resp = append(resp, 0, 0, 0, 0)
+	resp = append(resp, q[12:end]...)
+	for _, rdata := range rdatas {
+		resp = append(resp, 0xc0, 12) // pointer to the question name
+		resp = binary.BigEndian.AppendUint16(resp, qtype)
+		resp = binary.BigEndian.AppendUint16(resp, dnsClassIN)
+		resp = append(resp, 0, 0, 0x0e, 0x10)
+		resp = binary.BigEndian.AppendUint16(resp, uint16(len(rdata)))
+		resp = append(resp, rdata...)
+	}
+	return resp
+}
+
+// tlsaStub is a dnsStub publishing records as the TLSA set of every name.
+func tlsaStub(t *testing.T, secure bool, records ...TLSARecord) string {
+	return dnsStub(t, secure, func(_ string, qtype uint16) [][]byte {
+		var out [][]byte
+		for _, r := range records {
+			if qtype == dnsTypeTLSA {
+				out = append(out, append([]byte{r.Usage, r.Selector, r.MatchingType}, r.Data...))
+			}
+		}
+		return out
+	})
+}
+
+func TestTLSADataMatches(t *testing.T) {
+	c := genCert(t, "example.com", time.Now().Add(24*time.Hour))
+	certSum, spkiSum := sha256.Sum256(c.Raw), sha256.Sum256(c.RawSubjectPublicKeyInfo)
+	spki512 := sha512.Sum512(c.RawSubjectPublicKeyInfo)
+	tests := []struct {
+		name string
+		rec  TLSARecord
+		want bool
+	}{
+		{"full cert", TLSARecord{Selector: 0, MatchingType: 0, Data: c.Raw}, true},
+		{"full spki", TLSARecord{Selector: 1, MatchingType: 0, Data: c.RawSubjectPublicKeyInfo}, true},
+		{"sha256 cert", TLSARecord{Selector: 0, MatchingType: 1, Data: certSum[:]}, true},
+		{"sha256 spki", TLSARecord{Selector: 1, MatchingType: 1, Data: spkiSum[:]}, true},
+		{"sha512 spki", TLSARecord{Selector: 1, MatchingType: 2, Data: spki512[:]}, true},
+		{"selector mixed up", TLSARecord{Selector: 0, MatchingType: 1, Data: spkiSum[:]}, false},
+		{"unknown matching type", TLSARecord{Selector: 1, MatchingType: 9, Data: spkiSum[:]}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tlsaDataMatches(tt.rec, c); got != tt.want {
+				t.Errorf("tlsaDataMatches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFetch_DANE runs -dane against a local TLS server with a stub resolver
+// publishing matching, non-matching and unvalidated TLSA records.
+func TestFetch_DANE(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	leaf, pair := keyedCert(t, key, time.Now().Add(30*24*time.Hour))
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	other := genCert(t, "other.example", time.Now().Add(24*time.Hour))
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{pair}}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	spkiSum, otherSum := sha256.Sum256(leaf.RawSubjectPublicKeyInfo), sha256.Sum256(other.Raw)
+	match := TLSARecord{Usage: tlsaDANEEE, Selector: 1, MatchingType: 1, Data: spkiSum[:]}
+	miss := TLSARecord{Usage: tlsaDANEEE, Selector: 0, MatchingType: 1, Data: otherSum[:]}
+
+	fetch := func(resolver string) *CertInfo {
+		t.Helper()
+		info, err := (&CertificateFetcherImpl{}).Fetch("localhost", port, host, FetchOptions{Roots: roots, Timeout: 5 * time.Second, DANE: true, DNSResolver: resolver})
+		if err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		if info.DANE == nil {
+			t.Fatal("expected a DANE result")
+		}
+		return info
+	}
+
+	info := fetch(tlsaStub(t, true, match, miss))
+	d := info.DANE
+	if d.Err != nil || !d.Secure || len(d.Records) != 2 || len(d.Matched()) != 1 || d.Mismatch() || d.Inconclusive() {
+		t.Fatalf("matching records: unexpected result %+v", d)
+	}
+	if d.Name != "_"+port+"._tcp.localhost" {
+		t.Errorf("Name = %q", d.Name)
+	}
+	if HasWarnings(info) {
+		t.Error("matching records: unexpected warning")
+	}
+	out := captureStdout(t, func() { (&CertificatePrinterImpl{}).Print(info, PrintOptions{}) })
+	for _, want := range []string{"DANE: MATCH (1 of 2 TLSA records at _" + port + "._tcp.localhost, DNSSEC-validated)", "3 1 1 (DANE-EE SPKI SHA2-256)", "— match"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	if d := fetch(tlsaStub(t, true, miss)).DANE; !d.Mismatch() {
+		t.Errorf("non-matching records: expected a mismatch, got %+v", d)
+	}
+
+	info = fetch(tlsaStub(t, false, match))
+	if !info.DANE.Inconclusive() || !HasWarnings(info) {
+		t.Errorf("unvalidated records: expected a warning, got %+v", info.DANE)
+	}
+
+	if d := fetch(tlsaStub(t, true)).DANE; d.Err == nil || !d.Inconclusive() {
+		t.Errorf("no records: expected an error, got %+v", d)
+	}
+}
+
+// TestCheckDANE_PKIXTARoot verifies a PKIX-TA record pinning the trust-store
+// root matches a chain whose server left the root out.
+func TestCheckDANE_PKIXTARoot(t *testing.T) {
+	leaf, inter, root := issueChainCerts(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	info := &CertInfo{Cert: leaf, Chain: []*x509.Certificate{leaf, inter}, Verified: true}
+	sum := sha256.Sum256(root.RawSubjectPublicKeyInfo)
+	resolver := tlsaStub(t, true, TLSARecord{Usage: tlsaPKIXTA, Selector: 1, MatchingType: 1, Data: sum[:]})
+
+	if d := checkDANE(info, "leaf.example", "443", resolver, roots, 5*time.Second); d.Err != nil || d.Mismatch() || len(d.Matched()) != 1 {
+		t.Errorf("root in the trust store: expected a match, got %+v", d)
+	}
+	if d := checkDANE(info, "leaf.example", "443", resolver, x509.NewCertPool(), 5*time.Second); !d.Mismatch() {
+		t.Errorf("root not trusted: expected a mismatch, got %+v", d)
+	}
+}
@@ -0,0 +1,361 @@
+package cert
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// DNS record types and classes the tool queries.
+const (
+	dnsTypeA     = 1
+	dnsTypeCNAME = 5
+	dnsTypeAAAA  = 28
+	dnsTypeTLSA  = 52
+	dnsTypeCAA   = 257
+	dnsTypeOPT   = 41
+	dnsClassIN   = 1
+)
+
+// DNS header flag bits and response codes (RFC 1035, RFC 4035).
+const (
+	dnsFlagTC        = 0x0200 // truncated: retry over TCP
+	dnsFlagRD        = 0x0100 // recursion desired
+	dnsFlagAD        = 0x0020 // authenticated data: the resolver validated DNSSEC
+	dnsRcodeNXDomain = 3
+)
+
+// dnsRcodeNames names the common non-zero response codes.
+var dnsRcodeNames = map[int]string{1: "FORMERR", 2: "SERVFAIL", 4: "NOTIMP", 5: "REFUSED"}
+
+// dnsAnswer is one resource record from the answer section.
+type dnsAnswer struct {
+	Name   string // Owner name, without the trailing dot
+	Type   uint16
+	Class  uint16
+	TTL    uint32
+	Data   []byte
+	Target string // For a CNAME: the canonical name, decompressed
+}
+
+// dnsResponse is the part of a DNS response the tool uses.
+type dnsResponse struct {
+	Authenticated bool        // AD bit: the resolver validated the answer with DNSSEC
+	Answers       []dnsAnswer // Empty for NXDOMAIN or no data
+}
+
+// dotPrefix marks a DNS-over-TLS resolver (RFC 7858): tls://host[:port].
+const dotPrefix = "tls://"
+
+// dotRoots verifies DNS-over-TLS resolvers; nil = the system roots. It is a
+// variable so tests can trust their own resolver.
+var dotRoots *x509.CertPool
+
+// resolverAddress returns resolver as host:port, defaulting the port to 53 (853
+// for a tls:// resolver, which keeps its prefix), or the system's resolver when
+// it is empty.
+func resolverAddress(resolver string) string {
+	if resolver == "" {
+		return systemResolver()
+	}
+	scheme, port := "", "53"
+	if rest, ok := strings.CutPrefix(resolver, dotPrefix); ok {
+		scheme, port, resolver = dotPrefix, "853", rest
+	}
+	if _, _, err := net.SplitHostPort(resolver); err == nil {
+		return scheme + resolver
+	}
+	return scheme + net.JoinHostPort(strings.Trim(resolver, "[]"), port)
+}
+
+// systemResolver returns the first nameserver in /etc/resolv.conf as host:53,
+// or 127.0.0.1:53 when there is none.
+func systemResolver() string {
+	if data, err := os.ReadFile("/etc/resolv.conf"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && fields[0] == "nameserver" {
+				return net.JoinHostPort(fields[1], "53")
+			}
+		}
+	}
+	return "127.0.0.1:53"
+}
+
+// buildDNSQuery encodes a recursive query for name/qtype with id. It carries an
+// EDNS0 OPT record with the DO bit and sets AD, asking a validating resolver to
+// report whether the answer is DNSSEC-secure (RFC 6840 §5.7).
+func buildDNSQuery(id uint16, name string, qtype uint16) ([]byte, error) {
+	msg := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], dnsFlagRD|dnsFlagAD)
+	binary.BigEndian.PutUint16(msg[4:], 1)  // QDCOUNT
+	binary.BigEndian.PutUint16(msg[10:], 1) // ARCOUNT: the OPT record
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" || len(label) > 63 {
+			return nil, fmt.Errorf("invalid DNS name %q", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = binary.BigEndian.AppendUint16(msg, dnsClassIN)
+	// OPT: root name, type, UDP payload size, extended rcode/version, DO flag, no data.
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, dnsTypeOPT)
+	msg = binary.BigEndian.AppendUint16(msg, 1232)
+	msg = append(msg, 0, 0, 0x80, 0, 0, 0)
+	return msg, nil
+}
+
+// skipDNSName returns the offset just past the (possibly compressed) name at off.
+func skipDNSName(msg []byte, off int) (int, error) {
+	for {
+		if off >= len(msg) {
+			return 0, errors.New("truncated DNS name")
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			return off + 1, nil
+		case n&0xc0 == 0xc0: // compression pointer ends the name
+			return off + 2, nil
+		default:
+			off += 1 + n
+		}
+	}
+}
+
+// readDNSName decodes the (possibly compressed) name at off, without the
+// trailing dot.
+func readDNSName(msg []byte, off int) (string, error) {
+	var labels []string
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", errors.New("truncated DNS name")
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			return strings.Join(labels, "."), nil
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(msg) || jumps > 32 {
+				return "", errors.New("bad DNS name compression")
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			jumps++
+		default:
+			if off+1+n > len(msg) {
+				return "", errors.New("truncated DNS name")
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
+
+// parseDNSResponse decodes the header and answer section of a response to the
+// query with id.
+func parseDNSResponse(msg []byte, id uint16) (*dnsResponse, error) {
+	if len(msg) < 12 {
+		return nil, errors.New("DNS response too short")
+	}
+	if binary.BigEndian.Uint16(msg[0:]) != id {
+		return nil, errors.New("DNS response ID does not match the query")
+	}
+	flags := binary.BigEndian.Uint16(msg[2:])
+	rcode := int(flags & 0x000f)
+	out := &dnsResponse{Authenticated: flags&dnsFlagAD != 0}
+	if rcode != 0 && rcode != dnsRcodeNXDomain {
+		name := dnsRcodeNames[rcode]
+		if name == "" {
+			name = fmt.Sprintf("rcode %d", rcode)
+		}
+		return nil, fmt.Errorf("DNS query failed: %s", name)
+	}
+	qd, an := binary.BigEndian.Uint16(msg[4:]), binary.BigEndian.Uint16(msg[6:])
+	off := 12
+	for i := 0; i < int(qd); i++ {
+		var err error
+		if off, err = skipDNSName(msg, off); err != nil {
+			return nil, err
+		}
+		off += 4
+	}
+	for i := 0; i < int(an); i++ {
+		owner, err := readDNSName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		if off, err = skipDNSName(msg, off); err != nil {
+			return nil, err
+		}
+		if off+10 > len(msg) {
+			return nil, errors.New("truncated DNS record")
+		}
+		rr := dnsAnswer{
+			Name:  owner,
+			Type:  binary.BigEndian.Uint16(msg[off:]),
+			Class: binary.BigEndian.Uint16(msg[off+2:]),
+			TTL:   binary.BigEndian.Uint32(msg[off+4:]),
+		}
+		length := int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+length > len(msg) {
+			return nil, errors.New("truncated DNS record data")
+		}
+		rr.Data = msg[off : off+length]
+		if rr.Type == dnsTypeCNAME {
+			if rr.Target, err = readDNSName(msg, off); err != nil {
+				return nil, err
+			}
+		}
+		off += length
+		out.Answers = append(out.Answers, rr)
+	}
+	return out, nil
+}
+
+// queryDNS sends a query for name/qtype to resolver (host:port) over UDP, and
+// over TCP when the UDP answer comes back truncated; a tls://host:port resolver
+// is queried over TLS.
+func queryDNS(resolver, name string, qtype uint16, timeout time.Duration) (*dnsResponse, error) {
+	var idb [2]byte
+	if _, err := rand.Read(idb[:]); err != nil {
+		return nil, err
+	}
+	id := binary.BigEndian.Uint16(idb[:])
+	query, err := buildDNSQuery(id, name, qtype)
+	if err != nil {
+		return nil, err
+	}
+	if addr, ok := strings.CutPrefix(resolver, dotPrefix); ok {
+		return queryDNSTLS(addr, query, id, timeout)
+	}
+
+	conn, err := net.DialTimeout("udp", resolver, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach resolver %s: %v", resolver, err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(query); err != nil {
+		return nil, fmt.Errorf("resolver %s: %v", resolver, err)
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("resolver %s: %v", resolver, err)
+		}
+		// Ignore stray datagrams that do not answer this query.
+		if n < 12 || binary.BigEndian.Uint16(buf) != id {
+			continue
+		}
+		if binary.BigEndian.Uint16(buf[2:])&dnsFlagTC != 0 {
+			return queryDNSTCP(resolver, query, id, timeout)
+		}
+		return parseDNSResponse(buf[:n], id)
+	}
+}
+
+// queryDNSTCP sends query over TCP (RFC 1035 §4.2.2: a two-byte length prefix).
+func queryDNSTCP(resolver string, query []byte, id uint16, timeout time.Duration) (*dnsResponse, error) {
+	conn, err := net.DialTimeout("tcp", resolver, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach resolver %s over TCP: %v", resolver, err)
+	}
+	defer conn.Close()
+	return exchangeDNSStream(conn, resolver, query, id, timeout)
+}
+
+// queryDNSTLS sends query over TLS (RFC 7858), verifying the resolver's
+// certificate against its host name or address.
+func queryDNSTLS(resolver string, query []byte, id uint16, timeout time.Duration) (*dnsResponse, error) {
+	host, _, err := net.SplitHostPort(resolver)
+	if err != nil {
+		return nil, fmt.Errorf("invalid resolver %q: %v", resolver, err)
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", resolver, &tls.Config{ServerName: host, RootCAs: dotRoots})
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach resolver %s over TLS: %v", resolver, err)
+	}
+	defer conn.Close()
+	return exchangeDNSStream(conn, dotPrefix+resolver, query, id, timeout)
+}
+
+// exchangeDNSStream sends query on a stream connection and reads the answer,
+// both with a two-byte length prefix.
+func exchangeDNSStream(conn net.Conn, resolver string, query []byte, id uint16, timeout time.Duration) (*dnsResponse, error) {
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(query))), query...)); err != nil {
+		return nil, fmt.Errorf("resolver %s: %v", resolver, err)
+	}
+	var lb [2]byte
+	if _, err := io.ReadFull(conn, lb[:]); err != nil {
+		return nil, fmt.Errorf("resolver %s: %v", resolver, err)
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(lb[:]))
+	if _, err := io.ReadFull(conn, msg); err != nil {
+		return nil, fmt.Errorf("resolver %s: %v", resolver, err)
+	}
+	return parseDNSResponse(msg, id)
+}
+
+// LookupAddrs resolves host's A and AAAA records through resolver (host[:port],
+// or tls://host[:port] for DNS over TLS; empty = the system's nameserver),
+// addresses in answer order, IPv4 first. An IP literal is returned as is. It
+// fails only when neither lookup yields an address.
+func LookupAddrs(host, resolver string, timeout time.Duration) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	resolver = resolverAddress(resolver)
+	var ips []net.IP
+	var errs []error
+	for _, qtype := range []uint16{dnsTypeA, dnsTypeAAAA} {
+		resp, err := queryDNS(resolver, host, qtype, timeout)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, rr := range resp.Answers {
+			if rr.Type == qtype && (len(rr.Data) == net.IPv4len || len(rr.Data) == net.IPv6len) {
+				ips = append(ips, net.IP(append([]byte{}, rr.Data...)))
+			}
+		}
+	}
+	switch {
+	case len(ips) > 0:
+		return ips, nil
+	case len(errs) > 0:
+		return nil, errs[0]
+	}
+	return nil, fmt.Errorf("no A or AAAA records for %s at %s", host, resolver)
+}
+
+// resolveTarget returns the first address the first answering resolver gives
+// for host, and that resolver.
+func resolveTarget(host string, resolvers []string, timeout time.Duration) (ip, resolver string, err error) {
+	var firstErr error
+	for _, r := range resolvers {
+		ips, err := LookupAddrs(host, r, timeout)
+		if err == nil {
+			return ips[0].String(), r, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return "", "", fmt.Errorf("failed to resolve %s: %v", host, firstErr)
+}
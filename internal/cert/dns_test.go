@@ -0,0 +1,133 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// addrZone answers A/AAAA queries from a name → addresses table.
+func addrZone(zone map[string][]string) func(name string, qtype uint16) [][]byte {
+	return func(name string, qtype uint16) [][]byte {
+		var out [][]byte
+		for _, s := range zone[name] {
+			ip := net.ParseIP(s)
+			switch {
+			case qtype == dnsTypeA && ip.To4() != nil:
+				out = append(out, ip.To4())
+			case qtype == dnsTypeAAAA && ip.To4() == nil:
+				out = append(out, ip.To16())
+			}
+		}
+		return out
+	}
+}
+
+// dotStub is dnsStub over TLS (RFC 7858) with a certificate for localhost,
+// trusted through dotRoots for the duration of the test.
+func dotStub(t *testing.T, answer func(name string, qtype uint16) [][]byte) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	leaf, pair := keyedCert(t, key, time.Now().Add(24*time.Hour))
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{pair}})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	saved := dotRoots
+	dotRoots = x509.NewCertPool()
+	dotRoots.AddCert(leaf)
+	t.Cleanup(func() { dotRoots = saved })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				var lb [2]byte
+				if _, err := io.ReadFull(c, lb[:]); err != nil {
+					return
+				}
+				q := make([]byte, binary.BigEndian.Uint16(lb[:]))
+				if _, err := io.ReadFull(c, q); err != nil {
+					return
+				}
+				resp := dnsReply(q, false, answer)
+				_, _ = c.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...))
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return "tls://localhost:" + port
+}
+
+func TestResolverAddress(t *testing.T) {
+	for in, want := range map[string]string{
+		"10.0.0.53":          "10.0.0.53:53",
+		"10.0.0.53:5353":     "10.0.0.53:5353",
+		"[2001:db8::53]":     "[2001:db8::53]:53",
+		"tls://1.1.1.1":      "tls://1.1.1.1:853",
+		"tls://dns.example":  "tls://dns.example:853",
+		"tls://1.1.1.1:8853": "tls://1.1.1.1:8853",
+	} {
+		if got := resolverAddress(in); got != want {
+			t.Errorf("resolverAddress(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLookupAddrs(t *testing.T) {
+	zone := addrZone(map[string][]string{"www.example.com": {"192.0.2.1", "2001:db8::1", "192.0.2.2"}})
+	for name, resolver := range map[string]string{"udp": dnsStub(t, false, zone), "tls": dotStub(t, zone)} {
+		t.Run(name, func(t *testing.T) {
+			ips, err := LookupAddrs("www.example.com", resolver, 2*time.Second)
+			if err != nil {
+				t.Fatalf("LookupAddrs: %v", err)
+			}
+			if len(ips) != 3 || ips[0].String() != "192.0.2.1" || ips[1].String() != "192.0.2.2" || ips[2].String() != "2001:db8::1" {
+				t.Errorf("got %v", ips)
+			}
+			if _, err := LookupAddrs("missing.example.com", resolver, 2*time.Second); err == nil {
+				t.Error("expected an error for a name without addresses")
+			}
+		})
+	}
+	if ips, err := LookupAddrs("192.0.2.9", "", time.Second); err != nil || len(ips) != 1 {
+		t.Errorf("IP literal: got %v, %v", ips, err)
+	}
+}
+
+// TestFetch_Resolvers resolves the target through the first -resolver that
+// answers and records it.
+func TestFetch_Resolvers(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	empty := dnsStub(t, false, addrZone(nil))
+	good := dnsStub(t, false, addrZone(map[string][]string{"www.example.com": {"127.0.0.1"}}))
+
+	info, err := (&CertificateFetcherImpl{}).Fetch("www.example.com", port, "", FetchOptions{Insecure: true, Timeout: 5 * time.Second, Resolvers: []string{empty, good}})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if info.UsedIP != "127.0.0.1" || info.Resolver != good {
+		t.Errorf("UsedIP %q via %q, want 127.0.0.1 via %q", info.UsedIP, info.Resolver, good)
+	}
+	if _, err := (&CertificateFetcherImpl{}).Fetch("www.example.com", port, "", FetchOptions{Insecure: true, Timeout: 5 * time.Second, Resolvers: []string{empty}}); err == nil {
+		t.Error("expected an error when no resolver has an address")
+	}
+}
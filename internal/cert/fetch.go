@@ -1,14 +1,10 @@
 package cert
 
 import (
-	"bufio"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/base64"
 	"fmt"
 	"net"
-	"net/url"
-	"strings"
 	"time"
 )
 
@@ -18,8 +14,36 @@ type CertificateFetcherImpl struct{}
 
 // Fetch connects to the specified domain or IP address and retrieves the TLS certificate.
 // The handshake always skips verification so that details of an invalid certificate can
-// still be displayed; the chain is then verified separately unless insecure is true.
+// still be displayed; the chain is then verified separately unless insecure is true
+// (and, with opts.AIAFetch, an incomplete one is repaired via AIA caIssuers),
+// the chain is matched against the service's TLSA records when opts.DANE is set,
+// the domain's CAA policy is checked against the leaf's issuer when opts.CAA is set,
+// the domain's CNAME chain is looked up when opts.DNSInfo or opts.ExpectCNAME is,
+// the leaf's revocation status is checked when opts.OCSP is set and the chain's
+// against CRLs when opts.CRL or opts.CRLs is; opts.ScanVersions and
+// opts.ScanCiphers add one handshake per TLS version or cipher suite, and
+// opts.KeyTypes one per certificate key type. A stapled OCSP response is always
+// verified and kept. With opts.ProxyFromEnv and no opts.Proxy, the proxy for the
+// target is picked from the environment, as net/http would. Without ipaddr, the
+// domain is resolved through opts.Resolvers when set — for a direct connection
+// only: a proxy is handed the name, so it can resolve it, match it against its
+// allowlist, and NO_PROXY can match it first.
 func (f *CertificateFetcherImpl) Fetch(domain, port, ipaddr string, opts FetchOptions) (*CertInfo, error) {
+	if opts.ProxyFromEnv && opts.Proxy.URL == "" {
+		proxyURL, err := proxyFromEnv(domain, ipaddr, port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy in the environment: %v", err)
+		}
+		opts.Proxy.URL = proxyURL
+	}
+	resolvedBy := ""
+	if ipaddr == "" && opts.Proxy.URL == "" && len(opts.Resolvers) > 0 && net.ParseIP(domain) == nil {
+		ip, resolver, err := resolveTarget(domain, opts.Resolvers, opts.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		ipaddr, resolvedBy = ip, resolver
+	}
 	host := domain
 	if ipaddr != "" {
 		host = ipaddr
@@ -38,7 +62,7 @@ func (f *CertificateFetcherImpl) Fetch(domain, port, ipaddr string, opts FetchOp
 		tlsConfig.Certificates = []tls.Certificate{*opts.ClientCert}
 	}
 
-	conn, err := dialTLS(address, opts.Timeout, opts.StartTLS, opts.Proxy, tlsConfig)
+	conn, err := dialTLS(address, opts, tlsConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -62,18 +86,59 @@ func (f *CertificateFetcherImpl) Fetch(domain, port, ipaddr string, opts FetchOp
 		TLSVersion:  tls.VersionName(state.Version),
 		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
 		CheckedName: name,
+		At:          opts.At,
 	}
+	if opts.ProxyFromEnv {
+		info.Proxy = redactProxy(opts.Proxy.URL)
+	}
+	info.Resolver = resolvedBy
 	if !opts.Insecure {
 		info.Verified = true
-		info.ChainErr = verifyChain(certs, name, opts.Roots)
+		info.ChainErr = verifyChain(certs, name, opts.Roots, opts.At)
+		if _, unknown := info.ChainErr.(x509.UnknownAuthorityError); unknown && opts.AIAFetch {
+			info.AIAFetched, info.AIAErr = repairChain(certs, name, opts)
+		}
+	}
+	if opts.DANE {
+		info.DANE = checkDANE(info, domain, port, opts.DNSResolver, opts.Roots, opts.Timeout)
+	}
+	if opts.CAA {
+		info.CAA = checkCAA(info, domain, opts.DNSResolver, opts.CAAMap, opts.Timeout)
+	}
+	if opts.DNSInfo || opts.ExpectCNAME != "" {
+		// Ask the resolver that gave the address, so the chain shown is the one connected to.
+		resolver := opts.DNSResolver
+		if resolvedBy != "" {
+			resolver = resolvedBy
+		}
+		info.DNS = checkDNSInfo(domain, resolver, opts.ExpectCNAME, opts.Timeout)
+	}
+	if len(state.OCSPResponse) > 0 {
+		info.Staple = checkStaple(state.OCSPResponse, certs)
+	}
+	if opts.OCSP {
+		info.Revocation = checkOCSP(certs, opts.Timeout, opts.Proxy)
+	}
+	if opts.CRL || len(opts.CRLs) > 0 {
+		info.CRL = checkCRL(certs, opts)
+	}
+	if opts.ScanVersions {
+		info.Versions = scanVersions(address, opts, tlsConfig)
+	}
+	if opts.ScanCiphers {
+		info.Ciphers = scanCiphers(address, opts, tlsConfig)
+	}
+	if opts.KeyTypes {
+		info.KeyTypes = scanKeyTypes(address, name, opts, tlsConfig)
 	}
 	return info, nil
 }
 
 // verifyChain validates the leaf certificate (certs[0]) against roots (nil = the
 // system root store), using the remaining peer certificates as intermediates. The
-// check covers trust, hostname match and validity period.
-func verifyChain(certs []*x509.Certificate, name string, roots *x509.CertPool) error {
+// check covers trust, hostname match and validity period, the latter at the
+// given instant (zero = the current time).
+func verifyChain(certs []*x509.Certificate, name string, roots *x509.CertPool, at time.Time) error {
 	intermediates := x509.NewCertPool()
 	for _, c := range certs[1:] {
 		intermediates.AddCert(c)
@@ -82,28 +147,44 @@ func verifyChain(certs []*x509.Certificate, name string, roots *x509.CertPool) e
 		DNSName:       name,
 		Intermediates: intermediates,
 		Roots:         roots,
+		CurrentTime:   at,
 	})
 	return err
 }
 
-// dialTLS opens a TLS connection to address, optionally through an HTTP CONNECT
-// proxy and/or a STARTTLS upgrade. It dials the raw TCP connection (directly or
-// via the proxy), runs the STARTTLS negotiation when requested, and performs the
-// TLS handshake. The timeout bounds the connection, the negotiation and the
-// handshake.
-func dialTLS(address string, timeout time.Duration, starttls, proxy string, cfg *tls.Config) (*tls.Conn, error) {
-	conn, err := dialRaw(address, timeout, proxy)
+// dialTLS opens a TLS connection to address, optionally through a proxy and/or a
+// STARTTLS upgrade. It dials the raw TCP connection (directly or via
+// opts.Proxy), sends the opts.ProxyHeader PROXY protocol header when set, runs
+// the opts.StartTLS negotiation when requested, and performs the TLS handshake.
+// opts.Timeout bounds the connection, the negotiation and the handshake.
+func dialTLS(address string, opts FetchOptions, cfg *tls.Config) (*tls.Conn, error) {
+	header := opts.ProxyHeader
+	if header.Version != "" && opts.Proxy.URL != "" {
+		var err error
+		if header, err = header.throughProxy(address); err != nil {
+			return nil, fmt.Errorf("PROXY protocol header failed: %v", err)
+		}
+	}
+	conn, err := dialRaw(address, opts.Timeout, opts.Proxy)
 	if err != nil {
 		return nil, err
 	}
 	// Bound the negotiation and handshake by the same timeout.
-	_ = conn.SetDeadline(time.Now().Add(timeout))
-	if starttls != "" {
-		if err := negotiateStartTLS(conn, starttls); err != nil {
+	_ = conn.SetDeadline(time.Now().Add(opts.Timeout))
+	if header.Version != "" {
+		if err := writeProxyHeader(conn, header, cfg.ServerName); err != nil {
 			conn.Close()
-			return nil, fmt.Errorf("STARTTLS (%s) failed for %s: %v", starttls, address, err)
+			return nil, fmt.Errorf("PROXY protocol header failed for %s: %v", address, err)
 		}
 	}
+	if opts.StartTLS != "" {
+		upgraded, err := negotiateStartTLS(conn, opts.StartTLS, cfg.ServerName)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("STARTTLS (%s) failed for %s: %v", opts.StartTLS, address, err)
+		}
+		conn = upgraded
+	}
 
 	tlsConn := tls.Client(conn, cfg)
 	if err := tlsConn.Handshake(); err != nil {
@@ -115,9 +196,9 @@ func dialTLS(address string, timeout time.Duration, starttls, proxy string, cfg
 }
 
 // dialRaw opens a raw TCP connection to address, directly or — when proxy is set
-// — through an HTTP CONNECT proxy.
-func dialRaw(address string, timeout time.Duration, proxy string) (net.Conn, error) {
-	if proxy == "" {
+// — through the proxy (see dialViaProxy).
+func dialRaw(address string, timeout time.Duration, proxy Proxy) (net.Conn, error) {
+	if proxy.URL == "" {
 		conn, err := net.DialTimeout("tcp", address, timeout)
 		if err != nil {
 			return nil, fmt.Errorf("failed to connect to %s: %v", address, err)
@@ -126,68 +207,3 @@ func dialRaw(address string, timeout time.Duration, proxy string) (net.Conn, err
 	}
 	return dialViaProxy(address, timeout, proxy)
 }
-
-// dialViaProxy connects to an HTTP proxy and issues a CONNECT request to open a
-// tunnel to target, returning the tunneled connection ready for a TLS handshake.
-// Only the http scheme is supported; optional userinfo becomes Basic auth.
-func dialViaProxy(target string, timeout time.Duration, proxy string) (net.Conn, error) {
-	u, err := url.Parse(proxy)
-	if err != nil {
-		return nil, fmt.Errorf("invalid -proxy %q: %v", proxy, err)
-	}
-	if u.Scheme != "" && u.Scheme != "http" {
-		return nil, fmt.Errorf("unsupported proxy scheme %q (only http is supported)", u.Scheme)
-	}
-	if u.Hostname() == "" {
-		return nil, fmt.Errorf("invalid -proxy %q: missing host", proxy)
-	}
-	// Default to the http scheme's port when the URL omits one.
-	proxyAddr := u.Host
-	if u.Port() == "" {
-		proxyAddr = net.JoinHostPort(u.Hostname(), "80")
-	}
-
-	conn, err := net.DialTimeout("tcp", proxyAddr, timeout)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to proxy %s: %v", proxyAddr, err)
-	}
-	_ = conn.SetDeadline(time.Now().Add(timeout))
-
-	req := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", target, target)
-	if u.User != nil {
-		pass, _ := u.User.Password()
-		token := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + pass))
-		req += "Proxy-Authorization: Basic " + token + "\r\n"
-	}
-	req += "\r\n"
-	if _, err := conn.Write([]byte(req)); err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to send CONNECT to proxy %s: %v", proxyAddr, err)
-	}
-
-	br := bufio.NewReader(conn)
-	status, err := readLine(br)
-	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("proxy %s: failed to read CONNECT response: %v", proxyAddr, err)
-	}
-	// Status line: "HTTP/1.1 200 Connection established".
-	fields := strings.SplitN(status, " ", 3)
-	if len(fields) < 2 || fields[1] != "200" {
-		conn.Close()
-		return nil, fmt.Errorf("proxy %s refused CONNECT to %s: %s", proxyAddr, target, status)
-	}
-	// Drain the remaining response headers up to the blank line.
-	for {
-		line, err := readLine(br)
-		if err != nil {
-			conn.Close()
-			return nil, fmt.Errorf("proxy %s: failed to read CONNECT headers: %v", proxyAddr, err)
-		}
-		if line == "" {
-			break
-		}
-	}
-	_ = conn.SetDeadline(time.Time{})
-	return conn, nil
-}
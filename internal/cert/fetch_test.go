@@ -73,7 +73,7 @@ func TestFetch_ViaProxy(t *testing.T) {
 	info, err := fetcher.Fetch(host, port, "", FetchOptions{
 		Insecure: true,
 		Timeout:  5 * time.Second,
-		Proxy:    "http://" + ln.Addr().String(),
+		Proxy:    Proxy{URL: "http://" + ln.Addr().String()},
 	})
 	if err != nil {
 		t.Fatalf("unexpected error from Fetch via proxy: %v", err)
@@ -128,16 +128,16 @@ func TestFetch_ViaProxy_Refused(t *testing.T) {
 	_, err = fetcher.Fetch("example.com", "443", "", FetchOptions{
 		Insecure: true,
 		Timeout:  2 * time.Second,
-		Proxy:    "http://" + ln.Addr().String(),
+		Proxy:    Proxy{URL: "http://" + ln.Addr().String()},
 	})
 	if err == nil {
 		t.Error("expected an error when the proxy refuses CONNECT, got nil")
 	}
 }
 
-// TestDialViaProxy_BadScheme verifies a non-http proxy scheme is rejected.
+// TestDialViaProxy_BadScheme verifies an unsupported proxy scheme is rejected.
 func TestDialViaProxy_BadScheme(t *testing.T) {
-	if _, err := dialViaProxy("example.com:443", time.Second, "socks5://127.0.0.1:1080"); err == nil {
+	if _, err := dialViaProxy("example.com:443", time.Second, Proxy{URL: "ftp://127.0.0.1:21"}); err == nil {
 		t.Error("expected an error for an unsupported proxy scheme, got nil")
 	}
 }
@@ -33,6 +33,11 @@ func captureStdout(t *testing.T, fn func()) string {
 	return string(out)
 }
 
+// testNow is the fixed instant the expiry tests evaluate at, through
+// CertInfo.At or the now argument, so their day counts do not depend on when
+// they run. Certificates from genCert and certFromKey start just before it.
+var testNow = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
 // genCert generates a self-signed certificate with its raw DER populated (so
 // Fingerprint is meaningful). Each call uses a fresh key → a distinct cert.
 func genCert(t *testing.T, cn string, notAfter time.Time) *x509.Certificate {
@@ -44,7 +49,7 @@ func genCert(t *testing.T, cn string, notAfter time.Time) *x509.Certificate {
 	tmpl := x509.Certificate{
 		SerialNumber: big.NewInt(1),
 		Subject:      pkix.Name{CommonName: cn},
-		NotBefore:    time.Now().Add(-time.Hour),
+		NotBefore:    testNow.Add(-time.Hour),
 		NotAfter:     notAfter,
 		DNSNames:     []string{cn},
 		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
@@ -67,7 +72,7 @@ func certFromKey(t *testing.T, key *rsa.PrivateKey, serial *big.Int, notAfter ti
 	tmpl := x509.Certificate{
 		SerialNumber: serial,
 		Subject:      pkix.Name{CommonName: "reissue.example"},
-		NotBefore:    time.Now().Add(-time.Hour),
+		NotBefore:    testNow.Add(-time.Hour),
 		NotAfter:     notAfter,
 	}
 	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
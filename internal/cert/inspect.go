@@ -9,6 +9,7 @@ import (
 	"encoding/asn1"
 	"encoding/hex"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -90,11 +91,12 @@ func chainList(info *CertInfo) []*x509.Certificate {
 }
 
 // ChainPEM returns the PEM encoding of every certificate available for info — the
-// served chain (leaf first) or just the leaf for a file-loaded certificate — as
-// one CERTIFICATE block per certificate.
+// served chain (leaf first) followed by any intermediates -aia-fetch fetched to
+// repair it, or just the leaf for a file-loaded certificate — as one CERTIFICATE
+// block per certificate.
 func ChainPEM(info *CertInfo) []byte {
 	var out []byte
-	for _, c := range chainList(info) {
+	for _, c := range append(chainList(info), info.AIAFetched...) {
 		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
 	}
 	return out
@@ -120,9 +122,9 @@ func isWeakKey(c *x509.Certificate) bool {
 }
 
 // notYetValid reports whether the certificate's validity window has not started
-// yet (NotBefore is in the future).
-func notYetValid(c *x509.Certificate) bool {
-	return time.Now().Before(c.NotBefore)
+// yet at now (NotBefore is later).
+func notYetValid(c *x509.Certificate, now time.Time) bool {
+	return now.Before(c.NotBefore)
 }
 
 // nameMismatch reports whether the certificate does not cover the hostname it was
@@ -187,6 +189,18 @@ func MatchesPin(c *x509.Certificate, pin string) bool {
 	return pin == Fingerprint(c) || pin == SPKIFingerprint(c)
 }
 
+// MatchesAnyPin reports whether the certificate matches at least one of the
+// normalized pins (see MatchesPin), so a backup pin can cover a planned key
+// rollover. It is false for an empty pin set.
+func MatchesAnyPin(c *x509.Certificate, pins []string) bool {
+	for _, p := range pins {
+		if MatchesPin(c, p) {
+			return true
+		}
+	}
+	return false
+}
+
 // IssuerMatches reports whether the certificate's issuer DN contains substr,
 // case-insensitively. An empty substr matches anything.
 func IssuerMatches(c *x509.Certificate, substr string) bool {
@@ -196,14 +210,41 @@ func IssuerMatches(c *x509.Certificate, substr string) bool {
 	return strings.Contains(strings.ToLower(c.Issuer.String()), strings.ToLower(substr))
 }
 
+// stapleRefreshWindow is how close to its nextUpdate a stapled response may get
+// before it is flagged: a server that still staples it then is not refreshing.
+const stapleRefreshWindow = 24 * time.Hour
+
+// stapleExpiresSoon reports whether the stapled OCSP response is still current
+// but reaches its nextUpdate within stapleRefreshWindow.
+func stapleExpiresSoon(info *CertInfo) bool {
+	s := info.Staple
+	if s == nil || s.Err != nil || s.NextUpdate.IsZero() || s.Stale() {
+		return false
+	}
+	return time.Until(s.NextUpdate) < stapleRefreshWindow
+}
+
+// mustStapleMissing reports whether a fetched certificate requires OCSP stapling
+// (must-staple) but the server sent no staple — clients enforcing the extension
+// refuse such a connection.
+func mustStapleMissing(info *CertInfo) bool {
+	return info.TLSVersion != "" && info.Staple == nil && mustStaple(info.Cert)
+}
+
 // HasWarnings reports whether the certificate has any soft problem the tool warns
 // about — used by -strict to turn warnings into a non-zero exit.
 func HasWarnings(info *CertInfo) bool {
 	c := info.Cert
-	if notYetValid(c) || nameMismatch(info) || notServerAuth(c) {
+	if notYetValid(c, info.Now()) || nameMismatch(info) || notServerAuth(c) {
 		return true
 	}
-	if earliestExpiringBefore(info.Chain) != nil {
+	if earliestExpiringBefore(info.Chain) != nil || info.Revocation.Inconclusive() {
+		return true
+	}
+	if info.Staple.Inconclusive() || stapleExpiresSoon(info) || mustStapleMissing(info) || info.CRL.Inconclusive() || len(deprecatedVersions(info)) > 0 {
+		return true
+	}
+	if len(weakCiphers(info)) > 0 || keyTypeWarning(info) || info.DANE.Inconclusive() || info.CAA.Inconclusive() || info.DNS.Inconclusive() {
 		return true
 	}
 	return info.Verified && info.ChainErr != nil
@@ -278,6 +319,9 @@ func classifyChainErr(info *CertInfo) (kind, reason string) {
 		}
 		return "invalid", e.Error()
 	case x509.UnknownAuthorityError:
+		if len(info.AIAFetched) > 0 {
+			return "incomplete_chain", "incomplete chain: server must also send " + missingIntermediates(info)
+		}
 		if _, _, selfSigned, ok := chainBreak(info); ok && selfSigned {
 			return "untrusted_root", "chain ends at a self-signed root not in the system trust store"
 		}
@@ -287,6 +331,30 @@ func classifyChainErr(info *CertInfo) (kind, reason string) {
 	}
 }
 
+// classifyCRLErr turns a failed CRL check into a machine kind (crl_expired,
+// crl_unverifiable, crl_unavailable) and a human-readable reason. Returns empty
+// strings when the CRL check gave a verdict or did not run.
+func classifyCRLErr(info *CertInfo) (kind, reason string) {
+	if info.CRL == nil || info.CRL.Err == nil {
+		return "", ""
+	}
+	var e *crlError
+	if errors.As(info.CRL.Err, &e) {
+		return e.kind, e.Error()
+	}
+	return crlUnavailable, info.CRL.Err.Error()
+}
+
+// missingIntermediates lists the intermediates -aia-fetch had to fetch to
+// complete the chain, as quoted subject labels.
+func missingIntermediates(info *CertInfo) string {
+	names := make([]string, len(info.AIAFetched))
+	for i, c := range info.AIAFetched {
+		names[i] = fmt.Sprintf("%q", subjectName(c))
+	}
+	return strings.Join(names, ", ")
+}
+
 // untrustedIssuer returns the label of the issuer the chain could not be anchored
 // to, for the JSON view. Empty unless the failure is a trust/anchor problem.
 func untrustedIssuer(info *CertInfo) string {
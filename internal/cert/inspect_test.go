@@ -268,15 +268,15 @@ func TestIssuerMatches(t *testing.T) {
 
 // TestHasWarnings verifies the soft-problem predicate used by -strict.
 func TestHasWarnings(t *testing.T) {
-	healthy := genCert(t, "healthy.example", time.Now().Add(90*24*time.Hour))
-	if HasWarnings(&CertInfo{Cert: healthy, Verified: true}) {
+	healthy := genCert(t, "healthy.example", testNow.Add(90*24*time.Hour))
+	if HasWarnings(&CertInfo{Cert: healthy, Verified: true, At: testNow}) {
 		t.Error("a healthy verified cert should have no warnings")
 	}
 
 	notYet := &CertInfo{Cert: &x509.Certificate{
-		NotBefore: time.Now().Add(48 * time.Hour),
-		NotAfter:  time.Now().Add(90 * 24 * time.Hour),
-	}}
+		NotBefore: testNow.Add(48 * time.Hour),
+		NotAfter:  testNow.Add(90 * 24 * time.Hour),
+	}, At: testNow}
 	if !HasWarnings(notYet) {
 		t.Error("a not-yet-valid cert should warn")
 	}
@@ -346,13 +346,11 @@ func TestWeakCrypto(t *testing.T) {
 
 // TestCheapChecks verifies the not-yet-valid, name-coverage and EKU detectors.
 func TestCheapChecks(t *testing.T) {
-	now := time.Now()
-
 	// not-yet-valid
-	if !notYetValid(&x509.Certificate{NotBefore: now.Add(48 * time.Hour)}) {
+	if !notYetValid(&x509.Certificate{NotBefore: testNow.Add(48 * time.Hour)}, testNow) {
 		t.Error("future NotBefore should be flagged not-yet-valid")
 	}
-	if notYetValid(&x509.Certificate{NotBefore: now.Add(-time.Hour)}) {
+	if notYetValid(&x509.Certificate{NotBefore: testNow.Add(-time.Hour)}, testNow) {
 		t.Error("active certificate should not be flagged not-yet-valid")
 	}
 
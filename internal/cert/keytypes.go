@@ -0,0 +1,102 @@
+package cert
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// probedKeyTypes are the certificate key types -key-types asks for, each with the
+// cipher suite prefixes whose authentication requires that key type.
+var probedKeyTypes = []struct {
+	name     string
+	prefixes []string
+}{
+	{"RSA", []string{"TLS_ECDHE_RSA_", "TLS_RSA_"}},
+	{"ECDSA", []string{"TLS_ECDHE_ECDSA_"}},
+}
+
+// KeyTypeCert is the certificate a server presents to a client that only
+// accepts one key type (-key-types), for servers that run an RSA and an ECDSA
+// certificate side by side.
+type KeyTypeCert struct {
+	KeyType string    // "RSA" or "ECDSA"
+	Info    *CertInfo // nil when Err is set
+	Err     error     // The handshake failed: the server offers no certificate of this type
+}
+
+// keyTypeSuites returns every suite Go can offer with TLS 1.2 whose name starts
+// with one of prefixes, including tls.InsecureCipherSuites.
+func keyTypeSuites(prefixes []string) []uint16 {
+	var out []uint16
+	for _, s := range candidateSuites(tls.VersionTLS12) {
+		for _, p := range prefixes {
+			if strings.HasPrefix(s.Name, p) {
+				out = append(out, s.ID)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// scanKeyTypes runs one handshake per key type against address, pinned to TLS
+// 1.2 and offering only the cipher suites that key type can authenticate, so
+// the server must present its certificate of that type. TLS 1.3 cannot be used:
+// Go offers no way to narrow the signature algorithms a client advertises, so a
+// server that only speaks TLS 1.3 reports every key type as a failed handshake.
+// Each certificate is verified for name like the served one unless
+// opts.Insecure is set.
+func scanKeyTypes(address, name string, opts FetchOptions, base *tls.Config) []KeyTypeCert {
+	out := make([]KeyTypeCert, 0, len(probedKeyTypes))
+	for _, kt := range probedKeyTypes {
+		cfg := base.Clone()
+		cfg.MinVersion, cfg.MaxVersion = tls.VersionTLS12, tls.VersionTLS12
+		cfg.CipherSuites = keyTypeSuites(kt.prefixes)
+		conn, err := dialTLS(address, opts, cfg)
+		if err != nil {
+			out = append(out, KeyTypeCert{KeyType: kt.name, Err: err})
+			continue
+		}
+		state := conn.ConnectionState()
+		conn.Close()
+		certs := state.PeerCertificates
+		if len(certs) == 0 {
+			out = append(out, KeyTypeCert{KeyType: kt.name, Err: fmt.Errorf("no certificates found for %s", address)})
+			continue
+		}
+		info := &CertInfo{
+			Cert:        certs[0],
+			Chain:       certs,
+			TLSVersion:  tls.VersionName(state.Version),
+			CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+			CheckedName: name,
+			At:          opts.At,
+		}
+		if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+			info.UsedIP = tcpAddr.IP.String()
+		}
+		if !opts.Insecure {
+			info.Verified = true
+			info.ChainErr = verifyChain(certs, name, opts.Roots, opts.At)
+		}
+		out = append(out, KeyTypeCert{KeyType: kt.name, Info: info})
+	}
+	return out
+}
+
+// keyTypeWarning reports whether a certificate found by -key-types has a
+// problem the served one would be warned about: an invalid chain, a name
+// mismatch or a validity window that has not started.
+func keyTypeWarning(info *CertInfo) bool {
+	for _, k := range info.KeyTypes {
+		if k.Info == nil {
+			continue
+		}
+		if (k.Info.Verified && k.Info.ChainErr != nil) || nameMismatch(k.Info) || notYetValid(k.Info.Cert, k.Info.Now()) {
+			return true
+		}
+	}
+	return false
+}
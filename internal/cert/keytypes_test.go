@@ -0,0 +1,119 @@
+package cert
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// keyedCert issues a self-signed certificate for localhost with key, returned
+// both parsed and ready for a tls.Config.
+func keyedCert(t *testing.T, key crypto.Signer, notAfter time.Time) (*x509.Certificate, tls.Certificate) {
+	t.Helper()
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    testNow.Add(-time.Hour),
+		NotAfter:     notAfter,
+		DNSNames:     []string{"localhost"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	c, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return c, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: c}
+}
+
+// TestFetch_KeyTypes runs -key-types against a dual-certificate server whose
+// ECDSA certificate expires first, and against an RSA-only server.
+func TestFetch_KeyTypes(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	rsaCert, rsaPair := keyedCert(t, rsaKey, testNow.Add(90*24*time.Hour))
+	ecCert, ecPair := keyedCert(t, ecKey, testNow.Add(5*24*time.Hour+time.Hour))
+	roots := x509.NewCertPool()
+	roots.AddCert(rsaCert)
+	roots.AddCert(ecCert)
+
+	fetch := func(pairs ...tls.Certificate) *CertInfo {
+		t.Helper()
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		srv.TLS = &tls.Config{Certificates: pairs}
+		srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+		srv.StartTLS()
+		defer srv.Close()
+		host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+		info, err := (&CertificateFetcherImpl{}).Fetch("localhost", port, host, FetchOptions{Roots: roots, Timeout: 5 * time.Second, KeyTypes: true, At: testNow})
+		if err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		return info
+	}
+
+	dual := fetch(rsaPair, ecPair)
+	if len(dual.KeyTypes) != 2 || dual.KeyTypes[0].Info == nil || dual.KeyTypes[1].Info == nil {
+		t.Fatalf("dual server: expected both key types, got %+v", dual.KeyTypes)
+	}
+	if got := dual.KeyTypes[0].Info.Cert; Fingerprint(got) != Fingerprint(rsaCert) {
+		t.Errorf("dual server: RSA probe got %s", formatPublicKey(got))
+	}
+	if got := dual.KeyTypes[1].Info.Cert; Fingerprint(got) != Fingerprint(ecCert) {
+		t.Errorf("dual server: ECDSA probe got %s", formatPublicKey(got))
+	}
+	if dual.KeyTypes[1].Info.ChainErr != nil {
+		t.Errorf("dual server: ECDSA chain: %v", dual.KeyTypes[1].Info.ChainErr)
+	}
+	if Fingerprint(dual.Cert) == Fingerprint(ecCert) {
+		t.Fatal("dual server: expected the RSA certificate served by default")
+	}
+	if got := dual.MinDaysUntilExpiry(); got != 5 {
+		t.Errorf("dual server: MinDaysUntilExpiry = %d, want the ECDSA certificate's 5", got)
+	}
+	if HasWarnings(dual) {
+		t.Error("dual server: unexpected warning")
+	}
+
+	out := captureStdout(t, func() { (&CertificatePrinterImpl{}).Print(dual, PrintOptions{}) })
+	for _, want := range []string{"Certificates by key type:", "RSA    " + Fingerprint(rsaCert)[:16], "VALID (served by default)", "ECDSA  " + Fingerprint(ecCert)[:16] + "  5 days"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	single := fetch(rsaPair)
+	if len(single.KeyTypes) != 2 || single.KeyTypes[0].Info == nil || single.KeyTypes[1].Err == nil {
+		t.Fatalf("RSA-only server: expected RSA found and ECDSA failed, got %+v", single.KeyTypes)
+	}
+	if got := single.MinDaysUntilExpiry(); got != 90 {
+		t.Errorf("RSA-only server: MinDaysUntilExpiry = %d, want 90", got)
+	}
+	out = captureStdout(t, func() { (&CertificatePrinterImpl{}).Print(single, PrintOptions{}) })
+	if !strings.Contains(out, "ECDSA  not offered — ") {
+		t.Errorf("output missing the ECDSA failure:\n%s", out)
+	}
+}
@@ -0,0 +1,83 @@
+package cert
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strings"
+)
+
+// KnownCerts is the trust-on-first-use store of -known-certs: for each
+// host:port, the normalized pins (see NormalizePin) its certificate must match
+// one of. The first is the public key recorded on first contact or by
+// "known-certs accept"; any others are backups for a planned key rollover.
+type KnownCerts map[string][]string
+
+// knownCertsHeader opens every file SaveKnownCerts writes.
+const knownCertsHeader = `# ssl-watch known certificates (-known-certs).
+# One target per line: host:port, then the sha256 pins its certificate may
+# match. The first is the recorded public key; any others are backups.
+`
+
+// LoadKnownCerts reads a -known-certs file. A file that does not exist yet is
+// an empty store, so the first run creates it. Blank lines and lines starting
+// with "#" are skipped; several lines for one target add up.
+func LoadKnownCerts(path string) (KnownCerts, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return KnownCerts{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known certificates file: %v", err)
+	}
+	known := KnownCerts{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: expected host:port followed by at least one pin", path, i+1)
+		}
+		if _, _, err := net.SplitHostPort(fields[0]); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid target %q (expected host:port)", path, i+1, fields[0])
+		}
+		for _, f := range fields[1:] {
+			pin, err := NormalizePin(f)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, i+1, err)
+			}
+			known[fields[0]] = append(known[fields[0]], pin)
+		}
+	}
+	return known, nil
+}
+
+// SaveKnownCerts writes known to path, one line per target in sorted order,
+// replacing the file in one step like SaveState.
+func SaveKnownCerts(path string, known KnownCerts) error {
+	var b strings.Builder
+	b.WriteString(knownCertsHeader)
+	for _, target := range slices.Sorted(maps.Keys(known)) {
+		b.WriteString(known.Line(target) + "\n")
+	}
+	if err := replaceFile(path, []byte(b.String())); err != nil {
+		return fmt.Errorf("failed to write known certificates file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Line renders target's entry as it is written to the file: the target, then
+// each pin as "sha256:<hex>".
+func (k KnownCerts) Line(target string) string {
+	var b strings.Builder
+	b.WriteString(target)
+	for _, pin := range k[target] {
+		b.WriteString(" sha256:" + pin)
+	}
+	return b.String()
+}
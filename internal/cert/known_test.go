@@ -0,0 +1,52 @@
+package cert
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadSaveKnownCerts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_certs")
+	known, err := LoadKnownCerts(path)
+	if err != nil || len(known) != 0 {
+		t.Fatalf("missing file: got %v, %v", known, err)
+	}
+
+	a, b := strings.Repeat("ab", 32), strings.Repeat("cd", 32)
+	content := "# comment\n\nexample.com:443 sha256:" + strings.ToUpper(a) + "\n" +
+		"example.com:443 sha256:" + b + "\n[2001:db8::1]:8443 sha256:" + b + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	known, err = LoadKnownCerts(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !slices.Equal(known["example.com:443"], []string{a, b}) || len(known["[2001:db8::1]:8443"]) != 1 {
+		t.Errorf("load: got %v", known)
+	}
+
+	if err := SaveKnownCerts(path, known); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "#") || !strings.Contains(string(data), "example.com:443 sha256:"+a+" sha256:"+b+"\n") {
+		t.Errorf("save: got %q", data)
+	}
+	again, err := LoadKnownCerts(path)
+	if err != nil || !slices.Equal(again["example.com:443"], known["example.com:443"]) {
+		t.Errorf("roundtrip: got %v, %v", again, err)
+	}
+
+	for _, bad := range []string{"example.com:443\n", "example.com sha256:" + a + "\n", "example.com:443 " + a + "\n"} {
+		if err := os.WriteFile(path, []byte(bad), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadKnownCerts(path); err == nil {
+			t.Errorf("LoadKnownCerts(%q): expected an error", bad)
+		}
+	}
+}
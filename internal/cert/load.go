@@ -76,6 +76,25 @@ func LoadClientCert(certFile, keyFile string) (*tls.Certificate, error) {
 	return &pair, nil
 }
 
+// LoadCRLFiles reads the CRLs (DER or PEM) at paths, for checking chain
+// certificates against them (-crlfile). It returns an error naming the first
+// file that cannot be read or parsed.
+func LoadCRLFiles(paths []string) ([]CRLFile, error) {
+	out := make([]CRLFile, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CRL file %s: %v", path, err)
+		}
+		list, err := parseCRL(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CRL file %s: %v", path, err)
+		}
+		out = append(out, CRLFile{Path: path, List: list})
+	}
+	return out, nil
+}
+
 // LoadCAFile reads a PEM bundle and returns a certificate pool containing its
 // certificates, for use as the verification roots (replacing the system roots).
 // It returns an error if the file cannot be read or holds no certificates.
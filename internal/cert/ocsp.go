@@ -0,0 +1,489 @@
+package cert
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Revocation status values reported in Revocation.Status.
+const (
+	RevocationGood    = "good"
+	RevocationRevoked = "revoked"
+	RevocationUnknown = "unknown"
+)
+
+// Revocation is the outcome of a revocation check of the leaf certificate. When
+// the check could not be completed, Err is set and Status is empty; a revoked
+// certificate is always reported, a failed check never is.
+type Revocation struct {
+	Source     string    // How the status was obtained: "ocsp" (queried), "staple" (stapled to the handshake) or "crl"
+	Responder  string    // URL that answered, or the CRL's URL or file
+	Subject    string    // Certificate the status is about when it is not the leaf (CRL checks cover the chain)
+	Status     string    // RevocationGood, RevocationRevoked or RevocationUnknown; empty on error
+	RevokedAt  time.Time // When the certificate was revoked (revoked only)
+	Reason     string    // CRL reason (e.g. "keyCompromise"); empty when not given
+	ThisUpdate time.Time // When the status was known to be correct
+	NextUpdate time.Time // When newer status will be available; zero when not given
+	Err        error     // Why the check could not be completed
+}
+
+// Revoked reports whether the check completed and found the certificate revoked.
+func (r *Revocation) Revoked() bool {
+	return r != nil && r.Err == nil && r.Status == RevocationRevoked
+}
+
+// Stale reports whether the status is past its NextUpdate, i.e. the responder
+// served an outdated answer.
+func (r *Revocation) Stale() bool {
+	return r != nil && r.Err == nil && !r.NextUpdate.IsZero() && time.Now().After(r.NextUpdate)
+}
+
+// Inconclusive reports whether a revocation check ran but gave no trustworthy
+// verdict: it failed, the responder did not know the certificate, or the answer
+// is stale. Treated as a warning (see HasWarnings), never as revoked.
+func (r *Revocation) Inconclusive() bool {
+	return r != nil && !r.Revoked() && (r.Err != nil || r.Status == RevocationUnknown || r.Stale())
+}
+
+// sourceName is the human label of how the status was obtained.
+func (r *Revocation) sourceName() string {
+	if r.Source == "staple" {
+		return "stapled OCSP"
+	}
+	return strings.ToUpper(r.Source)
+}
+
+// revocationReasons names the RFC 5280 CRLReason codes.
+var revocationReasons = map[int]string{
+	0:  "unspecified",
+	1:  "keyCompromise",
+	2:  "cACompromise",
+	3:  "affiliationChanged",
+	4:  "superseded",
+	5:  "cessationOfOperation",
+	6:  "certificateHold",
+	8:  "removeFromCRL",
+	9:  "privilegeWithdrawn",
+	10: "aACompromise",
+}
+
+// reasonName returns the name of a CRLReason code, or the bare number for an
+// unassigned one.
+func reasonName(code int) string {
+	if name, ok := revocationReasons[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("reason %d", code)
+}
+
+// OCSP wire structures (RFC 6960), marshalled with encoding/asn1.
+
+var (
+	oidOCSPBasic = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidSHA1      = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256    = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+type ocspCertID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type ocspRequest struct {
+	TBSRequest ocspTBSRequest
+}
+
+type ocspTBSRequest struct {
+	Version     int `asn1:"explicit,tag:0,default:0,optional"`
+	RequestList []ocspSingleRequest
+}
+
+type ocspSingleRequest struct {
+	Cert ocspCertID
+}
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData    ocspResponseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	Raw                asn1.RawContent
+	Version            int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID     asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []ocspSingleResponse
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspSingleResponse struct {
+	CertID           ocspCertID
+	Good             asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked          ocspRevokedInfo  `asn1:"tag:1,optional"`
+	Unknown          asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate       time.Time        `asn1:"generalized"`
+	NextUpdate       time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	SingleExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional,default:-1"`
+}
+
+// ocspStatusText names the non-successful OCSPResponseStatus values.
+var ocspStatusText = map[asn1.Enumerated]string{
+	1: "malformed request",
+	2: "internal error",
+	3: "try later",
+	5: "signature required",
+	6: "unauthorized",
+}
+
+// signatureAlgorithms maps the signature algorithm OIDs an OCSP responder uses
+// to their x509 equivalents (x509 does not export its own table).
+var signatureAlgorithms = []struct {
+	oid asn1.ObjectIdentifier
+	alg x509.SignatureAlgorithm
+}{
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 5}, x509.SHA1WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}, x509.SHA256WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}, x509.SHA384WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}, x509.SHA512WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 1}, x509.ECDSAWithSHA1},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}, x509.ECDSAWithSHA256},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}, x509.ECDSAWithSHA384},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}, x509.ECDSAWithSHA512},
+	{asn1.ObjectIdentifier{1, 3, 101, 112}, x509.PureEd25519},
+}
+
+// signatureAlgorithm returns the x509 signature algorithm for an OID, or
+// UnknownSignatureAlgorithm.
+func signatureAlgorithm(oid asn1.ObjectIdentifier) x509.SignatureAlgorithm {
+	for _, a := range signatureAlgorithms {
+		if a.oid.Equal(oid) {
+			return a.alg
+		}
+	}
+	return x509.UnknownSignatureAlgorithm
+}
+
+// findIssuer returns the certificate in chain that issued c (matching subject
+// and a valid signature), or nil when the server did not send it.
+func findIssuer(c *x509.Certificate, chain []*x509.Certificate) *x509.Certificate {
+	for _, cand := range chain {
+		if cand == c || !bytes.Equal(cand.RawSubject, c.RawIssuer) {
+			continue
+		}
+		if c.CheckSignatureFrom(cand) == nil {
+			return cand
+		}
+	}
+	return nil
+}
+
+// issuerKeyHash hashes the issuer's subjectPublicKey bit string, as CertID
+// requires (the key itself, not the whole SubjectPublicKeyInfo).
+func issuerKeyHash(issuer *x509.Certificate, h crypto.Hash) ([]byte, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, fmt.Errorf("failed to parse issuer public key: %v", err)
+	}
+	hh := h.New()
+	hh.Write(spki.PublicKey.RightAlign())
+	return hh.Sum(nil), nil
+}
+
+// ocspCertIDFor builds the SHA-1 CertID identifying c issued by issuer — SHA-1
+// is the hash every responder is required to accept.
+func ocspCertIDFor(c, issuer *x509.Certificate) (ocspCertID, error) {
+	keyHash, err := issuerKeyHash(issuer, crypto.SHA1)
+	if err != nil {
+		return ocspCertID{}, err
+	}
+	nameHash := sha1.Sum(issuer.RawSubject)
+	return ocspCertID{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue},
+		NameHash:      nameHash[:],
+		IssuerKeyHash: keyHash,
+		SerialNumber:  c.SerialNumber,
+	}, nil
+}
+
+// createOCSPRequest returns the DER OCSP request for c issued by issuer.
+func createOCSPRequest(c, issuer *x509.Certificate) ([]byte, error) {
+	id, err := ocspCertIDFor(c, issuer)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(ocspRequest{TBSRequest: ocspTBSRequest{RequestList: []ocspSingleRequest{{Cert: id}}}})
+}
+
+// matchesCertID reports whether a response's CertID names c issued by issuer,
+// for either hash algorithm a responder may answer with.
+func matchesCertID(id ocspCertID, c, issuer *x509.Certificate) bool {
+	if id.SerialNumber == nil || id.SerialNumber.Cmp(c.SerialNumber) != 0 {
+		return false
+	}
+	var h crypto.Hash
+	switch {
+	case id.HashAlgorithm.Algorithm.Equal(oidSHA1):
+		h = crypto.SHA1
+	case id.HashAlgorithm.Algorithm.Equal(oidSHA256):
+		h = crypto.SHA256
+	default:
+		return false
+	}
+	keyHash, err := issuerKeyHash(issuer, h)
+	if err != nil || !bytes.Equal(keyHash, id.IssuerKeyHash) {
+		return false
+	}
+	var nameHash []byte
+	if h == crypto.SHA1 {
+		sum := sha1.Sum(issuer.RawSubject)
+		nameHash = sum[:]
+	} else {
+		sum := sha256.Sum256(issuer.RawSubject)
+		nameHash = sum[:]
+	}
+	return bytes.Equal(nameHash, id.NameHash)
+}
+
+// parseOCSPResponse decodes a DER OCSP response for c issued by issuer, checks
+// its signature — by the issuer itself or by a responder certificate the issuer
+// delegated OCSP signing to — and returns the status it reports for c.
+func parseOCSPResponse(der []byte, c, issuer *x509.Certificate) (*Revocation, error) {
+	var resp ocspResponse
+	rest, err := asn1.Unmarshal(der, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("malformed OCSP response: %v", err)
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("malformed OCSP response: trailing data")
+	}
+	if resp.Status != 0 {
+		if text, ok := ocspStatusText[resp.Status]; ok {
+			return nil, fmt.Errorf("OCSP responder error: %s", text)
+		}
+		return nil, fmt.Errorf("OCSP responder error: status %d", resp.Status)
+	}
+	if !resp.Response.ResponseType.Equal(oidOCSPBasic) {
+		return nil, errors.New("unsupported OCSP response type")
+	}
+	var basic ocspBasicResponse
+	if _, err := asn1.Unmarshal(resp.Response.Response, &basic); err != nil {
+		return nil, fmt.Errorf("malformed OCSP basic response: %v", err)
+	}
+
+	signer := issuer
+	if len(basic.Certificates) > 0 {
+		rc, err := x509.ParseCertificate(basic.Certificates[0].FullBytes)
+		if err != nil {
+			return nil, fmt.Errorf("malformed OCSP responder certificate: %v", err)
+		}
+		if !rc.Equal(issuer) {
+			if err := rc.CheckSignatureFrom(issuer); err != nil {
+				return nil, fmt.Errorf("OCSP responder certificate not issued by the certificate's issuer: %v", err)
+			}
+			if !hasExtKeyUsage(rc, x509.ExtKeyUsageOCSPSigning) {
+				return nil, errors.New("OCSP responder certificate is not authorized for OCSP signing")
+			}
+			signer = rc
+		}
+	}
+	alg := signatureAlgorithm(basic.SignatureAlgorithm.Algorithm)
+	if alg == x509.UnknownSignatureAlgorithm {
+		return nil, fmt.Errorf("unsupported OCSP signature algorithm %v", basic.SignatureAlgorithm.Algorithm)
+	}
+	if err := signer.CheckSignature(alg, basic.TBSResponseData.Raw, basic.Signature.RightAlign()); err != nil {
+		return nil, fmt.Errorf("OCSP response signature invalid: %v", err)
+	}
+
+	for _, r := range basic.TBSResponseData.Responses {
+		if !matchesCertID(r.CertID, c, issuer) {
+			continue
+		}
+		out := &Revocation{ThisUpdate: r.ThisUpdate, NextUpdate: r.NextUpdate}
+		switch {
+		case bool(r.Good):
+			out.Status = RevocationGood
+		case bool(r.Unknown):
+			out.Status = RevocationUnknown
+		default:
+			out.Status = RevocationRevoked
+			out.RevokedAt = r.Revoked.RevocationTime
+			if r.Revoked.Reason >= 0 {
+				out.Reason = reasonName(int(r.Revoked.Reason))
+			}
+		}
+		return out, nil
+	}
+	return nil, errors.New("OCSP response does not cover the certificate")
+}
+
+// hasExtKeyUsage reports whether c lists the given extended key usage.
+func hasExtKeyUsage(c *x509.Certificate, u x509.ExtKeyUsage) bool {
+	for _, have := range c.ExtKeyUsage {
+		if have == u {
+			return true
+		}
+	}
+	return false
+}
+
+// maxOCSPResponse bounds how much of a responder's answer is read.
+const maxOCSPResponse = 1 << 20
+
+// httpClient returns the client for side requests made while checking a
+// certificate (OCSP): bounded by timeout and routed through the -proxy, if any.
+// An http:// proxy forwards the requests; https:// and SOCKS proxies tunnel each
+// connection with dialViaProxy, so the proxy's own TLS settings apply.
+func httpClient(timeout time.Duration, proxy Proxy) (*http.Client, error) {
+	tr := &http.Transport{Proxy: nil}
+	if proxy.URL != "" {
+		u, err := url.Parse(proxy.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -proxy %q: %v", proxy.URL, err)
+		}
+		if u.Scheme == "" || u.Scheme == "http" {
+			tr.Proxy = http.ProxyURL(u)
+		} else {
+			tr.DialContext = func(_ context.Context, _, addr string) (net.Conn, error) {
+				return dialViaProxy(addr, timeout, proxy)
+			}
+		}
+	}
+	return &http.Client{Timeout: timeout, Transport: tr}, nil
+}
+
+// checkOCSP asks the leaf's OCSP responder (from its AIA extension) for the
+// leaf's status and verifies the signed answer. The issuer must be in chain. It
+// always returns a Revocation; a check that could not complete carries Err.
+func checkOCSP(chain []*x509.Certificate, timeout time.Duration, proxy Proxy) *Revocation {
+	out := &Revocation{Source: "ocsp"}
+	leaf := chain[0]
+	if len(leaf.OCSPServer) == 0 {
+		out.Err = errors.New("certificate names no OCSP responder")
+		return out
+	}
+	out.Responder = leaf.OCSPServer[0]
+	issuer := findIssuer(leaf, chain)
+	if issuer == nil {
+		out.Err = errors.New("issuer certificate not served; cannot build the OCSP request")
+		return out
+	}
+	req, err := createOCSPRequest(leaf, issuer)
+	if err != nil {
+		out.Err = err
+		return out
+	}
+	client, err := httpClient(timeout, proxy)
+	if err != nil {
+		out.Err = err
+		return out
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, out.Responder, bytes.NewReader(req))
+	if err != nil {
+		out.Err = fmt.Errorf("invalid OCSP responder URL %q: %v", out.Responder, err)
+		return out
+	}
+	httpReq.Header.Set("Content-Type", "application/ocsp-request")
+	httpReq.Header.Set("Accept", "application/ocsp-response")
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		out.Err = fmt.Errorf("OCSP request failed: %v", err)
+		return out
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		out.Err = fmt.Errorf("OCSP responder returned HTTP %d", resp.StatusCode)
+		return out
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxOCSPResponse))
+	if err != nil {
+		out.Err = fmt.Errorf("failed to read OCSP response: %v", err)
+		return out
+	}
+	status, err := parseOCSPResponse(body, leaf, issuer)
+	if err != nil {
+		out.Err = err
+		return out
+	}
+	status.Source, status.Responder = out.Source, out.Responder
+	return status
+}
+
+// checkStaple verifies the OCSP response the server stapled to the handshake
+// against the issuer in the served chain and returns the status it reports for
+// the leaf (Source "staple"). A staple that cannot be verified carries Err.
+func checkStaple(der []byte, chain []*x509.Certificate) *Revocation {
+	out := &Revocation{Source: "staple"}
+	issuer := findIssuer(chain[0], chain)
+	if issuer == nil {
+		out.Err = errors.New("issuer certificate not served; cannot verify the staple")
+		return out
+	}
+	status, err := parseOCSPResponse(der, chain[0], issuer)
+	if err != nil {
+		out.Err = err
+		return out
+	}
+	status.Source = out.Source
+	return status
+}
+
+// oidTLSFeature is the TLS Feature extension (RFC 7633); listing status_request
+// (5) in it makes the certificate "must-staple".
+var oidTLSFeature = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+
+// mustStaple reports whether the certificate requires a stapled OCSP response
+// (a TLS Feature extension listing status_request).
+func mustStaple(c *x509.Certificate) bool {
+	for _, ext := range c.Extensions {
+		if !ext.Id.Equal(oidTLSFeature) {
+			continue
+		}
+		var features []int
+		if _, err := asn1.Unmarshal(ext.Value, &features); err != nil {
+			return false
+		}
+		for _, f := range features {
+			if f == 5 {
+				return true
+			}
+		}
+	}
+	return false
+}
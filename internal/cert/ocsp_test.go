@@ -0,0 +1,344 @@
+package cert
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ocspCA is a test issuer able to sign leaf certificates and OCSP responses.
+type ocspCA struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+}
+
+// newOCSPCA creates a self-signed test CA.
+func newOCSPCA(t *testing.T) ocspCA {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	tmpl := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "OCSP Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	c, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse CA: %v", err)
+	}
+	return ocspCA{cert: c, key: key}
+}
+
+// issue signs a certificate from tmpl with the CA and returns it with its key.
+func (ca ocspCA) issue(t *testing.T, tmpl *x509.Certificate) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(90 * 24 * time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	c, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return c, key
+}
+
+// ocspLeaf issues a leaf naming responder as its OCSP server.
+func (ca ocspCA) ocspLeaf(t *testing.T, responder string) *x509.Certificate {
+	t.Helper()
+	leaf, _ := ca.issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(4242),
+		Subject:      pkix.Name{CommonName: "leaf.example"},
+		DNSNames:     []string{"leaf.example"},
+		OCSPServer:   []string{responder},
+	})
+	return leaf
+}
+
+// signOCSP builds a DER OCSP response carrying single for the CA's leaf, signed
+// by signer/key; a non-nil signer other than the CA is embedded as a delegated
+// responder certificate.
+func signOCSP(t *testing.T, ca ocspCA, single ocspSingleResponse, signer *x509.Certificate, key *rsa.PrivateKey) []byte {
+	t.Helper()
+	keyHash, err := issuerKeyHash(ca.cert, crypto.SHA1)
+	if err != nil {
+		t.Fatalf("key hash: %v", err)
+	}
+	idBytes, err := asn1.Marshal(keyHash)
+	if err != nil {
+		t.Fatalf("marshal responder id: %v", err)
+	}
+	tbs, err := asn1.Marshal(ocspResponseData{
+		RawResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: idBytes},
+		ProducedAt:     time.Now().UTC().Truncate(time.Second),
+		Responses:      []ocspSingleResponse{single},
+	})
+	if err != nil {
+		t.Fatalf("marshal response data: %v", err)
+	}
+	digest := sha256.Sum256(tbs)
+	sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	basic := ocspBasicResponse{
+		TBSResponseData:    ocspResponseData{Raw: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: signatureAlgorithms[1].oid, Parameters: asn1.NullRawValue},
+		Signature:          asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)},
+	}
+	if signer != ca.cert {
+		basic.Certificates = []asn1.RawValue{{FullBytes: signer.Raw}}
+	}
+	basicDER, err := asn1.Marshal(basic)
+	if err != nil {
+		t.Fatalf("marshal basic response: %v", err)
+	}
+	der, err := asn1.Marshal(ocspResponse{Response: ocspResponseBytes{ResponseType: oidOCSPBasic, Response: basicDER}})
+	if err != nil {
+		t.Fatalf("marshal response: %v", err)
+	}
+	return der
+}
+
+// singleFor builds a SingleResponse for leaf with the given status fields set.
+func singleFor(t *testing.T, ca ocspCA, leaf *x509.Certificate, set func(*ocspSingleResponse)) ocspSingleResponse {
+	t.Helper()
+	id, err := ocspCertIDFor(leaf, ca.cert)
+	if err != nil {
+		t.Fatalf("cert id: %v", err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	s := ocspSingleResponse{CertID: id, ThisUpdate: now.Add(-time.Hour), NextUpdate: now.Add(24 * time.Hour)}
+	set(&s)
+	return s
+}
+
+// TestCheckOCSP runs checkOCSP against a local responder: it must POST a request
+// naming the leaf, and report good / revoked (with time and reason) / unknown
+// answers, accepting a delegated responder only with the OCSP-signing EKU.
+func TestCheckOCSP(t *testing.T) {
+	ca := newOCSPCA(t)
+	var answer []byte
+	var gotSerial *big.Int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req ocspRequest
+		if r.Method == http.MethodPost && r.Header.Get("Content-Type") == "application/ocsp-request" {
+			if _, err := asn1.Unmarshal(body, &req); err == nil && len(req.TBSRequest.RequestList) == 1 {
+				gotSerial = req.TBSRequest.RequestList[0].Cert.SerialNumber
+			}
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		_, _ = w.Write(answer)
+	}))
+	defer srv.Close()
+
+	leaf := ca.ocspLeaf(t, srv.URL)
+	chain := []*x509.Certificate{leaf, ca.cert}
+	revokedAt := time.Now().UTC().Add(-48 * time.Hour).Truncate(time.Second)
+
+	t.Run("good", func(t *testing.T) {
+		answer = signOCSP(t, ca, singleFor(t, ca, leaf, func(s *ocspSingleResponse) { s.Good = true }), ca.cert, ca.key)
+		r := checkOCSP(chain, 5*time.Second, Proxy{})
+		if r.Err != nil || r.Status != RevocationGood {
+			t.Fatalf("expected good, got status=%q err=%v", r.Status, r.Err)
+		}
+		if gotSerial == nil || gotSerial.Cmp(leaf.SerialNumber) != 0 {
+			t.Errorf("responder did not receive the leaf serial, got %v", gotSerial)
+		}
+		if r.Responder != srv.URL || r.Source != "ocsp" || r.NextUpdate.IsZero() || r.Stale() || r.Inconclusive() {
+			t.Errorf("unexpected result details: %+v", r)
+		}
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		answer = signOCSP(t, ca, singleFor(t, ca, leaf, func(s *ocspSingleResponse) {
+			s.Revoked = ocspRevokedInfo{RevocationTime: revokedAt, Reason: 1}
+		}), ca.cert, ca.key)
+		r := checkOCSP(chain, 5*time.Second, Proxy{})
+		if !r.Revoked() || !r.RevokedAt.Equal(revokedAt) || r.Reason != "keyCompromise" {
+			t.Fatalf("expected revoked on %s (keyCompromise), got %+v", revokedAt, r)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		answer = signOCSP(t, ca, singleFor(t, ca, leaf, func(s *ocspSingleResponse) { s.Unknown = true }), ca.cert, ca.key)
+		r := checkOCSP(chain, 5*time.Second, Proxy{})
+		if r.Status != RevocationUnknown || !r.Inconclusive() {
+			t.Fatalf("expected unknown, got %+v", r)
+		}
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		answer = signOCSP(t, ca, singleFor(t, ca, leaf, func(s *ocspSingleResponse) {
+			s.Good = true
+			s.NextUpdate = time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+		}), ca.cert, ca.key)
+		if r := checkOCSP(chain, 5*time.Second, Proxy{}); !r.Stale() || !r.Inconclusive() {
+			t.Fatalf("expected a stale answer, got %+v", r)
+		}
+	})
+
+	t.Run("delegated responder", func(t *testing.T) {
+		responder, rkey := ca.issue(t, &x509.Certificate{
+			SerialNumber: big.NewInt(7),
+			Subject:      pkix.Name{CommonName: "OCSP Responder"},
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+		})
+		answer = signOCSP(t, ca, singleFor(t, ca, leaf, func(s *ocspSingleResponse) { s.Good = true }), responder, rkey)
+		if r := checkOCSP(chain, 5*time.Second, Proxy{}); r.Err != nil || r.Status != RevocationGood {
+			t.Fatalf("expected good from a delegated responder, got status=%q err=%v", r.Status, r.Err)
+		}
+
+		plain, pkey := ca.issue(t, &x509.Certificate{SerialNumber: big.NewInt(8), Subject: pkix.Name{CommonName: "Not A Responder"}})
+		answer = signOCSP(t, ca, singleFor(t, ca, leaf, func(s *ocspSingleResponse) { s.Good = true }), plain, pkey)
+		if r := checkOCSP(chain, 5*time.Second, Proxy{}); r.Err == nil || !strings.Contains(r.Err.Error(), "not authorized") {
+			t.Fatalf("expected a responder without the OCSP-signing EKU to be rejected, got %+v", r)
+		}
+	})
+
+	t.Run("forged signature", func(t *testing.T) {
+		other := newOCSPCA(t)
+		answer = signOCSP(t, ca, singleFor(t, ca, leaf, func(s *ocspSingleResponse) { s.Good = true }), ca.cert, other.key)
+		if r := checkOCSP(chain, 5*time.Second, Proxy{}); r.Err == nil || !strings.Contains(r.Err.Error(), "signature") {
+			t.Fatalf("expected a signature error, got %+v", r)
+		}
+	})
+
+	t.Run("responder error", func(t *testing.T) {
+		answer, _ = asn1.Marshal(ocspResponse{Status: 3})
+		if r := checkOCSP(chain, 5*time.Second, Proxy{}); r.Err == nil || !strings.Contains(r.Err.Error(), "try later") {
+			t.Fatalf("expected a try-later error, got %+v", r)
+		}
+	})
+}
+
+// TestCheckOCSP_Unavailable verifies the check fails softly (Err set, never
+// revoked) when there is no responder, no issuer or no usable HTTP answer.
+func TestCheckOCSP_Unavailable(t *testing.T) {
+	ca := newOCSPCA(t)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer down.Close()
+
+	noAIA, _ := ca.issue(t, &x509.Certificate{SerialNumber: big.NewInt(5), Subject: pkix.Name{CommonName: "noaia.example"}})
+	leaf := ca.ocspLeaf(t, down.URL)
+
+	cases := map[string]struct {
+		chain []*x509.Certificate
+		want  string
+	}{
+		"no responder": {[]*x509.Certificate{noAIA, ca.cert}, "no OCSP responder"},
+		"no issuer":    {[]*x509.Certificate{leaf}, "issuer certificate not served"},
+		"http error":   {[]*x509.Certificate{leaf, ca.cert}, "HTTP 500"},
+	}
+	for name, tc := range cases {
+		r := checkOCSP(tc.chain, 5*time.Second, Proxy{})
+		if r.Err == nil || !strings.Contains(r.Err.Error(), tc.want) || r.Revoked() || !r.Inconclusive() {
+			t.Errorf("%s: expected an inconclusive %q error, got %+v", name, tc.want, r)
+		}
+	}
+}
+
+// TestFetch_Staple serves a leaf with a stapled OCSP response from a local TLS
+// server and verifies Fetch parses and verifies it, and that a forged staple is
+// kept but reported as unusable.
+func TestFetch_Staple(t *testing.T) {
+	ca := newOCSPCA(t)
+	leaf, key := ca.issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(99),
+		Subject:      pkix.Name{CommonName: "staple.example"},
+		DNSNames:     []string{"staple.example"},
+	})
+	good := signOCSP(t, ca, singleFor(t, ca, leaf, func(s *ocspSingleResponse) { s.Good = true }), ca.cert, ca.key)
+	forged := signOCSP(t, ca, singleFor(t, ca, leaf, func(s *ocspSingleResponse) { s.Good = true }), ca.cert, newOCSPCA(t).key)
+
+	fetch := func(staple []byte) *CertInfo {
+		t.Helper()
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		srv.TLS = &tls.Config{Certificates: []tls.Certificate{{
+			Certificate: [][]byte{leaf.Raw, ca.cert.Raw},
+			PrivateKey:  key,
+			OCSPStaple:  staple,
+		}}}
+		srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+		srv.StartTLS()
+		defer srv.Close()
+		host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+		info, err := (&CertificateFetcherImpl{}).Fetch(host, port, "", FetchOptions{Insecure: true, Timeout: 5 * time.Second})
+		if err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		return info
+	}
+
+	if info := fetch(good); info.Staple == nil || info.Staple.Err != nil || info.Staple.Status != RevocationGood || info.Staple.Source != "staple" {
+		t.Errorf("expected a verified good staple, got %+v", info.Staple)
+	}
+	if info := fetch(forged); info.Staple == nil || info.Staple.Err == nil || !info.Staple.Inconclusive() {
+		t.Errorf("expected a forged staple to be reported as unusable, got %+v", info.Staple)
+	}
+	if info := fetch(nil); info.Staple != nil {
+		t.Errorf("expected no staple, got %+v", info.Staple)
+	}
+}
+
+// TestMustStaple verifies the TLS Feature extension is recognized, and that a
+// fetched must-staple certificate without a staple is flagged as a warning.
+func TestMustStaple(t *testing.T) {
+	ca := newOCSPCA(t)
+	feature, err := asn1.Marshal([]int{5})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	ms, _ := ca.issue(t, &x509.Certificate{
+		SerialNumber:    big.NewInt(11),
+		Subject:         pkix.Name{CommonName: "ms.example"},
+		ExtraExtensions: []pkix.Extension{{Id: oidTLSFeature, Value: feature}},
+	})
+	plain, _ := ca.issue(t, &x509.Certificate{SerialNumber: big.NewInt(12), Subject: pkix.Name{CommonName: "plain.example"}})
+
+	if !mustStaple(ms) || mustStaple(plain) {
+		t.Fatalf("mustStaple: got %v for must-staple, %v for plain", mustStaple(ms), mustStaple(plain))
+	}
+	if !mustStapleMissing(&CertInfo{Cert: ms, TLSVersion: "TLS 1.3"}) || !HasWarnings(&CertInfo{Cert: ms, TLSVersion: "TLS 1.3"}) {
+		t.Error("a fetched must-staple cert without a staple should be flagged")
+	}
+	if mustStapleMissing(&CertInfo{Cert: ms, TLSVersion: "TLS 1.3", Staple: &Revocation{Source: "staple", Status: RevocationGood}}) {
+		t.Error("a stapled must-staple cert should not be flagged")
+	}
+	if mustStapleMissing(&CertInfo{Cert: ms, FromFile: true}) {
+		t.Error("a certificate loaded from a file should not be flagged")
+	}
+}
@@ -0,0 +1,309 @@
+package cert
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Proxy is the proxy connections are routed through (-proxy).
+type Proxy struct {
+	URL      string         // http://, https://, socks5:// or socks5h:// URL; empty = direct connection
+	Roots    *x509.CertPool // CA pool verifying an https:// proxy's certificate; nil = the system roots
+	Insecure bool           // Skip verification of an https:// proxy's certificate
+}
+
+// proxyPorts gives the supported proxy schemes and the port each defaults to.
+var proxyPorts = map[string]string{"http": "80", "https": "443", "socks5": "1080", "socks5h": "1080"}
+
+// SOCKS5 protocol constants (RFC 1928, RFC 1929).
+const (
+	socksVersion     = 0x05
+	socksAuthNone    = 0x00
+	socksAuthPass    = 0x02
+	socksAuthNoMatch = 0xff
+	socksCmdConnect  = 0x01
+	socksAtypIPv4    = 0x01
+	socksAtypDomain  = 0x03
+	socksAtypIPv6    = 0x04
+)
+
+// socksReplies names the SOCKS5 CONNECT failure codes.
+var socksReplies = map[byte]string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// envProxy picks the proxy for a request from the environment. It is a variable
+// so tests can stand in for the environment, which net/http reads only once.
+var envProxy = http.ProxyFromEnvironment
+
+// proxyFromEnv returns the proxy HTTPS_PROXY and NO_PROXY (or their lower-case
+// forms) select for a TLS connection to host:port, with the semantics of
+// net/http's ProxyFromEnvironment, or "" for a direct connection. When the
+// connection goes to ipaddr instead of a resolution of host, NO_PROXY is also
+// matched against that address, so CIDR entries cover -ipaddr and -all-ips.
+func proxyFromEnv(host, ipaddr, port string) (string, error) {
+	lookup := func(h string) (*url.URL, error) {
+		return envProxy(&http.Request{URL: &url.URL{Scheme: "https", Host: net.JoinHostPort(h, port)}})
+	}
+	u, err := lookup(host)
+	if err != nil || u == nil {
+		return "", err
+	}
+	if ipaddr != "" {
+		if viaIP, err := lookup(ipaddr); err != nil || viaIP == nil {
+			return "", err
+		}
+	}
+	return u.String(), nil
+}
+
+// redactProxy renders a proxy URL for output with its password masked, or
+// "direct" when there is none.
+func redactProxy(raw string) string {
+	if raw == "" {
+		return "direct"
+	}
+	if u, err := url.Parse(raw); err == nil {
+		return u.Redacted()
+	}
+	return raw
+}
+
+// dialViaProxy opens a tunnel to target through proxy: an HTTP CONNECT proxy
+// reached over TCP (http) or TLS (https), or a SOCKS5 proxy, which resolves
+// target's host itself with socks5h and receives an address with socks5. The
+// returned connection is ready for a STARTTLS negotiation or TLS handshake.
+func dialViaProxy(target string, timeout time.Duration, proxy Proxy) (net.Conn, error) {
+	u, err := url.Parse(proxy.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -proxy %q: %v", proxy.URL, err)
+	}
+	scheme := u.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	defaultPort, ok := proxyPorts[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported proxy scheme %q (expected http, https, socks5 or socks5h)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid -proxy %q: missing host", proxy.URL)
+	}
+	// Default to the scheme's port when the URL omits one.
+	proxyAddr := u.Host
+	if u.Port() == "" {
+		proxyAddr = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+
+	conn, err := net.DialTimeout("tcp", proxyAddr, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to proxy %s: %v", proxyAddr, err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	switch scheme {
+	case "https":
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname(), RootCAs: proxy.Roots, InsecureSkipVerify: proxy.Insecure})
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("TLS handshake with proxy %s failed: %v", proxyAddr, err)
+		}
+		conn = tlsConn
+		err = httpConnect(conn, target, u.User)
+	case "socks5", "socks5h":
+		err = socksConnect(conn, target, u.User, scheme == "socks5h", timeout)
+	default:
+		err = httpConnect(conn, target, u.User)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %v", proxyAddr, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// httpConnect issues a CONNECT request for target on conn and reads the reply;
+// optional userinfo becomes Basic auth.
+func httpConnect(conn net.Conn, target string, user *url.Userinfo) error {
+	req := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", target, target)
+	if user != nil {
+		pass, _ := user.Password()
+		token := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + pass))
+		req += "Proxy-Authorization: Basic " + token + "\r\n"
+	}
+	req += "\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		return fmt.Errorf("failed to send CONNECT: %v", err)
+	}
+
+	br := bufio.NewReader(conn)
+	status, err := readLine(br)
+	if err != nil {
+		return fmt.Errorf("failed to read CONNECT response: %v", err)
+	}
+	// Status line: "HTTP/1.1 200 Connection established".
+	fields := strings.SplitN(status, " ", 3)
+	if len(fields) < 2 || fields[1] != "200" {
+		return fmt.Errorf("refused CONNECT to %s: %s", target, status)
+	}
+	// Drain the remaining response headers up to the blank line.
+	for {
+		line, err := readLine(br)
+		if err != nil {
+			return fmt.Errorf("failed to read CONNECT headers: %v", err)
+		}
+		if line == "" {
+			return nil
+		}
+	}
+}
+
+// socksConnect runs the SOCKS5 handshake on conn — method selection, optional
+// username/password authentication, then CONNECT to target. With remoteDNS the
+// proxy resolves target's host; otherwise it is resolved here first.
+func socksConnect(conn net.Conn, target string, user *url.Userinfo, remoteDNS bool, timeout time.Duration) error {
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port in %q", target)
+	}
+
+	methods := []byte{socksAuthNone}
+	if user != nil {
+		methods = append(methods, socksAuthPass)
+	}
+	if _, err := conn.Write(append([]byte{socksVersion, byte(len(methods))}, methods...)); err != nil {
+		return fmt.Errorf("failed to send SOCKS greeting: %v", err)
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return fmt.Errorf("failed to read SOCKS greeting reply: %v", err)
+	}
+	if reply[0] != socksVersion {
+		return fmt.Errorf("not a SOCKS5 proxy (version %d)", reply[0])
+	}
+	switch reply[1] {
+	case socksAuthNone:
+	case socksAuthPass:
+		if user == nil {
+			return errors.New("SOCKS proxy requires a username and password")
+		}
+		if err := socksAuthenticate(conn, user); err != nil {
+			return err
+		}
+	case socksAuthNoMatch:
+		return errors.New("SOCKS proxy accepted none of the offered authentication methods")
+	default:
+		return fmt.Errorf("SOCKS proxy chose unsupported authentication method %d", reply[1])
+	}
+
+	req := []byte{socksVersion, socksCmdConnect, 0}
+	ip := net.ParseIP(host)
+	if ip == nil && !remoteDNS {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %v", host, err)
+		}
+		ip = addrs[0].IP
+	}
+	switch {
+	case ip == nil:
+		if len(host) > 255 {
+			return fmt.Errorf("host name %q too long for SOCKS", host)
+		}
+		req = append(req, socksAtypDomain, byte(len(host)))
+		req = append(req, host...)
+	case ip.To4() != nil:
+		req = append(req, socksAtypIPv4)
+		req = append(req, ip.To4()...)
+	default:
+		req = append(req, socksAtypIPv6)
+		req = append(req, ip.To16()...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("failed to send SOCKS CONNECT: %v", err)
+	}
+
+	// Reply: version, status, reserved, then the bound address and port.
+	var head [4]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return fmt.Errorf("failed to read SOCKS CONNECT reply: %v", err)
+	}
+	if head[1] != 0 {
+		reason := socksReplies[head[1]]
+		if reason == "" {
+			reason = fmt.Sprintf("error %d", head[1])
+		}
+		return fmt.Errorf("refused CONNECT to %s: %s", target, reason)
+	}
+	var skip int
+	switch head[3] {
+	case socksAtypIPv4:
+		skip = net.IPv4len
+	case socksAtypIPv6:
+		skip = net.IPv6len
+	case socksAtypDomain:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return fmt.Errorf("failed to read SOCKS CONNECT reply: %v", err)
+		}
+		skip = int(n[0])
+	default:
+		return fmt.Errorf("SOCKS CONNECT reply has unknown address type %d", head[3])
+	}
+	if _, err := io.CopyN(io.Discard, conn, int64(skip+2)); err != nil {
+		return fmt.Errorf("failed to read SOCKS CONNECT reply: %v", err)
+	}
+	return nil
+}
+
+// socksAuthenticate runs the RFC 1929 username/password sub-negotiation.
+func socksAuthenticate(conn net.Conn, user *url.Userinfo) error {
+	name := user.Username()
+	pass, _ := user.Password()
+	if len(name) > 255 || len(pass) > 255 {
+		return errors.New("SOCKS username or password longer than 255 bytes")
+	}
+	req := []byte{0x01, byte(len(name))}
+	req = append(req, name...)
+	req = append(req, byte(len(pass)))
+	req = append(req, pass...)
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("failed to send SOCKS credentials: %v", err)
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return fmt.Errorf("failed to read SOCKS authentication reply: %v", err)
+	}
+	if reply[1] != 0 {
+		return errors.New("SOCKS proxy rejected the username/password")
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package cert
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"strconv"
+)
+
+// ProxyHeader is the PROXY protocol header sent to the server ahead of the
+// STARTTLS negotiation or TLS handshake (-proxy-protocol), for backends behind
+// a load balancer that expect one.
+type ProxyHeader struct {
+	Version string // "v1" (text) or "v2" (binary); empty = no header
+	Source  string // Client address claimed, ip:port; empty = the connection's local address
+	Dest    string // Server address claimed, ip:port; empty = the connection's remote address, or the target's through a proxy
+}
+
+// proxyV2Signature starts every PROXY protocol v2 header.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// PROXY protocol v2 command, address families and TLV types (haproxy's
+// proxy-protocol.txt §2.2).
+const (
+	ppV2Proxy      = 0x21 // version 2, PROXY command
+	ppV2TCP4       = 0x11
+	ppV2TCP6       = 0x21
+	ppTLVAuthority = 0x02 // the host name the client asked for (SNI)
+	ppTLVCRC32C    = 0x03 // CRC-32C of the whole header
+)
+
+// writeProxyHeader sends h on conn. Empty addresses default to the connection's
+// own; authority, when set, travels as a v2 AUTHORITY TLV.
+func writeProxyHeader(conn net.Conn, h ProxyHeader, authority string) error {
+	src, err := proxyHeaderAddr(h.Source, conn.LocalAddr())
+	if err != nil {
+		return fmt.Errorf("invalid source address: %v", err)
+	}
+	dst, err := proxyHeaderAddr(h.Dest, conn.RemoteAddr())
+	if err != nil {
+		return fmt.Errorf("invalid destination address: %v", err)
+	}
+	if (src.IP.To4() == nil) != (dst.IP.To4() == nil) {
+		return fmt.Errorf("source %s and destination %s are not the same address family", src, dst)
+	}
+	var header []byte
+	switch h.Version {
+	case "v1":
+		header = proxyHeaderV1(src, dst)
+	case "v2":
+		header = proxyHeaderV2(src, dst, authority)
+	default:
+		return fmt.Errorf("unknown PROXY protocol version %q", h.Version)
+	}
+	_, err = conn.Write(header)
+	return err
+}
+
+// throughProxy returns h for a connection to address tunnelled through a
+// proxy, whose remote end is the proxy rather than the server: without an
+// explicit destination, the header names address itself, which must then be an
+// IP — the name is left for the proxy to resolve, so its address is not known
+// here.
+func (h ProxyHeader) throughProxy(address string) (ProxyHeader, error) {
+	if h.Dest != "" {
+		return h, nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) == nil {
+		return h, fmt.Errorf("the server address behind the proxy is not known for %s; set -proxy-protocol-dst, or connect to an address with -ipaddr/-all-ips", address)
+	}
+	h.Dest = address
+	return h, nil
+}
+
+// proxyHeaderAddr parses an ip:port override, or takes the connection's address.
+func proxyHeaderAddr(s string, fallback net.Addr) (*net.TCPAddr, error) {
+	if s == "" {
+		if a, ok := fallback.(*net.TCPAddr); ok {
+			return a, nil
+		}
+		return nil, fmt.Errorf("connection address %s is not TCP; set it explicitly", fallback)
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(host)
+	n, err := strconv.Atoi(port)
+	if ip == nil || err != nil || n < 0 || n > 65535 {
+		return nil, fmt.Errorf("%q is not ip:port", s)
+	}
+	return &net.TCPAddr{IP: ip, Port: n}, nil
+}
+
+// proxyHeaderV1 renders the text header: "PROXY TCP4 src dst sport dport\r\n".
+func proxyHeaderV1(src, dst *net.TCPAddr) []byte {
+	proto := "TCP6"
+	if src.IP.To4() != nil {
+		proto = "TCP4"
+	}
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", proto, src.IP, dst.IP, src.Port, dst.Port))
+}
+
+// proxyHeaderV2 renders the binary header with an AUTHORITY TLV (when authority
+// is set) and a CRC32C TLV, which a receiver that checks it uses to reject a
+// corrupted header.
+func proxyHeaderV2(src, dst *net.TCPAddr, authority string) []byte {
+	var body []byte
+	family := byte(ppV2TCP6)
+	if s4, d4 := src.IP.To4(), dst.IP.To4(); s4 != nil {
+		family = ppV2TCP4
+		body = append(append(body, s4...), d4...)
+	} else {
+		body = append(append(body, src.IP.To16()...), dst.IP.To16()...)
+	}
+	body = binary.BigEndian.AppendUint16(body, uint16(src.Port))
+	body = binary.BigEndian.AppendUint16(body, uint16(dst.Port))
+	if authority != "" {
+		body = append(body, ppTLVAuthority)
+		body = binary.BigEndian.AppendUint16(body, uint16(len(authority)))
+		body = append(body, authority...)
+	}
+	body = append(body, ppTLVCRC32C, 0, 4, 0, 0, 0, 0)
+
+	header := append(append([]byte{}, proxyV2Signature...), ppV2Proxy, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(body)))
+	header = append(header, body...)
+	// The checksum covers the whole header with its own value zeroed.
+	sum := crc32.Checksum(header, crc32.MakeTable(crc32.Castagnoli))
+	binary.BigEndian.PutUint32(header[len(header)-4:], sum)
+	return header
+}
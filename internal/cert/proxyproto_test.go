@@ -0,0 +1,161 @@
+package cert
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyHeaderV1(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 50000}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 443}
+	if got, want := string(proxyHeaderV1(src, dst)), "PROXY TCP4 203.0.113.7 10.0.0.5 50000 443\r\n"; got != want {
+		t.Errorf("v1 = %q, want %q", got, want)
+	}
+	src6 := &net.TCPAddr{IP: net.ParseIP("2001:db8::7"), Port: 50000}
+	dst6 := &net.TCPAddr{IP: net.ParseIP("2001:db8::5"), Port: 443}
+	if got, want := string(proxyHeaderV1(src6, dst6)), "PROXY TCP6 2001:db8::7 2001:db8::5 50000 443\r\n"; got != want {
+		t.Errorf("v1 = %q, want %q", got, want)
+	}
+}
+
+func TestProxyHeaderV2(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 50000}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 443}
+	h := proxyHeaderV2(src, dst, "www.example.com")
+
+	if !bytes.HasPrefix(h, proxyV2Signature) || h[12] != ppV2Proxy || h[13] != ppV2TCP4 {
+		t.Fatalf("bad v2 preamble % x", h[:16])
+	}
+	if n := int(binary.BigEndian.Uint16(h[14:])); n != len(h)-16 {
+		t.Errorf("length field %d, header body %d", n, len(h)-16)
+	}
+	body := h[16:]
+	if !net.IP(body[0:4]).Equal(src.IP) || !net.IP(body[4:8]).Equal(dst.IP) ||
+		binary.BigEndian.Uint16(body[8:]) != 50000 || binary.BigEndian.Uint16(body[10:]) != 443 {
+		t.Errorf("bad v2 addresses % x", body[:12])
+	}
+	tlvs := body[12:]
+	if tlvs[0] != ppTLVAuthority || string(tlvs[3:3+15]) != "www.example.com" {
+		t.Errorf("expected the AUTHORITY TLV first, got % x", tlvs)
+	}
+	crc := tlvs[len(tlvs)-7:]
+	if crc[0] != ppTLVCRC32C {
+		t.Fatalf("expected the CRC32C TLV last, got % x", crc)
+	}
+	want := binary.BigEndian.Uint32(crc[3:])
+	zeroed := append([]byte{}, h...)
+	copy(zeroed[len(zeroed)-4:], []byte{0, 0, 0, 0})
+	if got := crc32.Checksum(zeroed, crc32.MakeTable(crc32.Castagnoli)); got != want {
+		t.Errorf("CRC32C = %08x, header carries %08x", got, want)
+	}
+}
+
+// TestFetch_ProxyProtocol fetches from a TLS server that, like a backend behind
+// HAProxy, reads a PROXY header before the handshake.
+func TestFetch_ProxyProtocol(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	_, pair := keyedCert(t, key, time.Now().Add(24*time.Hour))
+
+	serve := func(version string) (string, <-chan []byte) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		t.Cleanup(func() { ln.Close() })
+		got := make(chan []byte, 1)
+		go func() {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+			br := bufio.NewReader(c)
+			var header []byte
+			if version == "v1" {
+				line, err := br.ReadBytes('\n')
+				if err != nil {
+					return
+				}
+				header = line
+			} else {
+				head := make([]byte, 16)
+				if _, err := io.ReadFull(br, head); err != nil {
+					return
+				}
+				rest := make([]byte, binary.BigEndian.Uint16(head[14:]))
+				if _, err := io.ReadFull(br, rest); err != nil {
+					return
+				}
+				header = append(head, rest...)
+			}
+			got <- header
+			srv := tls.Server(&bufferedConn{Conn: c, r: br}, &tls.Config{Certificates: []tls.Certificate{pair}})
+			_ = srv.Handshake()
+		}()
+		return ln.Addr().String(), got
+	}
+
+	for _, version := range []string{"v1", "v2"} {
+		t.Run(version, func(t *testing.T) {
+			addr, got := serve(version)
+			host, port, _ := net.SplitHostPort(addr)
+			header := ProxyHeader{Version: version, Source: "203.0.113.7:50000"}
+			info, err := (&CertificateFetcherImpl{}).Fetch(host, port, "", FetchOptions{Insecure: true, Timeout: 5 * time.Second, ProxyHeader: header})
+			if err != nil || info.Cert == nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+			h := <-got
+			switch version {
+			case "v1":
+				if want := "PROXY TCP4 203.0.113.7 127.0.0.1 50000 " + port + "\r\n"; string(h) != want {
+					t.Errorf("server read %q, want %q", h, want)
+				}
+			case "v2":
+				if !bytes.HasPrefix(h, proxyV2Signature) || !net.IP(h[16:20]).Equal(net.ParseIP("203.0.113.7")) {
+					t.Errorf("server read % x", h)
+				}
+			}
+		})
+	}
+
+	// Through a proxy the connection's remote end is the proxy: the header names
+	// the backend address connected to instead, and without one it cannot be sent.
+	t.Run("via proxy", func(t *testing.T) {
+		addr, got := serve("v1")
+		_, port, _ := net.SplitHostPort(addr)
+		proxyAddr, _ := socksProxy(t, "", "", addr)
+		opts := FetchOptions{Insecure: true, Timeout: 5 * time.Second, Proxy: Proxy{URL: "socks5://" + proxyAddr}, ProxyHeader: ProxyHeader{Version: "v1", Source: "203.0.113.7:50000"}}
+		if _, err := (&CertificateFetcherImpl{}).Fetch("localhost", port, "127.0.0.1", opts); err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		if h, want := <-got, "PROXY TCP4 203.0.113.7 127.0.0.1 50000 "+port+"\r\n"; string(h) != want {
+			t.Errorf("server read %q, want %q", h, want)
+		}
+		if _, err := (&CertificateFetcherImpl{}).Fetch("localhost", port, "", opts); err == nil || !strings.Contains(err.Error(), "-proxy-protocol-dst") {
+			t.Errorf("by name through a proxy: expected an error asking for -proxy-protocol-dst, got %v", err)
+		}
+	})
+}
+
+// bufferedConn is a net.Conn whose reads come through r, which may already hold
+// bytes read past the PROXY header.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) { return c.r.Read(p) }
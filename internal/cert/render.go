@@ -1,6 +1,8 @@
 package cert
 
 import (
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"math/big"
@@ -16,7 +18,7 @@ type CertificatePrinterImpl struct{}
 // Print outputs the details of the certificate according to opts: as JSON,
 // as a single days-remaining number (short), or as full human-readable text.
 func (p *CertificatePrinterImpl) Print(info *CertInfo, opts PrintOptions) {
-	days := DaysUntilExpiry(info.Cert)
+	days := DaysUntilExpiry(info.Cert, info.Now())
 
 	switch {
 	case opts.JSON:
@@ -64,16 +66,36 @@ func (p *CertificatePrinterImpl) printText(info *CertInfo, days int, opts PrintO
 	fmt.Printf("Valid from: %s\n", cert.NotBefore.Format(dateFormat))
 	fmt.Printf("Expires on: %s\n", cert.NotAfter.Format(dateFormat))
 
-	daysStr := colorizeDays(days, opts.Threshold, opts.Color)
+	daysStr := colorizeDays(cert, info.Now(), opts)
 	if days < 0 {
 		daysStr += maybeColor(" (expired)", colorRed, opts.Color)
 	}
+	if !info.At.IsZero() {
+		daysStr += fmt.Sprintf(" (as of %s)", info.At.UTC().Format(dateFormat))
+	}
 	fmt.Printf("Days remaining: %s\n", daysStr)
 
 	if !info.FromFile {
 		fmt.Printf("Used IP address: %s\n", info.UsedIP)
+		if info.Resolver != "" {
+			fmt.Printf("Resolver: %s\n", info.Resolver)
+		}
+		if info.Proxy != "" {
+			fmt.Printf("Proxy: %s\n", info.Proxy)
+		}
 		if info.TLSVersion != "" {
 			fmt.Printf("TLS: %s (%s)\n", info.TLSVersion, info.CipherSuite)
+			if info.Versions != nil {
+				fmt.Printf("TLS versions: %s\n", versionsText(info, opts.Color))
+			}
+			if info.Ciphers != nil {
+				printCiphersText(info.Ciphers, opts.Color)
+			}
+			if info.Staple != nil {
+				fmt.Printf("OCSP staple: %s\n", revocationText(info.Staple, opts.Color))
+			} else {
+				fmt.Println("OCSP staple: none")
+			}
 		}
 	}
 	if info.Verified {
@@ -85,21 +107,46 @@ func (p *CertificatePrinterImpl) printText(info *CertInfo, days int, opts PrintO
 			if trail := issuerTrail(info); trail != "" {
 				fmt.Printf("  %s\n", trail)
 			}
+			if info.AIAErr != nil {
+				fmt.Printf("  AIA fetch could not complete the chain — %v\n", info.AIAErr)
+			}
 			if !hasSCT(cert) {
 				fmt.Println(maybeColor("WARNING: no embedded SCTs — certificate is not in Certificate Transparency; not from a genuine public CA (possible private/re-signed cert)", colorRed, opts.Color))
 			}
 		}
 	}
-	if opts.Pin != "" {
-		if MatchesPin(cert, opts.Pin) {
+	if info.KeyTypes != nil {
+		printKeyTypesText(info, opts)
+	}
+	if r := info.Revocation; r != nil {
+		fmt.Printf("Revocation: %s\n", revocationText(r, opts.Color))
+	}
+	if r := info.CRL; r != nil {
+		fmt.Printf("CRL: %s\n", revocationText(r, opts.Color))
+	}
+	if len(opts.Pins) > 0 {
+		if MatchesAnyPin(cert, opts.Pins) {
 			fmt.Printf("Pin: %s\n", maybeColor("MATCH", colorGreen, opts.Color))
 		} else {
 			fmt.Printf("Pin: %s (got SHA-256 cert %s)\n", maybeColor("MISMATCH", colorRed, opts.Color), Fingerprint(cert))
 		}
 	}
 
+	if d := info.DANE; d != nil {
+		printDANEText(d, opts.Color)
+	}
+	if c := info.CAA; c != nil {
+		printCAAText(c, opts.Color)
+	}
+	if d := info.DNS; d != nil {
+		printDNSText(d, opts.Color)
+	}
+	if s := info.State; s != nil {
+		printStateText(s, opts.Color)
+	}
+
 	if early := earliestExpiringBefore(info.Chain); early != nil {
-		earlyDays := DaysUntilExpiry(early)
+		earlyDays := DaysUntilExpiry(early, info.Now())
 		msg := fmt.Sprintf("WARNING: intermediate %q expires in %d days, before the leaf (%d days)",
 			subjectName(early), earlyDays, days)
 		if opts.Color {
@@ -112,8 +159,8 @@ func (p *CertificatePrinterImpl) printText(info *CertInfo, days int, opts PrintO
 		fmt.Println(msg)
 	}
 
-	if notYetValid(cert) {
-		inDays := int(time.Until(cert.NotBefore).Hours() / 24)
+	if notYetValid(cert, info.Now()) {
+		inDays := int(cert.NotBefore.Sub(info.Now()).Hours() / 24)
 		msg := fmt.Sprintf("WARNING: certificate is not valid yet — becomes valid in %d days (%s)",
 			inDays, cert.NotBefore.Format(dateFormat))
 		fmt.Println(maybeColor(msg, colorRed, opts.Color))
@@ -130,11 +177,259 @@ func (p *CertificatePrinterImpl) printText(info *CertInfo, days int, opts PrintO
 		fmt.Println(maybeColor(msg, colorRed, opts.Color))
 	}
 
+	for _, r := range []*Revocation{info.Revocation, info.Staple} {
+		if r.Stale() {
+			msg := fmt.Sprintf("WARNING: %s response is stale (next update was due %s)",
+				r.sourceName(), r.NextUpdate.Format(dateFormat))
+			fmt.Println(maybeColor(msg, colorYellow, opts.Color))
+		}
+	}
+	if stapleExpiresSoon(info) {
+		msg := fmt.Sprintf("WARNING: stapled OCSP response reaches its next update soon (%s) — is the server refreshing it?",
+			info.Staple.NextUpdate.Format(dateFormat))
+		fmt.Println(maybeColor(msg, colorYellow, opts.Color))
+	}
+	if old := deprecatedVersions(info); len(old) > 0 {
+		msg := fmt.Sprintf("WARNING: deprecated protocol version(s) still enabled: %s", strings.Join(old, ", "))
+		fmt.Println(maybeColor(msg, colorYellow, opts.Color))
+	}
+	if weak := weakCiphers(info); len(weak) > 0 {
+		color := colorYellow
+		if weak[0].Severity == severityHigh {
+			color = colorRed
+		}
+		msg := fmt.Sprintf("WARNING: %d weak cipher suite(s) accepted, worst %s (%s)", len(weak), weak[0].Name, weak[0].Reason)
+		fmt.Println(maybeColor(msg, color, opts.Color))
+	}
+	if kind, reason := classifyCRLErr(info); kind != "" {
+		msg := fmt.Sprintf("WARNING: revocation cannot be confirmed from the CRL [%s] — %s", kind, reason)
+		fmt.Println(maybeColor(msg, colorYellow, opts.Color))
+	}
+	if mustStapleMissing(info) {
+		fmt.Println(maybeColor("WARNING: certificate requires OCSP stapling (must-staple) but the server sent no staple — clients enforcing it will refuse the connection", colorRed, opts.Color))
+	}
+
 	if opts.Chain {
 		printChainText(info)
 	}
 }
 
+// revocationText renders a revocation check result for the "Revocation:",
+// "OCSP staple:" and "CRL:" lines: the verdict (colorized when on), its source and the dates
+// that qualify it.
+func revocationText(r *Revocation, on bool) string {
+	source := r.sourceName()
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("%s — %v", maybeColor("CHECK FAILED", colorYellow, on), r.Err)
+	case r.Status == RevocationRevoked:
+		s := fmt.Sprintf("%s on %s", maybeColor("REVOKED", colorRed, on), r.RevokedAt.Format(dateFormat))
+		if r.Reason != "" {
+			s += fmt.Sprintf(" (%s)", r.Reason)
+		}
+		s += " via " + source
+		if r.Subject != "" {
+			s += fmt.Sprintf(" — intermediate %q", r.Subject)
+		}
+		return s
+	case r.Status == RevocationUnknown:
+		return fmt.Sprintf("%s — the OCSP responder does not know this certificate (%s)", maybeColor("UNKNOWN", colorYellow, on), source)
+	}
+	s := fmt.Sprintf("%s (%s, updated %s", maybeColor("GOOD", colorGreen, on), source, r.ThisUpdate.Format(dateFormat))
+	if !r.NextUpdate.IsZero() {
+		s += ", next update " + r.NextUpdate.Format(dateFormat)
+	}
+	return s + ")"
+}
+
+// versionsText renders the -scan-versions result as "TLS 1.0 no, …, TLS 1.3
+// yes", with deprecated versions that are still accepted highlighted.
+func versionsText(info *CertInfo, on bool) string {
+	parts := make([]string, len(info.Versions))
+	for i, v := range info.Versions {
+		switch {
+		case !v.Supported:
+			parts[i] = v.Version + " no"
+		case v.Version == "TLS 1.0" || v.Version == "TLS 1.1":
+			parts[i] = v.Version + " " + maybeColor("yes", colorYellow, on)
+		default:
+			parts[i] = v.Version + " " + maybeColor("yes", colorGreen, on)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// printCiphersText prints the -scan-ciphers grade and, per protocol version, the
+// accepted suites with any weakness.
+func printCiphersText(scan *CipherScan, on bool) {
+	color := colorRed
+	switch scan.Grade {
+	case "A":
+		color = colorGreen
+	case "B", "C":
+		color = colorYellow
+	}
+	fmt.Printf("Cipher grade: %s\n", maybeColor(scan.Grade, color, on))
+	for _, v := range scan.Versions {
+		if len(v.Suites) == 0 {
+			continue
+		}
+		order := "client order"
+		if v.ServerPreference {
+			order = "server order"
+		}
+		fmt.Printf("Ciphers (%s, %s):\n", v.Version, order)
+		for _, s := range v.Suites {
+			if s.Severity == "" {
+				fmt.Printf("  %s\n", s.Name)
+				continue
+			}
+			sev := s.Severity
+			switch s.Severity {
+			case severityHigh:
+				sev = maybeColor(sev, colorRed, on)
+			case severityMedium:
+				sev = maybeColor(sev, colorYellow, on)
+			}
+			fmt.Printf("  %s [%s: %s]\n", s.Name, sev, s.Reason)
+		}
+	}
+}
+
+// printDANEText prints the -dane verdict and each TLSA record with whether the
+// served chain matched it.
+func printDANEText(d *DANEResult, on bool) {
+	if d.Err != nil {
+		fmt.Printf("DANE: %s — %v\n", maybeColor("UNKNOWN", colorYellow, on), d.Err)
+		return
+	}
+	secure := "DNSSEC-validated"
+	if !d.Secure {
+		secure = maybeColor("not DNSSEC-validated", colorYellow, on)
+	}
+	if matched := len(d.Matched()); matched > 0 {
+		fmt.Printf("DANE: %s (%d of %d TLSA records at %s, %s)\n", maybeColor("MATCH", colorGreen, on), matched, len(d.Records), d.Name, secure)
+	} else {
+		fmt.Printf("DANE: %s (none of %d TLSA records at %s, %s)\n", maybeColor("MISMATCH", colorRed, on), len(d.Records), d.Name, secure)
+	}
+	for _, r := range d.Records {
+		verdict := "no match"
+		if r.Matched {
+			verdict = maybeColor("match", colorGreen, on)
+		}
+		fmt.Printf("  %s — %s\n", r, verdict)
+	}
+}
+
+// printCAAText prints the -caa verdict and the CAA record set that governs the
+// domain.
+func printCAAText(c *CAAResult, on bool) {
+	allowed := "no CA"
+	if ids := c.Allowed(); len(ids) > 0 {
+		allowed = strings.Join(ids, ", ")
+	}
+	switch {
+	case c.Err != nil:
+		fmt.Printf("CAA: %s — %v\n", maybeColor("UNKNOWN", colorYellow, on), c.Err)
+	case len(c.Records) == 0:
+		fmt.Printf("CAA: %s (no CAA records up to the TLD — any CA may issue)\n", maybeColor("AUTHORIZED", colorGreen, on))
+	case c.Authorized && !hasCAATag(c.Records, c.Property):
+		fmt.Printf("CAA: %s (no %s records at %s — any CA may issue)\n", maybeColor("AUTHORIZED", colorGreen, on), c.Property, c.Domain)
+	case c.Authorized:
+		fmt.Printf("CAA: %s (%s at %s allows %s)\n", maybeColor("AUTHORIZED", colorGreen, on), c.Property, c.Domain, allowed)
+	default:
+		fmt.Printf("CAA: %s (issuer is %s; %s at %s allows %s)\n", maybeColor("NOT AUTHORIZED", colorRed, on), strings.Join(c.IssuerIDs, ", "), c.Property, c.Domain, allowed)
+	}
+	for _, r := range c.Records {
+		fmt.Printf("  %s\n", r)
+	}
+}
+
+// printDNSText prints the domain's CNAME chain with TTLs, the addresses it ends
+// at and, with -expect-cname, whether it ends under the expected suffix.
+func printDNSText(d *DNSInfo, on bool) {
+	if d.Err != nil {
+		status := "UNKNOWN"
+		if d.Expected != "" {
+			status = maybeColor(status, colorYellow, on)
+		}
+		fmt.Printf("DNS: %s — %v\n", status, d.Err)
+		return
+	}
+	chain := d.Final + " (no CNAME)"
+	if len(d.CNAMEs) > 0 {
+		chain = d.CNAMEs[0].Name
+		for _, h := range d.CNAMEs {
+			chain += fmt.Sprintf(" → %s (CNAME, TTL %ds)", h.Target, h.TTL)
+		}
+	}
+	fmt.Printf("DNS: %s\n", chain)
+	if len(d.Addresses) > 0 {
+		addrs := make([]string, len(d.Addresses))
+		for i, a := range d.Addresses {
+			addrs[i] = fmt.Sprintf("%s (TTL %ds)", a.IP, a.TTL)
+		}
+		fmt.Printf("Addresses: %s\n", strings.Join(addrs, ", "))
+	}
+	if d.Expected == "" {
+		return
+	}
+	if d.Match {
+		fmt.Printf("CNAME: %s (ends at %s, expected suffix %s)\n", maybeColor("MATCH", colorGreen, on), d.Final, d.Expected)
+	} else {
+		fmt.Printf("CNAME: %s (ends at %s, expected suffix %s)\n", maybeColor("MISMATCH", colorRed, on), d.Final, d.Expected)
+	}
+}
+
+// printStateText prints how the certificate compares with the previous run's
+// -state record.
+func printStateText(s *StateChange, on bool) {
+	p := s.Previous
+	switch {
+	case p == nil:
+		fmt.Println("State: first seen")
+	case !s.Changed():
+		fmt.Printf("State: unchanged since %s\n", p.SeenAt.Format(dateFormat))
+	default:
+		kinds := make([]string, len(s.Changes))
+		for i, c := range s.Changes {
+			kinds[i] = strings.ReplaceAll(c, "_", " ")
+		}
+		fmt.Printf("State: %s — %s (was %s, issued by %s, expiring %s; last seen %s)\n",
+			maybeColor("CHANGED", colorYellow, on), strings.Join(kinds, ", "),
+			p.Fingerprint[:16], p.Issuer, p.NotAfter.Format(dateFormat), p.SeenAt.Format(dateFormat))
+	}
+}
+
+// printKeyTypesText prints one line per -key-types probe: the certificate's
+// fingerprint, days remaining, expiry and chain status, or why none was offered.
+func printKeyTypesText(info *CertInfo, opts PrintOptions) {
+	fmt.Println("Certificates by key type:")
+	for _, k := range info.KeyTypes {
+		if k.Err != nil {
+			fmt.Printf("  %-5s  not offered — %v\n", k.KeyType, k.Err)
+			continue
+		}
+		c := k.Info.Cert
+		chain := ""
+		if k.Info.Verified {
+			if k.Info.ChainErr == nil {
+				chain = "  " + maybeColor("VALID", colorGreen, opts.Color)
+			} else {
+				_, reason := classifyChainErr(k.Info)
+				chain = "  " + maybeColor("INVALID", colorRed, opts.Color) + " — " + reason
+			}
+		}
+		served := ""
+		if Fingerprint(c) == Fingerprint(info.Cert) {
+			served = " (served by default)"
+		}
+		fmt.Printf("  %-5s  %s  %s days  expires %s%s%s\n",
+			k.KeyType, Fingerprint(c)[:16], colorizeDays(c, k.Info.Now(), opts),
+			c.NotAfter.Format(dateFormat), chain, served)
+	}
+}
+
 // printChainText prints every certificate in the chain (leaf first), one per
 // line, with its subject, issuer and expiry.
 func printChainText(info *CertInfo) {
@@ -142,7 +437,7 @@ func printChainText(info *CertInfo) {
 	fmt.Printf("Certificate chain (%d):\n", len(chain))
 	for i, c := range chain {
 		fmt.Printf("  [%d] %s (issued by %s) - expires %s, %d days\n",
-			i, subjectName(c), issuerName(c), c.NotAfter.Format(dateFormat), DaysUntilExpiry(c))
+			i, subjectName(c), issuerName(c), c.NotAfter.Format(dateFormat), DaysUntilExpiry(c, info.Now()))
 	}
 }
 
@@ -165,43 +460,206 @@ type chainCert struct {
 // for multi-domain runs and omitted otherwise, so single-target output keeps its
 // original schema.
 type certPayload struct {
-	Domain        string       `json:"domain,omitempty"`
-	IP            string       `json:"ip,omitempty"`
-	Fingerprint   string       `json:"fingerprint,omitempty"`
-	SPKIFinger    string       `json:"spki_fingerprint,omitempty"`
-	PinMatch      *bool        `json:"pin_match,omitempty"`
-	CommonName    string       `json:"common_name"`
-	Subject       string       `json:"subject"`
-	Issuer        string       `json:"issuer"`
-	SANs          []string     `json:"sans,omitempty"`
-	Serial        string       `json:"serial"`
-	Signature     string       `json:"signature_algorithm"`
-	WeakSignature bool         `json:"weak_signature,omitempty"`
-	PublicKey     string       `json:"public_key"`
-	WeakKey       bool         `json:"weak_key,omitempty"`
-	NotBefore     string       `json:"not_before"`
-	NotAfter      string       `json:"not_after"`
-	NotYetValid   bool         `json:"not_yet_valid,omitempty"`
-	DaysRemaining int          `json:"days_remaining"`
-	UsedIP        string       `json:"used_ip,omitempty"`
-	TLSVersion    string       `json:"tls_version,omitempty"`
-	CipherSuite   string       `json:"cipher_suite,omitempty"`
-	NameMismatch  bool         `json:"name_mismatch,omitempty"`
-	NotServerAuth bool         `json:"not_server_auth,omitempty"`
-	ChainValid    *bool        `json:"chain_valid,omitempty"`
-	ChainError    string       `json:"chain_error,omitempty"`
-	ChainErrKind  string       `json:"chain_error_kind,omitempty"`
-	UntrustedIss  string       `json:"untrusted_issuer,omitempty"`
-	NoSCT         bool         `json:"no_sct,omitempty"`
-	ChainExpiry   *chainExpiry `json:"chain_expiry_warning,omitempty"`
-	Chain         []chainCert  `json:"chain,omitempty"`
+	Domain        string          `json:"domain,omitempty"`
+	IP            string          `json:"ip,omitempty"`
+	Resolvers     []string        `json:"resolvers,omitempty"`
+	Fingerprint   string          `json:"fingerprint,omitempty"`
+	SPKIFinger    string          `json:"spki_fingerprint,omitempty"`
+	PinMatch      *bool           `json:"pin_match,omitempty"`
+	CommonName    string          `json:"common_name"`
+	Subject       string          `json:"subject"`
+	Issuer        string          `json:"issuer"`
+	SANs          []string        `json:"sans,omitempty"`
+	Serial        string          `json:"serial"`
+	Signature     string          `json:"signature_algorithm"`
+	WeakSignature bool            `json:"weak_signature,omitempty"`
+	PublicKey     string          `json:"public_key"`
+	WeakKey       bool            `json:"weak_key,omitempty"`
+	NotBefore     string          `json:"not_before"`
+	NotAfter      string          `json:"not_after"`
+	NotYetValid   bool            `json:"not_yet_valid,omitempty"`
+	DaysRemaining int             `json:"days_remaining"`
+	SecondsLeft   int64           `json:"seconds_remaining"`
+	EvaluatedAt   string          `json:"evaluated_at,omitempty"`
+	UsedIP        string          `json:"used_ip,omitempty"`
+	Resolver      string          `json:"resolver,omitempty"`
+	Proxy         string          `json:"proxy,omitempty"`
+	TLSVersion    string          `json:"tls_version,omitempty"`
+	CipherSuite   string          `json:"cipher_suite,omitempty"`
+	TLSVersions   map[string]bool `json:"tls_versions,omitempty"`
+	Ciphers       *cipherScan     `json:"ciphers,omitempty"`
+	KeyTypes      []keyTypeCert   `json:"key_types,omitempty"`
+	DANE          *daneResult     `json:"dane,omitempty"`
+	CAA           *caaResult      `json:"caa,omitempty"`
+	DNS           *dnsInfo        `json:"dns,omitempty"`
+	State         *statePayload   `json:"state,omitempty"`
+	NameMismatch  bool            `json:"name_mismatch,omitempty"`
+	NotServerAuth bool            `json:"not_server_auth,omitempty"`
+	ChainValid    *bool           `json:"chain_valid,omitempty"`
+	ChainError    string          `json:"chain_error,omitempty"`
+	ChainErrKind  string          `json:"chain_error_kind,omitempty"`
+	UntrustedIss  string          `json:"untrusted_issuer,omitempty"`
+	AIAFetched    []string        `json:"aia_fetched,omitempty"`
+	AIAError      string          `json:"aia_error,omitempty"`
+	NoSCT         bool            `json:"no_sct,omitempty"`
+	ChainExpiry   *chainExpiry    `json:"chain_expiry_warning,omitempty"`
+	Revocation    *revocation     `json:"revocation,omitempty"`
+	OCSPStapled   *bool           `json:"ocsp_stapled,omitempty"`
+	Staple        *revocation     `json:"ocsp_staple,omitempty"`
+	MustStaple    bool            `json:"must_staple,omitempty"`
+	CRL           *revocation     `json:"crl,omitempty"`
+	Chain         []chainCert     `json:"chain,omitempty"`
+}
+
+// cipherScan is the JSON view of a -scan-ciphers result.
+type cipherScan struct {
+	Grade    string          `json:"grade"`
+	Versions []cipherVersion `json:"versions"`
+}
+
+// cipherVersion is the JSON view of the suites accepted with one version.
+type cipherVersion struct {
+	Version          string        `json:"version"`
+	ServerPreference bool          `json:"server_preference"`
+	Suites           []cipherSuite `json:"suites"`
+}
+
+// cipherSuite is the JSON view of one accepted suite.
+type cipherSuite struct {
+	Name     string `json:"name"`
+	Severity string `json:"severity,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// daneResult is the JSON view of a -dane check. Matched is omitted, and Error
+// set, when the TLSA records could not be obtained.
+type daneResult struct {
+	Name     string       `json:"name"`
+	Resolver string       `json:"resolver"`
+	Secure   bool         `json:"dnssec_validated"`
+	Matched  *bool        `json:"matched,omitempty"`
+	Records  []tlsaRecord `json:"records,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// tlsaRecord is the JSON view of one TLSA record.
+type tlsaRecord struct {
+	Usage        uint8  `json:"usage"`
+	Selector     uint8  `json:"selector"`
+	MatchingType uint8  `json:"matching_type"`
+	Data         string `json:"data"`
+	Matched      bool   `json:"matched"`
+}
+
+// caaResult is the JSON view of a -caa check. Authorized is omitted, and Error
+// set, when the check could not decide.
+type caaResult struct {
+	Domain     string      `json:"domain,omitempty"`
+	Property   string      `json:"property"`
+	IssuerIDs  []string    `json:"issuer_ids"`
+	Allowed    []string    `json:"allowed,omitempty"`
+	Authorized *bool       `json:"authorized,omitempty"`
+	Records    []caaRecord `json:"records"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// caaRecord is the JSON view of one CAA record.
+type caaRecord struct {
+	Flags uint8  `json:"flags"`
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+// dnsInfo is the JSON view of a -dns-info/-expect-cname lookup. CNAMEMatch is
+// set only with an expected suffix and a successful lookup.
+type dnsInfo struct {
+	Resolver      string     `json:"resolver"`
+	CNAMEs        []cnameHop `json:"cnames"`
+	FinalName     string     `json:"final_name,omitempty"`
+	Addresses     []dnsAddr  `json:"addresses"`
+	ExpectedCNAME string     `json:"expected_cname,omitempty"`
+	CNAMEMatch    *bool      `json:"cname_match,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// statePayload is the JSON view of the comparison with the -state record:
+// the change kinds (empty when nothing changed) and the record they are
+// relative to, absent the first time the target is seen.
+type statePayload struct {
+	FirstSeen bool       `json:"first_seen,omitempty"`
+	Changes   []string   `json:"changes"`
+	Previous  *CertState `json:"previous,omitempty"`
+}
+
+// cnameHop is the JSON view of one CNAME record.
+type cnameHop struct {
+	Name   string `json:"name"`
+	Target string `json:"target"`
+	TTL    uint32 `json:"ttl"`
+}
+
+// dnsAddr is the JSON view of one address record.
+type dnsAddr struct {
+	IP  string `json:"ip"`
+	TTL uint32 `json:"ttl"`
+}
+
+// keyTypeCert is the JSON view of one -key-types probe. Error is set, and the
+// certificate fields empty, when the server offered no certificate of the type.
+type keyTypeCert struct {
+	KeyType       string `json:"key_type"`
+	CommonName    string `json:"common_name,omitempty"`
+	Issuer        string `json:"issuer,omitempty"`
+	PublicKey     string `json:"public_key,omitempty"`
+	Fingerprint   string `json:"fingerprint,omitempty"`
+	NotAfter      string `json:"not_after,omitempty"`
+	DaysRemaining *int   `json:"days_remaining,omitempty"`
+	ChainValid    *bool  `json:"chain_valid,omitempty"`
+	ChainError    string `json:"chain_error,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// revocation is the JSON view of a revocation check. Status is empty and Error
+// set when the check could not be completed.
+type revocation struct {
+	Status     string `json:"status,omitempty"`
+	Source     string `json:"source"`
+	Responder  string `json:"responder,omitempty"`
+	Subject    string `json:"subject,omitempty"`
+	RevokedAt  string `json:"revoked_at,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	ThisUpdate string `json:"this_update,omitempty"`
+	NextUpdate string `json:"next_update,omitempty"`
+	Stale      bool   `json:"stale,omitempty"`
+	ExpireSoon bool   `json:"next_update_soon,omitempty"`
+	Error      string `json:"error,omitempty"`
+	ErrorKind  string `json:"error_kind,omitempty"`
+}
+
+// revocationPayload builds the JSON view of a revocation check.
+func revocationPayload(r *Revocation) *revocation {
+	out := &revocation{Status: r.Status, Source: r.Source, Responder: r.Responder, Subject: r.Subject, Reason: r.Reason, Stale: r.Stale()}
+	if r.Err != nil {
+		out.Error = r.Err.Error()
+		return out
+	}
+	stamp := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	out.RevokedAt = stamp(r.RevokedAt)
+	out.ThisUpdate = stamp(r.ThisUpdate)
+	out.NextUpdate = stamp(r.NextUpdate)
+	return out
 }
 
 // payloadOptions selects the optional fields included when building the JSON view.
 type payloadOptions struct {
-	IncludeChain       bool   // add the full "chain" array
-	IncludeFingerprint bool   // add the cert and public-key SHA-256 fingerprints
-	Pin                string // when non-empty, add the "pin_match" verdict
+	IncludeChain       bool     // add the full "chain" array
+	IncludeFingerprint bool     // add the cert and public-key SHA-256 fingerprints
+	Pins               []string // when non-empty, add the "pin_match" verdict
 }
 
 // buildPayload assembles the JSON view of a certificate, tagged with domain
@@ -221,9 +679,12 @@ func buildPayload(info *CertInfo, domain string, opts payloadOptions) certPayloa
 		WeakKey:       isWeakKey(cert),
 		NotBefore:     cert.NotBefore.UTC().Format(time.RFC3339),
 		NotAfter:      cert.NotAfter.UTC().Format(time.RFC3339),
-		NotYetValid:   notYetValid(cert),
-		DaysRemaining: DaysUntilExpiry(cert),
+		NotYetValid:   notYetValid(cert, info.Now()),
+		DaysRemaining: DaysUntilExpiry(cert, info.Now()),
+		SecondsLeft:   SecondsUntilExpiry(cert, info.Now()),
 		UsedIP:        info.UsedIP,
+		Resolver:      info.Resolver,
+		Proxy:         info.Proxy,
 		TLSVersion:    info.TLSVersion,
 		CipherSuite:   info.CipherSuite,
 		NameMismatch:  nameMismatch(info),
@@ -236,18 +697,118 @@ func buildPayload(info *CertInfo, domain string, opts payloadOptions) certPayloa
 			out.ChainError = info.ChainErr.Error()
 			out.ChainErrKind, _ = classifyChainErr(info)
 			out.UntrustedIss = untrustedIssuer(info)
+			for _, c := range info.AIAFetched {
+				out.AIAFetched = append(out.AIAFetched, subjectName(c))
+			}
+			if info.AIAErr != nil {
+				out.AIAError = info.AIAErr.Error()
+			}
 			out.NoSCT = !hasSCT(cert)
 		}
 	}
+	if !info.At.IsZero() {
+		out.EvaluatedAt = info.At.UTC().Format(time.RFC3339)
+	}
 	if early := earliestExpiringBefore(info.Chain); early != nil {
-		out.ChainExpiry = &chainExpiry{Subject: subjectName(early), DaysRemaining: DaysUntilExpiry(early)}
+		out.ChainExpiry = &chainExpiry{Subject: subjectName(early), DaysRemaining: DaysUntilExpiry(early, info.Now())}
+	}
+	if info.Versions != nil {
+		out.TLSVersions = make(map[string]bool, len(info.Versions))
+		for _, v := range info.Versions {
+			out.TLSVersions[v.Version] = v.Supported
+		}
+	}
+	if scan := info.Ciphers; scan != nil {
+		out.Ciphers = &cipherScan{Grade: scan.Grade, Versions: []cipherVersion{}}
+		for _, v := range scan.Versions {
+			cv := cipherVersion{Version: v.Version, ServerPreference: v.ServerPreference, Suites: []cipherSuite{}}
+			for _, s := range v.Suites {
+				cv.Suites = append(cv.Suites, cipherSuite{Name: s.Name, Severity: s.Severity, Reason: s.Reason})
+			}
+			out.Ciphers.Versions = append(out.Ciphers.Versions, cv)
+		}
+	}
+	for _, k := range info.KeyTypes {
+		kt := keyTypeCert{KeyType: k.KeyType}
+		if k.Err != nil {
+			kt.Error = k.Err.Error()
+		} else {
+			c := k.Info.Cert
+			days := DaysUntilExpiry(c, k.Info.Now())
+			kt.CommonName, kt.Issuer, kt.PublicKey = c.Subject.CommonName, c.Issuer.String(), formatPublicKey(c)
+			kt.Fingerprint, kt.NotAfter, kt.DaysRemaining = Fingerprint(c), c.NotAfter.UTC().Format(time.RFC3339), &days
+			if k.Info.Verified {
+				valid := k.Info.ChainErr == nil
+				kt.ChainValid = &valid
+				if !valid {
+					kt.ChainError = k.Info.ChainErr.Error()
+				}
+			}
+		}
+		out.KeyTypes = append(out.KeyTypes, kt)
+	}
+	if d := info.DANE; d != nil {
+		out.DANE = &daneResult{Name: d.Name, Resolver: d.Resolver, Secure: d.Secure}
+		if d.Err != nil {
+			out.DANE.Error = d.Err.Error()
+		} else {
+			matched := !d.Mismatch()
+			out.DANE.Matched = &matched
+		}
+		for _, r := range d.Records {
+			out.DANE.Records = append(out.DANE.Records, tlsaRecord{Usage: r.Usage, Selector: r.Selector, MatchingType: r.MatchingType, Data: hex.EncodeToString(r.Data), Matched: r.Matched})
+		}
+	}
+	if c := info.CAA; c != nil {
+		out.CAA = &caaResult{Domain: c.Domain, Property: c.Property, IssuerIDs: c.IssuerIDs, Allowed: c.Allowed(), Records: []caaRecord{}}
+		if c.Err != nil {
+			out.CAA.Error = c.Err.Error()
+		} else {
+			out.CAA.Authorized = &c.Authorized
+		}
+		for _, r := range c.Records {
+			out.CAA.Records = append(out.CAA.Records, caaRecord{Flags: r.Flags, Tag: r.Tag, Value: r.Value})
+		}
+	}
+	if d := info.DNS; d != nil {
+		out.DNS = &dnsInfo{Resolver: d.Resolver, CNAMEs: []cnameHop{}, FinalName: d.Final, Addresses: []dnsAddr{}, ExpectedCNAME: d.Expected}
+		if d.Err != nil {
+			out.DNS.Error = d.Err.Error()
+		} else if d.Expected != "" {
+			out.DNS.CNAMEMatch = &d.Match
+		}
+		for _, h := range d.CNAMEs {
+			out.DNS.CNAMEs = append(out.DNS.CNAMEs, cnameHop{Name: h.Name, Target: h.Target, TTL: h.TTL})
+		}
+		for _, a := range d.Addresses {
+			out.DNS.Addresses = append(out.DNS.Addresses, dnsAddr{IP: a.IP, TTL: a.TTL})
+		}
+	}
+	if s := info.State; s != nil {
+		out.State = &statePayload{FirstSeen: s.Previous == nil, Changes: append([]string{}, s.Changes...), Previous: s.Previous}
+	}
+	if info.Revocation != nil {
+		out.Revocation = revocationPayload(info.Revocation)
+	}
+	if info.TLSVersion != "" {
+		stapled := info.Staple != nil
+		out.OCSPStapled = &stapled
+		out.MustStaple = mustStaple(cert)
+	}
+	if info.Staple != nil {
+		out.Staple = revocationPayload(info.Staple)
+		out.Staple.ExpireSoon = stapleExpiresSoon(info)
+	}
+	if info.CRL != nil {
+		out.CRL = revocationPayload(info.CRL)
+		out.CRL.ErrorKind, _ = classifyCRLErr(info)
 	}
 	if opts.IncludeFingerprint {
 		out.Fingerprint = Fingerprint(cert)
 		out.SPKIFinger = SPKIFingerprint(cert)
 	}
-	if opts.Pin != "" {
-		m := MatchesPin(cert, opts.Pin)
+	if len(opts.Pins) > 0 {
+		m := MatchesAnyPin(cert, opts.Pins)
 		out.PinMatch = &m
 	}
 	if opts.IncludeChain {
@@ -256,7 +817,7 @@ func buildPayload(info *CertInfo, domain string, opts payloadOptions) certPayloa
 				Subject:       subjectName(c),
 				Issuer:        issuerName(c),
 				NotAfter:      c.NotAfter.UTC().Format(time.RFC3339),
-				DaysRemaining: DaysUntilExpiry(c),
+				DaysRemaining: DaysUntilExpiry(c, info.Now()),
 			})
 		}
 	}
@@ -282,7 +843,7 @@ func ErrorPayload(domain, errMsg string) any {
 
 // printJSON renders a single certificate as indented JSON.
 func (p *CertificatePrinterImpl) printJSON(info *CertInfo, opts PrintOptions) {
-	b, err := json.MarshalIndent(buildPayload(info, "", payloadOptions{IncludeChain: opts.Chain, IncludeFingerprint: opts.Fingerprint, Pin: opts.Pin}), "", "  ")
+	b, err := json.MarshalIndent(buildPayload(info, "", payloadOptions{IncludeChain: opts.Chain, IncludeFingerprint: opts.Fingerprint, Pins: opts.Pins}), "", "  ")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to encode JSON: %v\n", err)
 		return
@@ -306,17 +867,19 @@ const (
 // colorize wraps s in the given ANSI color and a reset.
 func colorize(s, color string) string { return color + s + colorReset }
 
-// colorizeDays renders a days-remaining value, colorized (when on) red if expired,
-// yellow if below the threshold, green otherwise.
-func colorizeDays(days, threshold int, on bool) string {
+// colorizeDays renders c's days remaining at now, colorized (when opts.Color is
+// set) red if expired or past the critical threshold, yellow if past the
+// warning threshold, green otherwise.
+func colorizeDays(c *x509.Certificate, now time.Time, opts PrintOptions) string {
+	days := DaysUntilExpiry(c, now)
 	s := fmt.Sprintf("%d", days)
-	if !on {
+	if !opts.Color {
 		return s
 	}
 	switch {
-	case days < 0:
+	case days < 0 || opts.CriticalThreshold.Reached(c, now):
 		return colorize(s, colorRed)
-	case threshold > 0 && days < threshold:
+	case opts.Threshold.Reached(c, now):
 		return colorize(s, colorYellow)
 	default:
 		return colorize(s, colorGreen)
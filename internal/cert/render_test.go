@@ -6,6 +6,7 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/json"
+	"errors"
 	"math/big"
 	"strings"
 	"testing"
@@ -15,13 +16,13 @@ import (
 // TestPrint_ExpiredMarker verifies an expired leaf gets a textual "(expired)"
 // marker that survives without color, in addition to the negative day count.
 func TestPrint_ExpiredMarker(t *testing.T) {
-	cert := genCert(t, "old.example", time.Now().Add(-3*24*time.Hour))
-	info := &CertInfo{Cert: cert}
+	cert := genCert(t, "old.example", testNow.Add(-3*24*time.Hour))
+	info := &CertInfo{Cert: cert, At: testNow}
 
 	out := captureStdout(t, func() { (&CertificatePrinterImpl{}).Print(info, PrintOptions{}) })
 
-	if !strings.Contains(out, "(expired)") {
-		t.Errorf("expected an (expired) marker, got:\n%s", out)
+	if !strings.Contains(out, "Days remaining: -3 (expired) (as of 2026-06-01") {
+		t.Errorf("expected -3 days with an (expired) marker, got:\n%s", out)
 	}
 }
 
@@ -106,6 +107,135 @@ func TestPrint_ExpectIssuer(t *testing.T) {
 	}
 }
 
+// TestPrint_Revocation verifies the "Revocation:" line for each check outcome, the
+// stale-answer warning, and the JSON "revocation" object.
+func TestPrint_Revocation(t *testing.T) {
+	c := genCert(t, "rev.example", time.Now().Add(90*24*time.Hour))
+	printer := &CertificatePrinterImpl{}
+	revokedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name string
+		rev  *Revocation
+		want []string
+	}{
+		{"good", &Revocation{Source: "ocsp", Status: RevocationGood, ThisUpdate: time.Now(), NextUpdate: time.Now().Add(time.Hour)}, []string{"Revocation: GOOD (OCSP, updated", "next update"}},
+		{"revoked", &Revocation{Source: "ocsp", Status: RevocationRevoked, RevokedAt: revokedAt, Reason: "keyCompromise"}, []string{"Revocation: REVOKED on 2026-03-01 12:00 UTC (keyCompromise) via OCSP"}},
+		{"unknown", &Revocation{Source: "ocsp", Status: RevocationUnknown}, []string{"Revocation: UNKNOWN"}},
+		{"failed", &Revocation{Source: "ocsp", Err: errors.New("OCSP responder returned HTTP 500")}, []string{"Revocation: CHECK FAILED — OCSP responder returned HTTP 500"}},
+		{"stale", &Revocation{Source: "ocsp", Status: RevocationGood, NextUpdate: time.Now().Add(-time.Hour)}, []string{"WARNING: OCSP response is stale"}},
+	}
+	for _, tc := range cases {
+		out := captureStdout(t, func() { printer.Print(&CertInfo{Cert: c, Revocation: tc.rev}, PrintOptions{}) })
+		for _, want := range tc.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: output missing %q:\n%s", tc.name, want, out)
+			}
+		}
+	}
+
+	out := captureStdout(t, func() { printer.Print(&CertInfo{Cert: c}, PrintOptions{}) })
+	if strings.Contains(out, "Revocation:") {
+		t.Errorf("no revocation line expected without a check, got:\n%s", out)
+	}
+
+	out = captureStdout(t, func() {
+		printer.Print(&CertInfo{Cert: c, Revocation: cases[1].rev}, PrintOptions{JSON: true})
+	})
+	var got struct {
+		Revocation struct {
+			Status    string `json:"status"`
+			Source    string `json:"source"`
+			RevokedAt string `json:"revoked_at"`
+			Reason    string `json:"reason"`
+		} `json:"revocation"`
+	}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, out)
+	}
+	if got.Revocation.Status != "revoked" || got.Revocation.Source != "ocsp" || got.Revocation.RevokedAt != "2026-03-01T12:00:00Z" || got.Revocation.Reason != "keyCompromise" {
+		t.Errorf("unexpected JSON revocation: %+v", got.Revocation)
+	}
+}
+
+// TestPrint_Staple verifies the "OCSP staple:" line for fetched certificates,
+// the warning for a staple close to its next update, and the JSON staple fields.
+func TestPrint_Staple(t *testing.T) {
+	c := genCert(t, "staple.example", time.Now().Add(90*24*time.Hour))
+	printer := &CertificatePrinterImpl{}
+	good := &Revocation{Source: "staple", Status: RevocationGood, ThisUpdate: time.Now(), NextUpdate: time.Now().Add(48 * time.Hour)}
+	soon := &Revocation{Source: "staple", Status: RevocationGood, ThisUpdate: time.Now(), NextUpdate: time.Now().Add(2 * time.Hour)}
+
+	out := captureStdout(t, func() { printer.Print(&CertInfo{Cert: c, TLSVersion: "TLS 1.3", Staple: good}, PrintOptions{}) })
+	if !strings.Contains(out, "OCSP staple: GOOD (stapled OCSP, updated") || strings.Contains(out, "WARNING") {
+		t.Errorf("expected a good staple line and no warning:\n%s", out)
+	}
+	out = captureStdout(t, func() { printer.Print(&CertInfo{Cert: c, TLSVersion: "TLS 1.3"}, PrintOptions{}) })
+	if !strings.Contains(out, "OCSP staple: none") {
+		t.Errorf("expected \"OCSP staple: none\":\n%s", out)
+	}
+	out = captureStdout(t, func() { printer.Print(&CertInfo{Cert: c, TLSVersion: "TLS 1.3", Staple: soon}, PrintOptions{}) })
+	if !strings.Contains(out, "WARNING: stapled OCSP response reaches its next update soon") {
+		t.Errorf("expected the next-update warning:\n%s", out)
+	}
+	out = captureStdout(t, func() { printer.Print(&CertInfo{Cert: c, FromFile: true}, PrintOptions{}) })
+	if strings.Contains(out, "OCSP staple:") {
+		t.Errorf("no staple line expected for a file:\n%s", out)
+	}
+
+	out = captureStdout(t, func() {
+		printer.Print(&CertInfo{Cert: c, TLSVersion: "TLS 1.3", Staple: soon}, PrintOptions{JSON: true})
+	})
+	var got struct {
+		Stapled *bool `json:"ocsp_stapled"`
+		Staple  struct {
+			Status     string `json:"status"`
+			Source     string `json:"source"`
+			ExpireSoon bool   `json:"next_update_soon"`
+		} `json:"ocsp_staple"`
+	}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, out)
+	}
+	if got.Stapled == nil || !*got.Stapled || got.Staple.Status != "good" || got.Staple.Source != "staple" || !got.Staple.ExpireSoon {
+		t.Errorf("unexpected JSON staple fields: %+v", got)
+	}
+}
+
+// TestPrint_CRL verifies the "CRL:" line, the warning naming the CRL error kind,
+// and the JSON "crl" object.
+func TestPrint_CRL(t *testing.T) {
+	c := genCert(t, "crl.example", time.Now().Add(90*24*time.Hour))
+	printer := &CertificatePrinterImpl{}
+	expired := &Revocation{Source: "crl", Responder: "http://crl.example/ca.crl", Err: &crlError{crlExpired, errors.New("CRL expired on 2026-03-01 12:00 UTC")}}
+	revoked := &Revocation{Source: "crl", Status: RevocationRevoked, RevokedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), Subject: "Issuing CA"}
+
+	out := captureStdout(t, func() { printer.Print(&CertInfo{Cert: c, CRL: expired}, PrintOptions{}) })
+	for _, want := range []string{"CRL: CHECK FAILED — CRL expired on", "WARNING: revocation cannot be confirmed from the CRL [crl_expired]"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	out = captureStdout(t, func() { printer.Print(&CertInfo{Cert: c, CRL: revoked}, PrintOptions{}) })
+	if !strings.Contains(out, `CRL: REVOKED on 2026-03-01 12:00 UTC via CRL — intermediate "Issuing CA"`) {
+		t.Errorf("expected the revoked intermediate to be named:\n%s", out)
+	}
+
+	out = captureStdout(t, func() { printer.Print(&CertInfo{Cert: c, CRL: expired}, PrintOptions{JSON: true}) })
+	var got struct {
+		CRL struct {
+			Source    string `json:"source"`
+			ErrorKind string `json:"error_kind"`
+		} `json:"crl"`
+	}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, out)
+	}
+	if got.CRL.Source != "crl" || got.CRL.ErrorKind != crlExpired {
+		t.Errorf("unexpected JSON crl: %+v", got.CRL)
+	}
+}
+
 // TestPrint_Fingerprint verifies the two fingerprint lines appear only with the flag.
 func TestPrint_Fingerprint(t *testing.T) {
 	c := genCert(t, "fp.example", time.Now().Add(90*24*time.Hour))
@@ -130,12 +260,12 @@ func TestPrint_Pin(t *testing.T) {
 	info := &CertInfo{Cert: c}
 	printer := &CertificatePrinterImpl{}
 
-	out := captureStdout(t, func() { printer.Print(info, PrintOptions{Pin: Fingerprint(c)}) })
+	out := captureStdout(t, func() { printer.Print(info, PrintOptions{Pins: []string{Fingerprint(c)}}) })
 	if !strings.Contains(out, "Pin: MATCH") {
 		t.Errorf("expected Pin: MATCH, got:\n%s", out)
 	}
 
-	out = captureStdout(t, func() { printer.Print(info, PrintOptions{Pin: strings.Repeat("0", 64)}) })
+	out = captureStdout(t, func() { printer.Print(info, PrintOptions{Pins: []string{strings.Repeat("0", 64)}}) })
 	if !strings.Contains(out, "Pin: MISMATCH") {
 		t.Errorf("expected Pin: MISMATCH, got:\n%s", out)
 	}
@@ -145,7 +275,7 @@ func TestPrint_Pin(t *testing.T) {
 
 	mustPinMatch := func(pin string, expect bool) {
 		t.Helper()
-		out := captureStdout(t, func() { printer.Print(info, PrintOptions{JSON: true, Pin: pin}) })
+		out := captureStdout(t, func() { printer.Print(info, PrintOptions{JSON: true, Pins: []string{pin}}) })
 		var got struct {
 			PinMatch *bool `json:"pin_match"`
 		}
@@ -211,7 +341,7 @@ func TestPrint_WeakMarkers(t *testing.T) {
 // TestPrint_CheapWarnings verifies all three warnings appear in text and JSON for
 // a problematic certificate.
 func TestPrint_CheapWarnings(t *testing.T) {
-	now := time.Now()
+	now := testNow
 	cert := &x509.Certificate{
 		Subject:      pkix.Name{CommonName: "shop.example.com"},
 		SerialNumber: big.NewInt(1),
@@ -220,7 +350,7 @@ func TestPrint_CheapWarnings(t *testing.T) {
 		NotAfter:     now.Add(90 * 24 * time.Hour),
 		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
 	}
-	info := &CertInfo{Cert: cert, CheckedName: "api.shop.example.com"}
+	info := &CertInfo{Cert: cert, CheckedName: "api.shop.example.com", At: now}
 	printer := &CertificatePrinterImpl{}
 
 	out := captureStdout(t, func() { printer.Print(info, PrintOptions{}) })
@@ -251,7 +381,7 @@ func TestPrint_CheapWarnings(t *testing.T) {
 // TestPrint_HealthyNoWarnings verifies a healthy certificate produces no warnings
 // and omits the problem flags from JSON.
 func TestPrint_HealthyNoWarnings(t *testing.T) {
-	now := time.Now()
+	now := testNow
 	cert := &x509.Certificate{
 		Subject:      pkix.Name{CommonName: "good.example"},
 		SerialNumber: big.NewInt(1),
@@ -260,7 +390,7 @@ func TestPrint_HealthyNoWarnings(t *testing.T) {
 		NotAfter:     now.Add(90 * 24 * time.Hour),
 		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 	}
-	info := &CertInfo{Cert: cert, CheckedName: "good.example"}
+	info := &CertInfo{Cert: cert, CheckedName: "good.example", At: now}
 	printer := &CertificatePrinterImpl{}
 
 	out := captureStdout(t, func() { printer.Print(info, PrintOptions{}) })
@@ -385,8 +515,8 @@ func TestCertificatePrinter_Print(t *testing.T) {
 
 // TestCertificatePrinter_Print_Short verifies short mode prints only the days remaining.
 func TestCertificatePrinter_Print_Short(t *testing.T) {
-	cert := &x509.Certificate{NotAfter: time.Now().Add(30 * 24 * time.Hour)}
-	info := &CertInfo{Cert: cert}
+	cert := &x509.Certificate{NotAfter: testNow.Add(30 * 24 * time.Hour)}
+	info := &CertInfo{Cert: cert, At: testNow}
 
 	printer := &CertificatePrinterImpl{}
 	out := captureStdout(t, func() { printer.Print(info, PrintOptions{Short: true}) })
@@ -394,8 +524,8 @@ func TestCertificatePrinter_Print_Short(t *testing.T) {
 	if strings.Contains(out, "Certificate for") {
 		t.Errorf("short output should not contain full details, got:\n%s", out)
 	}
-	if strings.TrimSpace(out) == "" {
-		t.Error("expected short output to contain days remaining")
+	if strings.TrimSpace(out) != "30" {
+		t.Errorf("expected short output to be the 30 days remaining, got %q", out)
 	}
 }
 
@@ -467,26 +597,26 @@ func TestCertificatePrinter_Print_ChainExpiryWarning(t *testing.T) {
 	leaf := &x509.Certificate{
 		Subject:      pkix.Name{CommonName: "leaf.example"},
 		SerialNumber: big.NewInt(1),
-		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		NotAfter:     testNow.Add(90 * 24 * time.Hour),
 	}
 	earlyInter := &x509.Certificate{
 		Subject:  pkix.Name{CommonName: "Early Intermediate CA"},
-		NotAfter: time.Now().Add(20 * 24 * time.Hour),
+		NotAfter: testNow.Add(20 * 24 * time.Hour),
 	}
 	lateInter := &x509.Certificate{
 		Subject:  pkix.Name{CommonName: "Late Intermediate CA"},
-		NotAfter: time.Now().Add(200 * 24 * time.Hour),
+		NotAfter: testNow.Add(200 * 24 * time.Hour),
 	}
 
 	printer := &CertificatePrinterImpl{}
 
-	warned := &CertInfo{Cert: leaf, Chain: []*x509.Certificate{leaf, earlyInter}}
+	warned := &CertInfo{Cert: leaf, Chain: []*x509.Certificate{leaf, earlyInter}, At: testNow}
 	out := captureStdout(t, func() { printer.Print(warned, PrintOptions{}) })
 	if !strings.Contains(out, "WARNING") || !strings.Contains(out, "Early Intermediate CA") {
 		t.Errorf("expected chain expiry warning naming the intermediate, got:\n%s", out)
 	}
 
-	ok := &CertInfo{Cert: leaf, Chain: []*x509.Certificate{leaf, lateInter}}
+	ok := &CertInfo{Cert: leaf, Chain: []*x509.Certificate{leaf, lateInter}, At: testNow}
 	out = captureStdout(t, func() { printer.Print(ok, PrintOptions{}) })
 	if strings.Contains(out, "WARNING") {
 		t.Errorf("did not expect a warning when intermediate outlives leaf, got:\n%s", out)
@@ -499,14 +629,14 @@ func TestCertificatePrinter_Print_JSON_ChainExpiry(t *testing.T) {
 	leaf := &x509.Certificate{
 		Subject:      pkix.Name{CommonName: "leaf.example"},
 		SerialNumber: big.NewInt(1),
-		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		NotAfter:     testNow.Add(90 * 24 * time.Hour),
 	}
 	inter := &x509.Certificate{
 		Subject:      pkix.Name{CommonName: "Early Intermediate CA"},
 		SerialNumber: big.NewInt(2),
-		NotAfter:     time.Now().Add(20*24*time.Hour + time.Hour),
+		NotAfter:     testNow.Add(20*24*time.Hour + time.Hour),
 	}
-	info := &CertInfo{Cert: leaf, Chain: []*x509.Certificate{leaf, inter}}
+	info := &CertInfo{Cert: leaf, Chain: []*x509.Certificate{leaf, inter}, At: testNow}
 
 	printer := &CertificatePrinterImpl{}
 	out := captureStdout(t, func() { printer.Print(info, PrintOptions{JSON: true}) })
@@ -537,18 +667,25 @@ func TestCertificatePrinter_Print_ColorThreshold(t *testing.T) {
 	cert := &x509.Certificate{
 		Subject:      pkix.Name{CommonName: "soon.example"},
 		SerialNumber: big.NewInt(1),
-		NotAfter:     time.Now().Add(5 * 24 * time.Hour),
+		NotAfter:     testNow.Add(5 * 24 * time.Hour),
 	}
-	info := &CertInfo{Cert: cert}
+	info := &CertInfo{Cert: cert, At: testNow}
 
 	printer := &CertificatePrinterImpl{}
 	out := captureStdout(t, func() {
-		printer.Print(info, PrintOptions{Threshold: 30, Color: true})
+		printer.Print(info, PrintOptions{Threshold: ThresholdDays(30), Color: true})
 	})
 
 	if !strings.Contains(out, colorYellow) {
 		t.Errorf("expected yellow highlight for days below threshold, got:\n%q", out)
 	}
+
+	out = captureStdout(t, func() {
+		printer.Print(info, PrintOptions{Threshold: ThresholdDays(30), CriticalThreshold: ThresholdDays(7), Color: true})
+	})
+	if !strings.Contains(out, colorize("5", colorRed)) {
+		t.Errorf("expected red highlight for days below the critical threshold, got:\n%q", out)
+	}
 }
 
 // TestPayloadAndErrorPayload covers the exported JSON-assembly helpers used for
@@ -9,12 +9,35 @@ import (
 	"time"
 )
 
-// PromSample is the result for one domain in a Prometheus run: Info is nil when
-// the certificate could not be retrieved (Err is set).
+// PromSample is the result for one domain in a Prometheus run, or for one address
+// of a domain in an -all-ips run (IP set): Info is nil when the certificate could
+// not be retrieved (Err is set). Opts carries the target's own expectations (pins,
+// issuer, threshold) when they differ from the run-wide options, as for a -config
+// target.
 type PromSample struct {
 	Domain string
+	IP     string // the address checked under -all-ips; empty otherwise
 	Info   *CertInfo
 	Err    error
+	Opts   *PrintOptions // nil = the run-wide options apply
+}
+
+// name identifies the sample in human-readable output: the domain, followed by
+// the address under -all-ips.
+func (s PromSample) name() string {
+	if s.IP != "" {
+		return s.Domain + " [" + s.IP + "]"
+	}
+	return s.Domain
+}
+
+// options returns the print options that apply to this sample: its own, or the
+// run-wide ones.
+func (s PromSample) options(run PrintOptions) PrintOptions {
+	if s.Opts != nil {
+		return *s.Opts
+	}
+	return run
 }
 
 // promEscape escapes a Prometheus label value (backslash, quote, newline).
@@ -25,12 +48,58 @@ func promEscape(s string) string {
 	return s
 }
 
+// promLabels renders the label set of a sample's series: domain, ip under
+// -all-ips, then the extra name/value pairs.
+func promLabels(s PromSample, extra ...string) string {
+	labels := []string{fmt.Sprintf(`domain="%s"`, promEscape(s.Domain))}
+	if s.IP != "" {
+		labels = append(labels, fmt.Sprintf(`ip="%s"`, promEscape(s.IP)))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		labels = append(labels, fmt.Sprintf(`%s="%s"`, extra[i], promEscape(extra[i+1])))
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+// addressesMatch reports, for each domain checked per address (-all-ips),
+// whether every address that answered served the same certificate.
+func addressesMatch(samples []PromSample) map[string]bool {
+	fps := make(map[string]map[string]bool)
+	for _, s := range samples {
+		if s.IP == "" {
+			continue
+		}
+		if fps[s.Domain] == nil {
+			fps[s.Domain] = make(map[string]bool)
+		}
+		if s.Info != nil {
+			fps[s.Domain][Fingerprint(s.Info.Cert)] = true
+		}
+	}
+	match := make(map[string]bool, len(fps))
+	for domain, set := range fps {
+		match[domain] = len(set) <= 1
+	}
+	return match
+}
+
 // WritePrometheus renders the samples in Prometheus text exposition format,
-// grouped by metric family. The pin_match family is emitted only when a pin is
-// configured. A domain that failed to be retrieved gets ssl_cert_up 0 and no
-// other samples.
-func WritePrometheus(w io.Writer, samples []PromSample, pin string) {
-	label := func(d string) string { return fmt.Sprintf(`{domain="%s"}`, promEscape(d)) }
+// grouped by metric family. The pin_match family is emitted only when pins are
+// configured (run-wide or for a sample), and only for the samples that have them;
+// ssl_cert_revoked only when revocation was checked, and only for the samples
+// with a conclusive good/revoked answer; ssl_tls_version_supported only for the
+// samples whose protocol versions were scanned, ssl_cert_key_type_expiry_days
+// only for the key types a -key-types probe found a certificate for,
+// ssl_dane_match only for the samples whose TLSA records were found,
+// ssl_caa_authorized only for the samples whose CAA check reached a verdict,
+// and ssl_cname_match only for the samples with an -expect-cname verdict.
+// A domain that failed to be retrieved gets ssl_cert_up 0 and no other samples.
+// ssl_cert_expiry_threshold_days carries each sample's -threshold (level
+// "warning") and -critical-threshold (level "critical") when set. Expectations
+// come from the sample's own options, else from run.
+// Under -all-ips every series also carries an ip label, and
+// ssl_cert_addresses_match tells per domain whether its addresses agree.
+func WritePrometheus(w io.Writer, samples []PromSample, run PrintOptions) {
 
 	fmt.Fprintln(w, "# HELP ssl_cert_up Whether the certificate was retrieved (1) or not (0).")
 	fmt.Fprintln(w, "# TYPE ssl_cert_up gauge")
@@ -39,14 +108,22 @@ func WritePrometheus(w io.Writer, samples []PromSample, pin string) {
 		if s.Info != nil {
 			up = 1
 		}
-		fmt.Fprintf(w, "ssl_cert_up%s %d\n", label(s.Domain), up)
+		fmt.Fprintf(w, "ssl_cert_up%s %d\n", promLabels(s), up)
 	}
 
 	fmt.Fprintln(w, "# HELP ssl_cert_expiry_days Days until the leaf certificate expires.")
 	fmt.Fprintln(w, "# TYPE ssl_cert_expiry_days gauge")
 	for _, s := range samples {
 		if s.Info != nil {
-			fmt.Fprintf(w, "ssl_cert_expiry_days%s %d\n", label(s.Domain), DaysUntilExpiry(s.Info.Cert))
+			fmt.Fprintf(w, "ssl_cert_expiry_days%s %d\n", promLabels(s), DaysUntilExpiry(s.Info.Cert, s.Info.Now()))
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP ssl_cert_expiry_seconds Seconds until the leaf certificate expires.")
+	fmt.Fprintln(w, "# TYPE ssl_cert_expiry_seconds gauge")
+	for _, s := range samples {
+		if s.Info != nil {
+			fmt.Fprintf(w, "ssl_cert_expiry_seconds%s %d\n", promLabels(s), SecondsUntilExpiry(s.Info.Cert, s.Info.Now()))
 		}
 	}
 
@@ -54,7 +131,7 @@ func WritePrometheus(w io.Writer, samples []PromSample, pin string) {
 	fmt.Fprintln(w, "# TYPE ssl_cert_min_expiry_days gauge")
 	for _, s := range samples {
 		if s.Info != nil {
-			fmt.Fprintf(w, "ssl_cert_min_expiry_days%s %d\n", label(s.Domain), s.Info.MinDaysUntilExpiry())
+			fmt.Fprintf(w, "ssl_cert_min_expiry_days%s %d\n", promLabels(s), s.Info.MinDaysUntilExpiry())
 		}
 	}
 
@@ -62,7 +139,7 @@ func WritePrometheus(w io.Writer, samples []PromSample, pin string) {
 	fmt.Fprintln(w, "# TYPE ssl_cert_not_after_timestamp gauge")
 	for _, s := range samples {
 		if s.Info != nil {
-			fmt.Fprintf(w, "ssl_cert_not_after_timestamp%s %d\n", label(s.Domain), s.Info.Cert.NotAfter.Unix())
+			fmt.Fprintf(w, "ssl_cert_not_after_timestamp%s %d\n", promLabels(s), s.Info.Cert.NotAfter.Unix())
 		}
 	}
 
@@ -74,41 +151,271 @@ func WritePrometheus(w io.Writer, samples []PromSample, pin string) {
 			if s.Info.ChainErr == nil {
 				v = 1
 			}
-			fmt.Fprintf(w, "ssl_cert_chain_valid%s %d\n", label(s.Domain), v)
+			fmt.Fprintf(w, "ssl_cert_chain_valid%s %d\n", promLabels(s), v)
+		}
+	}
+
+	fetched, checked := false, false
+	for _, s := range samples {
+		if s.Info != nil && s.Info.TLSVersion != "" {
+			fetched = true
+		}
+		if s.Info != nil && len(s.Info.revocations()) > 0 {
+			checked = true
+		}
+	}
+	if fetched {
+		fmt.Fprintln(w, "# HELP ssl_ocsp_stapled Whether the server stapled an OCSP response to the handshake.")
+		fmt.Fprintln(w, "# TYPE ssl_ocsp_stapled gauge")
+		for _, s := range samples {
+			if s.Info != nil && s.Info.TLSVersion != "" {
+				v := 0
+				if s.Info.Staple != nil {
+					v = 1
+				}
+				fmt.Fprintf(w, "ssl_ocsp_stapled%s %d\n", promLabels(s), v)
+			}
+		}
+	}
+	if checked {
+		fmt.Fprintln(w, "# HELP ssl_cert_revoked Whether the certificate chain is revoked (1) or not (0), per its OCSP, staple or CRL check.")
+		fmt.Fprintln(w, "# TYPE ssl_cert_revoked gauge")
+		for _, s := range samples {
+			if s.Info == nil {
+				continue
+			}
+			if status := revocationStatus(s.Info); status == RevocationGood || status == RevocationRevoked {
+				v := 0
+				if status == RevocationRevoked {
+					v = 1
+				}
+				fmt.Fprintf(w, "ssl_cert_revoked%s %d\n", promLabels(s), v)
+			}
+		}
+	}
+
+	scanned := false
+	for _, s := range samples {
+		if s.Info != nil && s.Info.Versions != nil {
+			scanned = true
+		}
+	}
+	if scanned {
+		fmt.Fprintln(w, "# HELP ssl_tls_version_supported Whether the server accepts a handshake with this protocol version.")
+		fmt.Fprintln(w, "# TYPE ssl_tls_version_supported gauge")
+		for _, s := range samples {
+			if s.Info == nil {
+				continue
+			}
+			for _, v := range s.Info.Versions {
+				up := 0
+				if v.Supported {
+					up = 1
+				}
+				fmt.Fprintf(w, "ssl_tls_version_supported%s %d\n", promLabels(s, "version", v.Version), up)
+			}
 		}
 	}
 
-	if pin != "" {
+	probed := false
+	for _, s := range samples {
+		if s.Info != nil && s.Info.KeyTypes != nil {
+			probed = true
+		}
+	}
+	if probed {
+		fmt.Fprintln(w, "# HELP ssl_cert_key_type_expiry_days Days until the certificate presented for this key type expires.")
+		fmt.Fprintln(w, "# TYPE ssl_cert_key_type_expiry_days gauge")
+		for _, s := range samples {
+			if s.Info == nil {
+				continue
+			}
+			for _, k := range s.Info.KeyTypes {
+				if k.Info != nil {
+					fmt.Fprintf(w, "ssl_cert_key_type_expiry_days%s %d\n", promLabels(s, "key_type", k.KeyType), DaysUntilExpiry(k.Info.Cert, k.Info.Now()))
+				}
+			}
+		}
+	}
+
+	daneChecked := false
+	for _, s := range samples {
+		if s.Info != nil && s.Info.DANE != nil && s.Info.DANE.Err == nil {
+			daneChecked = true
+		}
+	}
+	if daneChecked {
+		fmt.Fprintln(w, "# HELP ssl_dane_match Whether the served chain matches one of the service's TLSA records.")
+		fmt.Fprintln(w, "# TYPE ssl_dane_match gauge")
+		for _, s := range samples {
+			if s.Info == nil || s.Info.DANE == nil || s.Info.DANE.Err != nil {
+				continue
+			}
+			v := 1
+			if s.Info.DANE.Mismatch() {
+				v = 0
+			}
+			fmt.Fprintf(w, "ssl_dane_match%s %d\n", promLabels(s), v)
+		}
+	}
+
+	caaChecked := false
+	for _, s := range samples {
+		if s.Info != nil && s.Info.CAA != nil && s.Info.CAA.Err == nil {
+			caaChecked = true
+		}
+	}
+	if caaChecked {
+		fmt.Fprintln(w, "# HELP ssl_caa_authorized Whether the domain's CAA records authorize the certificate's issuer.")
+		fmt.Fprintln(w, "# TYPE ssl_caa_authorized gauge")
+		for _, s := range samples {
+			if s.Info == nil || s.Info.CAA == nil || s.Info.CAA.Err != nil {
+				continue
+			}
+			v := 0
+			if s.Info.CAA.Authorized {
+				v = 1
+			}
+			fmt.Fprintf(w, "ssl_caa_authorized%s %d\n", promLabels(s), v)
+		}
+	}
+
+	cnameChecked := false
+	for _, s := range samples {
+		if s.Info != nil && s.Info.DNS != nil && s.Info.DNS.Expected != "" && s.Info.DNS.Err == nil {
+			cnameChecked = true
+		}
+	}
+	if cnameChecked {
+		fmt.Fprintln(w, "# HELP ssl_cname_match Whether the domain's CNAME chain ends under the expected suffix.")
+		fmt.Fprintln(w, "# TYPE ssl_cname_match gauge")
+		for _, s := range samples {
+			if s.Info == nil || s.Info.DNS == nil || s.Info.DNS.Expected == "" || s.Info.DNS.Err != nil {
+				continue
+			}
+			v := 0
+			if s.Info.DNS.Match {
+				v = 1
+			}
+			fmt.Fprintf(w, "ssl_cname_match%s %d\n", promLabels(s), v)
+		}
+	}
+
+	if match := addressesMatch(samples); len(match) > 0 {
+		fmt.Fprintln(w, "# HELP ssl_cert_addresses_match Whether every address of the domain serves the same certificate.")
+		fmt.Fprintln(w, "# TYPE ssl_cert_addresses_match gauge")
+		done := make(map[string]bool)
+		for _, s := range samples {
+			if _, ok := match[s.Domain]; !ok || done[s.Domain] {
+				continue
+			}
+			done[s.Domain] = true
+			v := 0
+			if match[s.Domain] {
+				v = 1
+			}
+			fmt.Fprintf(w, "ssl_cert_addresses_match%s %d\n", promLabels(PromSample{Domain: s.Domain}), v)
+		}
+	}
+
+	limited := false
+	for _, s := range samples {
+		if o := s.options(run); s.Info != nil && (!o.Threshold.IsZero() || !o.CriticalThreshold.IsZero()) {
+			limited = true
+		}
+	}
+	if limited {
+		fmt.Fprintln(w, "# HELP ssl_cert_expiry_threshold_days The configured threshold for each alert level, in days (a percentage applied to the leaf's lifetime).")
+		fmt.Fprintln(w, "# TYPE ssl_cert_expiry_threshold_days gauge")
+		for _, s := range samples {
+			if s.Info == nil {
+				continue
+			}
+			o := s.options(run)
+			if !o.Threshold.IsZero() {
+				fmt.Fprintf(w, "ssl_cert_expiry_threshold_days%s %s\n", promLabels(s, "level", "warning"), thresholdDays(o.Threshold, s.Info.Cert))
+			}
+			if !o.CriticalThreshold.IsZero() {
+				fmt.Fprintf(w, "ssl_cert_expiry_threshold_days%s %s\n", promLabels(s, "level", "critical"), thresholdDays(o.CriticalThreshold, s.Info.Cert))
+			}
+		}
+	}
+
+	pinned := false
+	for _, s := range samples {
+		if len(s.options(run).Pins) > 0 {
+			pinned = true
+		}
+	}
+	if pinned {
 		fmt.Fprintln(w, "# HELP ssl_cert_pin_match Whether the served certificate matches the pinned fingerprint.")
 		fmt.Fprintln(w, "# TYPE ssl_cert_pin_match gauge")
 		for _, s := range samples {
-			if s.Info != nil {
+			samplePins := s.options(run).Pins
+			if s.Info != nil && len(samplePins) > 0 {
 				v := 0
-				if MatchesPin(s.Info.Cert, pin) {
+				if MatchesAnyPin(s.Info.Cert, samplePins) {
 					v = 1
 				}
-				fmt.Fprintf(w, "ssl_cert_pin_match%s %d\n", label(s.Domain), v)
+				fmt.Fprintf(w, "ssl_cert_pin_match%s %d\n", promLabels(s), v)
 			}
 		}
 	}
 }
 
+// revocationStatus is the overall revocation verdict for info: revoked when any
+// answer says so, otherwise the first conclusive status of the queried OCSP
+// answer, the staple and the CRL check. Empty when none was obtained or all
+// failed.
+func revocationStatus(info *CertInfo) string {
+	if info.RevokedBy() != nil {
+		return RevocationRevoked
+	}
+	for _, r := range info.revocations() {
+		if r.Err == nil {
+			return r.Status
+		}
+	}
+	return ""
+}
+
+// WriteProbe renders the result of one on-demand probe (a /probe request): the
+// WritePrometheus families for the single sample, followed by how long the probe
+// took as ssl_probe_duration_seconds.
+func WriteProbe(w io.Writer, s PromSample, run PrintOptions, took time.Duration) {
+	WritePrometheus(w, []PromSample{s}, run)
+	fmt.Fprintln(w, "# HELP ssl_probe_duration_seconds How long the probe took to complete in seconds.")
+	fmt.Fprintln(w, "# TYPE ssl_probe_duration_seconds gauge")
+	fmt.Fprintf(w, "ssl_probe_duration_seconds{domain=\"%s\"} %g\n", promEscape(s.Domain), took.Seconds())
+}
+
 // csvHeader is the column order for CSV output. "domain" and "error" are always
 // present; for a domain that failed to be retrieved the certificate columns are
-// empty and "error" carries the reason.
+// empty and "error" carries the reason. "revocation" is the revocation status
+// (good/revoked/unknown), empty when not checked or the check failed;
+// "tls_versions" lists the accepted protocol versions ("TLS 1.2;TLS 1.3"),
+// empty without -scan-versions. "warning_threshold" and "critical_threshold"
+// are the -threshold and -critical-threshold that applied, empty when unset.
+// Under -all-ips an "ip" column follows "domain".
 var csvHeader = []string{
 	"domain", "common_name", "issuer",
 	"not_before", "not_after", "days_remaining", "min_days_remaining",
-	"chain_valid", "error",
+	"warning_threshold", "critical_threshold", "chain_valid", "revocation", "tls_versions", "error",
 }
 
 // WriteCSV renders the samples as RFC 4180 CSV with a header row, one row per
 // domain (machine-readable timestamps in RFC 3339, UTC). Quoting is handled by
 // encoding/csv, so issuer DNs and other fields containing commas are safe. It
-// shares the per-domain PromSample type with the prometheus output.
-func WriteCSV(w io.Writer, samples []PromSample) error {
+// shares the per-domain PromSample type with the prometheus output, and takes
+// each sample's thresholds from its own options, else from run.
+func WriteCSV(w io.Writer, samples []PromSample, run PrintOptions) error {
+	byIP := len(addressesMatch(samples)) > 0
+	header := csvHeader
+	if byIP {
+		header = append([]string{csvHeader[0], "ip"}, csvHeader[1:]...)
+	}
 	cw := csv.NewWriter(w)
-	if err := cw.Write(csvHeader); err != nil {
+	if err := cw.Write(header); err != nil {
 		return err
 	}
 	for _, s := range samples {
@@ -118,25 +425,35 @@ func WriteCSV(w io.Writer, samples []PromSample) error {
 			if s.Err != nil {
 				errMsg = s.Err.Error()
 			}
-			row = []string{s.Domain, "", "", "", "", "", "", "", errMsg}
+			row = make([]string, len(csvHeader))
+			row[0], row[len(row)-1] = s.Domain, errMsg
 		} else {
 			c := s.Info.Cert
 			chainValid := ""
 			if s.Info.Verified {
 				chainValid = strconv.FormatBool(s.Info.ChainErr == nil)
 			}
+			revoked := revocationStatus(s.Info)
+			o := s.options(run)
 			row = []string{
 				s.Domain,
 				c.Subject.CommonName,
 				c.Issuer.String(),
 				c.NotBefore.UTC().Format(time.RFC3339),
 				c.NotAfter.UTC().Format(time.RFC3339),
-				strconv.Itoa(DaysUntilExpiry(c)),
+				strconv.Itoa(DaysUntilExpiry(c, s.Info.Now())),
 				strconv.Itoa(s.Info.MinDaysUntilExpiry()),
+				o.Threshold.String(),
+				o.CriticalThreshold.String(),
 				chainValid,
+				revoked,
+				supportedVersions(s.Info, ";"),
 				"",
 			}
 		}
+		if byIP {
+			row = append([]string{row[0], s.IP}, row[1:]...)
+		}
 		if err := cw.Write(row); err != nil {
 			return err
 		}
@@ -156,68 +473,119 @@ const (
 var nagiosStatusText = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
 
 // nagiosEval determines the Nagios status and a human detail line for one sample,
-// applying Nagios severity: an unreachable/invalid/expired/mismatched certificate
-// is CRITICAL, an upcoming expiry within -threshold (or any warning under -strict)
-// is WARNING, otherwise OK.
+// applying Nagios severity: an unreachable/revoked/invalid/expired/mismatched
+// certificate, or a must-staple one served without a staple, is CRITICAL; an
+// inconclusive revocation check, an unusable or soon-outdated staple, or an
+// upcoming expiry within -threshold (or any warning under -strict) is WARNING;
+// otherwise OK.
 func nagiosEval(s PromSample, opts PrintOptions, strict bool) (code int, detail string) {
+	name := s.name()
 	if s.Info == nil {
-		return nagiosCritical, fmt.Sprintf("%s: %v", s.Domain, s.Err)
+		return nagiosCritical, fmt.Sprintf("%s: %v", name, s.Err)
 	}
 	info := s.Info
 	c := info.Cert
 	expiry := c.NotAfter.Format(dateFormat)
 	switch {
-	case opts.Pin != "" && !MatchesPin(c, opts.Pin):
-		return nagiosCritical, fmt.Sprintf("%s: certificate does not match the pin", s.Domain)
+	case info.RevokedBy() != nil:
+		return nagiosCritical, fmt.Sprintf("%s: certificate REVOKED on %s", name, info.RevokedBy().RevokedAt.Format(dateFormat))
+	case mustStapleMissing(info):
+		return nagiosCritical, fmt.Sprintf("%s: must-staple certificate served without an OCSP staple", name)
+	case len(opts.Pins) > 0 && !MatchesAnyPin(c, opts.Pins):
+		return nagiosCritical, fmt.Sprintf("%s: certificate does not match the pin", name)
 	case opts.ExpectIssuer != "" && !IssuerMatches(c, opts.ExpectIssuer):
-		return nagiosCritical, fmt.Sprintf("%s: unexpected issuer %s", s.Domain, c.Issuer.String())
+		return nagiosCritical, fmt.Sprintf("%s: unexpected issuer %s", name, c.Issuer.String())
+	case info.DANE.Mismatch():
+		return nagiosCritical, fmt.Sprintf("%s: DANE mismatch — none of %d TLSA records at %s", name, len(info.DANE.Records), info.DANE.Name)
+	case info.DNS.Mismatch():
+		return nagiosCritical, fmt.Sprintf("%s: CNAME chain ends at %s, not under %s", name, info.DNS.Final, info.DNS.Expected)
+	case info.CAA.Unauthorized():
+		return nagiosCritical, fmt.Sprintf("%s: issuer not authorized by CAA at %s", name, info.CAA.Domain)
 	case info.Verified && info.ChainErr != nil:
 		kind, _ := classifyChainErr(info)
-		return nagiosCritical, fmt.Sprintf("%s: chain INVALID (%s)", s.Domain, kind)
+		return nagiosCritical, fmt.Sprintf("%s: chain INVALID (%s)", name, kind)
 	}
 	days := info.MinDaysUntilExpiry()
 	switch {
 	case days < 0:
-		return nagiosCritical, fmt.Sprintf("%s: certificate expired on %s", s.Domain, expiry)
-	case opts.Threshold > 0 && days < opts.Threshold:
-		return nagiosWarning, fmt.Sprintf("%s: expires in %d days (%s)", s.Domain, days, expiry)
+		return nagiosCritical, fmt.Sprintf("%s: certificate expired on %s", name, expiry)
+	case info.Expiring(opts.CriticalThreshold):
+		return nagiosCritical, fmt.Sprintf("%s: expires in %d days (%s)", name, days, expiry)
+	case info.Revocation.Inconclusive():
+		return nagiosWarning, fmt.Sprintf("%s: revocation status unknown (%s), expires in %d days (%s)", name, revocationBrief(info.Revocation), days, expiry)
+	case info.Staple.Inconclusive():
+		return nagiosWarning, fmt.Sprintf("%s: OCSP staple not usable (%s), expires in %d days (%s)", name, revocationBrief(info.Staple), days, expiry)
+	case info.CRL.Inconclusive():
+		kind, reason := classifyCRLErr(info)
+		return nagiosWarning, fmt.Sprintf("%s: revocation not confirmed by CRL (%s: %s), expires in %d days (%s)", name, kind, reason, days, expiry)
+	case info.DANE.Inconclusive():
+		return nagiosWarning, fmt.Sprintf("%s: DANE not confirmed (%s), expires in %d days (%s)", name, daneBrief(info.DANE), days, expiry)
+	case info.CAA.Inconclusive():
+		return nagiosWarning, fmt.Sprintf("%s: CAA not confirmed (%v), expires in %d days (%s)", name, info.CAA.Err, days, expiry)
+	case info.DNS.Inconclusive():
+		return nagiosWarning, fmt.Sprintf("%s: CNAME not confirmed (%v), expires in %d days (%s)", name, info.DNS.Err, days, expiry)
+	case stapleExpiresSoon(info):
+		return nagiosWarning, fmt.Sprintf("%s: OCSP staple reaches its next update on %s, expires in %d days (%s)", name, info.Staple.NextUpdate.Format(dateFormat), days, expiry)
+	case info.Expiring(opts.Threshold):
+		return nagiosWarning, fmt.Sprintf("%s: expires in %d days (%s)", name, days, expiry)
 	case strict && HasWarnings(info):
-		return nagiosWarning, fmt.Sprintf("%s: warnings present, expires in %d days (%s)", s.Domain, days, expiry)
+		return nagiosWarning, fmt.Sprintf("%s: warnings present, expires in %d days (%s)", name, days, expiry)
+	}
+	return nagiosOK, fmt.Sprintf("%s: valid, expires in %d days (%s)", name, days, expiry)
+}
+
+// revocationBrief says in a few words why a revocation check was inconclusive.
+func revocationBrief(r *Revocation) string {
+	switch {
+	case r.Err != nil:
+		return r.Err.Error()
+	case r.Status == RevocationUnknown:
+		return "responder does not know the certificate"
 	}
-	return nagiosOK, fmt.Sprintf("%s: valid, expires in %d days (%s)", s.Domain, days, expiry)
+	return "stale " + r.sourceName() + " response"
 }
 
 // nagiosPerf renders the performance data token for one sample (empty when the
 // certificate could not be retrieved): days remaining with -threshold in the
-// warning slot.
+// warning slot and -critical-threshold in the critical one, both in days (a
+// percentage applied to the leaf's lifetime).
 func nagiosPerf(s PromSample, opts PrintOptions) string {
 	if s.Info == nil {
 		return ""
 	}
-	warn := ""
-	if opts.Threshold > 0 {
-		warn = strconv.Itoa(opts.Threshold)
-	}
-	return fmt.Sprintf("'%s'=%d;%s;;", s.Domain, s.Info.MinDaysUntilExpiry(), warn)
+	warn, crit := thresholdDays(opts.Threshold, s.Info.Cert), thresholdDays(opts.CriticalThreshold, s.Info.Cert)
+	return fmt.Sprintf("'%s'=%d;%s;%s;", s.name(), s.Info.MinDaysUntilExpiry(), warn, crit)
 }
 
 // WriteNagios renders the samples as a Nagios/Icinga plugin result and returns the
 // Nagios exit code (0 OK / 1 WARNING / 2 CRITICAL). For a single target it prints
 // one "SSL <STATUS> - <detail> | <perfdata>" line; for several it prints a summary
 // line (worst status + counts + perfdata) followed by one detail line per target.
-// The returned code follows the Nagios convention, overriding the tool's normal
+// Under -all-ips each address is a target of its own, and an address serving a
+// certificate that differs from the domain's other addresses is a WARNING at
+// least. The returned code follows the Nagios convention, overriding the tool's normal
 // exit codes.
 func WriteNagios(w io.Writer, samples []PromSample, opts PrintOptions, strict bool) int {
 	codes := make([]int, len(samples))
 	details := make([]string, len(samples))
 	perfs := make([]string, 0, len(samples))
 	worst := nagiosOK
+	match := addressesMatch(samples)
 	for i, s := range samples {
-		codes[i], details[i] = nagiosEval(s, opts, strict)
+		o := s.options(opts)
+		codes[i], details[i] = nagiosEval(s, o, strict)
+		if codes[i] == nagiosOK && s.IP != "" && !match[s.Domain] {
+			codes[i] = nagiosWarning
+			details[i] = fmt.Sprintf("%s: certificate %s differs from the domain's other addresses, expires in %d days (%s)",
+				s.name(), Fingerprint(s.Info.Cert)[:16], s.Info.MinDaysUntilExpiry(), s.Info.Cert.NotAfter.Format(dateFormat))
+		}
+		if s.Info != nil && s.Info.Ciphers != nil {
+			details[i] += ", cipher grade " + s.Info.Ciphers.Grade
+		}
 		if codes[i] > worst {
 			worst = codes[i]
 		}
-		if p := nagiosPerf(s, opts); p != "" {
+		if p := nagiosPerf(s, o); p != "" {
 			perfs = append(perfs, p)
 		}
 	}
@@ -249,3 +617,11 @@ func WriteNagios(w io.Writer, samples []PromSample, opts PrintOptions, strict bo
 	}
 	return worst
 }
+
+// daneBrief is the short reason a -dane check is inconclusive.
+func daneBrief(d *DANEResult) string {
+	if d.Err != nil {
+		return d.Err.Error()
+	}
+	return "TLSA records not DNSSEC-validated"
+}
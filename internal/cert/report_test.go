@@ -12,14 +12,14 @@ import (
 // TestWritePrometheus verifies the exposition output: TYPE headers, up=1/0,
 // per-domain samples, chain_valid only when verified, and pin_match only with a pin.
 func TestWritePrometheus(t *testing.T) {
-	ok := genCert(t, "ok.example", time.Now().Add(90*24*time.Hour))
+	ok := genCert(t, "ok.example", testNow.Add(90*24*time.Hour))
 	samples := []PromSample{
-		{Domain: "ok.example", Info: &CertInfo{Cert: ok, Chain: []*x509.Certificate{ok}, Verified: true}},
+		{Domain: "ok.example", Info: &CertInfo{Cert: ok, Chain: []*x509.Certificate{ok}, Verified: true, At: testNow}},
 		{Domain: "bad.example", Err: errors.New("connection refused")},
 	}
 
 	var buf strings.Builder
-	WritePrometheus(&buf, samples, "")
+	WritePrometheus(&buf, samples, PrintOptions{})
 	out := buf.String()
 
 	for _, want := range []string{
@@ -38,31 +38,161 @@ func TestWritePrometheus(t *testing.T) {
 	if strings.Contains(out, `ssl_cert_expiry_days{domain="bad.example"}`) {
 		t.Errorf("failed domain should not have an expiry sample:\n%s", out)
 	}
+	// No threshold → no threshold family.
+	if strings.Contains(out, "ssl_cert_expiry_threshold_days") {
+		t.Errorf("threshold family should be absent without thresholds:\n%s", out)
+	}
 	// No pin → no pin_match family.
 	if strings.Contains(out, "ssl_cert_pin_match") {
 		t.Errorf("pin_match should be absent without a pin:\n%s", out)
 	}
 
+	// No revocation check → no revoked family.
+	if strings.Contains(out, "ssl_cert_revoked") {
+		t.Errorf("revoked should be absent without a revocation check:\n%s", out)
+	}
+
+	// ssl_cert_revoked appears for conclusive checks only.
+	buf.Reset()
+	revoked := &CertInfo{Cert: ok, Revocation: &Revocation{Source: "ocsp", Status: RevocationRevoked}}
+	failed := &CertInfo{Cert: ok, Revocation: &Revocation{Source: "ocsp", Err: errors.New("timeout")}}
+	WritePrometheus(&buf, []PromSample{{Domain: "rev.example", Info: revoked}, {Domain: "fail.example", Info: failed}}, PrintOptions{})
+	if revOut := buf.String(); !strings.Contains(revOut, `ssl_cert_revoked{domain="rev.example"} 1`) || strings.Contains(revOut, `ssl_cert_revoked{domain="fail.example"}`) {
+		t.Errorf("expected revoked 1 for the revoked cert and no sample for the failed check:\n%s", revOut)
+	}
+
+	// ssl_ocsp_stapled is reported for fetched certificates, 0 without a staple.
+	buf.Reset()
+	stapled := &CertInfo{Cert: ok, TLSVersion: "TLS 1.3", Staple: &Revocation{Source: "staple", Status: RevocationGood}}
+	plain := &CertInfo{Cert: ok, TLSVersion: "TLS 1.3"}
+	WritePrometheus(&buf, []PromSample{{Domain: "s.example", Info: stapled}, {Domain: "p.example", Info: plain}}, PrintOptions{})
+	if stOut := buf.String(); !strings.Contains(stOut, `ssl_ocsp_stapled{domain="s.example"} 1`) || !strings.Contains(stOut, `ssl_ocsp_stapled{domain="p.example"} 0`) {
+		t.Errorf("expected ssl_ocsp_stapled 1 and 0:\n%s", stOut)
+	}
+
+	// ssl_tls_version_supported appears per scanned version only.
+	if strings.Contains(out, "ssl_tls_version_supported") {
+		t.Errorf("tls_version_supported should be absent without a scan:\n%s", out)
+	}
+	buf.Reset()
+	scanned := &CertInfo{Cert: ok, Versions: []VersionSupport{{Version: "TLS 1.0"}, {Version: "TLS 1.3", Supported: true}}}
+	WritePrometheus(&buf, []PromSample{{Domain: "v.example", Info: scanned}}, PrintOptions{})
+	for _, want := range []string{`ssl_tls_version_supported{domain="v.example",version="TLS 1.0"} 0`, `ssl_tls_version_supported{domain="v.example",version="TLS 1.3"} 1`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("prometheus output missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	// ssl_cert_key_type_expiry_days appears per key type a certificate was found
+	// for, and the soonest of them drives ssl_cert_min_expiry_days.
+	buf.Reset()
+	soon := genCert(t, "soon.example", testNow.Add(10*24*time.Hour+time.Hour))
+	dual := &CertInfo{Cert: ok, At: testNow, KeyTypes: []KeyTypeCert{{KeyType: "RSA", Info: &CertInfo{Cert: ok, At: testNow}}, {KeyType: "ECDSA", Info: &CertInfo{Cert: soon, At: testNow}}}}
+	single := &CertInfo{Cert: ok, At: testNow, KeyTypes: []KeyTypeCert{{KeyType: "RSA", Info: &CertInfo{Cert: ok, At: testNow}}, {KeyType: "ECDSA", Err: errors.New("handshake failure")}}}
+	WritePrometheus(&buf, []PromSample{{Domain: "d.example", Info: dual}, {Domain: "r.example", Info: single}}, PrintOptions{})
+	for _, want := range []string{`ssl_cert_key_type_expiry_days{domain="d.example",key_type="ECDSA"} 10`, `ssl_cert_key_type_expiry_days{domain="r.example",key_type="RSA"}`, `ssl_cert_min_expiry_days{domain="d.example"} 10`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("prometheus output missing %q:\n%s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), `domain="r.example",key_type="ECDSA"`) {
+		t.Errorf("key type without a certificate should be absent:\n%s", buf.String())
+	}
+
+	// ssl_dane_match appears only for samples whose TLSA records were found.
+	buf.Reset()
+	daneOK := &CertInfo{Cert: ok, DANE: &DANEResult{Secure: true, Records: []TLSARecord{{Matched: true}}}}
+	daneMiss := &CertInfo{Cert: ok, DANE: &DANEResult{Secure: true, Records: []TLSARecord{{}}}}
+	daneErr := &CertInfo{Cert: ok, DANE: &DANEResult{Err: errors.New("no TLSA records")}}
+	WritePrometheus(&buf, []PromSample{{Domain: "m.example", Info: daneOK}, {Domain: "x.example", Info: daneMiss}, {Domain: "e.example", Info: daneErr}}, PrintOptions{})
+	for _, want := range []string{`ssl_dane_match{domain="m.example"} 1`, `ssl_dane_match{domain="x.example"} 0`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("prometheus output missing %q:\n%s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), `ssl_dane_match{domain="e.example"}`) {
+		t.Errorf("failed TLSA lookup should be absent:\n%s", buf.String())
+	}
+
 	// With a matching pin, the pin_match family appears as 1.
 	buf.Reset()
-	WritePrometheus(&buf, samples[:1], Fingerprint(ok))
+	WritePrometheus(&buf, samples[:1], PrintOptions{Pins: []string{Fingerprint(ok)}})
 	if pinOut := buf.String(); !strings.Contains(pinOut, `ssl_cert_pin_match{domain="ok.example"} 1`) {
 		t.Errorf("expected pin_match 1 with a matching pin:\n%s", pinOut)
 	}
 }
 
+// TestWritePrometheus_Thresholds carries the run-wide thresholds, or a sample's
+// own, for every retrieved sample.
+func TestWritePrometheus_Thresholds(t *testing.T) {
+	ok := genCert(t, "ok.example", time.Now().Add(90*24*time.Hour))
+	info := &CertInfo{Cert: ok, Chain: []*x509.Certificate{ok}}
+	samples := []PromSample{
+		{Domain: "a.example", Info: info},
+		{Domain: "b.example", Info: info, Opts: &PrintOptions{Threshold: ThresholdDays(14)}},
+		{Domain: "down.example", Err: errors.New("refused")},
+	}
+	var buf strings.Builder
+	WritePrometheus(&buf, samples, PrintOptions{Threshold: ThresholdDays(30), CriticalThreshold: ThresholdDays(7)})
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE ssl_cert_expiry_threshold_days gauge",
+		`ssl_cert_expiry_threshold_days{domain="a.example",level="warning"} 30`,
+		`ssl_cert_expiry_threshold_days{domain="a.example",level="critical"} 7`,
+		`ssl_cert_expiry_threshold_days{domain="b.example",level="warning"} 14`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("prometheus output missing %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{`domain="b.example",level="critical"`, `ssl_cert_expiry_threshold_days{domain="down.example"`} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("unexpected %q:\n%s", unwanted, out)
+		}
+	}
+}
+
+// TestWriteProbe verifies a probe result carries the regular families for its
+// single sample plus the probe duration, including for a failed probe.
+func TestWriteProbe(t *testing.T) {
+	ok := genCert(t, "ok.example", time.Now().Add(90*24*time.Hour))
+
+	var buf strings.Builder
+	WriteProbe(&buf, PromSample{Domain: "ok.example:8443", Info: &CertInfo{Cert: ok}}, PrintOptions{}, 1500*time.Millisecond)
+	out := buf.String()
+	for _, want := range []string{
+		`ssl_cert_up{domain="ok.example:8443"} 1`,
+		`ssl_cert_expiry_days{domain="ok.example:8443"}`,
+		"# TYPE ssl_probe_duration_seconds gauge",
+		`ssl_probe_duration_seconds{domain="ok.example:8443"} 1.5`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("probe output missing %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	WriteProbe(&buf, PromSample{Domain: "down.example", Err: errors.New("refused")}, PrintOptions{}, time.Second)
+	if out := buf.String(); !strings.Contains(out, `ssl_cert_up{domain="down.example"} 0`) || !strings.Contains(out, "ssl_probe_duration_seconds") {
+		t.Errorf("failed probe should report up 0 and a duration:\n%s", out)
+	}
+}
+
 // TestWriteCSV verifies the header, one row per domain, an empty cert row with
 // the error filled for a failed domain, and that a comma-bearing issuer DN is
-// quoted (parsed back cleanly by encoding/csv).
+// quoted (parsed back cleanly by encoding/csv). The thresholds that applied fill
+// their columns.
 func TestWriteCSV(t *testing.T) {
 	ok := genCert(t, "ok.example", time.Now().Add(90*24*time.Hour))
 	samples := []PromSample{
-		{Domain: "ok.example", Info: &CertInfo{Cert: ok, Chain: []*x509.Certificate{ok}, Verified: true}},
+		{Domain: "ok.example", Info: &CertInfo{Cert: ok, Chain: []*x509.Certificate{ok}, Verified: true, Versions: []VersionSupport{
+			{Version: "TLS 1.1"}, {Version: "TLS 1.2", Supported: true}, {Version: "TLS 1.3", Supported: true},
+		}}},
 		{Domain: "bad.example:8443", Err: errors.New("connection refused")},
 	}
 
 	var buf strings.Builder
-	if err := WriteCSV(&buf, samples); err != nil {
+	if err := WriteCSV(&buf, samples, PrintOptions{Threshold: ThresholdDays(30), CriticalThreshold: ThresholdDays(7)}); err != nil {
 		t.Fatalf("WriteCSV: %v", err)
 	}
 
@@ -79,8 +209,11 @@ func TestWriteCSV(t *testing.T) {
 	if len(rows[1]) != len(csvHeader) {
 		t.Fatalf("data row has %d columns, want %d", len(rows[1]), len(csvHeader))
 	}
-	if rows[1][0] != "ok.example" || rows[1][7] != "true" {
-		t.Errorf("ok row: domain/chain_valid wrong: %v", rows[1])
+	if rows[1][0] != "ok.example" || rows[1][9] != "true" || rows[1][11] != "TLS 1.2;TLS 1.3" {
+		t.Errorf("ok row: domain/chain_valid/tls_versions wrong: %v", rows[1])
+	}
+	if rows[1][7] != "30" || rows[1][8] != "7" {
+		t.Errorf("ok row: thresholds wrong: %v", rows[1])
 	}
 	// The issuer DN (self-signed → contains the subject CN with commas in a real
 	// DN) round-trips through csv quoting; here just confirm the field is intact.
@@ -88,7 +221,7 @@ func TestWriteCSV(t *testing.T) {
 		t.Errorf("issuer column should carry the DN, got %q", rows[1][2])
 	}
 	// Failed domain: empty cert columns, error filled, label keeps its port.
-	if rows[2][0] != "bad.example:8443" || rows[2][1] != "" || rows[2][8] != "connection refused" {
+	if rows[2][0] != "bad.example:8443" || rows[2][1] != "" || rows[2][12] != "connection refused" {
 		t.Errorf("error row wrong: %v", rows[2])
 	}
 }
@@ -98,12 +231,12 @@ func TestWriteCSV(t *testing.T) {
 // expired certificate and on a fetch error, and the multi-target summary that
 // reports the worst status with per-target counts.
 func TestWriteNagios(t *testing.T) {
-	now := time.Now()
+	now := testNow
 	ok := genCert(t, "ok.example", now.Add(90*24*time.Hour))
 	soon := genCert(t, "soon.example", now.Add(8*24*time.Hour))
 	expired := genCert(t, "exp.example", now.Add(-24*time.Hour))
 	infoOf := func(c *x509.Certificate) *CertInfo {
-		return &CertInfo{Cert: c, Chain: []*x509.Certificate{c}, Verified: true}
+		return &CertInfo{Cert: c, Chain: []*x509.Certificate{c}, Verified: true, At: now}
 	}
 
 	t.Run("ok with perfdata", func(t *testing.T) {
@@ -121,9 +254,21 @@ func TestWriteNagios(t *testing.T) {
 		}
 	})
 
+	t.Run("cipher grade in detail", func(t *testing.T) {
+		var buf strings.Builder
+		info := infoOf(ok)
+		info.Ciphers = &CipherScan{Grade: "C"}
+		if code := WriteNagios(&buf, []PromSample{{Domain: "ok.example", Info: info}}, PrintOptions{}, false); code != nagiosOK {
+			t.Fatalf("expected OK (0), got %d", code)
+		}
+		if !strings.Contains(buf.String(), ", cipher grade C") {
+			t.Errorf("expected the cipher grade, got: %q", buf.String())
+		}
+	})
+
 	t.Run("warning on threshold", func(t *testing.T) {
 		var buf strings.Builder
-		code := WriteNagios(&buf, []PromSample{{Domain: "soon.example", Info: infoOf(soon)}}, PrintOptions{Threshold: 30}, false)
+		code := WriteNagios(&buf, []PromSample{{Domain: "soon.example", Info: infoOf(soon)}}, PrintOptions{Threshold: ThresholdDays(30)}, false)
 		if code != nagiosWarning {
 			t.Fatalf("expected WARNING (1), got %d", code)
 		}
@@ -136,6 +281,21 @@ func TestWriteNagios(t *testing.T) {
 		}
 	})
 
+	t.Run("critical on critical threshold", func(t *testing.T) {
+		var buf strings.Builder
+		code := WriteNagios(&buf, []PromSample{{Domain: "soon.example", Info: infoOf(soon)}}, PrintOptions{Threshold: ThresholdDays(30), CriticalThreshold: ThresholdDays(14)}, false)
+		if code != nagiosCritical {
+			t.Fatalf("expected CRITICAL (2), got %d", code)
+		}
+		out := buf.String()
+		if !strings.HasPrefix(out, "SSL CRITICAL - soon.example: expires in") {
+			t.Errorf("unexpected CRITICAL line: %q", out)
+		}
+		if !strings.Contains(out, ";30;14;") {
+			t.Errorf("expected perfdata with both thresholds, got: %q", out)
+		}
+	})
+
 	t.Run("critical on expired", func(t *testing.T) {
 		var buf strings.Builder
 		code := WriteNagios(&buf, []PromSample{{Domain: "exp.example", Info: infoOf(expired)}}, PrintOptions{}, false)
@@ -169,7 +329,7 @@ func TestWriteNagios(t *testing.T) {
 			{Domain: "soon.example", Info: infoOf(soon)},
 			{Domain: "bad.example", Err: errors.New("connection refused")},
 		}
-		code := WriteNagios(&buf, samples, PrintOptions{Threshold: 30}, false)
+		code := WriteNagios(&buf, samples, PrintOptions{Threshold: ThresholdDays(30)}, false)
 		if code != nagiosCritical {
 			t.Fatalf("expected worst status CRITICAL (2), got %d", code)
 		}
@@ -191,20 +351,98 @@ func TestWriteNagios(t *testing.T) {
 	})
 }
 
-// TestNagiosEval covers the CRITICAL branches not exercised by TestWriteNagios:
-// pin mismatch, issuer mismatch and an invalid chain.
+// TestNagiosEval covers the branches not exercised by TestWriteNagios: pin
+// mismatch, issuer mismatch, a revoked certificate, an inconclusive revocation
+// check and an invalid chain.
 func TestNagiosEval(t *testing.T) {
 	c := genCert(t, "n.example", time.Now().Add(90*24*time.Hour))
 	healthy := &CertInfo{Cert: c, Chain: []*x509.Certificate{c}}
 
-	if code, d := nagiosEval(PromSample{Domain: "n.example", Info: healthy}, PrintOptions{Pin: "00ff"}, false); code != nagiosCritical || !strings.Contains(d, "pin") {
+	if code, d := nagiosEval(PromSample{Domain: "n.example", Info: healthy}, PrintOptions{Pins: []string{"00ff"}}, false); code != nagiosCritical || !strings.Contains(d, "pin") {
 		t.Errorf("pin mismatch: code=%d detail=%q", code, d)
 	}
 	if code, d := nagiosEval(PromSample{Domain: "n.example", Info: healthy}, PrintOptions{ExpectIssuer: "Nonexistent CA"}, false); code != nagiosCritical || !strings.Contains(d, "issuer") {
 		t.Errorf("issuer mismatch: code=%d detail=%q", code, d)
 	}
+	revoked := &CertInfo{Cert: c, Chain: []*x509.Certificate{c}, Revocation: &Revocation{Source: "ocsp", Status: RevocationRevoked}}
+	if code, d := nagiosEval(PromSample{Domain: "n.example", Info: revoked}, PrintOptions{}, false); code != nagiosCritical || !strings.Contains(d, "REVOKED") {
+		t.Errorf("revoked: code=%d detail=%q", code, d)
+	}
+	unknown := &CertInfo{Cert: c, Chain: []*x509.Certificate{c}, Revocation: &Revocation{Source: "ocsp", Status: RevocationUnknown}}
+	if code, d := nagiosEval(PromSample{Domain: "n.example", Info: unknown}, PrintOptions{}, false); code != nagiosWarning || !strings.Contains(d, "revocation status unknown") {
+		t.Errorf("unknown revocation: code=%d detail=%q", code, d)
+	}
+	stapleRevoked := &CertInfo{Cert: c, Chain: []*x509.Certificate{c}, TLSVersion: "TLS 1.3", Staple: &Revocation{Source: "staple", Status: RevocationRevoked}}
+	if code, d := nagiosEval(PromSample{Domain: "n.example", Info: stapleRevoked}, PrintOptions{}, false); code != nagiosCritical || !strings.Contains(d, "REVOKED") {
+		t.Errorf("revoked staple: code=%d detail=%q", code, d)
+	}
+	crlExpiredInfo := &CertInfo{Cert: c, Chain: []*x509.Certificate{c}, CRL: &Revocation{Source: "crl", Err: &crlError{crlExpired, errors.New("CRL expired")}}}
+	if code, d := nagiosEval(PromSample{Domain: "n.example", Info: crlExpiredInfo}, PrintOptions{}, false); code != nagiosWarning || !strings.Contains(d, crlExpired) {
+		t.Errorf("expired CRL: code=%d detail=%q", code, d)
+	}
+	daneMiss := &CertInfo{Cert: c, Chain: []*x509.Certificate{c}, DANE: &DANEResult{Name: "_443._tcp.n.example", Secure: true, Records: []TLSARecord{{Usage: 3, Selector: 1, MatchingType: 1}}}}
+	if code, d := nagiosEval(PromSample{Domain: "n.example", Info: daneMiss}, PrintOptions{}, false); code != nagiosCritical || !strings.Contains(d, "DANE mismatch") {
+		t.Errorf("DANE mismatch: code=%d detail=%q", code, d)
+	}
+	daneInsecure := &CertInfo{Cert: c, Chain: []*x509.Certificate{c}, DANE: &DANEResult{Name: "_443._tcp.n.example", Records: []TLSARecord{{Usage: 3, Selector: 1, MatchingType: 1, Matched: true}}}}
+	if code, d := nagiosEval(PromSample{Domain: "n.example", Info: daneInsecure}, PrintOptions{}, false); code != nagiosWarning || !strings.Contains(d, "not DNSSEC-validated") {
+		t.Errorf("unvalidated DANE: code=%d detail=%q", code, d)
+	}
+	caaDenied := &CertInfo{Cert: c, Chain: []*x509.Certificate{c}, CAA: &CAAResult{Domain: "n.example", Property: "issue", Records: []CAARecord{{Tag: "issue", Value: "ca.example"}}}}
+	if code, d := nagiosEval(PromSample{Domain: "n.example", Info: caaDenied}, PrintOptions{}, false); code != nagiosCritical || !strings.Contains(d, "CAA") {
+		t.Errorf("CAA not authorized: code=%d detail=%q", code, d)
+	}
+	caaDenied.Revocation = &Revocation{Source: "ocsp", Err: errors.New("timeout")}
+	if code, d := nagiosEval(PromSample{Domain: "n.example", Info: caaDenied}, PrintOptions{}, false); code != nagiosCritical || !strings.Contains(d, "CAA") {
+		t.Errorf("CAA not authorized with an inconclusive OCSP check: code=%d detail=%q", code, d)
+	}
 	invalid := &CertInfo{Cert: c, Chain: []*x509.Certificate{c}, Verified: true, ChainErr: x509.UnknownAuthorityError{}}
 	if code, d := nagiosEval(PromSample{Domain: "n.example", Info: invalid}, PrintOptions{}, false); code != nagiosCritical || !strings.Contains(d, "INVALID") {
 		t.Errorf("invalid chain: code=%d detail=%q", code, d)
 	}
 }
+
+// TestReports_PerAddress verifies the -all-ips report forms: an ip label and the
+// per-domain ssl_cert_addresses_match in Prometheus, an ip column in CSV, and a
+// Nagios WARNING for each address of a domain whose certificates differ.
+func TestReports_PerAddress(t *testing.T) {
+	cur := genCert(t, "www.example", testNow.Add(90*24*time.Hour))
+	stale := genCert(t, "www.example", testNow.Add(20*24*time.Hour))
+	samples := []PromSample{
+		{Domain: "www.example", IP: "192.0.2.1", Info: &CertInfo{Cert: cur, At: testNow}},
+		{Domain: "www.example", IP: "192.0.2.2", Info: &CertInfo{Cert: stale, At: testNow}},
+		{Domain: "api.example", IP: "192.0.2.3", Info: &CertInfo{Cert: cur, At: testNow}},
+	}
+
+	var buf strings.Builder
+	WritePrometheus(&buf, samples, PrintOptions{})
+	for _, want := range []string{
+		`ssl_cert_up{domain="www.example",ip="192.0.2.2"} 1`,
+		`ssl_cert_expiry_days{domain="www.example",ip="192.0.2.2"} 20`,
+		`ssl_cert_addresses_match{domain="www.example"} 0`,
+		`ssl_cert_addresses_match{domain="api.example"} 1`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("prometheus output missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := WriteCSV(&buf, samples, PrintOptions{}); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(buf.String())).ReadAll()
+	if err != nil || len(rows) != 4 || rows[0][1] != "ip" || rows[2][0] != "www.example" || rows[2][1] != "192.0.2.2" {
+		t.Errorf("unexpected CSV (%v):\n%s", err, buf.String())
+	}
+
+	buf.Reset()
+	if code := WriteNagios(&buf, samples, PrintOptions{}, false); code != nagiosWarning {
+		t.Errorf("expected WARNING, got %d", code)
+	}
+	for _, want := range []string{"1 OK, 2 WARNING", "WARNING www.example [192.0.2.2]: certificate", "differs from the domain's other addresses", "OK api.example [192.0.2.3]: valid"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("nagios output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
@@ -2,22 +2,41 @@ package cert
 
 import (
 	"bufio"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"strings"
 )
 
 // negotiateStartTLS performs the protocol-specific STARTTLS exchange on a
-// plaintext connection, leaving it ready for the TLS handshake. Supported
-// protocols: smtp, imap, pop3, ftp.
-func negotiateStartTLS(conn net.Conn, proto string) error {
+// plaintext connection and returns the connection to run the TLS handshake on:
+// conn itself, or for mssql an adapter that frames the handshake in TDS. host is
+// the name the client asks for, which XMPP puts in its stream header.
+func negotiateStartTLS(conn net.Conn, proto, host string) (net.Conn, error) {
+	if proto == "mssql" {
+		return negotiateTDS(conn)
+	}
+	return conn, startTLSExchange(conn, proto, host)
+}
+
+// startTLSExchange runs the command exchange of a protocol whose TLS handshake
+// then follows on the plain connection. Supported protocols: smtp, lmtp, imap,
+// pop3, ftp, nntp, sieve, irc, xmpp, xmpp-server, ldap, postgres, mysql.
+func startTLSExchange(conn net.Conn, proto, host string) error {
 	br := bufio.NewReader(conn)
 	switch proto {
-	case "smtp":
+	case "smtp", "lmtp":
+		greet := "EHLO"
+		if proto == "lmtp" {
+			greet = "LHLO"
+		}
 		if err := expectCodeReply(br, "220"); err != nil {
 			return err
 		}
-		if _, err := fmt.Fprintf(conn, "EHLO ssl-watch\r\n"); err != nil {
+		if _, err := fmt.Fprintf(conn, "%s ssl-watch\r\n", greet); err != nil {
 			return err
 		}
 		if err := expectCodeReply(br, "250"); err != nil {
@@ -27,6 +46,25 @@ func negotiateStartTLS(conn net.Conn, proto string) error {
 			return err
 		}
 		return expectCodeReply(br, "220")
+	case "nntp":
+		// 200 (posting allowed) and 201 (no posting) both greet.
+		if err := expectCodeReply(br, "20"); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(conn, "STARTTLS\r\n"); err != nil {
+			return err
+		}
+		return expectCodeReply(br, "382")
+	case "sieve":
+		if err := expectSieveOK(br, true); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(conn, "STARTTLS\r\n"); err != nil {
+			return err
+		}
+		return expectSieveOK(br, false)
+	case "irc":
+		return negotiateIRC(conn, br)
 	case "ftp":
 		if err := expectCodeReply(br, "220"); err != nil {
 			return err
@@ -51,6 +89,16 @@ func negotiateStartTLS(conn net.Conn, proto string) error {
 			return err
 		}
 		return expectTaggedOK(br, "a")
+	case "xmpp":
+		return negotiateXMPP(conn, br, host, "jabber:client")
+	case "xmpp-server":
+		return negotiateXMPP(conn, br, host, "jabber:server")
+	case "ldap":
+		return negotiateLDAP(conn, br)
+	case "postgres":
+		return negotiatePostgres(conn, br)
+	case "mysql":
+		return negotiateMySQL(conn, br)
 	default:
 		return fmt.Errorf("unknown STARTTLS protocol %q", proto)
 	}
@@ -113,3 +161,309 @@ func expectTaggedOK(br *bufio.Reader, tag string) error {
 		}
 	}
 }
+
+// maxStartTLSRead bounds how much a binary or XML STARTTLS exchange reads before
+// giving up on a server that never sends what is expected.
+const maxStartTLSRead = 64 << 10
+
+// negotiateXMPP opens an XMPP stream to host in namespace ns (jabber:client or
+// jabber:server, RFC 6120), checks that the stream features offer STARTTLS and
+// asks for it; the server answers <proceed/> or <failure/>.
+func negotiateXMPP(conn net.Conn, br *bufio.Reader, host, ns string) error {
+	if _, err := fmt.Fprintf(conn, "<?xml version='1.0'?><stream:stream xmlns:stream='http://etherx.jabber.org/streams' xmlns='%s' to='%s' version='1.0'>", ns, host); err != nil {
+		return err
+	}
+	features, err := readUntil(br, "</stream:features>", "<stream:error")
+	if err != nil {
+		return err
+	}
+	if strings.Contains(features, "<stream:error") || !strings.Contains(features, "<starttls") {
+		return errors.New("server does not offer STARTTLS")
+	}
+	if _, err := fmt.Fprint(conn, "<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>"); err != nil {
+		return err
+	}
+	reply, err := readUntil(br, "<proceed", "<failure")
+	if err != nil {
+		return err
+	}
+	if strings.Contains(reply, "<failure") {
+		return errors.New("STARTTLS rejected")
+	}
+	// Consume the rest of the <proceed/> element so the TLS handshake starts clean.
+	_, err = readUntil(br, ">")
+	return err
+}
+
+// readUntil reads from br until one of markers has been seen and returns
+// everything read so far; it fails after maxStartTLSRead bytes.
+func readUntil(br *bufio.Reader, markers ...string) (string, error) {
+	var sb strings.Builder
+	for sb.Len() < maxStartTLSRead {
+		b, err := br.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(b)
+		for _, m := range markers {
+			if strings.HasSuffix(sb.String(), m) {
+				return sb.String(), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("expected %q within %d bytes", markers[0], maxStartTLSRead)
+}
+
+// ldapStartTLS is the LDAP StartTLS extended request (RFC 4511 §4.14) with
+// message ID 1: an LDAPMessage SEQUENCE holding an ExtendedRequest
+// ([APPLICATION 23]) whose requestName ([0]) is 1.3.6.1.4.1.1466.20037.
+var ldapStartTLS = append([]byte{0x30, 0x1d, 0x02, 0x01, 0x01, 0x77, 0x18, 0x80, 0x16}, "1.3.6.1.4.1.1466.20037"...)
+
+// negotiateLDAP sends the StartTLS extended request and checks that the
+// ExtendedResponse ([APPLICATION 24]) reports success (resultCode 0).
+func negotiateLDAP(conn net.Conn, br *bufio.Reader) error {
+	if _, err := conn.Write(ldapStartTLS); err != nil {
+		return err
+	}
+	packet, err := readBER(br)
+	if err != nil {
+		return err
+	}
+	var msg struct {
+		ID int
+		Op asn1.RawValue
+	}
+	if _, err := asn1.Unmarshal(packet, &msg); err != nil {
+		return fmt.Errorf("malformed LDAP response: %v", err)
+	}
+	if msg.Op.Class != asn1.ClassApplication || msg.Op.Tag != 24 {
+		return fmt.Errorf("unexpected LDAP response (tag %d)", msg.Op.Tag)
+	}
+	var code asn1.Enumerated
+	if _, err := asn1.Unmarshal(msg.Op.Bytes, &code); err != nil {
+		return fmt.Errorf("malformed LDAP response: %v", err)
+	}
+	if code != 0 {
+		return fmt.Errorf("StartTLS rejected (LDAP result code %d)", code)
+	}
+	return nil
+}
+
+// readBER reads one BER element (tag, definite length, contents) and returns it
+// whole.
+func readBER(br *bufio.Reader) ([]byte, error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, err
+	}
+	length := int(head[1])
+	if head[1]&0x80 != 0 {
+		n := int(head[1] & 0x7f)
+		if n == 0 || n > 4 {
+			return nil, fmt.Errorf("unsupported BER length encoding 0x%02x", head[1])
+		}
+		lb := make([]byte, n)
+		if _, err := io.ReadFull(br, lb); err != nil {
+			return nil, err
+		}
+		head = append(head, lb...)
+		length = 0
+		for _, b := range lb {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxStartTLSRead {
+		return nil, fmt.Errorf("BER element of %d bytes is too large", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(br, body); err != nil {
+		return nil, err
+	}
+	return append(head, body...), nil
+}
+
+// postgresSSLRequest is the PostgreSQL SSLRequest message: its length (8) and
+// the request code 80877103.
+var postgresSSLRequest = []byte{0, 0, 0, 8, 0x04, 0xd2, 0x16, 0x2f}
+
+// negotiatePostgres sends an SSLRequest; the server answers a single byte, 'S'
+// to go ahead with TLS or 'N' when it does not support it.
+func negotiatePostgres(conn net.Conn, br *bufio.Reader) error {
+	if _, err := conn.Write(postgresSSLRequest); err != nil {
+		return err
+	}
+	b, err := br.ReadByte()
+	if err != nil {
+		return err
+	}
+	switch b {
+	case 'S':
+		return nil
+	case 'N':
+		return errors.New("server does not support SSL")
+	default:
+		return fmt.Errorf("unexpected SSLRequest reply %q", b)
+	}
+}
+
+// MySQL capability flags used in the SSLRequest packet.
+const (
+	mysqlLongPassword     = 0x00000001
+	mysqlProtocol41       = 0x00000200
+	mysqlSSL              = 0x00000800
+	mysqlSecureConnection = 0x00008000
+)
+
+// negotiateMySQL reads the server's initial handshake packet, checks that it
+// advertises the SSL capability and answers with an SSLRequest packet (sequence
+// 1), after which the server expects the TLS handshake.
+func negotiateMySQL(conn net.Conn, br *bufio.Reader) error {
+	payload, err := readMySQLPacket(br)
+	if err != nil {
+		return err
+	}
+	if payload[0] == 0xff {
+		msg := ""
+		if len(payload) > 3 {
+			msg = string(payload[3:])
+		}
+		return fmt.Errorf("server refused the connection: %s", msg)
+	}
+	// protocol version, NUL-terminated server version, connection id (4),
+	// auth-plugin-data part 1 (8), filler (1), then the lower capability flags.
+	end := strings.IndexByte(string(payload[1:]), 0)
+	off := 1 + end + 1 + 4 + 8 + 1
+	if payload[0] != 10 || end < 0 || len(payload) < off+2 {
+		return errors.New("malformed MySQL handshake packet")
+	}
+	if binary.LittleEndian.Uint16(payload[off:])&mysqlSSL == 0 {
+		return errors.New("server does not support SSL")
+	}
+	req := make([]byte, 4+32)
+	req[0], req[3] = 32, 1 // payload length, sequence id
+	binary.LittleEndian.PutUint32(req[4:], mysqlLongPassword|mysqlProtocol41|mysqlSSL|mysqlSecureConnection)
+	binary.LittleEndian.PutUint32(req[8:], 1<<24) // max packet size
+	req[12] = 33                                  // utf8_general_ci
+	_, err = conn.Write(req)
+	return err
+}
+
+// readMySQLPacket reads one MySQL protocol packet (3-byte little-endian length,
+// sequence id, payload) and returns its non-empty payload.
+func readMySQLPacket(br *bufio.Reader) ([]byte, error) {
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, err
+	}
+	length := int(head[0]) | int(head[1])<<8 | int(head[2])<<16
+	if length == 0 || length > maxStartTLSRead {
+		return nil, fmt.Errorf("unexpected MySQL packet length %d", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// expectSieveOK reads ManageSieve (RFC 5804) response lines up to the final OK.
+// With capabilities set, the lines before it are the server's capability list,
+// which must include STARTTLS. NO and BYE are errors.
+func expectSieveOK(br *bufio.Reader, capabilities bool) error {
+	offered := false
+	for {
+		line, err := readLine(br)
+		if err != nil {
+			return err
+		}
+		upper := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(upper, "OK"):
+			if capabilities && !offered {
+				return errors.New("server does not offer STARTTLS")
+			}
+			return nil
+		case strings.HasPrefix(upper, "NO"), strings.HasPrefix(upper, "BYE"):
+			return fmt.Errorf("STARTTLS rejected: %q", line)
+		case upper == `"STARTTLS"`:
+			offered = true
+		}
+	}
+}
+
+// negotiateIRC lists the server's capabilities (IRCv3 CAP LS), checks that "tls"
+// is among them and sends STARTTLS, which the server confirms with numeric 670
+// (RPL_STARTTLS) or refuses with 691 (ERR_STARTTLS). Notices the server sends
+// while looking up the client are skipped.
+func negotiateIRC(conn net.Conn, br *bufio.Reader) error {
+	if _, err := fmt.Fprintf(conn, "CAP LS\r\n"); err != nil {
+		return err
+	}
+	offered := false
+	for {
+		cmd, params, err := readIRCMessage(br)
+		if err != nil {
+			return err
+		}
+		if cmd == "ERROR" {
+			return fmt.Errorf("server closed the connection: %s", strings.Join(params, " "))
+		}
+		// CAP <nick> LS [*] :<caps>; a "*" marks a continuation line.
+		if cmd != "CAP" || len(params) < 3 || params[1] != "LS" {
+			continue
+		}
+		for _, c := range strings.Fields(params[len(params)-1]) {
+			if c == "tls" {
+				offered = true
+			}
+		}
+		if len(params) == 3 {
+			break
+		}
+	}
+	if !offered {
+		return errors.New("server does not offer STARTTLS (no tls capability)")
+	}
+	if _, err := fmt.Fprintf(conn, "STARTTLS\r\n"); err != nil {
+		return err
+	}
+	for {
+		cmd, params, err := readIRCMessage(br)
+		if err != nil {
+			return err
+		}
+		switch cmd {
+		case "670":
+			return nil
+		case "691", "421", "ERROR":
+			return fmt.Errorf("STARTTLS rejected: %s %s", cmd, strings.Join(params, " "))
+		}
+	}
+}
+
+// readIRCMessage reads one IRC message and splits it into its command and
+// parameters, dropping any prefix; a trailing parameter (after " :") is kept
+// whole.
+func readIRCMessage(br *bufio.Reader) (cmd string, params []string, err error) {
+	line, err := readLine(br)
+	if err != nil {
+		return "", nil, err
+	}
+	if strings.HasPrefix(line, ":") {
+		if _, rest, ok := strings.Cut(line, " "); ok {
+			line = rest
+		} else {
+			line = ""
+		}
+	}
+	line, trailing, hasTrailing := strings.Cut(line, " :")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", nil, nil
+	}
+	params = fields[1:]
+	if hasTrailing {
+		params = append(params, trailing)
+	}
+	return strings.ToUpper(fields[0]), params, nil
+}
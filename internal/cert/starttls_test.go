@@ -2,8 +2,16 @@ package cert
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"net"
+	"strings"
 	"testing"
 	"time"
 )
@@ -16,7 +24,8 @@ func runNegotiate(t *testing.T, proto string, serverScript func(server net.Conn,
 	errCh := make(chan error, 1)
 	go func() {
 		client.SetDeadline(time.Now().Add(5 * time.Second))
-		errCh <- negotiateStartTLS(client, proto)
+		_, err := negotiateStartTLS(client, proto, "example.com")
+		errCh <- err
 		client.Close()
 	}()
 
@@ -71,7 +80,247 @@ func TestNegotiateStartTLS_POP3Rejected(t *testing.T) {
 func TestNegotiateStartTLS_Unknown(t *testing.T) {
 	client, _ := net.Pipe()
 	defer client.Close()
-	if err := negotiateStartTLS(client, "gopher"); err == nil {
+	if _, err := negotiateStartTLS(client, "gopher", "example.com"); err == nil {
 		t.Error("expected error for unknown protocol, got nil")
 	}
 }
+
+// readTo reads from the scripted server's side until marker has been received.
+func readTo(r *bufio.Reader, marker string) string {
+	var got []byte
+	for !strings.HasSuffix(string(got), marker) {
+		b, err := r.ReadByte()
+		if err != nil {
+			break
+		}
+		got = append(got, b)
+	}
+	return string(got)
+}
+
+// TestNegotiateStartTLS_XMPP verifies the client and server stream namespaces,
+// the requested host, and a server that answers <failure/>.
+func TestNegotiateStartTLS_XMPP(t *testing.T) {
+	for proto, ns := range map[string]string{"xmpp": "jabber:client", "xmpp-server": "jabber:server"} {
+		var header string
+		err := runNegotiate(t, proto, func(server net.Conn, r *bufio.Reader) {
+			header = readTo(r, "version='1.0'>")
+			fmt.Fprint(server, "<?xml version='1.0'?><stream:stream from='example.com' id='1' version='1.0' xmlns='"+ns+"' xmlns:stream='http://etherx.jabber.org/streams'>")
+			fmt.Fprint(server, "<stream:features><starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'><required/></starttls></stream:features>")
+			readTo(r, "/>")
+			fmt.Fprint(server, "<proceed xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")
+		})
+		if err != nil {
+			t.Errorf("%s: expected successful negotiation, got error: %v", proto, err)
+		}
+		if !strings.Contains(header, "xmlns='"+ns+"'") || !strings.Contains(header, "to='example.com'") {
+			t.Errorf("%s: unexpected stream header %q", proto, header)
+		}
+	}
+
+	err := runNegotiate(t, "xmpp", func(server net.Conn, r *bufio.Reader) {
+		readTo(r, "version='1.0'>")
+		fmt.Fprint(server, "<stream:stream version='1.0'><stream:features><starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/></stream:features>")
+		readTo(r, "/>")
+		fmt.Fprint(server, "<failure xmlns='urn:ietf:params:xml:ns:xmpp-tls'/></stream:stream>")
+	})
+	if err == nil {
+		t.Error("expected error when STARTTLS fails, got nil")
+	}
+
+	err = runNegotiate(t, "xmpp", func(server net.Conn, r *bufio.Reader) {
+		readTo(r, "version='1.0'>")
+		fmt.Fprint(server, "<stream:stream version='1.0'><stream:features><mechanisms/></stream:features>")
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not offer STARTTLS") {
+		t.Errorf("expected a missing-STARTTLS error, got %v", err)
+	}
+}
+
+// TestNegotiateStartTLS_LDAP verifies the StartTLS extended request on the wire
+// and that a non-zero result code is an error.
+func TestNegotiateStartTLS_LDAP(t *testing.T) {
+	for code, wantErr := range map[byte]bool{0: false, 2: true} {
+		var req []byte
+		err := runNegotiate(t, "ldap", func(server net.Conn, r *bufio.Reader) {
+			req = make([]byte, len(ldapStartTLS))
+			io.ReadFull(r, req)
+			// ExtendedResponse: resultCode, empty matchedDN and diagnosticMessage.
+			server.Write([]byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x78, 0x07, 0x0a, 0x01, code, 0x04, 0x00, 0x04, 0x00})
+		})
+		if (err != nil) != wantErr {
+			t.Errorf("result code %d: error = %v, wantErr %v", code, err, wantErr)
+		}
+		if !bytes.Contains(req, []byte("1.3.6.1.4.1.1466.20037")) {
+			t.Errorf("request does not name the StartTLS OID: %x", req)
+		}
+	}
+}
+
+// TestNegotiateStartTLS_Postgres verifies the SSLRequest packet and both replies.
+func TestNegotiateStartTLS_Postgres(t *testing.T) {
+	for reply, wantErr := range map[string]bool{"S": false, "N": true} {
+		var req []byte
+		err := runNegotiate(t, "postgres", func(server net.Conn, r *bufio.Reader) {
+			req = make([]byte, 8)
+			io.ReadFull(r, req)
+			fmt.Fprint(server, reply)
+		})
+		if (err != nil) != wantErr {
+			t.Errorf("reply %s: error = %v, wantErr %v", reply, err, wantErr)
+		}
+		if !bytes.Equal(req, postgresSSLRequest) {
+			t.Errorf("unexpected SSLRequest %x", req)
+		}
+	}
+}
+
+// TestNegotiateStartTLS_MySQL verifies the SSLRequest packet sent after the
+// server handshake, and that a server without the SSL capability is an error.
+func TestNegotiateStartTLS_MySQL(t *testing.T) {
+	handshake := func(caps uint16) []byte {
+		payload := append([]byte{10}, "8.0.36\x00"...)
+		payload = append(payload, 1, 0, 0, 0)                   // connection id
+		payload = append(payload, "abcdefgh"...)                // auth-plugin-data part 1
+		payload = append(payload, 0, byte(caps), byte(caps>>8)) // filler, capabilities
+		payload = append(payload, 33, 2, 0)                     // charset, status
+		return append([]byte{byte(len(payload)), 0, 0, 0}, payload...)
+	}
+
+	var req []byte
+	err := runNegotiate(t, "mysql", func(server net.Conn, r *bufio.Reader) {
+		server.Write(handshake(0xffff))
+		req = make([]byte, 36)
+		io.ReadFull(r, req)
+	})
+	if err != nil {
+		t.Fatalf("expected successful MySQL negotiation, got error: %v", err)
+	}
+	if req[0] != 32 || req[3] != 1 || binary.LittleEndian.Uint32(req[4:])&mysqlSSL == 0 {
+		t.Errorf("unexpected SSLRequest packet %x", req)
+	}
+
+	err = runNegotiate(t, "mysql", func(server net.Conn, r *bufio.Reader) {
+		server.Write(handshake(0xffff &^ mysqlSSL))
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not support SSL") {
+		t.Errorf("expected a no-SSL error, got %v", err)
+	}
+}
+
+// TestNegotiateStartTLS_LineDialects verifies NNTP, LMTP and ManageSieve
+// negotiations, and that ManageSieve without STARTTLS in its capabilities fails.
+func TestNegotiateStartTLS_LineDialects(t *testing.T) {
+	err := runNegotiate(t, "nntp", func(server net.Conn, r *bufio.Reader) {
+		fmt.Fprint(server, "201 news.example.com ready (no posting)\r\n")
+		r.ReadString('\n') // STARTTLS
+		fmt.Fprint(server, "382 Continue with TLS negotiation\r\n")
+	})
+	if err != nil {
+		t.Errorf("nntp: expected successful negotiation, got error: %v", err)
+	}
+
+	var hello string
+	err = runNegotiate(t, "lmtp", func(server net.Conn, r *bufio.Reader) {
+		fmt.Fprint(server, "220 lmtp.example.com LMTP ready\r\n")
+		hello, _ = r.ReadString('\n')
+		fmt.Fprint(server, "250-lmtp.example.com\r\n250 STARTTLS\r\n")
+		r.ReadString('\n') // STARTTLS
+		fmt.Fprint(server, "220 2.0.0 Ready to start TLS\r\n")
+	})
+	if err != nil || !strings.HasPrefix(hello, "LHLO ") {
+		t.Errorf("lmtp: expected LHLO and a successful negotiation, got %q, %v", hello, err)
+	}
+
+	err = runNegotiate(t, "sieve", func(server net.Conn, r *bufio.Reader) {
+		fmt.Fprint(server, "\"IMPLEMENTATION\" \"Dovecot Pigeonhole\"\r\n\"SIEVE\" \"fileinto reject\"\r\n\"STARTTLS\"\r\n\"VERSION\" \"1.0\"\r\nOK \"ready\"\r\n")
+		r.ReadString('\n') // STARTTLS
+		fmt.Fprint(server, "OK \"Begin TLS negotiation now.\"\r\n")
+	})
+	if err != nil {
+		t.Errorf("sieve: expected successful negotiation, got error: %v", err)
+	}
+
+	err = runNegotiate(t, "sieve", func(server net.Conn, r *bufio.Reader) {
+		fmt.Fprint(server, "\"IMPLEMENTATION\" \"Example\"\r\nOK \"ready\"\r\n")
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not offer STARTTLS") {
+		t.Errorf("sieve: expected a missing-STARTTLS error, got %v", err)
+	}
+}
+
+// TestNegotiateStartTLS_IRC verifies the CAP LS exchange (with a continuation
+// line and a lookup notice), the 670 confirmation and a 691 refusal.
+func TestNegotiateStartTLS_IRC(t *testing.T) {
+	for reply, wantErr := range map[string]bool{
+		":irc.example.net 670 * :STARTTLS successful, proceed with TLS handshake": false,
+		":irc.example.net 691 * :STARTTLS failure":                                true,
+	} {
+		err := runNegotiate(t, "irc", func(server net.Conn, r *bufio.Reader) {
+			r.ReadString('\n') // CAP LS
+			fmt.Fprint(server, ":irc.example.net NOTICE * :*** Looking up your hostname...\r\n")
+			fmt.Fprint(server, ":irc.example.net CAP * LS * :multi-prefix sasl\r\n")
+			fmt.Fprint(server, ":irc.example.net CAP * LS :tls away-notify\r\n")
+			r.ReadString('\n') // STARTTLS
+			fmt.Fprint(server, reply+"\r\n")
+		})
+		if (err != nil) != wantErr {
+			t.Errorf("reply %q: error = %v, wantErr %v", reply, err, wantErr)
+		}
+	}
+
+	err := runNegotiate(t, "irc", func(server net.Conn, r *bufio.Reader) {
+		r.ReadString('\n') // CAP LS
+		fmt.Fprint(server, ":irc.example.net CAP * LS :sasl\r\n")
+	})
+	if err == nil || !strings.Contains(err.Error(), "no tls capability") {
+		t.Errorf("expected a missing-capability error, got %v", err)
+	}
+}
+
+// TestDialTLS_MSSQL runs a full certificate fetch against a fake SQL Server that
+// answers PRELOGIN and carries the TLS handshake inside TDS packets, and checks
+// that a server without encryption support is reported.
+func TestDialTLS_MSSQL(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	leaf, pair := keyedCert(t, key, time.Now().Add(30*24*time.Hour))
+
+	serve := func(encryption byte) string {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		t.Cleanup(func() { ln.Close() })
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			if _, _, err := readTDSMessage(conn); err != nil {
+				return
+			}
+			// PRELOGIN response: ENCRYPTION option only.
+			writeTDS(conn, tdsReply, 1, []byte{tdsOptEncrypt, 0x00, 0x06, 0x00, 0x01, tdsOptEnd, encryption})
+			srv := tls.Server(&tdsConn{Conn: conn}, &tls.Config{Certificates: []tls.Certificate{pair}})
+			srv.Handshake()
+		}()
+		return ln.Addr().String()
+	}
+
+	conn, err := dialTLS(serve(tdsEncryptOn), FetchOptions{Timeout: 5 * time.Second, StartTLS: "mssql"}, &tls.Config{InsecureSkipVerify: true, ServerName: "localhost"})
+	if err != nil {
+		t.Fatalf("dialTLS: %v", err)
+	}
+	defer conn.Close()
+	if certs := conn.ConnectionState().PeerCertificates; len(certs) == 0 || Fingerprint(certs[0]) != Fingerprint(leaf) {
+		t.Error("expected the fake server's certificate")
+	}
+
+	if _, err := dialTLS(serve(tdsEncryptNone), FetchOptions{Timeout: 5 * time.Second, StartTLS: "mssql"}, &tls.Config{InsecureSkipVerify: true}); err == nil || !strings.Contains(err.Error(), "does not support encryption") {
+		t.Errorf("expected a no-encryption error, got %v", err)
+	}
+}
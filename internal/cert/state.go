@@ -0,0 +1,157 @@
+package cert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Change kinds reported by -state, in the order they are listed.
+const (
+	ChangeRenewed    = "renewed"        // A different certificate that expires later
+	ChangeDowngrade  = "downgrade"      // A different certificate that expires no later than the recorded one
+	ChangeKeyRotated = "key_rotated"    // A different public key
+	ChangeIssuer     = "issuer_changed" // A different issuer DN
+	ChangeChain      = "chain_changed"  // Different intermediates
+)
+
+// ChangeKinds lists every change kind, in the order they are reported.
+var ChangeKinds = []string{ChangeRenewed, ChangeDowngrade, ChangeKeyRotated, ChangeIssuer, ChangeChain}
+
+// stateVersion is the format version written to a -state file.
+const stateVersion = 1
+
+// CertState is what -state records of a target's certificate between runs.
+type CertState struct {
+	Fingerprint string    `json:"fingerprint"`      // SHA-256 of the leaf
+	SPKI        string    `json:"spki_fingerprint"` // SHA-256 of the leaf's public key
+	Issuer      string    `json:"issuer"`
+	Serial      string    `json:"serial"`
+	NotAfter    time.Time `json:"not_after"`
+	Chain       []string  `json:"chain,omitempty"` // SHA-256 of each intermediate, in served order
+	SeenAt      time.Time `json:"seen_at"`         // When the certificate was last served
+}
+
+// StateChange is the comparison of a target's certificate with its -state
+// record.
+type StateChange struct {
+	Previous *CertState // The record of the previous run; nil the first time the target is seen
+	Changes  []string   // Change kinds, in ChangeKinds order; empty when nothing changed
+}
+
+// stateFile is the on-disk form of a -state file, keyed by target.
+type stateFile struct {
+	Version int                  `json:"version"`
+	Targets map[string]CertState `json:"targets"`
+}
+
+// NewCertState records info's leaf and intermediates as seen at seen.
+func NewCertState(info *CertInfo, seen time.Time) CertState {
+	c := info.Cert
+	s := CertState{
+		Fingerprint: Fingerprint(c),
+		SPKI:        SPKIFingerprint(c),
+		Issuer:      c.Issuer.String(),
+		Serial:      formatSerial(c.SerialNumber),
+		NotAfter:    c.NotAfter.UTC(),
+		SeenAt:      seen.UTC(),
+	}
+	if len(info.Chain) > 1 {
+		for _, ic := range info.Chain[1:] {
+			s.Chain = append(s.Chain, Fingerprint(ic))
+		}
+	}
+	return s
+}
+
+// CompareState compares cur with the previous record, nil when there is none.
+// A new leaf is a renewal when it expires later than the recorded one and a
+// downgrade otherwise; the key, issuer and intermediates are compared on their
+// own, so a renewal that keeps its key reports only "renewed".
+func CompareState(prev *CertState, cur CertState) *StateChange {
+	out := &StateChange{Previous: prev}
+	if prev == nil {
+		return out
+	}
+	if cur.Fingerprint != prev.Fingerprint {
+		if cur.NotAfter.After(prev.NotAfter) {
+			out.Changes = append(out.Changes, ChangeRenewed)
+		} else {
+			out.Changes = append(out.Changes, ChangeDowngrade)
+		}
+	}
+	if cur.SPKI != prev.SPKI {
+		out.Changes = append(out.Changes, ChangeKeyRotated)
+	}
+	if cur.Issuer != prev.Issuer {
+		out.Changes = append(out.Changes, ChangeIssuer)
+	}
+	if !slices.Equal(cur.Chain, prev.Chain) {
+		out.Changes = append(out.Changes, ChangeChain)
+	}
+	return out
+}
+
+// Changed reports whether the certificate differs from the previous record.
+func (s *StateChange) Changed() bool {
+	return s != nil && len(s.Changes) > 0
+}
+
+// LoadState reads a -state file. A file that does not exist yet is an empty
+// state, so the first run creates it.
+func LoadState(path string) (map[string]CertState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]CertState{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %v", err)
+	}
+	var f stateFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %v", path, err)
+	}
+	if f.Version != stateVersion {
+		return nil, fmt.Errorf("state file %s has version %d, expected %d", path, f.Version, stateVersion)
+	}
+	if f.Targets == nil {
+		f.Targets = map[string]CertState{}
+	}
+	return f.Targets, nil
+}
+
+// SaveState writes states to path, via a temporary file renamed into place so
+// an interrupted run never leaves a partial file behind.
+func SaveState(path string, states map[string]CertState) error {
+	data, err := json.MarshalIndent(stateFile{Version: stateVersion, Targets: states}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %v", err)
+	}
+	if err := replaceFile(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write state file %s: %v", path, err)
+	}
+	return nil
+}
+
+// replaceFile writes data to a temporary file next to path and renames it over
+// path, so readers see either the old content or the new, never a mix.
+func replaceFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	_, werr := tmp.Write(data)
+	cerr := tmp.Close()
+	if werr == nil && cerr == nil {
+		werr = os.Rename(tmp.Name(), path)
+	}
+	if werr != nil || cerr != nil {
+		os.Remove(tmp.Name())
+		return errors.Join(werr, cerr)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package cert
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareState(t *testing.T) {
+	expiry := time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC)
+	prev := CertState{Fingerprint: "aa", SPKI: "k1", Issuer: "CN=R10", NotAfter: expiry, Chain: []string{"i1"}}
+
+	if s := CompareState(nil, prev); s.Previous != nil || s.Changed() {
+		t.Errorf("first seen: got %+v", s)
+	}
+	for _, tt := range []struct {
+		name string
+		cur  func(c *CertState)
+		want []string
+	}{
+		{"unchanged", func(c *CertState) {}, nil},
+		{"renewed, same key", func(c *CertState) { c.Fingerprint, c.NotAfter = "bb", expiry.AddDate(0, 3, 0) }, []string{ChangeRenewed}},
+		{"renewed, new key and issuer", func(c *CertState) {
+			c.Fingerprint, c.NotAfter, c.SPKI, c.Issuer, c.Chain = "bb", expiry.AddDate(0, 3, 0), "k2", "CN=R11", []string{"i2"}
+		}, []string{ChangeRenewed, ChangeKeyRotated, ChangeIssuer, ChangeChain}},
+		{"downgrade", func(c *CertState) { c.Fingerprint, c.NotAfter = "00", expiry.AddDate(0, -3, 0) }, []string{ChangeDowngrade}},
+		{"chain only", func(c *CertState) { c.Chain = nil }, []string{ChangeChain}},
+	} {
+		cur := prev
+		cur.Chain = slices.Clone(prev.Chain)
+		tt.cur(&cur)
+		s := CompareState(&prev, cur)
+		if !slices.Equal(s.Changes, tt.want) || s.Changed() != (len(tt.want) > 0) {
+			t.Errorf("%s: changes = %v, want %v", tt.name, s.Changes, tt.want)
+		}
+	}
+}
+
+func TestLoadSaveState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	states, err := LoadState(path)
+	if err != nil || len(states) != 0 {
+		t.Fatalf("missing file: got %v, %v", states, err)
+	}
+
+	info := &CertInfo{Cert: genCert(t, "state.example", time.Now().Add(90*24*time.Hour))}
+	seen := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	states["state.example:443"] = NewCertState(info, seen)
+	if err := SaveState(path, states); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	rec := got["state.example:443"]
+	if rec.Fingerprint != Fingerprint(info.Cert) || rec.SPKI != SPKIFingerprint(info.Cert) || !rec.SeenAt.Equal(seen) {
+		t.Errorf("roundtrip: got %+v", rec)
+	}
+	if s := CompareState(&rec, NewCertState(info, time.Now())); s.Changed() {
+		t.Errorf("same certificate reported as changed: %v", s.Changes)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version": 9, "targets": {}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadState(path); err == nil {
+		t.Error("expected an error for an unknown state file version")
+	}
+}
+
+// TestState_Render covers the state line in text and the state object in JSON.
+func TestState_Render(t *testing.T) {
+	info := &CertInfo{Cert: genCert(t, "state.example", time.Now().Add(90*24*time.Hour))}
+	p := &CertificatePrinterImpl{}
+
+	info.State = &StateChange{}
+	if out := captureStdout(t, func() { p.Print(info, PrintOptions{}) }); !strings.Contains(out, "State: first seen") {
+		t.Errorf("text: expected first seen, got %q", out)
+	}
+
+	prev := NewCertState(info, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC))
+	prev.Fingerprint = strings.Repeat("ab", 32)
+	prev.SPKI = "old"
+	info.State = &StateChange{Previous: &prev, Changes: []string{ChangeRenewed, ChangeKeyRotated}}
+	out := captureStdout(t, func() { p.Print(info, PrintOptions{}) })
+	if !strings.Contains(out, "State: CHANGED — renewed, key rotated (was abababababababab") {
+		t.Errorf("text: expected the changes, got %q", out)
+	}
+
+	var got struct {
+		State struct {
+			FirstSeen bool      `json:"first_seen"`
+			Changes   []string  `json:"changes"`
+			Previous  CertState `json:"previous"`
+		} `json:"state"`
+	}
+	b, _ := json.Marshal(Payload(info, "", false, false))
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.State.FirstSeen || !slices.Equal(got.State.Changes, []string{"renewed", "key_rotated"}) || got.State.Previous.SPKI != "old" {
+		t.Errorf("json: got %+v", got.State)
+	}
+}
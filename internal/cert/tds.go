@@ -0,0 +1,164 @@
+package cert
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// TDS (MS-TDS) packet types and the prelogin options used to negotiate TLS
+// with Microsoft SQL Server.
+const (
+	tdsPrelogin    = 0x12 // PRELOGIN request; also frames the TLS handshake
+	tdsReply       = 0x04 // tabular result, the server's PRELOGIN response
+	tdsHeaderLen   = 8
+	tdsMaxPacket   = 4096 // the packet size both sides assume before login
+	tdsStatusEOM   = 0x01 // last packet of a message
+	tdsOptVersion  = 0x00
+	tdsOptEncrypt  = 0x01
+	tdsOptEnd      = 0xff
+	tdsEncryptOn   = 0x01
+	tdsEncryptNone = 0x02 // ENCRYPT_NOT_SUP
+)
+
+// tdsPreloginRequest is a PRELOGIN payload carrying a zero VERSION and
+// ENCRYPTION=ON: an option table (token, offset, length) ended by 0xff, then
+// the option data.
+var tdsPreloginRequest = []byte{
+	tdsOptVersion, 0x00, 0x0b, 0x00, 0x06,
+	tdsOptEncrypt, 0x00, 0x11, 0x00, 0x01,
+	tdsOptEnd,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // VERSION
+	tdsEncryptOn,
+}
+
+// negotiateTDS sends a PRELOGIN asking for encryption and checks that the
+// server supports it. SQL Server then expects the TLS handshake inside PRELOGIN
+// packets, so the connection is returned wrapped in a tdsConn.
+func negotiateTDS(conn net.Conn) (net.Conn, error) {
+	if _, err := writeTDS(conn, tdsPrelogin, 0, tdsPreloginRequest); err != nil {
+		return nil, err
+	}
+	typ, payload, err := readTDSMessage(conn)
+	if err != nil {
+		return nil, err
+	}
+	if typ != tdsReply {
+		return nil, fmt.Errorf("unexpected TDS packet type 0x%02x in reply to PRELOGIN", typ)
+	}
+	enc, err := tdsEncryption(payload)
+	if err != nil {
+		return nil, err
+	}
+	if enc == tdsEncryptNone {
+		return nil, errors.New("server does not support encryption")
+	}
+	return &tdsConn{Conn: conn}, nil
+}
+
+// tdsEncryption returns the ENCRYPTION option of a PRELOGIN response.
+func tdsEncryption(payload []byte) (byte, error) {
+	for i := 0; i+5 <= len(payload) && payload[i] != tdsOptEnd; i += 5 {
+		if payload[i] != tdsOptEncrypt {
+			continue
+		}
+		off := int(binary.BigEndian.Uint16(payload[i+1:]))
+		if binary.BigEndian.Uint16(payload[i+3:]) < 1 || off >= len(payload) {
+			break
+		}
+		return payload[off], nil
+	}
+	return 0, errors.New("PRELOGIN response has no ENCRYPTION option")
+}
+
+// writeTDS sends data as one TDS message of type typ, split into packets of at
+// most tdsMaxPacket bytes, numbered from id; it returns the next packet id.
+func writeTDS(w io.Writer, typ byte, id byte, data []byte) (byte, error) {
+	for {
+		n := min(len(data), tdsMaxPacket-tdsHeaderLen)
+		pkt := make([]byte, tdsHeaderLen+n)
+		pkt[0] = typ
+		if n == len(data) {
+			pkt[1] = tdsStatusEOM
+		}
+		binary.BigEndian.PutUint16(pkt[2:], uint16(len(pkt)))
+		pkt[6] = id
+		copy(pkt[tdsHeaderLen:], data[:n])
+		if _, err := w.Write(pkt); err != nil {
+			return id, err
+		}
+		data, id = data[n:], id+1
+		if len(data) == 0 {
+			return id, nil
+		}
+	}
+}
+
+// readTDSPacket reads one TDS packet and returns its type, status and payload.
+func readTDSPacket(r io.Reader) (typ, status byte, payload []byte, err error) {
+	head := make([]byte, tdsHeaderLen)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return 0, 0, nil, err
+	}
+	length := int(binary.BigEndian.Uint16(head[2:]))
+	if length < tdsHeaderLen {
+		return 0, 0, nil, fmt.Errorf("invalid TDS packet length %d", length)
+	}
+	payload = make([]byte, length-tdsHeaderLen)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, 0, nil, err
+	}
+	return head[0], head[1], payload, nil
+}
+
+// readTDSMessage reads TDS packets up to the end of a message and returns the
+// message type and its reassembled payload.
+func readTDSMessage(r io.Reader) (byte, []byte, error) {
+	var msg []byte
+	for {
+		typ, status, payload, err := readTDSPacket(r)
+		if err != nil {
+			return 0, nil, err
+		}
+		msg = append(msg, payload...)
+		if len(msg) > maxStartTLSRead {
+			return 0, nil, errors.New("TDS message too large")
+		}
+		if status&tdsStatusEOM != 0 {
+			return typ, msg, nil
+		}
+	}
+}
+
+// tdsConn carries the TLS handshake with SQL Server inside TDS PRELOGIN packets:
+// each Write becomes one TDS message and Read returns the payloads of the
+// packets received. Only the handshake is framed this way — SQL Server expects
+// raw TLS records afterwards — which is all a certificate check needs.
+type tdsConn struct {
+	net.Conn
+	buf []byte // payload received but not yet read
+	id  byte   // next packet id
+}
+
+func (c *tdsConn) Write(b []byte) (int, error) {
+	var err error
+	if c.id, err = writeTDS(c.Conn, tdsPrelogin, c.id, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *tdsConn) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		_, _, payload, err := readTDSPacket(c.Conn)
+		if err != nil {
+			return 0, err
+		}
+		c.buf = payload
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,162 @@
+package cert
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Threshold is an expiry alert level (-threshold, -critical-threshold): either
+// the time left before NotAfter, given in days or as a duration, or the share
+// of the certificate's lifetime (NotBefore to NotAfter) still left. The zero
+// value is disabled.
+type Threshold struct {
+	Remaining time.Duration // Alert when less than this is left (0 with Percent)
+	Percent   float64       // Alert when less than this share, 0-100, of the lifetime is left
+}
+
+// ThresholdDays returns the threshold for a whole number of days, the unit the
+// flags have always taken.
+func ThresholdDays(days int) Threshold {
+	return Threshold{Remaining: time.Duration(days) * 24 * time.Hour}
+}
+
+// ParseThreshold parses a threshold: a number of days ("21", "21d"), a
+// duration ("36h", "90m") or a percentage of the lifetime ("33%"). An empty
+// value or zero disables it.
+func ParseThreshold(s string) (Threshold, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Threshold{}, nil
+	}
+	if p, ok := strings.CutSuffix(s, "%"); ok {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 || v > 100 {
+			return Threshold{}, fmt.Errorf("%q is not a percentage between 0 and 100", s)
+		}
+		return Threshold{Percent: v}, nil
+	}
+	if n, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil {
+		if n < 0 {
+			return Threshold{}, fmt.Errorf("%q is negative", s)
+		}
+		return ThresholdDays(n), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return Threshold{}, fmt.Errorf("%q is not a number of days, a duration (36h) or a percentage (33%%)", s)
+	}
+	if d < 0 {
+		return Threshold{}, fmt.Errorf("%q is negative", s)
+	}
+	return Threshold{Remaining: d}, nil
+}
+
+// UnmarshalJSON accepts a number of days or a string in the ParseThreshold
+// syntax, so config files written for the day-only thresholds keep working.
+func (t *Threshold) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+	}
+	v, err := ParseThreshold(s)
+	if err != nil {
+		return fmt.Errorf("invalid threshold: %v", err)
+	}
+	*t = v
+	return nil
+}
+
+// IsZero reports whether the threshold is disabled.
+func (t Threshold) IsZero() bool {
+	return t.Remaining <= 0 && t.Percent <= 0
+}
+
+// String renders the threshold the way ParseThreshold reads it: whole days as
+// a bare number, other durations in the largest exact unit, and percentages
+// with a "%". A disabled threshold renders empty.
+func (t Threshold) String() string {
+	switch {
+	case t.IsZero():
+		return ""
+	case t.Percent > 0:
+		return strconv.FormatFloat(t.Percent, 'f', -1, 64) + "%"
+	case t.Remaining%(24*time.Hour) == 0:
+		return strconv.Itoa(int(t.Remaining / (24 * time.Hour)))
+	case t.Remaining%time.Hour == 0:
+		return strconv.Itoa(int(t.Remaining/time.Hour)) + "h"
+	case t.Remaining%time.Minute == 0:
+		return strconv.Itoa(int(t.Remaining/time.Minute)) + "m"
+	}
+	return t.Remaining.String()
+}
+
+// Window returns how long before c's expiry the threshold is reached: the
+// duration itself, or the percentage of c's lifetime. For a chain, pass the
+// leaf: its window applies to every certificate (see Expiring).
+func (t Threshold) Window(c *x509.Certificate) time.Duration {
+	if t.Percent > 0 {
+		return time.Duration(t.Percent / 100 * float64(c.NotAfter.Sub(c.NotBefore)))
+	}
+	return t.Remaining
+}
+
+// Reached reports whether c has less time left at now than the threshold
+// allows. A disabled threshold is never reached.
+func (t Threshold) Reached(c *x509.Certificate, now time.Time) bool {
+	return !t.IsZero() && c.NotAfter.Sub(now) < t.Window(c)
+}
+
+// Exceeds reports whether t is the larger of two thresholds of the same kind;
+// a duration and a percentage do not compare, and neither does a disabled one.
+func (t Threshold) Exceeds(o Threshold) bool {
+	switch {
+	case t.Percent > 0 && o.Percent > 0:
+		return t.Percent > o.Percent
+	case t.Remaining > 0 && o.Remaining > 0:
+		return t.Remaining > o.Remaining
+	}
+	return false
+}
+
+// thresholdDays renders t's window for c in days, to two decimals, for the
+// Prometheus and Nagios outputs whose values are days. Empty when disabled.
+func thresholdDays(t Threshold, c *x509.Certificate) string {
+	if t.IsZero() {
+		return ""
+	}
+	days := math.Round(t.Window(c).Hours()/24*100) / 100
+	return strconv.FormatFloat(days, 'f', -1, 64)
+}
+
+// Expiring reports whether any certificate MinDaysUntilExpiry looks at — the
+// leaf, the chain and the chains found by -key-types — has reached t. A
+// percentage is a share of the leaf's lifetime for the whole chain: an
+// intermediate that lives for years must not trip "33%" years early, while one
+// expiring within that window of a short-lived leaf still does.
+func (info *CertInfo) Expiring(t Threshold) bool {
+	if t.IsZero() {
+		return false
+	}
+	window, now := t.Window(info.Cert), info.Now()
+	if info.Cert.NotAfter.Sub(now) < window {
+		return true
+	}
+	for _, c := range info.Chain {
+		if c.NotAfter.Sub(now) < window {
+			return true
+		}
+	}
+	for _, k := range info.KeyTypes {
+		if k.Info != nil && k.Info.Expiring(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package cert
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseThreshold(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want Threshold
+		str  string
+	}{
+		{"", Threshold{}, ""},
+		{"0", Threshold{}, ""},
+		{"21", ThresholdDays(21), "21"},
+		{"21d", ThresholdDays(21), "21"},
+		{"36h", Threshold{Remaining: 36 * time.Hour}, "36h"},
+		{"48h", ThresholdDays(2), "2"},
+		{"90m", Threshold{Remaining: 90 * time.Minute}, "90m"},
+		{"33%", Threshold{Percent: 33}, "33%"},
+		{"12.5%", Threshold{Percent: 12.5}, "12.5%"},
+	} {
+		got, err := ParseThreshold(tt.in)
+		if err != nil {
+			t.Errorf("ParseThreshold(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want || got.String() != tt.str {
+			t.Errorf("ParseThreshold(%q) = %+v (%q), want %+v (%q)", tt.in, got, got, tt.want, tt.str)
+		}
+	}
+	for _, in := range []string{"-1", "-36h", "abc", "120%", "%", "1w"} {
+		if _, err := ParseThreshold(in); err == nil {
+			t.Errorf("ParseThreshold(%q): expected an error", in)
+		}
+	}
+}
+
+func TestThreshold_UnmarshalJSON(t *testing.T) {
+	var v struct {
+		Days, Hours, Share Threshold
+	}
+	if err := json.Unmarshal([]byte(`{"Days": 30, "Hours": "36h", "Share": "33%"}`), &v); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if v.Days != ThresholdDays(30) || v.Hours.Remaining != 36*time.Hour || v.Share.Percent != 33 {
+		t.Errorf("got %+v", v)
+	}
+	if err := json.Unmarshal([]byte(`{"Days": -1}`), &v); err == nil {
+		t.Error("expected an error for a negative threshold")
+	}
+}
+
+// TestThreshold_Reached covers a short-lived certificate against hour and
+// percentage thresholds, which a whole-days check cannot tell apart.
+func TestThreshold_Reached(t *testing.T) {
+	now := testNow
+	// A 24h certificate issued 18h ago: 6h, a quarter of its lifetime, left.
+	c := &x509.Certificate{NotBefore: now.Add(-18 * time.Hour), NotAfter: now.Add(6 * time.Hour)}
+	for _, tt := range []struct {
+		in   string
+		want bool
+	}{
+		{"8h", true},
+		{"4h", false},
+		{"1", true},
+		{"30%", true},
+		{"20%", false},
+		{"0", false},
+	} {
+		th, _ := ParseThreshold(tt.in)
+		if got := th.Reached(c, now); got != tt.want {
+			t.Errorf("%s: Reached = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	if got := thresholdDays(Threshold{Percent: 50}, c); got != "0.5" {
+		t.Errorf("thresholdDays(50%%) = %q, want 0.5", got)
+	}
+
+	// The chain is judged too, against the same window.
+	leaf := &x509.Certificate{NotBefore: now.Add(-time.Hour), NotAfter: now.Add(89 * 24 * time.Hour)}
+	info := &CertInfo{Cert: leaf, Chain: []*x509.Certificate{leaf, c}, At: now}
+	if !info.Expiring(Threshold{Remaining: 8 * time.Hour}) || (&CertInfo{Cert: leaf, At: now}).Expiring(Threshold{Remaining: 8 * time.Hour}) {
+		t.Error("expected only the chain with the short-lived certificate to be expiring")
+	}
+}
+
+// TestThreshold_PercentChain checks that a percentage is taken of the leaf's
+// lifetime for the whole chain: a 90-day leaf with most of its life left is not
+// expiring because its five-year intermediate has under a third of its own left.
+func TestThreshold_PercentChain(t *testing.T) {
+	now := testNow
+	leaf := &x509.Certificate{NotBefore: now.Add(-10 * 24 * time.Hour), NotAfter: now.Add(80 * 24 * time.Hour)}
+	inter := &x509.Certificate{NotBefore: now.Add(-4 * 365 * 24 * time.Hour), NotAfter: now.Add(150 * 24 * time.Hour)}
+	info := &CertInfo{Cert: leaf, Chain: []*x509.Certificate{leaf, inter}, At: now}
+	if info.Expiring(Threshold{Percent: 33}) {
+		t.Error("33%: a long-lived intermediate must not make a fresh leaf expiring")
+	}
+
+	// An intermediate that expires within the leaf's 33% window still counts.
+	inter.NotAfter = now.Add(20 * 24 * time.Hour)
+	if !info.Expiring(Threshold{Percent: 33}) {
+		t.Error("33%: expected an intermediate expiring within the leaf's window to count")
+	}
+}
+
+// TestThreshold_Outputs carries an hour threshold and the exact seconds left
+// through the Nagios, Prometheus and JSON outputs.
+func TestThreshold_Outputs(t *testing.T) {
+	info := &CertInfo{Cert: genCert(t, "short.example", testNow.Add(20*time.Hour)), At: testNow}
+	opts := PrintOptions{Threshold: Threshold{Remaining: 48 * time.Hour}, CriticalThreshold: Threshold{Remaining: 36 * time.Hour}}
+
+	var buf strings.Builder
+	if code := WriteNagios(&buf, []PromSample{{Domain: "short.example", Info: info}}, opts, false); code != nagiosCritical {
+		t.Errorf("nagios: expected CRITICAL, got %d: %q", code, buf.String())
+	}
+	if !strings.Contains(buf.String(), "'short.example'=0;2;1.5;") {
+		t.Errorf("nagios: expected the thresholds in days in the perfdata, got %q", buf.String())
+	}
+
+	buf.Reset()
+	WritePrometheus(&buf, []PromSample{{Domain: "short.example", Info: info}}, opts)
+	for _, want := range []string{
+		`ssl_cert_expiry_seconds{domain="short.example"} 72000`,
+		`ssl_cert_expiry_threshold_days{domain="short.example",level="critical"} 1.5`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("prometheus: missing %q", want)
+		}
+	}
+
+	var got struct {
+		SecondsRemaining int64 `json:"seconds_remaining"`
+	}
+	b, _ := json.Marshal(Payload(info, "", false, false))
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.SecondsRemaining != 72000 {
+		t.Errorf("seconds_remaining = %d, want 72000", got.SecondsRemaining)
+	}
+}
@@ -0,0 +1,56 @@
+package cert
+
+import (
+	"crypto/tls"
+	"strings"
+)
+
+// scannedVersions are the protocol versions -scan-versions probes, oldest first.
+var scannedVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13}
+
+// VersionSupport is whether the server completed a handshake pinned to one
+// protocol version (-scan-versions).
+type VersionSupport struct {
+	Version   string // "TLS 1.0" … "TLS 1.3"
+	Supported bool
+}
+
+// scanVersions runs one handshake per protocol version against address, pinning
+// MinVersion and MaxVersion on a copy of base, and reports which ones the
+// server accepted. A handshake that fails for any reason counts as unsupported.
+func scanVersions(address string, opts FetchOptions, base *tls.Config) []VersionSupport {
+	out := make([]VersionSupport, 0, len(scannedVersions))
+	for _, v := range scannedVersions {
+		cfg := base.Clone()
+		cfg.MinVersion, cfg.MaxVersion = v, v
+		conn, err := dialTLS(address, opts, cfg)
+		if err == nil {
+			conn.Close()
+		}
+		out = append(out, VersionSupport{Version: tls.VersionName(v), Supported: err == nil})
+	}
+	return out
+}
+
+// deprecatedVersions lists the deprecated protocol versions (TLS 1.0 and 1.1,
+// RFC 8996) the server still accepts; empty when not scanned.
+func deprecatedVersions(info *CertInfo) []string {
+	var out []string
+	for _, v := range info.Versions {
+		if v.Supported && (v.Version == "TLS 1.0" || v.Version == "TLS 1.1") {
+			out = append(out, v.Version)
+		}
+	}
+	return out
+}
+
+// supportedVersions joins the accepted protocol versions with sep.
+func supportedVersions(info *CertInfo, sep string) string {
+	var out []string
+	for _, v := range info.Versions {
+		if v.Supported {
+			out = append(out, v.Version)
+		}
+	}
+	return strings.Join(out, sep)
+}
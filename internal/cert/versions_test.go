@@ -0,0 +1,56 @@
+package cert
+
+import (
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFetch_ScanVersions runs -scan-versions against local servers with
+// different protocol ranges and verifies which versions are reported and that
+// an enabled TLS 1.0/1.1 is flagged as a warning.
+func TestFetch_ScanVersions(t *testing.T) {
+	scan := func(min, max uint16) *CertInfo {
+		t.Helper()
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		srv.TLS = &tls.Config{MinVersion: min, MaxVersion: max}
+		srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+		srv.StartTLS()
+		defer srv.Close()
+		host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+		info, err := (&CertificateFetcherImpl{}).Fetch(host, port, "", FetchOptions{Insecure: true, Timeout: 5 * time.Second, ScanVersions: true})
+		if err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		return info
+	}
+
+	modern := scan(tls.VersionTLS12, tls.VersionTLS13)
+	if got := supportedVersions(modern, ","); got != "TLS 1.2,TLS 1.3" {
+		t.Errorf("TLS 1.2-1.3 server: got %q", got)
+	}
+	if len(modern.Versions) != 4 || len(deprecatedVersions(modern)) != 0 || HasWarnings(modern) {
+		t.Errorf("TLS 1.2-1.3 server: unexpected scan %+v", modern.Versions)
+	}
+
+	legacy := scan(tls.VersionTLS10, tls.VersionTLS12)
+	if got := supportedVersions(legacy, ","); got != "TLS 1.0,TLS 1.1,TLS 1.2" {
+		t.Errorf("TLS 1.0-1.2 server: got %q", got)
+	}
+	if old := deprecatedVersions(legacy); len(old) != 2 || !HasWarnings(legacy) {
+		t.Errorf("TLS 1.0-1.2 server: expected TLS 1.0 and 1.1 flagged, got %v", old)
+	}
+
+	out := captureStdout(t, func() { (&CertificatePrinterImpl{}).Print(legacy, PrintOptions{}) })
+	for _, want := range []string{"TLS versions: TLS 1.0 yes, TLS 1.1 yes, TLS 1.2 yes, TLS 1.3 no", "WARNING: deprecated protocol version(s) still enabled: TLS 1.0, TLS 1.1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 )
 
 // Project metadata shared by the help header and the version output.
@@ -16,36 +17,75 @@ const (
 	GitURL = "https://github.com/idesyatov/ssl-watch"
 )
 
+// CommandServe is the subcommand that keeps running as a Prometheus exporter.
+const CommandServe = "serve"
+
+// CommandKnownCerts is the subcommand that manages the -known-certs file; its
+// action (list, accept or revoke) follows it.
+const CommandKnownCerts = "known-certs"
+
 // Config holds the parsed command-line options.
 type Config struct {
-	Domain       string // Domain(s) to check, comma-separated for several
-	DomainFile   string // Path to a file with one domain per line ("-" reads stdin)
-	CertFile     string // Path to the local certificate file
-	Port         string // Port to connect to
-	IPAddr       string // IP address to connect to (optional)
-	ServerName   string // SNI / hostname to verify against (overrides the domain)
-	CAFile       string // PEM bundle of trust anchors to verify against (replaces system roots)
-	ClientCert   string // Client certificate (PEM) for mutual TLS
-	ClientKey    string // Private key (PEM) for the client certificate
-	Short        bool   // Output only the number of days remaining until expiration
-	Insecure     bool   // Skip certificate chain verification
-	Threshold    int    // Expiry warning threshold in days (0 = disabled); drives exit code 2
-	ExpectIssuer string // Assert the issuer contains this substring; exit 3 on mismatch
-	Strict       bool   // Treat warnings as failures (exit 2)
-	Output       string // Output format: text, json, prometheus, csv or nagios
-	Chain        bool   // Print every certificate in the chain
-	Fingerprint  bool   // Print the certificate and public-key SHA-256 fingerprints
-	Pin          string // Verify against a pinned fingerprint (sha256:<hex>); exit 3 on mismatch
-	Pem          bool   // Print the certificate chain as PEM to stdout
-	Export       string // Write the certificate chain as PEM to the given file
-	AllIPs       bool   // Check the certificate on every resolved IP of the domain
-	IPv4Only     bool   // Restrict -all-ips to IPv4 addresses
-	IPv6Only     bool   // Restrict -all-ips to IPv6 addresses
-	Timeout      int    // Connection timeout in seconds for fetching a remote certificate
-	Concurrency  int    // Number of targets to check in parallel in a batch (1 = sequential)
-	StartTLS     string // STARTTLS protocol to upgrade the connection: smtp/imap/pop3/ftp (empty = direct TLS)
-	Proxy        string // HTTP CONNECT proxy URL (http://[user:pass@]host:port); empty = direct
-	ShowVersion  bool   // Show version and exit
+	Command           string // Subcommand given before the flags ("serve", "known-certs"); empty = a one-shot check
+	Action            string // The known-certs action given after it: list, accept or revoke
+	Domain            string // Domain(s) to check, comma-separated for several
+	DomainFile        string // Path to a file with one domain per line ("-" reads stdin)
+	ConfigFile        string // Path to a JSON file of targets with per-target settings
+	CertFile          string // Path to the local certificate file
+	Port              string // Port to connect to
+	IPAddr            string // IP address to connect to (optional)
+	ServerName        string // SNI / hostname to verify against (overrides the domain)
+	CAFile            string // PEM bundle of trust anchors to verify against (replaces system roots)
+	ClientCert        string // Client certificate (PEM) for mutual TLS
+	ClientKey         string // Private key (PEM) for the client certificate
+	Short             bool   // Output only the number of days remaining until expiration
+	Insecure          bool   // Skip certificate chain verification
+	AIAFetch          bool   // Fetch missing intermediates via AIA caIssuers to repair an incomplete chain
+	Threshold         string // Expiry warning threshold: days, a duration (36h) or a share of the lifetime (33%); empty or 0 = disabled; drives exit code 2
+	CriticalThreshold string // Expiry critical threshold, in the same forms and at most Threshold; drives exit code 5
+	At                string // Evaluate expiry, validity and the chain at this instant (RFC 3339 or a date) instead of now
+	ExpectIssuer      string // Assert the issuer contains this substring; exit 3 on mismatch
+	Strict            bool   // Treat warnings as failures (exit 2)
+	Output            string // Output format: text, json, prometheus, csv or nagios
+	Chain             bool   // Print every certificate in the chain
+	Fingerprint       bool   // Print the certificate and public-key SHA-256 fingerprints
+	OCSP              bool   // Check the leaf's revocation status via OCSP; exit 4 if revoked
+	CRL               bool   // Check the chain against the CRLs at its distribution points; exit 4 if revoked
+	CRLFile           string // CRL file(s), comma-separated, to check the chain against
+	CRLCache          string // Directory caching downloaded CRLs (empty = the user cache directory)
+	ScanVersions      bool   // Probe which TLS versions (1.0-1.3) the server accepts; deprecated ones warn
+	ScanCiphers       bool   // Probe which cipher suites (TLS 1.0-1.2) the server accepts and grade them; weak ones warn
+	KeyTypes          bool   // Fetch the RSA and the ECDSA certificate separately; the soonest expiry drives -threshold
+	DANE              bool   // Match the served chain against the service's TLSA records; exit 3 when none match
+	CAA               bool   // Check the domain's CAA records authorize the issuer; exit 3 when they do not
+	CAAMap            string // JSON file mapping issuer substrings to CAA identifiers, checked before the built-in table
+	DNSInfo           bool   // Report the domain's CNAME chain, final addresses and TTLs
+	ExpectCNAME       string // Assert the CNAME chain ends under this suffix; exit 3 when it does not
+	DNSResolver       string // DNS resolver (host[:port]) for the -dane, -caa and CNAME lookups (empty = the first -resolver, else the system's)
+	State             string // Path to a JSON file recording each target's certificate between runs, to report changes
+	StateExit         string // Exit codes for the -state change kinds (kind=code,...), over the defaults
+	KnownCerts        string // Path to the trust-on-first-use file of pins per host:port; a mismatch exits 3
+	BackupPins        string // Backup pins (sha256:<hex>,...) that known-certs accept records with the served key
+	Pin               string // Verify against a pinned fingerprint (sha256:<hex>); exit 3 on mismatch
+	Pem               bool   // Print the certificate chain as PEM to stdout
+	Export            string // Write the certificate chain as PEM to the given file
+	AllIPs            bool   // Check the certificate on every resolved IP of each domain
+	IPv4Only          bool   // Restrict -all-ips to IPv4 addresses
+	IPv6Only          bool   // Restrict -all-ips to IPv6 addresses
+	Timeout           int    // Connection timeout in seconds for fetching a remote certificate
+	Concurrency       int    // Number of targets to check in parallel in a batch (1 = sequential)
+	StartTLS          string // STARTTLS protocol to upgrade the connection: smtp/imap/pop3/ftp (empty = direct TLS)
+	Proxy             string // Proxy URL (http, https, socks5 or socks5h://[user:pass@]host:port); empty = direct
+	ProxyCAFile       string // PEM bundle verifying an https:// proxy's certificate (empty = system roots)
+	ProxyInsecure     bool   // Skip verification of an https:// proxy's certificate
+	ProxyFromEnv      bool   // Pick the proxy per target from HTTPS_PROXY/NO_PROXY, as net/http does
+	ProxyProtocol     string // PROXY protocol header to send before the handshake: v1 or v2 (empty = none)
+	ProxyProtocolSrc  string // Client address (ip:port) claimed in the PROXY header (empty = the local address)
+	ProxyProtocolDst  string // Server address (ip:port) claimed in the PROXY header (empty = the remote address)
+	Resolver          string // DNS resolver(s), comma-separated: host[:port] or tls://host[:port] (empty = the system resolver)
+	Listen            string // Address the serve mode listens on for /metrics and /healthz
+	Interval          int    // Seconds between check cycles in serve mode
+	ShowVersion       bool   // Show version and exit
 }
 
 // FlagParser defines an interface for parsing command-line flags.
@@ -65,70 +105,143 @@ type FlagParser interface {
 // It owns its own flag set to avoid relying on global state, which makes it
 // safe to construct and parse repeatedly (e.g. in tests).
 type DefaultFlagParser struct {
-	fs           *flag.FlagSet
-	domain       *string
-	domainFile   *string
-	certFile     *string
-	port         *string
-	ipaddr       *string
-	serverName   *string
-	caFile       *string
-	clientCert   *string
-	clientKey    *string
-	short        *bool
-	insecure     *bool
-	threshold    *int
-	output       *string
-	chain        *bool
-	fingerprint  *bool
-	pin          *string
-	expectIssuer *string
-	strict       *bool
-	pem          *bool
-	export       *string
-	allIPs       *bool
-	ipv4Only     *bool
-	ipv6Only     *bool
-	timeout      *int
-	concurrency  *int
-	starttls     *string
-	proxy        *string
-	showVersion  *bool
+	fs                *flag.FlagSet
+	domain            *string
+	domainFile        *string
+	configFile        *string
+	certFile          *string
+	port              *string
+	ipaddr            *string
+	serverName        *string
+	caFile            *string
+	clientCert        *string
+	clientKey         *string
+	short             *bool
+	insecure          *bool
+	aiaFetch          *bool
+	threshold         *string
+	criticalThreshold *string
+	at                *string
+	output            *string
+	chain             *bool
+	fingerprint       *bool
+	pin               *string
+	ocsp              *bool
+	crl               *bool
+	crlFile           *string
+	crlCache          *string
+	scanVersions      *bool
+	scanCiphers       *bool
+	keyTypes          *bool
+	dane              *bool
+	caa               *bool
+	caaMap            *string
+	dnsInfo           *bool
+	expectCNAME       *string
+	dnsResolver       *string
+	state             *string
+	stateExit         *string
+	knownCerts        *string
+	backupPins        *string
+	expectIssuer      *string
+	strict            *bool
+	pem               *bool
+	export            *string
+	allIPs            *bool
+	ipv4Only          *bool
+	ipv6Only          *bool
+	timeout           *int
+	concurrency       *int
+	starttls          *string
+	proxy             *string
+	proxyCAFile       *string
+	proxyInsecure     *bool
+	proxyFromEnv      *bool
+	proxyProtocol     *string
+	proxyProtocolSrc  *string
+	proxyProtocolDst  *string
+	resolver          *string
+	listen            *string
+	interval          *int
+	showVersion       *bool
 }
 
 // Parse processes the command-line flags and returns the parsed configuration.
+// A leading "serve" argument selects the long-running exporter mode, and
+// "known-certs" followed by its action the -known-certs management; the flags
+// follow them as usual.
 func (d *DefaultFlagParser) Parse() Config {
+	args := os.Args[1:]
+	command, action := "", ""
+	if len(args) > 0 && (args[0] == CommandServe || args[0] == CommandKnownCerts) {
+		command, args = args[0], args[1:]
+	}
+	if command == CommandKnownCerts && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
 	// flag.ExitOnError makes Parse exit on error rather than return one.
-	_ = d.fs.Parse(os.Args[1:])
+	_ = d.fs.Parse(args)
 	return Config{
-		Domain:       *d.domain,
-		DomainFile:   *d.domainFile,
-		CertFile:     *d.certFile,
-		Port:         *d.port,
-		IPAddr:       *d.ipaddr,
-		ServerName:   *d.serverName,
-		CAFile:       *d.caFile,
-		ClientCert:   *d.clientCert,
-		ClientKey:    *d.clientKey,
-		Short:        *d.short,
-		Insecure:     *d.insecure,
-		Threshold:    *d.threshold,
-		Output:       *d.output,
-		Chain:        *d.chain,
-		ExpectIssuer: *d.expectIssuer,
-		Strict:       *d.strict,
-		Fingerprint:  *d.fingerprint,
-		Pin:          *d.pin,
-		Pem:          *d.pem,
-		Export:       *d.export,
-		AllIPs:       *d.allIPs,
-		IPv4Only:     *d.ipv4Only,
-		IPv6Only:     *d.ipv6Only,
-		Timeout:      *d.timeout,
-		Concurrency:  *d.concurrency,
-		StartTLS:     *d.starttls,
-		Proxy:        *d.proxy,
-		ShowVersion:  *d.showVersion,
+		Command:           command,
+		Action:            action,
+		Domain:            *d.domain,
+		DomainFile:        *d.domainFile,
+		ConfigFile:        *d.configFile,
+		CertFile:          *d.certFile,
+		Port:              *d.port,
+		IPAddr:            *d.ipaddr,
+		ServerName:        *d.serverName,
+		CAFile:            *d.caFile,
+		ClientCert:        *d.clientCert,
+		ClientKey:         *d.clientKey,
+		Short:             *d.short,
+		Insecure:          *d.insecure,
+		AIAFetch:          *d.aiaFetch,
+		Threshold:         *d.threshold,
+		CriticalThreshold: *d.criticalThreshold,
+		At:                *d.at,
+		Output:            *d.output,
+		Chain:             *d.chain,
+		ExpectIssuer:      *d.expectIssuer,
+		Strict:            *d.strict,
+		Fingerprint:       *d.fingerprint,
+		Pin:               *d.pin,
+		OCSP:              *d.ocsp,
+		CRL:               *d.crl,
+		CRLFile:           *d.crlFile,
+		CRLCache:          *d.crlCache,
+		ScanVersions:      *d.scanVersions,
+		ScanCiphers:       *d.scanCiphers,
+		KeyTypes:          *d.keyTypes,
+		DANE:              *d.dane,
+		CAA:               *d.caa,
+		CAAMap:            *d.caaMap,
+		DNSInfo:           *d.dnsInfo,
+		ExpectCNAME:       *d.expectCNAME,
+		DNSResolver:       *d.dnsResolver,
+		State:             *d.state,
+		StateExit:         *d.stateExit,
+		KnownCerts:        *d.knownCerts,
+		BackupPins:        *d.backupPins,
+		Pem:               *d.pem,
+		Export:            *d.export,
+		AllIPs:            *d.allIPs,
+		IPv4Only:          *d.ipv4Only,
+		IPv6Only:          *d.ipv6Only,
+		Timeout:           *d.timeout,
+		Concurrency:       *d.concurrency,
+		StartTLS:          *d.starttls,
+		Proxy:             *d.proxy,
+		ProxyCAFile:       *d.proxyCAFile,
+		ProxyInsecure:     *d.proxyInsecure,
+		ProxyFromEnv:      *d.proxyFromEnv,
+		ProxyProtocol:     *d.proxyProtocol,
+		ProxyProtocolSrc:  *d.proxyProtocolSrc,
+		ProxyProtocolDst:  *d.proxyProtocolDst,
+		Resolver:          *d.resolver,
+		Listen:            *d.listen,
+		Interval:          *d.interval,
+		ShowVersion:       *d.showVersion,
 	}
 }
 
@@ -147,35 +260,65 @@ func (d *DefaultFlagParser) Usage() {
 func NewDefaultFlagParser() FlagParser {
 	fs := flag.NewFlagSet(appName, flag.ExitOnError)
 	p := &DefaultFlagParser{
-		fs:           fs,
-		domain:       fs.String("domain", "", "Domain(s) to check, comma-separated for several; each may carry a port (host:port) or be a URL (e.g. a.com,b.com:8443)"),
-		domainFile:   fs.String("domain-file", "", "Path to a file with one domain per line (\"-\" reads stdin)"),
-		certFile:     fs.String("certfile", "", "Path to the local certificate file (- for stdin)"),
-		port:         fs.String("port", "443", "Default port for targets that don't carry their own (host:port overrides)"),
-		ipaddr:       fs.String("ipaddr", "", "IP address to connect to (optional)"),
-		serverName:   fs.String("servername", "", "SNI/hostname to verify against, overriding the domain (e.g. with -ipaddr)"),
-		caFile:       fs.String("cafile", "", "PEM bundle of trusted roots to verify against, replacing the system roots"),
-		clientCert:   fs.String("client-cert", "", "Client certificate (PEM) for mutual TLS (requires -client-key)"),
-		clientKey:    fs.String("client-key", "", "Private key (PEM) for the client certificate (requires -client-cert)"),
-		short:        fs.Bool("short", false, "Output only the number of days remaining until certificate expiration"),
-		insecure:     fs.Bool("insecure", false, "Skip certificate chain verification"),
-		threshold:    fs.Int("threshold", 0, "Warn (exit code 2) when days remaining is below this value (0 disables)"),
-		output:       fs.String("output", "text", "Output format: text, json, prometheus, csv or nagios"),
-		chain:        fs.Bool("chain", false, "Print every certificate in the chain"),
-		fingerprint:  fs.Bool("fingerprint", false, "Print the certificate and public-key SHA-256 fingerprints"),
-		pin:          fs.String("pin", "", "Verify against a pinned fingerprint (sha256:<hex>, cert or public key); exit 3 on mismatch"),
-		expectIssuer: fs.String("expect-issuer", "", "Assert the certificate issuer contains this substring (case-insensitive); exit 3 on mismatch"),
-		strict:       fs.Bool("strict", false, "Treat warnings (not-yet-valid, name mismatch, untrusted chain, …) as failures; exit 2"),
-		pem:          fs.Bool("pem", false, "Print the certificate chain as PEM to stdout"),
-		export:       fs.String("export", "", "Write the certificate chain as PEM to the given file"),
-		allIPs:       fs.Bool("all-ips", false, "Check the certificate on every resolved IP of the domain (single domain only)"),
-		ipv4Only:     fs.Bool("4", false, "With -all-ips, check IPv4 addresses only"),
-		ipv6Only:     fs.Bool("6", false, "With -all-ips, check IPv6 addresses only"),
-		timeout:      fs.Int("timeout", 10, "Connection timeout in seconds when fetching a remote certificate"),
-		concurrency:  fs.Int("concurrency", 1, "Number of targets to check in parallel when several are given (1 = sequential)"),
-		starttls:     fs.String("starttls", "", "Upgrade the connection via STARTTLS: smtp, imap, pop3 or ftp (default: direct TLS)"),
-		proxy:        fs.String("proxy", "", "Route the connection through an HTTP CONNECT proxy (http://[user:pass@]host:port)"),
-		showVersion:  fs.Bool("version", false, "Show version"),
+		fs:                fs,
+		domain:            fs.String("domain", "", "Domain(s) to check, comma-separated for several; each may carry a port (host:port) or be a URL (e.g. a.com,b.com:8443)"),
+		domainFile:        fs.String("domain-file", "", "Path to a file with one domain per line (\"-\" reads stdin)"),
+		configFile:        fs.String("config", "", "Path to a JSON file of targets, each with its own settings (port, starttls, pins, threshold, …)"),
+		certFile:          fs.String("certfile", "", "Path to the local certificate file (- for stdin)"),
+		port:              fs.String("port", "443", "Default port for targets that don't carry their own (host:port overrides)"),
+		ipaddr:            fs.String("ipaddr", "", "IP address to connect to (optional)"),
+		serverName:        fs.String("servername", "", "SNI/hostname to verify against, overriding the domain (e.g. with -ipaddr)"),
+		caFile:            fs.String("cafile", "", "PEM bundle of trusted roots to verify against, replacing the system roots"),
+		clientCert:        fs.String("client-cert", "", "Client certificate (PEM) for mutual TLS (requires -client-key)"),
+		clientKey:         fs.String("client-key", "", "Private key (PEM) for the client certificate (requires -client-cert)"),
+		short:             fs.Bool("short", false, "Output only the number of days remaining until certificate expiration"),
+		insecure:          fs.Bool("insecure", false, "Skip certificate chain verification"),
+		aiaFetch:          fs.Bool("aia-fetch", false, "On an incomplete chain, fetch the missing intermediates via AIA caIssuers and retry verification"),
+		threshold:         fs.String("threshold", "", "Warn (exit code 2) when less than this is left: days, a duration (36h) or a share of the lifetime (33%); 0 disables"),
+		criticalThreshold: fs.String("critical-threshold", "", "Critical (exit code 5) when less than this is left, in the same forms as -threshold and at most it; 0 disables"),
+		at:                fs.String("at", "", "Evaluate expiry, validity and thresholds at this instant instead of now (2026-12-20 or RFC 3339)"),
+		output:            fs.String("output", "text", "Output format: text, json, prometheus, csv or nagios"),
+		chain:             fs.Bool("chain", false, "Print every certificate in the chain"),
+		fingerprint:       fs.Bool("fingerprint", false, "Print the certificate and public-key SHA-256 fingerprints"),
+		pin:               fs.String("pin", "", "Verify against a pinned fingerprint (sha256:<hex>, cert or public key); exit 3 on mismatch"),
+		ocsp:              fs.Bool("ocsp", false, "Check the leaf certificate's revocation status with its OCSP responder; exit 4 if revoked"),
+		crl:               fs.Bool("crl", false, "Check the chain against the CRLs named in its certificates (cached on disk); exit 4 if revoked"),
+		crlFile:           fs.String("crlfile", "", "CRL file(s), comma-separated, to check the chain against (DER or PEM)"),
+		crlCache:          fs.String("crl-cache", "", "Directory caching downloaded CRLs (default: ssl-watch/crl in the user cache directory)"),
+		scanVersions:      fs.Bool("scan-versions", false, "Probe which TLS versions (1.0-1.3) the server accepts, one handshake each; TLS 1.0/1.1 warn"),
+		scanCiphers:       fs.Bool("scan-ciphers", false, "Probe which cipher suites (TLS 1.0-1.2) the server accepts, one handshake each, and grade them A-F; weak suites warn"),
+		keyTypes:          fs.Bool("key-types", false, "Fetch the RSA and the ECDSA certificate separately (dual-certificate servers); the soonest expiry drives -threshold"),
+		dane:              fs.Bool("dane", false, "Match the served chain against the TLSA records at _port._tcp.<domain>; exit 3 when none match"),
+		caa:               fs.Bool("caa", false, "Check the domain's CAA records authorize the certificate's issuer; exit 3 when they do not"),
+		caaMap:            fs.String("caa-map", "", "JSON file mapping issuer substrings to CAA identifiers, e.g. {\"Example CA\": [\"ca.example.net\"]}, for CAs the built-in table lacks"),
+		dnsInfo:           fs.Bool("dns-info", false, "Report the domain's CNAME chain, final A/AAAA records and their TTLs"),
+		expectCNAME:       fs.String("expect-cname", "", "Assert the domain's CNAME chain ends under this suffix, e.g. cdn.example.net (implies -dns-info); exit 3 on mismatch"),
+		dnsResolver:       fs.String("dns-resolver", "", "DNS resolver (host[:port]) for -dane, -caa and -dns-info, overriding -resolver; should validate DNSSEC (default: the first -resolver, else the first nameserver in /etc/resolv.conf)"),
+		state:             fs.String("state", "", "JSON file recording each target's certificate; report renewals, key, issuer and chain changes since the last run"),
+		knownCerts:        fs.String("known-certs", "", "Trust-on-first-use file: record each host:port's public key on first contact, then require it; exit 3 on mismatch"),
+		backupPins:        fs.String("backup-pin", "", "Backup pins (sha256:<hex>, comma-separated) that known-certs accept records with the served key"),
+		stateExit:         fs.String("state-exit", "", "Exit codes for -state changes, e.g. renewed=0,key_rotated=2 (default: issuer_changed=2,downgrade=3, others 0)"),
+		expectIssuer:      fs.String("expect-issuer", "", "Assert the certificate issuer contains this substring (case-insensitive); exit 3 on mismatch"),
+		strict:            fs.Bool("strict", false, "Treat warnings (not-yet-valid, name mismatch, untrusted chain, …) as failures; exit 2"),
+		pem:               fs.Bool("pem", false, "Print the certificate chain as PEM to stdout"),
+		export:            fs.String("export", "", "Write the certificate chain as PEM to the given file"),
+		allIPs:            fs.Bool("all-ips", false, "Check the certificate on every resolved IP of each domain"),
+		ipv4Only:          fs.Bool("4", false, "With -all-ips, check IPv4 addresses only"),
+		ipv6Only:          fs.Bool("6", false, "With -all-ips, check IPv6 addresses only"),
+		timeout:           fs.Int("timeout", 10, "Connection timeout in seconds when fetching a remote certificate"),
+		concurrency:       fs.Int("concurrency", 1, "Number of targets to check in parallel when several are given (1 = sequential)"),
+		starttls:          fs.String("starttls", "", "Upgrade the connection via STARTTLS: smtp, lmtp, imap, pop3, ftp, nntp, sieve, irc, xmpp, xmpp-server, ldap, postgres, mysql or mssql (default: direct TLS)"),
+		proxy:             fs.String("proxy", "", "Route the connection through a proxy: HTTP CONNECT (http:// or https://) or SOCKS5 (socks5://, socks5h:// for proxy-side DNS), with optional user:pass@"),
+		proxyCAFile:       fs.String("proxy-cafile", "", "PEM bundle to verify an https:// -proxy against instead of the system roots"),
+		proxyInsecure:     fs.Bool("proxy-insecure", false, "Skip verification of an https:// -proxy's certificate"),
+		proxyFromEnv:      fs.Bool("proxy-from-env", false, "Pick the proxy for each target from HTTPS_PROXY/NO_PROXY (as net/http does) instead of -proxy"),
+		proxyProtocol:     fs.String("proxy-protocol", "", "Send a PROXY protocol header (v1 or v2) before the handshake, for backends behind a load balancer"),
+		proxyProtocolSrc:  fs.String("proxy-protocol-src", "", "Client address (ip:port) claimed in the PROXY header (default: the connection's local address)"),
+		proxyProtocolDst:  fs.String("proxy-protocol-dst", "", "Server address (ip:port) claimed in the PROXY header (default: the connection's remote address)"),
+		resolver:          fs.String("resolver", "", "DNS resolver(s) for the targets and the -all-ips addresses, comma-separated: host[:port], or tls://host[:port] for DNS over TLS (default: the system resolver)"),
+		listen:            fs.String("listen", ":9219", "Address to serve /metrics and /healthz on (serve mode)"),
+		interval:          fs.Int("interval", 300, "Seconds between check cycles (serve mode)"),
+		showVersion:       fs.Bool("version", false, "Show version"),
 	}
 
 	// Custom usage: description, examples, the project link and flags grouped by
@@ -206,6 +349,9 @@ func NewDefaultFlagParser() FlagParser {
 		fmt.Fprintf(out, "  %s -domain example.com -chain\n", appName)
 		fmt.Fprintf(out, "  %s -domain example.com -all-ips\n", appName)
 		fmt.Fprintf(out, "  %s -domain example.com -pin sha256:<hex>\n", appName)
+		fmt.Fprintf(out, "  %s -config targets.json -concurrency 10\n", appName)
+		fmt.Fprintf(out, "  %s serve -domain-file domains.txt -interval 300\n", appName)
+		fmt.Fprintf(out, "  %s known-certs accept -known-certs known_certs -domain example.com\n", appName)
 		fmt.Fprintf(out, "  %s -certfile /path/to/cert.crt\n", appName)
 		fmt.Fprintf(out, "  cat cert.pem | %s -certfile -\n\n", appName)
 		fmt.Fprintf(out, "GitHub: %s\n\n", GitURL)
@@ -213,6 +359,7 @@ func NewDefaultFlagParser() FlagParser {
 		fmt.Fprintf(out, "Target:\n")
 		flagLine("domain")
 		flagLine("domain-file")
+		flagLine("config")
 		flagLine("certfile")
 		fmt.Fprintf(out, "\nConnection:\n")
 		flagLine("port")
@@ -220,12 +367,20 @@ func NewDefaultFlagParser() FlagParser {
 		flagLine("servername")
 		flagLine("starttls")
 		flagLine("proxy")
+		flagLine("proxy-cafile")
+		flagLine("proxy-insecure")
+		flagLine("proxy-from-env")
+		flagLine("proxy-protocol")
+		flagLine("proxy-protocol-src")
+		flagLine("proxy-protocol-dst")
+		flagLine("resolver")
 		flagLine("timeout")
 		flagLine("concurrency")
 		flagLine("cafile")
 		flagLine("client-cert")
 		flagLine("client-key")
 		flagLine("insecure")
+		flagLine("aia-fetch")
 		fmt.Fprintf(out, "\nOutput:\n")
 		flagLine("output")
 		flagLine("short")
@@ -238,9 +393,32 @@ func NewDefaultFlagParser() FlagParser {
 		flagLine("6")
 		fmt.Fprintf(out, "\nMonitoring:\n")
 		flagLine("threshold")
+		flagLine("critical-threshold")
+		flagLine("at")
 		flagLine("pin")
 		flagLine("expect-issuer")
 		flagLine("strict")
+		flagLine("ocsp")
+		flagLine("crl")
+		flagLine("crlfile")
+		flagLine("crl-cache")
+		flagLine("scan-versions")
+		flagLine("scan-ciphers")
+		flagLine("key-types")
+		flagLine("dane")
+		flagLine("caa")
+		flagLine("caa-map")
+		flagLine("dns-info")
+		flagLine("expect-cname")
+		flagLine("dns-resolver")
+		flagLine("state")
+		flagLine("state-exit")
+		flagLine("known-certs")
+		fmt.Fprintf(out, "\nServe mode (%s serve ...):\n", appName)
+		flagLine("listen")
+		flagLine("interval")
+		fmt.Fprintf(out, "\nKnown certificates (%s known-certs list|accept|revoke ...):\n", appName)
+		flagLine("backup-pin")
 		fmt.Fprintf(out, "\nMisc:\n")
 		flagLine("version")
 	}
@@ -15,6 +15,7 @@ func TestParse(t *testing.T) {
 	os.Args = []string{"cmd",
 		"-domain", "example.com",
 		"-domain-file", "domains.txt",
+		"-config", "targets.json",
 		"-certfile", "cert.pem",
 		"-port", "443",
 		"-ipaddr", "192.168.1.1",
@@ -24,11 +25,30 @@ func TestParse(t *testing.T) {
 		"-client-key", "client.key",
 		"-short",
 		"-insecure",
+		"-aia-fetch",
 		"-threshold", "30",
+		"-critical-threshold", "7",
+		"-at", "2026-12-20",
 		"-output", "json",
 		"-chain",
 		"-expect-issuer", "Let's Encrypt",
 		"-strict",
+		"-ocsp",
+		"-crl",
+		"-crlfile", "a.crl,b.crl",
+		"-crl-cache", "/tmp/crl",
+		"-scan-versions",
+		"-scan-ciphers",
+		"-key-types",
+		"-dane",
+		"-caa",
+		"-caa-map", "caa.json",
+		"-dns-info",
+		"-expect-cname", "cdn.example.net",
+		"-dns-resolver", "9.9.9.9",
+		"-state", "state.json",
+		"-state-exit", "renewed=2",
+		"-known-certs", "known_certs",
 		"-fingerprint",
 		"-pin", "sha256:e4134cbc32c0c0976599c684ae0b6ac849b2d75546d934dfdb611fa0d9a0e9cb",
 		"-pem",
@@ -39,6 +59,15 @@ func TestParse(t *testing.T) {
 		"-concurrency", "8",
 		"-starttls", "smtp",
 		"-proxy", "http://127.0.0.1:3128",
+		"-proxy-cafile", "proxy-ca.pem",
+		"-proxy-insecure",
+		"-proxy-from-env",
+		"-proxy-protocol", "v2",
+		"-proxy-protocol-src", "203.0.113.7:50000",
+		"-proxy-protocol-dst", "10.0.0.5:443",
+		"-resolver", "10.0.0.53,tls://1.1.1.1",
+		"-listen", "127.0.0.1:9000",
+		"-interval", "60",
 		"-version"}
 
 	// Create a new instance of the DefaultFlagParser
@@ -74,8 +103,11 @@ func TestParse(t *testing.T) {
 	if !cfg.Insecure {
 		t.Error("expected insecure to be true")
 	}
-	if cfg.Threshold != 30 {
-		t.Errorf("expected threshold to be 30, got %d", cfg.Threshold)
+	if cfg.Threshold != "30" || cfg.CriticalThreshold != "7" {
+		t.Errorf("expected thresholds 30 and 7, got %q and %q", cfg.Threshold, cfg.CriticalThreshold)
+	}
+	if cfg.At != "2026-12-20" {
+		t.Errorf("expected at to be '2026-12-20', got '%s'", cfg.At)
 	}
 	if cfg.Output != "json" {
 		t.Errorf("expected output to be 'json', got '%s'", cfg.Output)
@@ -89,18 +121,63 @@ func TestParse(t *testing.T) {
 	if cfg.DomainFile != "domains.txt" {
 		t.Errorf("expected domainFile to be 'domains.txt', got '%s'", cfg.DomainFile)
 	}
+	if cfg.ConfigFile != "targets.json" {
+		t.Errorf("expected configFile to be 'targets.json', got '%s'", cfg.ConfigFile)
+	}
 	if cfg.StartTLS != "smtp" {
 		t.Errorf("expected starttls to be 'smtp', got '%s'", cfg.StartTLS)
 	}
 	if cfg.Proxy != "http://127.0.0.1:3128" {
 		t.Errorf("expected proxy to be parsed, got '%s'", cfg.Proxy)
 	}
+	if cfg.ProxyCAFile != "proxy-ca.pem" || !cfg.ProxyInsecure || !cfg.ProxyFromEnv {
+		t.Errorf("expected proxy settings, got %q %v %v", cfg.ProxyCAFile, cfg.ProxyInsecure, cfg.ProxyFromEnv)
+	}
+	if cfg.ProxyProtocol != "v2" || cfg.ProxyProtocolSrc != "203.0.113.7:50000" || cfg.ProxyProtocolDst != "10.0.0.5:443" {
+		t.Errorf("expected PROXY protocol settings, got %q %q %q", cfg.ProxyProtocol, cfg.ProxyProtocolSrc, cfg.ProxyProtocolDst)
+	}
+	if cfg.Resolver != "10.0.0.53,tls://1.1.1.1" {
+		t.Errorf("expected resolvers, got %q", cfg.Resolver)
+	}
 	if !cfg.Chain {
 		t.Error("expected chain to be true")
 	}
 	if cfg.ExpectIssuer != "Let's Encrypt" {
 		t.Errorf("expected expect-issuer to be parsed, got '%s'", cfg.ExpectIssuer)
 	}
+	if !cfg.OCSP {
+		t.Error("expected ocsp to be true")
+	}
+	if !cfg.ScanVersions {
+		t.Error("expected scan-versions to be true")
+	}
+	if !cfg.ScanCiphers {
+		t.Error("expected scan-ciphers to be true")
+	}
+	if !cfg.KeyTypes {
+		t.Error("expected key-types to be true")
+	}
+	if cfg.KnownCerts != "known_certs" {
+		t.Errorf("expected known-certs to be 'known_certs', got '%s'", cfg.KnownCerts)
+	}
+	if cfg.State != "state.json" || cfg.StateExit != "renewed=2" {
+		t.Errorf("expected state state.json with renewed=2, got %q %q", cfg.State, cfg.StateExit)
+	}
+	if !cfg.DANE || cfg.DNSResolver != "9.9.9.9" {
+		t.Errorf("expected dane with resolver 9.9.9.9, got %v %q", cfg.DANE, cfg.DNSResolver)
+	}
+	if !cfg.CAA || cfg.CAAMap != "caa.json" {
+		t.Errorf("expected caa with map caa.json, got %v %q", cfg.CAA, cfg.CAAMap)
+	}
+	if !cfg.DNSInfo || cfg.ExpectCNAME != "cdn.example.net" {
+		t.Errorf("expected dns-info with expect-cname cdn.example.net, got %v %q", cfg.DNSInfo, cfg.ExpectCNAME)
+	}
+	if !cfg.AIAFetch {
+		t.Error("expected aia-fetch to be true")
+	}
+	if !cfg.CRL || cfg.CRLFile != "a.crl,b.crl" || cfg.CRLCache != "/tmp/crl" {
+		t.Errorf("expected crl flags to be parsed, got %v %q %q", cfg.CRL, cfg.CRLFile, cfg.CRLCache)
+	}
 	if !cfg.Strict {
 		t.Error("expected strict to be true")
 	}
@@ -122,11 +199,58 @@ func TestParse(t *testing.T) {
 	if !cfg.IPv4Only {
 		t.Error("expected ipv4-only (-4) to be true")
 	}
+	if cfg.Listen != "127.0.0.1:9000" {
+		t.Errorf("expected listen to be parsed, got '%s'", cfg.Listen)
+	}
+	if cfg.Interval != 60 {
+		t.Errorf("expected interval to be 60, got %d", cfg.Interval)
+	}
+	if cfg.Command != "" {
+		t.Errorf("expected no command, got '%s'", cfg.Command)
+	}
 	if !cfg.ShowVersion {
 		t.Error("expected showVersion to be true")
 	}
 }
 
+// TestParseServe verifies a leading "serve" argument selects the serve command
+// and that the flags after it are still parsed.
+func TestParseServe(t *testing.T) {
+	os.Args = []string{"cmd", "serve", "-domain", "a.com,b.com", "-interval", "30"}
+
+	cfg := NewDefaultFlagParser().Parse()
+
+	if cfg.Command != CommandServe {
+		t.Errorf("expected command %q, got %q", CommandServe, cfg.Command)
+	}
+	if cfg.Domain != "a.com,b.com" || cfg.Interval != 30 {
+		t.Errorf("expected flags after serve to be parsed, got domain=%q interval=%d", cfg.Domain, cfg.Interval)
+	}
+	if cfg.Listen != ":9219" {
+		t.Errorf("expected default listen ':9219', got %q", cfg.Listen)
+	}
+}
+
+// TestParseKnownCerts verifies "known-certs" takes the action after it, and
+// that a flag in its place is parsed as a flag instead.
+func TestParseKnownCerts(t *testing.T) {
+	os.Args = []string{"cmd", "known-certs", "accept", "-known-certs", "kc", "-domain", "a.com", "-backup-pin", "sha256:ab"}
+
+	cfg := NewDefaultFlagParser().Parse()
+
+	if cfg.Command != CommandKnownCerts || cfg.Action != "accept" {
+		t.Errorf("expected known-certs accept, got %q %q", cfg.Command, cfg.Action)
+	}
+	if cfg.KnownCerts != "kc" || cfg.Domain != "a.com" || cfg.BackupPins != "sha256:ab" {
+		t.Errorf("expected flags after the action to be parsed, got %q %q %q", cfg.KnownCerts, cfg.Domain, cfg.BackupPins)
+	}
+
+	os.Args = []string{"cmd", "known-certs", "-known-certs", "kc"}
+	if cfg := NewDefaultFlagParser().Parse(); cfg.Action != "" || cfg.KnownCerts != "kc" {
+		t.Errorf("expected no action, got %q (known-certs %q)", cfg.Action, cfg.KnownCerts)
+	}
+}
+
 // TestParseDefaults verifies the timeout falls back to its 10-second default
 // when the flag is not supplied.
 func TestParseDefaults(t *testing.T) {
@@ -172,7 +296,7 @@ func TestUsage(t *testing.T) {
 	parser.Usage()
 
 	out := buf.String()
-	for _, want := range []string{GitURL, "Usage:", "Target:", "Connection:", "Output:", "Monitoring:", "-domain", "-domain-file", "-threshold", "-timeout", "-concurrency", "-starttls", "-proxy", "-cafile", "-servername", "-client-cert", "-client-key", "-chain", "-fingerprint", "-pin", "-expect-issuer", "-strict", "-pem", "-export", "-all-ips", "-4", "-6", "prometheus", "csv", "nagios"} {
+	for _, want := range []string{GitURL, "Usage:", "Target:", "Connection:", "Output:", "Monitoring:", "-domain", "-domain-file", "-config", "-threshold", "-critical-threshold", "-at", "-timeout", "-concurrency", "-starttls", "-proxy", "-proxy-cafile", "-proxy-insecure", "-proxy-from-env", "-proxy-protocol", "-proxy-protocol-src", "-proxy-protocol-dst", "-resolver", "-cafile", "-servername", "-client-cert", "-client-key", "-aia-fetch", "-chain", "-fingerprint", "-pin", "-expect-issuer", "-strict", "-ocsp", "-crl", "-crlfile", "-crl-cache", "-scan-versions", "-scan-ciphers", "-key-types", "-dane", "-caa", "-caa-map", "-dns-info", "-expect-cname", "-dns-resolver", "-state", "-state-exit", "-known-certs", "-backup-pin", "known-certs", "-pem", "-export", "-all-ips", "-4", "-6", "-listen", "-interval", "serve", "prometheus", "csv", "nagios"} {
 		if !strings.Contains(out, want) {
 			t.Errorf("expected usage output to contain %q, got:\n%s", want, out)
 		}